@@ -0,0 +1,71 @@
+package fakeocient
+
+import (
+	"math"
+	"time"
+)
+
+// timestampFormat is the layout Ocient uses for TIMESTAMP values.
+const timestampFormat = "2006-01-02 15:04:05.999999999"
+
+// DemoDatasets returns a small catalog with one time series table, enough to
+// drive the schema browser, the query builder and a graph panel.
+func DemoDatasets() []Dataset {
+	return []Dataset{
+		{
+			Match:   "distinct(table_schema)",
+			Columns: []Column{{Name: "table_schema", Type: "VARCHAR"}},
+			Rows:    []map[string]interface{}{{"table_schema": "demo"}},
+		},
+		{
+			Match:   "distinct(table_name)",
+			Columns: []Column{{Name: "table_name", Type: "VARCHAR"}},
+			Rows:    []map[string]interface{}{{"table_name": "sensor_data"}},
+		},
+		{
+			Match: "information_schema.columns",
+			Columns: []Column{
+				{Name: "column_name", Type: "VARCHAR"},
+				{Name: "data_type", Type: "VARCHAR"},
+				{Name: "is_nullable", Type: "VARCHAR"},
+				{Name: "column_default", Type: "VARCHAR"},
+			},
+			Rows: []map[string]interface{}{
+				{"column_name": "host", "data_type": "VARCHAR", "is_nullable": "NO", "column_default": nil},
+				{"column_name": "timestamp", "data_type": "TIMESTAMP", "is_nullable": "NO", "column_default": nil},
+				{"column_name": "value", "data_type": "DOUBLE", "is_nullable": "YES", "column_default": nil},
+			},
+		},
+		{
+			Match: "sensor_data",
+			Columns: []Column{
+				{Name: "timestamp", Type: "TIMESTAMP"},
+				{Name: "host", Type: "VARCHAR"},
+				{Name: "value", Type: "DOUBLE"},
+			},
+			Rows: sensorRows(time.Now().UTC(), 120),
+		},
+		{
+			Match:   "select 1",
+			Columns: []Column{{Name: "1", Type: "INT"}},
+			Rows:    []map[string]interface{}{{"1": float64(1)}},
+		},
+	}
+}
+
+// sensorRows generates n minutely samples for two hosts ending at end.
+func sensorRows(end time.Time, n int) []map[string]interface{} {
+	end = end.Truncate(time.Minute)
+	rows := make([]map[string]interface{}, 0, 2*n)
+	for i := n - 1; i >= 0; i-- {
+		ts := end.Add(-time.Duration(i) * time.Minute).Format(timestampFormat)
+		for h, host := range []string{"sql-node-1", "sql-node-2"} {
+			rows = append(rows, map[string]interface{}{
+				"host":      host,
+				"timestamp": ts,
+				"value":     50 + 25*math.Sin(float64(i)/10+float64(h)),
+			})
+		}
+	}
+	return rows
+}
@@ -0,0 +1,403 @@
+// Package fakeocient provides an in-process imitation of the Ocient REST API. It is
+// used by the backend tests and, through the devFakeServer datasource setting, lets
+// contributors run the plugin against canned data without access to a cluster.
+package fakeocient
+
+import (
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status mirrors the status object returned by the Ocient API.
+type Status struct {
+	Reason     string `json:"reason"`
+	SQLState   string `json:"sql_state"`
+	VendorCode int    `json:"vendor_code"`
+}
+
+// StatusOK is the status reported for successful statements.
+var StatusOK = Status{Reason: "", SQLState: "00000"}
+
+// Column is a result column reported in the "table" response format.
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Dataset is a canned response for every statement that contains Match
+// (compared case-insensitively). An empty Match acts as a catch-all.
+type Dataset struct {
+	Match string
+	// Columns declares the result columns. When empty they are derived from the
+	// keys of Rows, ordered by name, with types guessed from the values.
+	Columns []Column
+	Rows    []map[string]interface{}
+	// Latency delays the response for statements served by this dataset.
+	Latency time.Duration
+	// Status, when set, is reported instead of StatusOK and no rows are returned.
+	Status *Status
+	// HTTPStatus, when set, fails the request with this HTTP status code.
+	HTTPStatus int
+}
+
+// Request is a statement received by the server.
+type Request struct {
+	Database  string `json:"database"`
+	Statement string `json:"statement"`
+	Format    string `json:"format"`
+	Username  string `json:"-"`
+	// Compressed is set for gzip compressed request bodies
+	Compressed bool   `json:"-"`
+	UserAgent  string `json:"-"`
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithDatasets registers datasets, matched in the order they are given.
+func WithDatasets(datasets ...Dataset) Option {
+	return func(s *Server) {
+		s.datasets = append(s.datasets, datasets...)
+	}
+}
+
+// WithLatency delays every response by d.
+func WithLatency(d time.Duration) Option {
+	return func(s *Server) {
+		s.latency = d
+	}
+}
+
+// WithCredentials makes the server require HTTP basic auth with the given
+// user. Given several times, any of the users is accepted.
+func WithCredentials(username, password string) Option {
+	return func(s *Server) {
+		if s.users == nil {
+			s.users = make(map[string]string)
+		}
+		s.users[username] = password
+	}
+}
+
+// WithClientCAs makes the server require a client certificate signed by one of
+// the CAs in pool, like a gateway terminating mutual TLS.
+func WithClientCAs(pool *x509.CertPool) Option {
+	return func(s *Server) {
+		s.clientCAs = pool
+	}
+}
+
+// WithPlainHTTP serves the API over plain HTTP, like a development cluster
+// without TLS.
+func WithPlainHTTP() Option {
+	return func(s *Server) {
+		s.plainHTTP = true
+	}
+}
+
+// WithGzip compresses responses to clients accepting gzip.
+func WithGzip() Option {
+	return func(s *Server) {
+		s.gzip = true
+	}
+}
+
+// WithSessions serves the session endpoint, which opens sessions of the user
+// of WithCredentials lasting ttl, and accepts their tokens as bearer tokens.
+func WithSessions(ttl time.Duration) Option {
+	return func(s *Server) {
+		s.sessionTTL = ttl
+		s.sessions = make(map[string]fakeSession)
+	}
+}
+
+// WithBearerTokens accepts the tokens, mapped to the user they identify, as
+// bearer tokens, like a cluster validating the OAuth tokens of its identity
+// provider.
+func WithBearerTokens(tokens map[string]string) Option {
+	return func(s *Server) {
+		s.tokens = tokens
+	}
+}
+
+// fakeSession is an open session of the fake server.
+type fakeSession struct {
+	username string
+	expires  time.Time
+}
+
+// Server is a running fake Ocient API.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	datasets  []Dataset
+	latency   time.Duration
+	users     map[string]string
+	clientCAs *x509.CertPool
+	plainHTTP bool
+	gzip      bool
+	requests  []Request
+	queryID   int
+	conns     int
+
+	sessionTTL time.Duration
+	sessions   map[string]fakeSession
+	logins     int
+	tokens     map[string]string
+}
+
+// NewServer starts a TLS fake Ocient API, unless WithPlainHTTP is given.
+// Clients must skip certificate verification or trust the certificate of the
+// embedded httptest server.
+func NewServer(opts ...Option) *Server {
+	s := &Server{}
+	for _, opt := range opts {
+		opt(s)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/execute", s.handleExecute)
+	if s.sessions != nil {
+		mux.HandleFunc("/v1/session", s.handleSession)
+	}
+	s.Server = httptest.NewUnstartedServer(mux)
+	s.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+		}
+	}
+	if s.clientCAs != nil {
+		s.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: s.clientCAs}
+	}
+	if s.plainHTTP {
+		s.Start()
+		return s
+	}
+	s.StartTLS()
+	return s
+}
+
+// Connections returns the number of client connections accepted so far.
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+// AddDataset registers an additional dataset on a running server.
+func (s *Server) AddDataset(ds Dataset) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.datasets = append(s.datasets, ds)
+}
+
+// Requests returns the statements received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Logins returns the number of sessions opened so far.
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+// EndSessions ends every open session, as a restart of Ocient would.
+func (s *Server) EndSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.sessions)
+}
+
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, pass, ok := r.BasicAuth()
+	if password, known := s.users[user]; !ok || !known || pass != password {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	s.logins++
+	token := fmt.Sprintf("session-%d", s.logins)
+	s.sessions[token] = fakeSession{username: user, expires: time.Now().Add(s.sessionTTL)}
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"token": token, "expires_in": int(s.sessionTTL.Seconds())})
+}
+
+// authenticate returns the user of a request, from its session token or its
+// basic auth, and whether it is accepted.
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	token, bearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if user, ok := s.tokens[token]; bearer && ok {
+		return user, true
+	}
+	if bearer && s.sessions != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		session, ok := s.sessions[token]
+		if !ok || time.Now().After(session.expires) {
+			return "", false
+		}
+		return session.username, true
+	}
+	user, pass, _ := r.BasicAuth()
+	if s.users == nil {
+		return user, true
+	}
+	password, known := s.users[user]
+	return user, known && pass == password
+}
+
+func (s *Server) handleExecute(w http.ResponseWriter, r *http.Request) {
+	// Like Ocient, reject unauthenticated requests before looking at them
+	username, ok := s.authenticate(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body := r.Body
+	compressed := r.Header.Get("Content-Encoding") == "gzip"
+	if compressed {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid gzip body: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer zr.Close()
+		body = zr
+	}
+	var req Request
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	req.Username = username
+	req.Compressed = compressed
+	req.UserAgent = r.UserAgent()
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.queryID++
+	queryID := fmt.Sprintf("fake-%d", s.queryID)
+	latency := s.latency
+	ds, found := s.match(req.Statement)
+	s.mu.Unlock()
+
+	if found {
+		latency += ds.Latency
+	}
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if found && ds.HTTPStatus != 0 {
+		http.Error(w, http.StatusText(ds.HTTPStatus), ds.HTTPStatus)
+		return
+	}
+
+	resp := struct {
+		QueryID string      `json:"query_id"`
+		Status  Status      `json:"status"`
+		Columns []Column    `json:"columns,omitempty"`
+		Data    interface{} `json:"data"`
+	}{QueryID: queryID, Status: StatusOK, Data: []interface{}{}}
+
+	switch {
+	case !found:
+		resp.Status = Status{Reason: "no dataset matches statement", SQLState: "42000", VendorCode: -1}
+	case ds.Status != nil:
+		resp.Status = *ds.Status
+	case req.Format == "table":
+		resp.Columns = ds.columns()
+		rows := make([][]interface{}, len(ds.Rows))
+		for i, row := range ds.Rows {
+			rows[i] = make([]interface{}, len(resp.Columns))
+			for j, col := range resp.Columns {
+				rows[i][j] = row[col.Name]
+			}
+		}
+		resp.Data = rows
+	default:
+		resp.Data = ds.Rows
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if s.gzip && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		defer zw.Close()
+		_ = json.NewEncoder(zw).Encode(resp)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// columns returns the declared columns or derives them from the rows.
+func (ds Dataset) columns() []Column {
+	if len(ds.Columns) > 0 {
+		return ds.Columns
+	}
+	types := make(map[string]string)
+	for _, row := range ds.Rows {
+		for name, v := range row {
+			if _, ok := types[name]; ok && v == nil {
+				continue
+			}
+			switch v.(type) {
+			case float64:
+				types[name] = "DOUBLE"
+			case bool:
+				types[name] = "BOOLEAN"
+			default:
+				types[name] = "VARCHAR"
+			}
+		}
+	}
+	columns := make([]Column, 0, len(types))
+	for name, typ := range types {
+		columns = append(columns, Column{Name: name, Type: typ})
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Name < columns[j].Name })
+	return columns
+}
+
+// match returns the first dataset whose Match is contained in the statement.
+// Callers must hold s.mu.
+func (s *Server) match(statement string) (Dataset, bool) {
+	statement = strings.ToLower(statement)
+	for _, ds := range s.datasets {
+		if strings.Contains(statement, strings.ToLower(ds.Match)) {
+			return ds, true
+		}
+	}
+	return Dataset{}, false
+}
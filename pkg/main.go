@@ -2,6 +2,8 @@ package main
 
 import (
 	"os"
+	// Embed the timezone database so session timezones resolve on hosts without one
+	_ "time/tzdata"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
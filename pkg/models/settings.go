@@ -1,47 +1,444 @@
 package models
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"slices"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 )
 
+// DefaultMaxColumns is the column limit applied when maxColumns is not set. Wider
+// frames than this are known to make the browser unresponsive.
+const DefaultMaxColumns = 1000
+
+// DefaultQueryTimeoutSeconds is the timeout of interactive queries when
+// queryTimeoutSeconds is not set, so that a hung Ocient node fails panels
+// instead of blocking them.
+const DefaultQueryTimeoutSeconds = 30
+
+// DefaultPublicMaxRows is the row limit for public dashboard queries when
+// publicDashboards.maxRows is not set.
+const DefaultPublicMaxRows = 10000
+
+// Defaults for the reporting limits when reporting is enabled without them.
+const (
+	DefaultReportingHeader         = "X-Grafana-Reporting"
+	DefaultReportingMaxRows        = 1000000
+	DefaultReportingTimeoutSeconds = 300
+)
+
+// Defaults for the connection pool of the REST transport. MaxConnsPerHost
+// defaults to 0, no limit.
+const (
+	DefaultMaxIdleConns           = 100
+	DefaultMaxIdleConnsPerHost    = 16
+	DefaultIdleConnTimeoutSeconds = 90
+)
+
+// Transports supported by the backend. The REST API is the default; the native
+// driver is used through database/sql when it is linked into the plugin binary.
+const (
+	TransportREST   = "rest"
+	TransportNative = "native"
+	// NativeDriverName is the database/sql driver name the Ocient native
+	// driver registers itself under. The driver is not linked into the
+	// default build; a build that wants the native transport adds a blank
+	// import for it.
+	NativeDriverName = "ocient"
+)
+
+// Authentication methods of the REST API. Basic sends the credentials with
+// every statement; session logs in to the session endpoint once and sends
+// the session token it returns instead, until the session expires.
+const (
+	AuthBasic   = "basic"
+	AuthSession = "session"
+)
+
+// Defaults for retrying statements that fail with a transient error.
+const (
+	DefaultRetryMaxAttempts      = 3
+	DefaultRetryInitialBackoffMs = 200
+	DefaultRetryMaxBackoffMs     = 2000
+)
+
+// Defaults for the circuit breaker that pauses queries to an unreachable
+// cluster.
+const (
+	DefaultCircuitFailureThreshold = 5
+	DefaultCircuitCooldownSeconds  = 30
+)
+
+// DefaultDiscoveryRefreshSeconds is how often discovered SQL nodes are
+// resolved again.
+const DefaultDiscoveryRefreshSeconds = 300
+
+// URL schemes of the Ocient API. HTTPS is the default; plain HTTP is meant for
+// development and CI clusters without TLS.
+const (
+	SchemeHTTPS = "https"
+	SchemeHTTP  = "http"
+)
+
+// Load balancing policies across the SQL nodes of Host and Hosts. Failover
+// sends every statement to the same node until it can't be reached; round
+// robin takes the nodes in turn and least outstanding picks the node with
+// the fewest statements in flight.
+const (
+	LoadBalancingFailover         = "failover"
+	LoadBalancingRoundRobin       = "round_robin"
+	LoadBalancingLeastOutstanding = "least_outstanding"
+)
+
+// Policies for datasources that skip TLS verification. Queries carry a warning
+// notice by default; the block policy refuses to run queries at all.
+const (
+	InsecurePolicyWarn  = "warn"
+	InsecurePolicyBlock = "block"
+)
+
+// PluginSettings are the settings of a datasource. Hosts lists other SQL nodes,
+// as host or host:port, that statements fail over to when Host can't be
+// reached or are spread across by LoadBalancing. IgnoreProxyEnv, the
+// ignoreProxyEnvironment setting, connects to Ocient directly even when the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables of the Grafana
+// server name a proxy.
 type PluginSettings struct {
-	Host              string                `json:"host"`
-	Port              int                   `json:"port"`
-	Database          string                `json:"database"`
-	InsecureSkipVerify bool                 `json:"insecureSkipVerify"`
+	Host                string                   `json:"host"`
+	Hosts               []string                 `json:"hosts"`
+	LoadBalancing       string                   `json:"loadBalancing"`
+	Discovery           *DiscoverySettings       `json:"discovery"`
+	Port                int                      `json:"port"`
+	Scheme              string                   `json:"scheme"`
+	Database            string                   `json:"database"`
+	DefaultSchema       string                   `json:"defaultSchema"`
+	InsecureSkipVerify  bool                     `json:"insecureSkipVerify"`
+	InsecurePolicy      string                   `json:"insecureSkipVerifyPolicy"`
+	TLSServerName       string                   `json:"tlsServerName"`
+	IgnoreProxyEnv      bool                     `json:"ignoreProxyEnvironment"`
+	Transport           string                   `json:"transport"`
+	AuthMethod          string                   `json:"authMethod"`
+	OAuthPassThru       bool                     `json:"oauthPassThru"`
+	DevFakeServer       bool                     `json:"devFakeServer"`
+	WarmUp              bool                     `json:"warmUp"`
+	MaxColumns          int                      `json:"maxColumns"`
+	MaxRows             int                      `json:"maxRows"`
+	QueryTimeout        int                      `json:"queryTimeoutSeconds"`
+	MaxIdleConns        int                      `json:"maxIdleConns"`
+	MaxIdleConnsPerHost int                      `json:"maxIdleConnsPerHost"`
+	MaxConnsPerHost     int                      `json:"maxConnsPerHost"`
+	IdleConnTimeout     int                      `json:"idleConnTimeoutSeconds"`
+	GzipRequestsOver    int                      `json:"compressRequestsOverBytes"`
+	Timezone            string                   `json:"timezone"`
+	TimestampFormats    []string                 `json:"timestampFormats"`
+	NullPolicy          string                   `json:"nullPolicy"`
+	NonFiniteNumbers    string                   `json:"nonFiniteNumbers"`
+	LogLevels           map[string]string        `json:"logLevels"`
+	MinIntervals        map[string]string        `json:"minIntervals"`
+	TimeColumns         map[string]string        `json:"timeColumns"`
+	HealthCheckObjects  []string                 `json:"healthCheckObjects"`
+	Retry               *RetrySettings           `json:"retry"`
+	CircuitBreaker      *CircuitBreakerSettings  `json:"circuitBreaker"`
+	Chaos               *ChaosSettings           `json:"chaos"`
+	PublicDashboards    *PublicDashboardSettings `json:"publicDashboards"`
+	Reporting           *ReportingSettings       `json:"reporting"`
+	Environments        []EnvironmentSettings    `json:"environments"`
+	CredentialMappings  []CredentialMapping      `json:"credentialMappings"`
+	// AuditComments tags statements with the Grafana user, organization and
+	// dashboard they run for. It is off by default, as it changes the SQL sent
+	// and puts identities into the logs of the cluster.
+	AuditComments bool `json:"auditComments"`
+	// ConfiguredSecrets names the secure fields the configuration page saved,
+	// so that secrets Grafana fails to decrypt can be told from unset ones.
+	ConfiguredSecrets []string              `json:"configuredSecrets"`
 	Secrets           *SecretPluginSettings `json:"-"`
+	// UndecryptedSecrets are the configured secrets missing from the
+	// decrypted secure fields, as when the Grafana secret key changed.
+	UndecryptedSecrets []string `json:"-"`
+}
+
+// ChaosSettings configures fault injection into the query transport so operators
+// can see how dashboards and alerts behave while Ocient is degraded. It is
+// deliberately not exposed in the config editor and must be set in jsonData.
+type ChaosSettings struct {
+	// FailureRate is the fraction (0-1) of statements that fail outright.
+	FailureRate float64 `json:"failureRate"`
+	// MalformedRate is the fraction (0-1) of successful results that are corrupted.
+	MalformedRate float64 `json:"malformedRate"`
+	// LatencyMs is added to every statement, plus up to LatencyJitterMs at random.
+	LatencyMs       int `json:"latencyMs"`
+	LatencyJitterMs int `json:"latencyJitterMs"`
+	// Seed makes the injected faults reproducible when non-zero.
+	Seed int64 `json:"seed"`
+}
+
+// RetrySettings configures retries of read-only statements that fail with a
+// transient error, such as a connection reset or a 502, 503 or 504 from a
+// gateway while a SQL node restarts. The delay between attempts starts at
+// InitialBackoffMs and doubles up to MaxBackoffMs. Failures with one of
+// VendorCodes are retried too. MaxAttempts of 1 disables retries.
+type RetrySettings struct {
+	MaxAttempts      int   `json:"maxAttempts"`
+	InitialBackoffMs int   `json:"initialBackoffMs"`
+	MaxBackoffMs     int   `json:"maxBackoffMs"`
+	VendorCodes      []int `json:"vendorCodes"`
+}
+
+// CircuitBreakerSettings configures how queries are paused while Ocient is
+// unreachable. After FailureThreshold statements in a row fail with a
+// transient error, statements fail at once for CooldownSeconds; then a single
+// statement is let through to find out whether Ocient is back. A
+// FailureThreshold below 0 disables the circuit breaker.
+type CircuitBreakerSettings struct {
+	FailureThreshold int `json:"failureThreshold"`
+	CooldownSeconds  int `json:"cooldownSeconds"`
+}
+
+// DiscoverySettings resolves the SQL nodes statements are sent to, instead of
+// Host and Hosts, from the DNS SRV records of SRV, such as
+// _ocient._tcp.example.com, or from URL answering a JSON array of host or
+// host:port strings. They are resolved when the datasource is created and
+// again every RefreshSeconds; a RefreshSeconds below 0 resolves them once.
+type DiscoverySettings struct {
+	SRV            string `json:"srv"`
+	URL            string `json:"url"`
+	RefreshSeconds int    `json:"refreshSeconds"`
+}
+
+// PublicDashboardSettings enables queries from public dashboards, which reach the
+// plugin as Grafana's anonymous viewer. Such queries run with the restricted
+// public credentials, must be read-only and return at most MaxRows rows.
+type PublicDashboardSettings struct {
+	MaxRows int `json:"maxRows"`
+}
+
+// ReportingSettings raises the row limit and query timeout for requests made by
+// PDF reports and CSV exports, recognized by Header being set to "true". The
+// limits never go below the interactive ones.
+type ReportingSettings struct {
+	Header       string `json:"header"`
+	MaxRows      int    `json:"maxRows"`
+	QueryTimeout int    `json:"queryTimeoutSeconds"`
+}
+
+// CredentialMapping sends the queries of some Grafana users with other Ocient
+// credentials than those of the datasource, so that one datasource enforces
+// different data access per group of users. Users are matched by login or
+// email, and Roles by their organization role (Viewer, Editor or Admin),
+// since Grafana doesn't tell plugins the teams of a user. Its credentials are
+// the credential.<name>.username and credential.<name>.password secrets.
+type CredentialMapping struct {
+	Name     string   `json:"name"`
+	Users    []string `json:"users"`
+	Roles    []string `json:"roles"`
+	Username string   `json:"-"`
+	Password string   `json:"-"`
+}
+
+// EnvironmentSettings is a named Ocient cluster, such as dev, stage or prod,
+// that queries can target instead of the default one. Its credentials are the
+// environment.<name>.username and environment.<name>.password secrets; unset
+// fields are inherited from the default cluster.
+type EnvironmentSettings struct {
+	Name     string `json:"name"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Database string `json:"database"`
+	Username string `json:"-"`
+	Password string `json:"-"`
 }
 
 type SecretPluginSettings struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// PublicUsername and PublicPassword are the restricted credentials used for
+	// public dashboard queries.
+	PublicUsername string `json:"publicUsername"`
+	PublicPassword string `json:"publicPassword"`
+	// TLSCACert is a PEM bundle of the CA certificates trusted to sign the
+	// certificate of Ocient.
+	TLSCACert string `json:"tlsCACert"`
+	// TLSClientCert and TLSClientKey are the PEM encoded certificate and key
+	// presented for mutual TLS.
+	TLSClientCert string `json:"tlsClientCert"`
+	TLSClientKey  string `json:"tlsClientKey"`
 }
 
 func LoadPluginSettings(source backend.DataSourceInstanceSettings) (*PluginSettings, error) {
 	// Log the raw JSON data received
 	fmt.Printf("Raw JSONData (len=%d): %s\n", len(source.JSONData), string(source.JSONData))
-	
+
 	settings := PluginSettings{}
 	err := json.Unmarshal(source.JSONData, &settings)
 	if err != nil {
 		return nil, fmt.Errorf("could not unmarshal PluginSettings json: %w", err)
 	}
 
-	// If port is 0, set the default port
+	switch settings.Scheme {
+	case "":
+		settings.Scheme = SchemeHTTPS
+	case SchemeHTTPS, SchemeHTTP:
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q, expected %q or %q", settings.Scheme, SchemeHTTPS, SchemeHTTP)
+	}
+
+	switch settings.LoadBalancing {
+	case "":
+		settings.LoadBalancing = LoadBalancingFailover
+	case LoadBalancingFailover, LoadBalancingRoundRobin, LoadBalancingLeastOutstanding:
+	default:
+		return nil, fmt.Errorf("unsupported load balancing %q, expected %q, %q or %q", settings.LoadBalancing,
+			LoadBalancingFailover, LoadBalancingRoundRobin, LoadBalancingLeastOutstanding)
+	}
+
+	if settings.Discovery != nil {
+		if (settings.Discovery.SRV == "") == (settings.Discovery.URL == "") {
+			return nil, fmt.Errorf("discovery needs either an SRV record or a URL")
+		}
+		if settings.Discovery.RefreshSeconds == 0 {
+			settings.Discovery.RefreshSeconds = DefaultDiscoveryRefreshSeconds
+		}
+	}
+
+	// If port is 0, set the default port of the scheme
 	if settings.Port == 0 {
 		settings.Port = 443 // Default to HTTPS port
+		if settings.Scheme == SchemeHTTP {
+			settings.Port = 80
+		}
+	}
+
+	// Guard against ultra-wide results unless a limit is configured
+	if settings.MaxColumns <= 0 {
+		settings.MaxColumns = DefaultMaxColumns
+	}
+
+	// Never wait on a hung node for longer than the default timeout
+	if settings.QueryTimeout <= 0 {
+		settings.QueryTimeout = DefaultQueryTimeoutSeconds
+	}
+
+	// Keep connections to Ocient alive unless the pool is sized explicitly
+	if settings.MaxIdleConns <= 0 {
+		settings.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if settings.MaxIdleConnsPerHost <= 0 {
+		settings.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if settings.MaxConnsPerHost < 0 {
+		settings.MaxConnsPerHost = 0
+	}
+	if settings.IdleConnTimeout <= 0 {
+		settings.IdleConnTimeout = DefaultIdleConnTimeoutSeconds
+	}
+
+	// Ride out momentary SQL node restarts unless configured otherwise
+	if settings.Retry == nil {
+		settings.Retry = &RetrySettings{}
+	}
+	if settings.Retry.MaxAttempts <= 0 {
+		settings.Retry.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if settings.Retry.InitialBackoffMs <= 0 {
+		settings.Retry.InitialBackoffMs = DefaultRetryInitialBackoffMs
+	}
+	if settings.Retry.MaxBackoffMs <= 0 {
+		settings.Retry.MaxBackoffMs = DefaultRetryMaxBackoffMs
+	}
+	settings.Retry.MaxBackoffMs = max(settings.Retry.MaxBackoffMs, settings.Retry.InitialBackoffMs)
+
+	// Spare a down cluster the queries of every panel unless configured otherwise
+	if settings.CircuitBreaker == nil {
+		settings.CircuitBreaker = &CircuitBreakerSettings{}
+	}
+	if settings.CircuitBreaker.FailureThreshold == 0 {
+		settings.CircuitBreaker.FailureThreshold = DefaultCircuitFailureThreshold
+	}
+	if settings.CircuitBreaker.CooldownSeconds <= 0 {
+		settings.CircuitBreaker.CooldownSeconds = DefaultCircuitCooldownSeconds
+	}
+
+	// Public dashboard queries are always row limited
+	if settings.PublicDashboards != nil && settings.PublicDashboards.MaxRows <= 0 {
+		settings.PublicDashboards.MaxRows = DefaultPublicMaxRows
+	}
+
+	// Reports get complete data within bounds unless configured otherwise
+	if settings.Reporting != nil {
+		if settings.Reporting.Header == "" {
+			settings.Reporting.Header = DefaultReportingHeader
+		}
+		if settings.Reporting.MaxRows <= 0 {
+			settings.Reporting.MaxRows = DefaultReportingMaxRows
+		}
+		if settings.Reporting.QueryTimeout <= 0 {
+			settings.Reporting.QueryTimeout = DefaultReportingTimeoutSeconds
+		}
+	}
+
+	// Make skipped TLS verification visible unless configured otherwise
+	if settings.InsecurePolicy == "" {
+		settings.InsecurePolicy = InsecurePolicyWarn
+	}
+
+	// Default to the REST API when no transport is selected
+	switch settings.Transport {
+	case "":
+		settings.Transport = TransportREST
+	case TransportREST:
+	case TransportNative:
+		if !slices.Contains(sql.Drivers(), NativeDriverName) {
+			return nil, fmt.Errorf("the native transport needs a build of the plugin with the Ocient database/sql driver, which this build lacks; use the REST transport")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported transport %q, expected %q", settings.Transport, TransportREST)
+	}
+
+	switch settings.AuthMethod {
+	case "":
+		settings.AuthMethod = AuthBasic
+	case AuthBasic, AuthSession:
+	default:
+		return nil, fmt.Errorf("unsupported authentication method %q, expected %q or %q", settings.AuthMethod, AuthBasic, AuthSession)
 	}
 
 	// Load secrets (credentials)
 	settings.Secrets = loadSecretPluginSettings(source.DecryptedSecureJSONData)
 
+	seen := make(map[string]bool)
+	for i := range settings.Environments {
+		env := &settings.Environments[i]
+		if env.Name == "" || seen[env.Name] {
+			return nil, fmt.Errorf("environment %d needs a unique name, got %q", i+1, env.Name)
+		}
+		seen[env.Name] = true
+		env.Username = source.DecryptedSecureJSONData["environment."+env.Name+".username"]
+		env.Password = source.DecryptedSecureJSONData["environment."+env.Name+".password"]
+	}
+
+	seen = make(map[string]bool)
+	for i := range settings.CredentialMappings {
+		mapping := &settings.CredentialMappings[i]
+		if mapping.Name == "" || seen[mapping.Name] {
+			return nil, fmt.Errorf("credential mapping %d needs a unique name, got %q", i+1, mapping.Name)
+		}
+		seen[mapping.Name] = true
+		mapping.Username = source.DecryptedSecureJSONData["credential."+mapping.Name+".username"]
+		mapping.Password = source.DecryptedSecureJSONData["credential."+mapping.Name+".password"]
+	}
+
+	for _, key := range settings.ConfiguredSecrets {
+		if source.DecryptedSecureJSONData[key] == "" {
+			settings.UndecryptedSecrets = append(settings.UndecryptedSecrets, key)
+		}
+	}
+
 	// Log the loaded settings
-	fmt.Printf("Loaded settings: host=%s, port=%d, database=%s, insecureSkipVerify=%v\n", 
+	fmt.Printf("Loaded settings: host=%s, port=%d, database=%s, insecureSkipVerify=%v\n",
 		settings.Host, settings.Port, settings.Database, settings.InsecureSkipVerify)
-	fmt.Printf("Secrets loaded: username=%v, password=%v\n", 
+	fmt.Printf("Secrets loaded: username=%v, password=%v\n",
 		settings.Secrets.Username != "", settings.Secrets.Password != "")
 
 	return &settings, nil
@@ -49,7 +446,12 @@ func LoadPluginSettings(source backend.DataSourceInstanceSettings) (*PluginSetti
 
 func loadSecretPluginSettings(source map[string]string) *SecretPluginSettings {
 	return &SecretPluginSettings{
-		Username: source["username"],
-		Password: source["password"],
+		Username:       source["username"],
+		Password:       source["password"],
+		PublicUsername: source["publicUsername"],
+		PublicPassword: source["publicPassword"],
+		TLSCACert:      source["tlsCACert"],
+		TLSClientCert:  source["tlsClientCert"],
+		TLSClientKey:   source["tlsClientKey"],
 	}
 }
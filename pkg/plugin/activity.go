@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// States of an in-flight query.
+const (
+	// activityQueued queries wait for the earlier queries of their request.
+	activityQueued = "queued"
+	// activityRunning queries wait for Ocient to answer.
+	activityRunning = "running"
+	// activityStreaming queries are reading and converting their result.
+	activityStreaming = "streaming"
+)
+
+// activityEntry is one in-flight query reported by the /activity resource.
+// Statements are only identified by a hash, as they may carry sensitive values.
+type activityEntry struct {
+	RefID     string    `json:"refId"`
+	Mode      string    `json:"mode"`
+	State     string    `json:"state"`
+	SQLHash   string    `json:"sqlHash,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+	ElapsedMs float64   `json:"elapsedMs"`
+}
+
+// activityTracker keeps the queries in flight. The zero value tracks nothing
+// until the first query is added.
+type activityTracker struct {
+	mu      sync.Mutex
+	nextID  int
+	queries map[int]*activeQuery
+}
+
+// activeQuery is the tracked state of one query.
+type activeQuery struct {
+	tracker *activityTracker
+	id      int
+	entry   activityEntry
+}
+
+// add tracks a new query in the queued state.
+func (t *activityTracker) add(refID string, mode executionMode) *activeQuery {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.queries == nil {
+		t.queries = make(map[int]*activeQuery)
+	}
+	t.nextID++
+	q := &activeQuery{tracker: t, id: t.nextID, entry: activityEntry{
+		RefID: refID, Mode: mode.String(), State: activityQueued, StartedAt: time.Now(),
+	}}
+	t.queries[q.id] = q
+	return q
+}
+
+// snapshot returns the queries in flight, oldest first.
+func (t *activityTracker) snapshot() []activityEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ids := make([]int, 0, len(t.queries))
+	for id := range t.queries {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	entries := make([]activityEntry, len(ids))
+	for i, id := range ids {
+		entries[i] = t.queries[id].entry
+		entries[i].ElapsedMs = float64(time.Since(entries[i].StartedAt).Microseconds()) / 1000
+	}
+	return entries
+}
+
+// running marks the query as sent to Ocient.
+func (q *activeQuery) running(statement string) {
+	sum := sha256.Sum256([]byte(statement))
+	q.tracker.mu.Lock()
+	defer q.tracker.mu.Unlock()
+	q.entry.State, q.entry.SQLHash = activityRunning, hex.EncodeToString(sum[:8])
+}
+
+// streaming marks the query as reading its result.
+func (q *activeQuery) streaming() {
+	q.tracker.mu.Lock()
+	defer q.tracker.mu.Unlock()
+	q.entry.State = activityStreaming
+}
+
+// done stops tracking the query.
+func (q *activeQuery) done() {
+	q.tracker.mu.Lock()
+	defer q.tracker.mu.Unlock()
+	delete(q.tracker.queries, q.id)
+}
+
+// handleActivity lists the queries the datasource is running right now.
+func (d *Datasource) handleActivity(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, d.activity.snapshot())
+}
@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// blockingTransport holds every statement until release is closed.
+type blockingTransport struct {
+	started chan string
+	release chan struct{}
+}
+
+func (b *blockingTransport) Execute(_ context.Context, statement string) (*QueryResult, error) {
+	b.started <- statement
+	<-b.release
+	return &QueryResult{Columns: []Column{{Name: "value", Type: "INT"}}, Rows: [][]interface{}{{float64(1)}}}, nil
+}
+
+func (b *blockingTransport) Close() error {
+	return nil
+}
+
+func TestActivityResource(t *testing.T) {
+	transport := &blockingTransport{started: make(chan string, 2), release: make(chan struct{})}
+	ds := &Datasource{transport: transport}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = ds.QueryData(context.Background(), &backend.QueryDataRequest{Queries: []backend.DataQuery{
+			{RefID: "A", JSON: []byte(`{"queryText": "SELECT 1"}`)},
+			{RefID: "B", JSON: []byte(`{"queryText": "SELECT 2"}`)},
+		}})
+	}()
+	select {
+	case <-transport.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the first query never started")
+	}
+
+	var entries []activityEntry
+	if err := json.Unmarshal(callResource(t, ds, "GET", "activity").Body, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 queries in flight, got %+v", entries)
+	}
+	if entries[0].RefID != "A" || entries[0].State != activityRunning || entries[0].SQLHash == "" {
+		t.Errorf("expected A to be running, got %+v", entries[0])
+	}
+	if entries[1].RefID != "B" || entries[1].State != activityQueued || entries[1].SQLHash != "" {
+		t.Errorf("expected B to be queued, got %+v", entries[1])
+	}
+
+	close(transport.release)
+	<-done
+	if entries := ds.activity.snapshot(); len(entries) != 0 {
+		t.Errorf("expected no queries in flight after the request, got %+v", entries)
+	}
+}
@@ -0,0 +1,245 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// Column types of tables change rarely, so they are cached for the ad-hoc
+// filters of every refresh.
+const (
+	columnTypeCacheTTL     = 5 * time.Minute
+	columnTypeCacheEntries = 100
+)
+
+// Ad-hoc filter operators, as Grafana names them. "=~" and "!~" match LIKE
+// patterns, "=|" and "!=|" compare with one of several values.
+const (
+	adhocEquals      = "="
+	adhocNotEquals   = "!="
+	adhocLess        = "<"
+	adhocLessOrEq    = "<="
+	adhocGreater     = ">"
+	adhocGreaterOrEq = ">="
+	adhocLike        = "=~"
+	adhocNotLike     = "!~"
+	adhocOneOf       = "=|"
+	adhocNotOneOf    = "!=|"
+)
+
+// adhocFilter is a filter of an ad-hoc filters dashboard variable, applied by
+// the $__adhocFilters() macro.
+type adhocFilter struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+	// Values are the values of the one of operators
+	Values []string `json:"values"`
+}
+
+// adhocCondition returns the SQL condition of a filter. Values are written as
+// literals of the column type when it is known, or else as numbers when they
+// look like one and as strings otherwise.
+func adhocCondition(f adhocFilter, columnTypes map[string]string, loc *time.Location) (string, error) {
+	if f.Key == "" {
+		return "", fmt.Errorf("ad-hoc filter without a key")
+	}
+	col := quoteQualifiedIdentifier(f.Key)
+	sqlType := columnTypes[strings.ToLower(f.Key)]
+
+	switch f.Operator {
+	case adhocEquals, adhocNotEquals, adhocLess, adhocLessOrEq, adhocGreater, adhocGreaterOrEq:
+		value, err := adhocLiteral(f.Value, sqlType, loc)
+		if err != nil {
+			return "", fmt.Errorf("ad-hoc filter on %s: %w", f.Key, err)
+		}
+		op := f.Operator
+		if op == adhocNotEquals {
+			op = "<>"
+		}
+		return fmt.Sprintf("%s %s %s", col, op, value), nil
+	case adhocLike, adhocNotLike:
+		op := "LIKE"
+		if f.Operator == adhocNotLike {
+			op = "NOT LIKE"
+		}
+		// Patterns are matched against the text of any column type
+		if kind, _ := kindForSQLType(sqlType); sqlType != "" && kind != kindString {
+			col = "CAST(" + col + " AS VARCHAR)"
+		}
+		return fmt.Sprintf("%s %s %s", col, op, stringLiteral(f.Value)), nil
+	case adhocOneOf, adhocNotOneOf:
+		values := f.Values
+		if len(values) == 0 && f.Value != "" {
+			values = []string{f.Value}
+		}
+		if len(values) == 0 {
+			return "", fmt.Errorf("ad-hoc filter on %s has no values", f.Key)
+		}
+		literals := make([]string, len(values))
+		for i, v := range values {
+			literal, err := adhocLiteral(v, sqlType, loc)
+			if err != nil {
+				return "", fmt.Errorf("ad-hoc filter on %s: %w", f.Key, err)
+			}
+			literals[i] = literal
+		}
+		op := "IN"
+		if f.Operator == adhocNotOneOf {
+			op = "NOT IN"
+		}
+		return fmt.Sprintf("%s %s (%s)", col, op, strings.Join(literals, ", ")), nil
+	default:
+		return "", fmt.Errorf("unsupported ad-hoc filter operator %q", f.Operator)
+	}
+}
+
+// decimalLiteral matches the numbers ad-hoc filters write unquoted. Forms
+// strconv accepts besides, such as Inf, NaN, hex floats and underscores, are
+// identifiers or syntax errors in Ocient.
+var decimalLiteral = regexp.MustCompile(`^-?\d+(\.\d+)?([eE][-+]?\d+)?$`)
+
+// adhocLiteral writes a filter value as a literal of the SQL type of its
+// column. Timestamps given with a zone are written in the session timezone,
+// like the time macros.
+func adhocLiteral(value, sqlType string, loc *time.Location) (string, error) {
+	if sqlType == "" {
+		if decimalLiteral.MatchString(value) {
+			return value, nil
+		}
+		return stringLiteral(value), nil
+	}
+
+	kind, _ := kindForSQLType(sqlType)
+	switch kind {
+	case kindInt, kindFloat:
+		if !decimalLiteral.MatchString(strings.TrimSpace(value)) {
+			return "", fmt.Errorf("value %q is not a number", value)
+		}
+		return strings.TrimSpace(value), nil
+	case kindBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return "", fmt.Errorf("value %q is not a boolean", value)
+		}
+		return strings.ToUpper(strconv.FormatBool(b)), nil
+	case kindTime:
+		if t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(value)); err == nil {
+			return macroContext{loc: loc}.literal(t), nil
+		}
+		return stringLiteral(value), nil
+	default:
+		return stringLiteral(value), nil
+	}
+}
+
+// stringLiteral quotes s as an SQL string literal.
+func stringLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// adhocFiltersCondition joins the conditions of every filter, or returns 1=1
+// without filters so that the macro can always follow WHERE or AND.
+func adhocFiltersCondition(filters []adhocFilter, columnTypes map[string]string, loc *time.Location) (string, error) {
+	if len(filters) == 0 {
+		return "1=1", nil
+	}
+	conditions := make([]string, len(filters))
+	for i, f := range filters {
+		condition, err := adhocCondition(f, columnTypes, loc)
+		if err != nil {
+			return "", err
+		}
+		conditions[i] = condition
+	}
+	return strings.Join(conditions, " AND "), nil
+}
+
+// adhocColumnTypes returns the SQL types of the columns of the table a query
+// with ad-hoc filters reads, by lower case column name, so that filter values
+// are typed like their columns. It returns nil when the query reads more than
+// one table or the types can't be looked up, and values are then typed by how
+// they look. Public dashboard queries never look up types.
+func (d *Datasource) adhocColumnTypes(ctx context.Context, mode executionMode, qm queryModel) map[string]string {
+	if len(qm.AdhocFilters) == 0 || mode == modePublic {
+		return nil
+	}
+	table, ok := singleTable(qm.QueryText)
+	if !ok {
+		return nil
+	}
+	transport, err := d.environmentTransport(qm.Environment)
+	if err != nil {
+		return nil
+	}
+
+	parts := splitQualifiedName(table)
+	if len(parts) == 1 && d.settings.DefaultSchema != "" {
+		parts = []string{d.settings.DefaultSchema, parts[0]}
+	}
+	statement := "SELECT column_name, data_type FROM information_schema.columns WHERE LOWER(table_name) = " +
+		stringLiteral(strings.ToLower(parts[len(parts)-1]))
+	if len(parts) > 1 {
+		statement += " AND LOWER(table_schema) = " + stringLiteral(strings.ToLower(parts[len(parts)-2]))
+	}
+
+	key := cacheScope(ctx) + qm.Environment + "\x00" + statement
+	if d.columnTypeCache != nil {
+		if types, _, ok := d.columnTypeCache.get(key); ok {
+			return types
+		}
+	}
+	result, err := transport.Execute(ctx, statement)
+	if err == nil {
+		err = result.decodeRows()
+	}
+	if err != nil {
+		backend.Logger.Warn("Column type lookup for ad-hoc filters failed", "table", table, "error", err.Error())
+		return nil
+	}
+	types := make(map[string]string, len(result.Rows))
+	for _, row := range result.Rows {
+		if len(row) < 2 {
+			continue
+		}
+		name, nameOK := row[0].(string)
+		sqlType, typeOK := row[1].(string)
+		if nameOK && typeOK {
+			types[strings.ToLower(name)] = sqlType
+		}
+	}
+	if d.columnTypeCache != nil {
+		d.columnTypeCache.set(key, types)
+	}
+	return types
+}
+
+// splitQualifiedName splits a possibly qualified and quoted name such as
+// schema."My Table" into its unquoted parts.
+func splitQualifiedName(name string) []string {
+	var parts []string
+	for i := 0; i < len(name); {
+		if name[i] == '"' {
+			end := skipQuoted(name, i)
+			if end < 0 {
+				end = len(name)
+			}
+			parts = append(parts, strings.ReplaceAll(name[i+1:max(end-1, i+1)], `""`, `"`))
+			i = end + 1
+			continue
+		}
+		end := strings.IndexByte(name[i:], '.')
+		if end < 0 {
+			end = len(name) - i
+		}
+		parts = append(parts, strings.TrimSpace(name[i:i+end]))
+		i += end + 1
+	}
+	return parts
+}
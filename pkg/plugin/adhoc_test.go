@@ -0,0 +1,107 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestAdhocCondition(t *testing.T) {
+	types := map[string]string{"status": "INT", "host": "VARCHAR(64)", "ts": "TIMESTAMP", "ok": "BOOLEAN"}
+	loc, _ := time.LoadLocation("America/Chicago")
+	tests := []struct {
+		filter adhocFilter
+		want   string
+	}{
+		{adhocFilter{Key: "status", Operator: "=", Value: "500"}, "status = 500"},
+		{adhocFilter{Key: "status", Operator: "!=", Value: "200"}, "status <> 200"},
+		{adhocFilter{Key: "status", Operator: ">=", Value: "400"}, "status >= 400"},
+		{adhocFilter{Key: "host", Operator: "=", Value: "o'neil"}, "host = 'o''neil'"},
+		{adhocFilter{Key: "host", Operator: "=", Value: "42"}, "host = '42'"},
+		{adhocFilter{Key: "ts", Operator: ">", Value: "2024-03-01T12:00:00Z"}, "ts > '2024-03-01 06:00:00'"},
+		{adhocFilter{Key: "ts", Operator: "<", Value: "2024-03-01"}, "ts < '2024-03-01'"},
+		{adhocFilter{Key: "ok", Operator: "=", Value: "true"}, "ok = TRUE"},
+		{adhocFilter{Key: "host", Operator: "=~", Value: "web-%"}, "host LIKE 'web-%'"},
+		{adhocFilter{Key: "status", Operator: "!~", Value: "5%"}, "CAST(status AS VARCHAR) NOT LIKE '5%'"},
+		{adhocFilter{Key: "status", Operator: "=|", Values: []string{"500", "503"}}, "status IN (500, 503)"},
+		{adhocFilter{Key: "host", Operator: "!=|", Values: []string{"a", "b"}}, "host NOT IN ('a', 'b')"},
+		{adhocFilter{Key: "user", Operator: "=", Value: "1.5"}, `"user" = 1.5`},
+		{adhocFilter{Key: "region", Operator: "=", Value: "eu"}, "region = 'eu'"},
+		{adhocFilter{Key: "region", Operator: "=", Value: "-2.5E-3"}, "region = -2.5E-3"},
+		// Numbers only Go parses are strings when the column type is unknown
+		{adhocFilter{Key: "region", Operator: "=", Value: "Inf"}, "region = 'Inf'"},
+		{adhocFilter{Key: "region", Operator: "=", Value: "NaN"}, "region = 'NaN'"},
+		{adhocFilter{Key: "region", Operator: "=", Value: "0x1p-2"}, "region = '0x1p-2'"},
+		{adhocFilter{Key: "region", Operator: "=", Value: "1_0"}, "region = '1_0'"},
+	}
+	for _, tt := range tests {
+		got, err := adhocCondition(tt.filter, types, loc)
+		if err != nil {
+			t.Errorf("%+v: %v", tt.filter, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.filter, got, tt.want)
+		}
+	}
+
+	for _, f := range []adhocFilter{
+		{Key: "status", Operator: "=", Value: "abc"},
+		{Key: "status", Operator: "=", Value: "Inf"},
+		{Key: "status", Operator: "=", Value: "0x1p-2"},
+		{Key: "status", Operator: "=|", Values: []string{"1", "1_0"}},
+		{Key: "ok", Operator: "=", Value: "maybe"},
+		{Key: "status", Operator: "=|"},
+		{Key: "status", Operator: "<>", Value: "1"},
+		{Operator: "=", Value: "1"},
+	} {
+		if _, err := adhocCondition(f, types, loc); err == nil {
+			t.Errorf("%+v: expected an error", f)
+		}
+	}
+}
+
+func TestQueryDataAdhocFilters(t *testing.T) {
+	transport := &statementTransport{results: map[string]*QueryResult{
+		"SELECT column_name, data_type FROM information_schema.columns": {
+			Columns: []Column{{Name: "column_name", Type: "VARCHAR"}, {Name: "data_type", Type: "VARCHAR"}},
+			Rows:    [][]interface{}{{"code", "VARCHAR"}, {"latency", "DOUBLE"}},
+		},
+		"SELECT code": {
+			Columns: []Column{{Name: "code", Type: "VARCHAR"}},
+			Rows:    [][]interface{}{{"500"}},
+		},
+	}}
+	ds := Datasource{transport: transport, columnTypeCache: newTTLCache[map[string]string](columnTypeCacheTTL, columnTypeCacheEntries)}
+
+	for i := 0; i < 2; i++ {
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{
+				"queryText": "SELECT code FROM app.requests WHERE $__adhocFilters()",
+				"adhocFilters": [{"key": "code", "operator": "=|", "values": ["500", "503"]}, {"key": "latency", "operator": ">", "value": "0.5"}]
+			}`)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := resp.Responses["A"]; res.Error != nil {
+			t.Fatal(res.Error)
+		}
+	}
+
+	want := []string{
+		"SELECT column_name, data_type FROM information_schema.columns WHERE LOWER(table_name) = 'requests' AND LOWER(table_schema) = 'app'",
+		"SELECT code FROM app.requests WHERE code IN ('500', '503') AND latency > 0.5",
+		"SELECT code FROM app.requests WHERE code IN ('500', '503') AND latency > 0.5",
+	}
+	if len(transport.statements) != len(want) {
+		t.Fatalf("expected the column types to be looked up once, got statements %q", transport.statements)
+	}
+	for i := range want {
+		if transport.statements[i] != want[i] {
+			t.Errorf("statement %d: got %q, want %q", i, transport.statements[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package plugin
+
+import (
+	"strings"
+)
+
+// Array column modes selectable per query.
+const (
+	// arrayModeJSON returns each array as a JSON field value.
+	arrayModeJSON = "json"
+	// arrayModeExplode returns one row per array element, repeating the other
+	// columns. Several array columns in one row are zipped by position.
+	arrayModeExplode = "explode"
+)
+
+// arrayElementType returns the element type of an array SQL type, such as INT
+// for INT[] or ARRAY(INT). The second return value is false for other types.
+func arrayElementType(sqlType string) (string, bool) {
+	t := strings.TrimSpace(sqlType)
+	if strings.HasSuffix(t, "[]") {
+		return strings.TrimSpace(strings.TrimSuffix(t, "[]")), true
+	}
+	upper := strings.ToUpper(t)
+	if upper == "ARRAY" {
+		return "", true
+	}
+	if strings.HasPrefix(upper, "ARRAY(") && strings.HasSuffix(t, ")") {
+		return strings.TrimSpace(t[len("ARRAY(") : len(t)-1]), true
+	}
+	return "", false
+}
+
+// isArrayColumn reports whether a result column holds arrays, either by its
+// declared type or, for untyped columns, by its first non-null value.
+func isArrayColumn(result *QueryResult, index int) bool {
+	if _, ok := arrayElementType(result.Columns[index].Type); ok {
+		return true
+	}
+	if result.Columns[index].Type != "" {
+		return false
+	}
+	for _, row := range result.Rows {
+		if index < len(row) && row[index] != nil {
+			_, ok := row[index].([]interface{})
+			return ok
+		}
+	}
+	return false
+}
+
+// explodeArrays expands every row into one row per element of its array columns.
+// Array columns take on their element type; rows with empty or null arrays are
+// kept with a null element so no other data is lost.
+func explodeArrays(result *QueryResult) *QueryResult {
+	var arrays []int
+	columns := append([]Column(nil), result.Columns...)
+	for i := range columns {
+		if isArrayColumn(result, i) {
+			arrays = append(arrays, i)
+			columns[i].Type, _ = arrayElementType(columns[i].Type)
+		}
+	}
+	if len(arrays) == 0 {
+		return result
+	}
+
+	exploded := &QueryResult{QueryID: result.QueryID, Columns: columns, Rows: make([][]interface{}, 0, len(result.Rows))}
+	for _, row := range result.Rows {
+		n := 1
+		for _, col := range arrays {
+			if col < len(row) {
+				if elems, ok := row[col].([]interface{}); ok && len(elems) > n {
+					n = len(elems)
+				}
+			}
+		}
+		for i := 0; i < n; i++ {
+			out := append([]interface{}(nil), row...)
+			for _, col := range arrays {
+				if col >= len(out) {
+					continue
+				}
+				elems, _ := out[col].([]interface{})
+				if i < len(elems) {
+					out[col] = elems[i]
+				} else {
+					out[col] = nil
+				}
+			}
+			exploded.Rows = append(exploded.Rows, out)
+		}
+	}
+	return exploded
+}
@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+const (
+	// dashboardUIDHeader and panelIDHeader are the headers Grafana forwards
+	// with the queries of dashboard panels.
+	dashboardUIDHeader = "X-Dashboard-Uid"
+	panelIDHeader      = "X-Panel-Id"
+)
+
+// auditPanelKey is the context key of the dashboard panel a request queries
+// for, see withAuditPanel.
+type auditPanelKey struct{}
+
+type auditPanel struct {
+	dashboardUID string
+	panelID      string
+}
+
+// withAuditPanel returns ctx carrying the dashboard and panel of a query
+// request, taken from the headers Grafana forwards with it.
+func withAuditPanel(ctx context.Context, req *backend.QueryDataRequest) context.Context {
+	panel := auditPanel{dashboardUID: req.GetHTTPHeader(dashboardUIDHeader), panelID: req.GetHTTPHeader(panelIDHeader)}
+	if panel == (auditPanel{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, auditPanelKey{}, panel)
+}
+
+// auditComment returns the comment tagging the statements of ctx with the
+// Grafana user, organization, dashboard and panel they run for, in the
+// sqlcommenter format: sorted key='value' pairs with URL encoded values, so
+// that no value can end the comment. It is empty for background statements,
+// which run for none.
+func auditComment(ctx context.Context) string {
+	tags := make(map[string]string)
+	pluginContext := backend.PluginConfigFromContext(ctx)
+	if pluginContext.User != nil && pluginContext.User.Login != "" {
+		tags["grafana_user"] = pluginContext.User.Login
+	}
+	if pluginContext.OrgID != 0 {
+		tags["grafana_org"] = strconv.FormatInt(pluginContext.OrgID, 10)
+	}
+	if panel, ok := ctx.Value(auditPanelKey{}).(auditPanel); ok {
+		if panel.dashboardUID != "" {
+			tags["dashboard_uid"] = panel.dashboardUID
+		}
+		if panel.panelID != "" {
+			tags["panel_id"] = panel.panelID
+		}
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "='" + url.PathEscape(tags[key]) + "'"
+	}
+	return "/*" + strings.Join(pairs, ",") + "*/"
+}
+
+// auditTransport wraps another transport and prefixes statements with their
+// audit comment, so that the audit log of the cluster attributes every
+// statement to the Grafana user and dashboard it ran for, whichever Ocient
+// user sent it.
+type auditTransport struct {
+	next QueryTransport
+}
+
+func newAuditTransport(next QueryTransport) *auditTransport {
+	return &auditTransport{next: next}
+}
+
+func (t *auditTransport) Execute(ctx context.Context, statement string) (*QueryResult, error) {
+	if comment := auditComment(ctx); comment != "" {
+		statement = comment + " " + statement
+	}
+	return t.next.Execute(ctx, statement)
+}
+
+func (t *auditTransport) Close() error {
+	return t.next.Close()
+}
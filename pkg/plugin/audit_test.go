@@ -0,0 +1,85 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/ocient/ocient-datasource/pkg/internal/fakeocient"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+func TestAuditComment(t *testing.T) {
+	if comment := auditComment(context.Background()); comment != "" {
+		t.Errorf("got comment %q for a background statement, want none", comment)
+	}
+
+	ctx := backend.WithPluginContext(context.Background(), backend.PluginContext{OrgID: 2, User: &backend.User{Login: "eve*/ DROP TABLE t; /*"}})
+	// Values are URL encoded, so that no login can end the comment early
+	want := "/*grafana_org='2',grafana_user='eve%2A%2F%20DROP%20TABLE%20t%3B%20%2F%2A'*/"
+	if comment := auditComment(ctx); comment != want {
+		t.Errorf("got comment %q, want %q", comment, want)
+	}
+}
+
+func TestQueryDataAuditComment(t *testing.T) {
+	transport, server := newFakeRESTTransport(t,
+		fakeocient.WithDatasets(fakeocient.Dataset{Match: "from t", Rows: []map[string]interface{}{{"a": float64(1)}}}))
+	ds := Datasource{settings: transport.settings, transport: newAuditTransport(transport)}
+
+	ctx := backend.WithPluginContext(context.Background(), backend.PluginContext{OrgID: 1, User: &backend.User{Login: "alice"}})
+	req := &backend.QueryDataRequest{Queries: []backend.DataQuery{{RefID: "A", JSON: json.RawMessage(`{"queryText": "SELECT a FROM t"}`)}}}
+	req.SetHTTPHeader(dashboardUIDHeader, "sales-overview")
+	req.SetHTTPHeader(panelIDHeader, "4")
+	resp, err := ds.QueryData(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := resp.Responses["A"]; r.Error != nil {
+		t.Fatal(r.Error)
+	}
+
+	want := "/*dashboard_uid='sales-overview',grafana_org='1',grafana_user='alice',panel_id='4'*/ SELECT a FROM t"
+	if requests := server.Requests(); len(requests) != 1 || requests[0].Statement != want {
+		t.Errorf("got requests %+v, want statement %q", requests, want)
+	}
+}
+
+func TestAuditCommentsOptIn(t *testing.T) {
+	settings, err := models.LoadPluginSettings(backend.DataSourceInstanceSettings{JSONData: []byte(`{"host": "ocient"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport, err := newTransport(*settings, http.DefaultClient, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := innerTransport(transport).(*auditTransport); ok {
+		t.Error("expected statements to be sent as written by default")
+	}
+
+	settings.AuditComments = true
+	if transport, err = newTransport(*settings, http.DefaultClient, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := innerTransport(transport).(*auditTransport); !ok {
+		t.Errorf("expected auditComments to tag statements, got %T", innerTransport(transport))
+	}
+}
+
+// innerTransport returns the transport below the retry and circuit breaker
+// wrappers.
+func innerTransport(transport QueryTransport) QueryTransport {
+	for {
+		switch t := transport.(type) {
+		case *retryTransport:
+			transport = t.next
+		case *circuitTransport:
+			transport = t.next
+		default:
+			return transport
+		}
+	}
+}
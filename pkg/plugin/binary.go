@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Binary column renderings selectable per query.
+const (
+	binaryFormatHex    = "hex"
+	binaryFormatBase64 = "base64"
+	binaryFormatLength = "length"
+)
+
+// binaryFieldKind returns the field kind used for binary columns in a format.
+func binaryFieldKind(format string) fieldKind {
+	if format == binaryFormatLength {
+		return kindInt
+	}
+	return kindString
+}
+
+// binaryBytes returns the bytes of a binary value. Native driver values are
+// already bytes; the REST API encodes them as "0x" prefixed hex or base64.
+func binaryBytes(v interface{}) ([]byte, bool) {
+	switch val := v.(type) {
+	case []byte:
+		return val, true
+	case string:
+		if strings.HasPrefix(val, "0x") || strings.HasPrefix(val, "0X") {
+			if b, err := hex.DecodeString(val[2:]); err == nil {
+				return b, true
+			}
+		}
+		if b, err := base64.StdEncoding.DecodeString(val); err == nil {
+			return b, true
+		}
+		return []byte(val), true
+	default:
+		return nil, false
+	}
+}
+
+// renderBinary converts a binary value into the value appended to the field,
+// a string for hex and base64 or the byte count for length. Null stays null.
+func renderBinary(v interface{}, format string) interface{} {
+	b, ok := binaryBytes(v)
+	if !ok {
+		return nil
+	}
+	switch format {
+	case binaryFormatBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	case binaryFormatLength:
+		return int64(len(b))
+	default:
+		return hex.EncodeToString(b)
+	}
+}
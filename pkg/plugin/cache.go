@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlCache is a small concurrency safe cache whose entries expire after a fixed
+// time to live. When full, expired entries are dropped first and then the oldest.
+type ttlCache[V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]cacheEntry[V]
+}
+
+type cacheEntry[V any] struct {
+	value  V
+	stored time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration, maxEntries int) *ttlCache[V] {
+	return &ttlCache[V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]cacheEntry[V]),
+	}
+}
+
+// get returns the cached value and its age.
+func (c *ttlCache[V]) get(key string) (V, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, 0, false
+	}
+	age := time.Since(entry.stored)
+	if age > c.ttl {
+		delete(c.entries, key)
+		var zero V
+		return zero, 0, false
+	}
+	return entry.value, age, true
+}
+
+// set stores a value, evicting entries if the cache is full.
+func (c *ttlCache[V]) set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[key] = cacheEntry[V]{value: value, stored: time.Now()}
+}
+
+// evict drops expired entries, or the oldest entry if none expired. Callers
+// must hold c.mu.
+func (c *ttlCache[V]) evict() {
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range c.entries {
+		if time.Since(entry.stored) > c.ttl {
+			delete(c.entries, key)
+			continue
+		}
+		if oldestKey == "" || entry.stored.Before(oldest) {
+			oldestKey, oldest = key, entry.stored
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		delete(c.entries, oldestKey)
+	}
+}
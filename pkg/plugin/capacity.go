@@ -0,0 +1,154 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Capacity functions, which queries call instead of SQL to get typed frames
+// of the storage and segment system tables for capacity dashboards.
+const (
+	capacityStorage  = "storage_usage"
+	capacitySegments = "segment_health"
+)
+
+// capacityQueryPattern matches a query text calling a capacity function,
+// such as storage_usage().
+var capacityQueryPattern = regexp.MustCompile(`(?is)^\s*(storage_usage|segment_health)\s*\(\s*\)\s*;?\s*$`)
+
+// capacityStatements are the system table queries of the capacity functions.
+var capacityStatements = map[string]string{
+	capacityStorage:  "SELECT * FROM sys.storage_space_usage",
+	capacitySegments: "SELECT * FROM sys.segments",
+}
+
+// Columns of the system tables read by the capacity functions, by the names
+// releases have used for them.
+var (
+	storageNodeColumns  = []string{"node_name", "name", "node_id"}
+	storageUsedColumns  = []string{"used_bytes", "bytes_used", "used_space"}
+	storageTotalColumns = []string{"total_bytes", "capacity_bytes", "total_space"}
+	segmentTableColumns = []string{"table_name", "table"}
+	segmentStateColumns = []string{"state", "status", "segment_state"}
+)
+
+// parseCapacityQuery returns the capacity function that a query text calls.
+func parseCapacityQuery(text string) (string, bool) {
+	match := capacityQueryPattern.FindStringSubmatch(text)
+	if match == nil {
+		return "", false
+	}
+	return strings.ToLower(match[1]), true
+}
+
+// capacityResponse answers a query calling a capacity function.
+func (d *Datasource) capacityResponse(ctx context.Context, qm queryModel, function string) backend.DataResponse {
+	transport, err := d.environmentTransport(qm.Environment)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	result, err := transport.Execute(ctx, capacityStatements[function])
+	if err == nil {
+		err = result.decodeRows()
+	}
+	if err != nil {
+		backend.Logger.Error("Capacity query failed", "function", function, "error", err.Error())
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("%s() failed: %v", function, err))
+	}
+
+	var frame *data.Frame
+	if function == capacityStorage {
+		frame, err = storageUsageFrame(result)
+	} else {
+		frame, err = segmentHealthFrame(result)
+	}
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("%s() failed: %v", function, err))
+	}
+	setFrameType(frame, data.FrameTypeTable, data.VisTypeTable)
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// storageUsageFrame returns the used and total bytes of storage per node, and
+// the share used in percent.
+func storageUsageFrame(result *QueryResult) (*data.Frame, error) {
+	node := findColumn(result.Columns, storageNodeColumns)
+	used := findColumn(result.Columns, storageUsedColumns)
+	total := findColumn(result.Columns, storageTotalColumns)
+	if used < 0 || total < 0 {
+		return nil, fmt.Errorf("the storage table has no used and total bytes columns")
+	}
+
+	nodes := make([]string, 0, len(result.Rows))
+	var usedBytes, totalBytes, usedPercent []*float64
+	for _, row := range result.Rows {
+		if len(row) <= max(used, total) {
+			continue
+		}
+		nodes = append(nodes, rowString(row, node))
+		u, uok := toFloat64(row[used])
+		t, tok := toFloat64(row[total])
+		usedBytes = append(usedBytes, optionalFloat(u, uok))
+		totalBytes = append(totalBytes, optionalFloat(t, tok))
+		usedPercent = append(usedPercent, optionalFloat(u/t*100, uok && tok && t > 0))
+	}
+
+	frame := data.NewFrame(capacityStorage,
+		data.NewField("node", nil, nodes),
+		data.NewField("used", nil, usedBytes).SetConfig(&data.FieldConfig{Unit: "bytes"}),
+		data.NewField("total", nil, totalBytes).SetConfig(&data.FieldConfig{Unit: "bytes"}),
+		data.NewField("used_percent", nil, usedPercent).SetConfig(&data.FieldConfig{Unit: "percent"}),
+	)
+	return frame, nil
+}
+
+// segmentHealthFrame counts the segments of every table by state.
+func segmentHealthFrame(result *QueryResult) (*data.Frame, error) {
+	table := findColumn(result.Columns, segmentTableColumns)
+	state := findColumn(result.Columns, segmentStateColumns)
+	if state < 0 {
+		return nil, fmt.Errorf("the segments table has no state column")
+	}
+
+	type group struct{ table, state string }
+	counts := make(map[group]int64)
+	for _, row := range result.Rows {
+		counts[group{rowString(row, table), strings.ToUpper(rowString(row, state))}]++
+	}
+	groups := make([]group, 0, len(counts))
+	for g := range counts {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].table != groups[j].table {
+			return groups[i].table < groups[j].table
+		}
+		return groups[i].state < groups[j].state
+	})
+
+	tables := make([]string, len(groups))
+	states := make([]string, len(groups))
+	segments := make([]int64, len(groups))
+	for i, g := range groups {
+		tables[i], states[i], segments[i] = g.table, g.state, counts[g]
+	}
+	return data.NewFrame(capacitySegments,
+		data.NewField("table", nil, tables),
+		data.NewField("state", nil, states),
+		data.NewField("segments", nil, segments),
+	), nil
+}
+
+// optionalFloat returns a pointer to f when ok, nil otherwise.
+func optionalFloat(f float64, ok bool) *float64 {
+	if !ok {
+		return nil
+	}
+	return &f
+}
@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestQueryDataCapacity(t *testing.T) {
+	transport := &statementTransport{results: map[string]*QueryResult{
+		capacityStatements[capacityStorage]: {
+			Columns: []Column{{Name: "NODE_NAME"}, {Name: "used_bytes"}, {Name: "total_bytes"}},
+			Rows:    [][]interface{}{{"lts-1", json.Number("250"), json.Number("1000")}, {"lts-2", nil, float64(0)}},
+		},
+		capacityStatements[capacitySegments]: {
+			Columns: []Column{{Name: "table_name"}, {Name: "state"}},
+			Rows:    [][]interface{}{{"metrics", "healthy"}, {"metrics", "HEALTHY"}, {"metrics", "rebuilding"}, {"events", "HEALTHY"}},
+		},
+	}}
+	ds := Datasource{transport: transport}
+	query := func(text string) *data.Frame {
+		t.Helper()
+		body, _ := json.Marshal(map[string]string{"queryText": text})
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: body}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := resp.Responses["A"]; r.Error != nil {
+			t.Fatal(r.Error)
+		}
+		return resp.Responses["A"].Frames[0]
+	}
+
+	storage := query("storage_usage()")
+	if storage.Rows() != 2 || storage.Fields[1].Config.Unit != "bytes" || storage.Fields[3].Config.Unit != "percent" {
+		t.Fatalf("unexpected storage frame %v", storage)
+	}
+	if percent := storage.Fields[3].At(0).(*float64); percent == nil || *percent != 25 {
+		t.Errorf("used percent = %v, want 25", percent)
+	}
+	if percent := storage.Fields[3].At(1).(*float64); percent != nil {
+		t.Errorf("used percent of a node without a total = %v, want null", *percent)
+	}
+
+	segments := query(" Segment_Health( );")
+	want := [][]interface{}{{"events", "HEALTHY", int64(1)}, {"metrics", "HEALTHY", int64(2)}, {"metrics", "REBUILDING", int64(1)}}
+	if segments.Rows() != len(want) {
+		t.Fatalf("got %d segment rows, want %d", segments.Rows(), len(want))
+	}
+	for i, row := range want {
+		for j, v := range row {
+			if got := segments.Fields[j].At(i); got != v {
+				t.Errorf("segments row %d field %d = %v, want %v", i, j, got, v)
+			}
+		}
+	}
+}
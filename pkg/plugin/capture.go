@@ -0,0 +1,167 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// Bounds for the number of queries a debug capture records.
+const (
+	defaultCaptureCount = 20
+	maxCaptureCount     = 200
+)
+
+// capturedQuery is one query recorded by a debug capture. The statement and
+// error have their string and numeric literals redacted.
+type capturedQuery struct {
+	RefID      string    `json:"refId"`
+	Time       time.Time `json:"time"`
+	Statement  string    `json:"statement"`
+	DurationMs float64   `json:"durationMs"`
+	Status     int       `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Frames     int       `json:"frames"`
+	Rows       int       `json:"rows"`
+	Fields     int       `json:"fields"`
+}
+
+// captureBundle is the downloadable support bundle.
+type captureBundle struct {
+	StartedAt time.Time       `json:"startedAt"`
+	Requested int             `json:"requested"`
+	Remaining int             `json:"remaining"`
+	Settings  captureSettings `json:"settings"`
+	Queries   []capturedQuery `json:"queries"`
+}
+
+// captureSettings are the settings included in a bundle, without secrets or
+// host names.
+type captureSettings struct {
+	Transport          string `json:"transport"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	MaxRows            int    `json:"maxRows"`
+	MaxColumns         int    `json:"maxColumns"`
+	QueryTimeout       int    `json:"queryTimeoutSeconds"`
+	Timezone           string `json:"timezone"`
+}
+
+// queryCapture records the next queries after a capture is started. The zero
+// value is an idle capture.
+type queryCapture struct {
+	mu        sync.Mutex
+	started   time.Time
+	requested int
+	remaining int
+	queries   []capturedQuery
+}
+
+// start discards any previous capture and records the next count queries.
+func (c *queryCapture) start(count int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started, c.requested, c.remaining, c.queries = time.Now(), count, count, nil
+}
+
+// record adds a query to a running capture.
+func (c *queryCapture) record(refID, statement string, duration time.Duration, res backend.DataResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.remaining == 0 {
+		return
+	}
+	c.remaining--
+
+	q := capturedQuery{
+		RefID:      refID,
+		Time:       time.Now(),
+		Statement:  redactValues(statement),
+		DurationMs: float64(duration.Microseconds()) / 1000,
+		Status:     int(res.Status),
+		Frames:     len(res.Frames),
+	}
+	if q.Status == 0 {
+		q.Status = int(backend.StatusOK)
+	}
+	if res.Error != nil {
+		// Ocient errors often quote the values that failed
+		q.Error = redactValues(res.Error.Error())
+	}
+	for _, frame := range res.Frames {
+		q.Rows += frame.Rows()
+		q.Fields += len(frame.Fields)
+	}
+	c.queries = append(c.queries, q)
+}
+
+// bundle returns what has been captured so far.
+func (c *queryCapture) bundle() captureBundle {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return captureBundle{
+		StartedAt: c.started,
+		Requested: c.requested,
+		Remaining: c.remaining,
+		Queries:   append([]capturedQuery{}, c.queries...),
+	}
+}
+
+// requireOrgAdmin wraps a handler of a resource call, answering 403 unless the
+// caller is an admin of the organization. Captures record the queries of
+// every user of the datasource, so only admins may run and download them.
+func requireOrgAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := backend.PluginConfigFromContext(r.Context()).User
+		if user == nil || user.Role != "Admin" {
+			writeError(w, http.StatusForbidden, "only organization admins can capture queries")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleStartCapture starts capturing the next ?count= queries.
+func (d *Datasource) handleStartCapture(w http.ResponseWriter, r *http.Request) {
+	count := defaultCaptureCount
+	if v := r.URL.Query().Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxCaptureCount {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("count must be between 1 and %d", maxCaptureCount))
+			return
+		}
+		count = n
+	}
+	d.capture.start(count)
+	writeJSON(w, http.StatusOK, d.captureBundle())
+}
+
+// handleCaptureBundle downloads the captured queries.
+func (d *Datasource) handleCaptureBundle(w http.ResponseWriter, _ *http.Request) {
+	bundle := d.captureBundle()
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="ocient-capture-%s.json"`, bundle.StartedAt.UTC().Format("20060102-150405")))
+	writeJSON(w, http.StatusOK, bundle)
+}
+
+// handleStopCapture stops capturing and discards the captured queries.
+func (d *Datasource) handleStopCapture(w http.ResponseWriter, _ *http.Request) {
+	d.capture.start(0)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (d *Datasource) captureBundle() captureBundle {
+	bundle := d.capture.bundle()
+	bundle.Settings = captureSettings{
+		Transport:          d.settings.Transport,
+		InsecureSkipVerify: d.settings.InsecureSkipVerify,
+		MaxRows:            d.settings.MaxRows,
+		MaxColumns:         d.settings.MaxColumns,
+		QueryTimeout:       d.settings.QueryTimeout,
+		Timezone:           d.settings.Timezone,
+	}
+	return bundle
+}
@@ -0,0 +1,104 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestDebugCapture(t *testing.T) {
+	transport := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "value", Type: "DOUBLE"}},
+		Rows:    [][]interface{}{{float64(1)}, {float64(2)}},
+	}}
+	ds := &Datasource{transport: transport}
+	admin := &backend.User{Login: "admin", Role: "Admin"}
+	req := &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{RefID: "A", JSON: []byte(`{"queryText": "SELECT value FROM t WHERE name = 'secret' AND account_id = 123456789"}`)},
+			{RefID: "B", JSON: []byte(`{"queryText": ""}`)},
+		},
+	}
+
+	if resp := callResourceAs(t, ds, admin, "POST", "debug/capture?count=2", nil); resp.Status != 200 {
+		t.Fatalf("unexpected status %d starting the capture", resp.Status)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := ds.QueryData(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp := callResourceAs(t, ds, admin, "GET", "debug/capture", nil)
+	var bundle captureBundle
+	if err := json.Unmarshal(resp.Body, &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.Queries) != 2 || bundle.Remaining != 0 {
+		t.Fatalf("expected exactly 2 captured queries, got %+v", bundle)
+	}
+	a, b := bundle.Queries[0], bundle.Queries[1]
+	if a.RefID == "B" {
+		a, b = b, a
+	}
+	if a.Statement != "SELECT value FROM t WHERE name = '***' AND account_id = ***" || a.Rows != 2 || a.Status != 200 {
+		t.Errorf("unexpected capture %+v", a)
+	}
+	if b.Status != int(backend.StatusBadRequest) || b.Error == "" {
+		t.Errorf("expected the empty query to be captured as failed, got %+v", b)
+	}
+
+	if resp := callResourceAs(t, ds, admin, "POST", "debug/capture?count=1000", nil); resp.Status != 400 {
+		t.Errorf("expected counts above the maximum to be rejected, got %d", resp.Status)
+	}
+}
+
+func TestDebugCaptureRedactsErrors(t *testing.T) {
+	transport := &fakeTransport{err: fmt.Errorf("value 'alice' of account 123456789 violates constraint")}
+	ds := &Datasource{transport: transport}
+	admin := &backend.User{Login: "admin", Role: "Admin"}
+	if resp := callResourceAs(t, ds, admin, "POST", "debug/capture?count=1", nil); resp.Status != 200 {
+		t.Fatalf("unexpected status %d starting the capture: %s", resp.Status, resp.Body)
+	}
+	req := &backend.QueryDataRequest{Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"queryText": "SELECT value FROM t"}`)}}}
+	if _, err := ds.QueryData(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	var bundle captureBundle
+	if err := json.Unmarshal(callResourceAs(t, ds, admin, "GET", "debug/capture", nil).Body, &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.Queries) != 1 || strings.Contains(bundle.Queries[0].Error, "alice") || strings.Contains(bundle.Queries[0].Error, "123456789") {
+		t.Errorf("expected the captured error to be redacted, got %+v", bundle.Queries)
+	}
+}
+
+func TestDebugCaptureRequiresOrgAdmin(t *testing.T) {
+	ds := &Datasource{transport: &fakeTransport{}}
+	editor := &backend.User{Login: "editor", Role: "Editor"}
+	for _, method := range []string{"POST", "GET", "DELETE"} {
+		if resp := callResourceAs(t, ds, editor, method, "debug/capture", nil); resp.Status != 403 {
+			t.Errorf("%s by an editor: got status %d, want 403", method, resp.Status)
+		}
+		if resp := callResource(t, ds, method, "debug/capture"); resp.Status != 403 {
+			t.Errorf("%s without a user: got status %d, want 403", method, resp.Status)
+		}
+	}
+}
+
+func TestRedactValues(t *testing.T) {
+	for in, want := range map[string]string{
+		"SELECT col1 FROM t2 WHERE id = 42 AND x > -1.5e3": "SELECT col1 FROM t2 WHERE id = *** AND x > -***",
+		"LIMIT 10":           "LIMIT ***",
+		`"col 7" = 'v' + .5`: `"col 7" = '***' + ***`,
+	} {
+		if got := redactValues(in); got != want {
+			t.Errorf("redactValues(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
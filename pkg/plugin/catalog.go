@@ -0,0 +1,157 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// Catalogs change rarely compared to how often dashboards with navigation
+// variables are loaded, so their listings are cached.
+const (
+	catalogCacheTTL     = 5 * time.Minute
+	catalogCacheEntries = 100
+)
+
+// Metadata functions of template variable queries.
+const (
+	catalogDatabases = "databases"
+	catalogSchemas   = "schemas"
+	catalogTables    = "tables"
+)
+
+// catalogQueryPattern matches a template variable query calling a metadata
+// function, such as schemas(sales) or tables('web'), instead of SQL.
+var catalogQueryPattern = regexp.MustCompile(`(?is)^\s*(databases|schemas|tables)\s*\(\s*(.*?)\s*\)\s*;?\s*$`)
+
+// catalogQuery is a parsed metadata function call. Arg is the database of
+// schemas() or the schema of tables(), empty for their defaults.
+type catalogQuery struct {
+	Function string
+	Arg      string
+}
+
+// parseCatalogQuery returns the metadata function that a query text calls.
+// The argument may be quoted, as dashboard variables often are.
+func parseCatalogQuery(text string) (catalogQuery, bool) {
+	match := catalogQueryPattern.FindStringSubmatch(text)
+	if match == nil {
+		return catalogQuery{}, false
+	}
+	arg := match[2]
+	if len(arg) >= 2 && (arg[0] == '\'' || arg[0] == '"') && arg[len(arg)-1] == arg[0] {
+		quote := arg[:1]
+		arg = strings.ReplaceAll(arg[1:len(arg)-1], quote+quote, quote)
+	}
+	return catalogQuery{Function: strings.ToLower(match[1]), Arg: arg}, true
+}
+
+// catalogStatement returns the statement listing the names asked for.
+// tables() without a schema lists the tables of the default schema, or else
+// every table qualified by its schema.
+func (q catalogQuery) catalogStatement(defaultSchema string) (string, error) {
+	switch q.Function {
+	case catalogDatabases:
+		if q.Arg != "" {
+			return "", fmt.Errorf("databases() takes no argument")
+		}
+		return "SELECT name FROM sys.databases ORDER BY name", nil
+	case catalogSchemas:
+		return "SELECT DISTINCT table_schema FROM information_schema.tables ORDER BY table_schema", nil
+	case catalogTables:
+		schema := q.Arg
+		if schema == "" {
+			schema = defaultSchema
+		}
+		if schema == "" {
+			return "SELECT table_schema || '.' || table_name FROM information_schema.tables ORDER BY 1", nil
+		}
+		return "SELECT table_name FROM information_schema.tables WHERE table_schema = " +
+			stringLiteral(schema) + " ORDER BY table_name", nil
+	default:
+		return "", fmt.Errorf("unknown metadata function %s()", q.Function)
+	}
+}
+
+// catalogResponse answers a template variable query calling a metadata
+// function with a frame of names, from the cache when they were listed
+// recently.
+func (d *Datasource) catalogResponse(ctx context.Context, qm queryModel, q catalogQuery) backend.DataResponse {
+	statement, err := q.catalogStatement(d.settings.DefaultSchema)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	database := ""
+	if q.Function == catalogSchemas {
+		database = q.Arg
+	}
+	transport, err := d.catalogTransport(qm.Environment, database)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	key := cacheScope(ctx) + qm.Environment + "\x00" + database + "\x00" + statement
+	var names []string
+	var ok bool
+	if d.catalogCache != nil {
+		names, _, ok = d.catalogCache.get(key)
+	}
+	if !ok {
+		result, err := transport.Execute(ctx, statement)
+		if err == nil {
+			err = result.decodeRows()
+		}
+		if err != nil {
+			backend.Logger.Error("Metadata variable query failed", "function", q.Function, "error", err.Error())
+			return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("%s() failed: %v", q.Function, err))
+		}
+		names = make([]string, 0, len(result.Rows))
+		for _, row := range result.Rows {
+			if len(row) > 0 && row[0] != nil {
+				names = append(names, stringify(row[0]))
+			}
+		}
+		if d.catalogCache != nil {
+			d.catalogCache.set(key, names)
+		}
+	}
+
+	frame := data.NewFrame(q.Function, data.NewField(strings.TrimSuffix(q.Function, "s"), nil, names))
+	setFrameType(frame, data.FrameTypeTable, data.VisTypeTable)
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// catalogTransport returns the transport listing the metadata of database in
+// the environment. Databases other than the configured one are reached by a
+// REST transport sharing the HTTP client of the datasource.
+func (d *Datasource) catalogTransport(environment, database string) (QueryTransport, error) {
+	transport, err := d.environmentTransport(environment)
+	if err != nil {
+		return nil, err
+	}
+	settings := d.settings
+	for _, env := range d.settings.Environments {
+		if env.Name == environment {
+			settings = environmentSettings(d.settings, env)
+		}
+	}
+	if database == "" || database == settings.Database {
+		return transport, nil
+	}
+	if settings.Transport != models.TransportREST || d.httpClient == nil {
+		return nil, fmt.Errorf("schemas of database %s can only be listed through the REST API", database)
+	}
+	settings.Database = database
+	rest := newRESTTransport(settings, d.httpClient)
+	rest.discovery = d.environmentDiscovery(environment)
+	if d.sessions != nil {
+		rest.sessions = d.sessions
+	}
+	return rest, nil
+}
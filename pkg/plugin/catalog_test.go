@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/ocient/ocient-datasource/pkg/internal/fakeocient"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+func TestParseCatalogQuery(t *testing.T) {
+	tests := []struct {
+		text string
+		want catalogQuery
+		ok   bool
+	}{
+		{"databases()", catalogQuery{Function: "databases"}, true},
+		{" Schemas( sales ) ;", catalogQuery{Function: "schemas", Arg: "sales"}, true},
+		{"tables('it''s')", catalogQuery{Function: "tables", Arg: "it's"}, true},
+		{`tables("web")`, catalogQuery{Function: "tables", Arg: "web"}, true},
+		{"SELECT * FROM tables()", catalogQuery{}, false},
+		{"columns(t)", catalogQuery{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseCatalogQuery(tt.text)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%q: got %+v, %v, want %+v, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestQueryDataCatalog(t *testing.T) {
+	transport := &statementTransport{results: map[string]*QueryResult{
+		"SELECT table_name FROM information_schema.tables WHERE table_schema = 'web'": {
+			Columns: []Column{{Name: "table_name", Type: "VARCHAR"}},
+			Rows:    [][]interface{}{{"requests"}, {"sessions"}},
+		},
+	}}
+	ds := Datasource{transport: transport, catalogCache: newTTLCache[[]string](catalogCacheTTL, catalogCacheEntries)}
+
+	for i := 0; i < 2; i++ {
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"queryText": "tables('web')"}`)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := resp.Responses["A"]
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		field := res.Frames[0].Fields[0]
+		if field.Name != "table" || field.Len() != 2 || field.At(1) != "sessions" {
+			t.Fatalf("unexpected tables %v", field)
+		}
+	}
+	if len(transport.statements) != 1 {
+		t.Errorf("expected the tables to be listed once, got %q", transport.statements)
+	}
+}
+
+func TestCatalogTransportOtherDatabase(t *testing.T) {
+	server := fakeocient.NewServer(fakeocient.WithDatasets(fakeocient.Dataset{
+		Match: "information_schema.tables",
+		Rows:  []map[string]interface{}{{"table_schema": "public"}},
+	}))
+	defer server.Close()
+	settings := models.PluginSettings{Database: "db", Transport: models.TransportREST, Secrets: &models.SecretPluginSettings{}}
+	if err := useFakeServer(&settings, server); err != nil {
+		t.Fatal(err)
+	}
+	client, err := newHTTPClient(settings, httpclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := Datasource{settings: settings, transport: newRESTTransport(settings, client), httpClient: client}
+
+	res := ds.catalogResponse(context.Background(), queryModel{}, catalogQuery{Function: catalogSchemas, Arg: "archive"})
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if requests := server.Requests(); len(requests) != 1 || requests[0].Database != "archive" {
+		t.Errorf("expected the schemas to be listed in the archive database, got %+v", requests)
+	}
+}
@@ -0,0 +1,107 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// errChaosFailure is returned for statements failed by the chaos transport.
+var errChaosFailure = errors.New("chaos: injected failure")
+
+// chaosTransport wraps another transport and injects failures, latency and
+// corrupted results according to the chaos settings.
+type chaosTransport struct {
+	next     QueryTransport
+	settings models.ChaosSettings
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newChaosTransport(next QueryTransport, settings models.ChaosSettings) *chaosTransport {
+	seed := settings.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &chaosTransport{
+		next:     next,
+		settings: settings,
+		rand:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// roll returns true with the given probability.
+func (t *chaosTransport) roll(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rand.Float64() < probability
+}
+
+func (t *chaosTransport) latency() time.Duration {
+	d := time.Duration(t.settings.LatencyMs) * time.Millisecond
+	if t.settings.LatencyJitterMs > 0 {
+		t.mu.Lock()
+		d += time.Duration(t.rand.Intn(t.settings.LatencyJitterMs)) * time.Millisecond
+		t.mu.Unlock()
+	}
+	return d
+}
+
+func (t *chaosTransport) Execute(ctx context.Context, statement string) (*QueryResult, error) {
+	if d := t.latency(); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("error executing query: %w", ctx.Err())
+		}
+	}
+
+	if t.roll(t.settings.FailureRate) {
+		return nil, fmt.Errorf("error executing query: %w", errChaosFailure)
+	}
+
+	result, err := t.next.Execute(ctx, statement)
+	if err != nil || !t.roll(t.settings.MalformedRate) {
+		return result, err
+	}
+	return t.corrupt(result), nil
+}
+
+// corrupt returns a copy of the result in which every row has one value replaced
+// with a value of the wrong type or is cut short, imitating a malformed response.
+func (t *chaosTransport) corrupt(result *QueryResult) *QueryResult {
+	if err := result.decodeRows(); err != nil {
+		return result
+	}
+	corrupted := &QueryResult{QueryID: result.QueryID, Columns: result.Columns, Rows: make([][]interface{}, len(result.Rows))}
+	garbage := []interface{}{"chaos", float64(-1), true, nil, []interface{}{"chaos"}, map[string]interface{}{"chaos": true}}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, row := range result.Rows {
+		copied := append([]interface{}(nil), row...)
+		if len(copied) > 0 {
+			col := t.rand.Intn(len(copied))
+			if n := t.rand.Intn(len(garbage) + 1); n == len(garbage) {
+				copied = copied[:col]
+			} else {
+				copied[col] = garbage[n]
+			}
+		}
+		corrupted.Rows[i] = copied
+	}
+	return corrupted
+}
+
+func (t *chaosTransport) Close() error {
+	return t.next.Close()
+}
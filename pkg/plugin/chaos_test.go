@@ -0,0 +1,70 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ocient/ocient-datasource/pkg/internal/fakeocient"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+func TestChaosTransportFailure(t *testing.T) {
+	next := &fakeTransport{result: &QueryResult{}}
+	transport := newChaosTransport(next, models.ChaosSettings{FailureRate: 1, Seed: 1})
+
+	_, err := transport.Execute(context.Background(), "SELECT 1")
+	if !errors.Is(err, errChaosFailure) {
+		t.Fatalf("expected injected failure, got %v", err)
+	}
+	if len(next.statements) != 0 {
+		t.Fatal("failed statements must not reach the wrapped transport")
+	}
+}
+
+func TestChaosTransportMalformed(t *testing.T) {
+	rows := [][]interface{}{{float64(1), "x"}, {float64(2), "y"}}
+	next := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "a", Type: "DOUBLE"}, {Name: "b", Type: "VARCHAR"}},
+		Rows:    rows,
+	}}
+	transport := newChaosTransport(next, models.ChaosSettings{MalformedRate: 1, Seed: 1})
+
+	result, err := transport.Execute(context.Background(), "SELECT a, b FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(result.Rows, rows) {
+		t.Fatal("expected corrupted rows")
+	}
+	if rows[0][0] != float64(1) || len(rows[0]) != 2 {
+		t.Fatal("the wrapped result must not be modified")
+	}
+	if _, err := convertToDataFrames(result, conversionOptions{}); err != nil {
+		t.Fatalf("conversion must tolerate corrupted rows: %v", err)
+	}
+}
+
+func TestChaosFailureRetried(t *testing.T) {
+	transport, server := newFakeRESTTransport(t, fakeocient.WithDatasets(fakeocient.Dataset{
+		Match: "from t",
+		Rows:  []map[string]interface{}{{"a": float64(1)}},
+	}))
+	settings := transport.settings
+	// The first roll of seed 6 fails the statement, the second lets it through
+	settings.Chaos = &models.ChaosSettings{FailureRate: 0.5, Seed: 6}
+	settings.Retry = &models.RetrySettings{MaxAttempts: 3, InitialBackoffMs: 1, MaxBackoffMs: 1}
+	settings.CircuitBreaker = &models.CircuitBreakerSettings{FailureThreshold: 5, CooldownSeconds: 1}
+
+	wrapped, err := newTransport(settings, transport.client, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrapped.Execute(context.Background(), "SELECT a FROM t"); err != nil {
+		t.Fatalf("expected the injected failure to be retried, got %v", err)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("Ocient received %d statements, want 1", n)
+	}
+}
@@ -0,0 +1,99 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// CircuitOpenError is returned without contacting Ocient while the circuit
+// breaker pauses statements to an unreachable cluster.
+type CircuitOpenError struct {
+	Failures int
+	Until    time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("datasource unavailable: the last %d statements failed to reach Ocient, queries are paused until %s",
+		e.Failures, e.Until.UTC().Format(time.TimeOnly))
+}
+
+// circuitTransport wraps another transport and fails statements fast once
+// enough of them in a row failed with a transient error, so that dashboards
+// with many panels don't pile onto a cluster that is down. After the
+// cooldown a single statement is let through; its success closes the
+// circuit and its failure opens it for another cooldown.
+type circuitTransport struct {
+	next        QueryTransport
+	settings    models.CircuitBreakerSettings
+	vendorCodes []int
+	// now is replaced by tests
+	now func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitTransport(next QueryTransport, settings models.CircuitBreakerSettings, vendorCodes []int) *circuitTransport {
+	return &circuitTransport{next: next, settings: settings, vendorCodes: vendorCodes, now: time.Now}
+}
+
+func (t *circuitTransport) Execute(ctx context.Context, statement string) (*QueryResult, error) {
+	if err := t.allow(); err != nil {
+		return nil, err
+	}
+	result, err := t.next.Execute(ctx, statement)
+	t.record(err)
+	return result, err
+}
+
+func (t *circuitTransport) Close() error {
+	return t.next.Close()
+}
+
+// allow returns an error when the circuit is open, or half open with its
+// trial statement still running.
+func (t *circuitTransport) allow() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failures < t.settings.FailureThreshold {
+		return nil
+	}
+	until := t.openedAt.Add(time.Duration(t.settings.CooldownSeconds) * time.Second)
+	if t.probing || t.now().Before(until) {
+		return &CircuitOpenError{Failures: t.failures, Until: until}
+	}
+	t.probing = true
+	return nil
+}
+
+// record counts the outcome of a statement. Errors of the statement itself,
+// such as a syntax error, show that Ocient is reachable; cancelled and timed
+// out statements tell nothing.
+func (t *circuitTransport) record(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.probing = false
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	if err == nil || !isTransientError(err, t.vendorCodes) {
+		if t.failures >= t.settings.FailureThreshold {
+			backend.Logger.Info("Ocient is reachable again, resuming queries")
+		}
+		t.failures = 0
+		return
+	}
+	t.failures++
+	if t.failures >= t.settings.FailureThreshold {
+		backend.Logger.Warn("Ocient is unreachable, pausing queries", "failures", t.failures, "cooldownSeconds", t.settings.CooldownSeconds)
+		t.openedAt = t.now()
+	}
+}
@@ -0,0 +1,68 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+func TestCircuitTransport(t *testing.T) {
+	next := &failingTransport{errs: []error{syscall.ECONNREFUSED, syscall.ECONNREFUSED, syscall.ECONNREFUSED}}
+	transport := newCircuitTransport(next, models.CircuitBreakerSettings{FailureThreshold: 2, CooldownSeconds: 30}, nil)
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	transport.now = func() time.Time { return now }
+	execute := func() error {
+		_, err := transport.Execute(context.Background(), "SELECT 1")
+		return err
+	}
+
+	// Two failures in a row open the circuit
+	_ = execute()
+	_ = execute()
+	var circuitErr *CircuitOpenError
+	if err := execute(); !errors.As(err, &circuitErr) || len(next.statements) != 2 {
+		t.Fatalf("expected the circuit to be open, got %v after %d statements", err, len(next.statements))
+	}
+
+	// After the cooldown a trial statement fails and opens it again
+	now = now.Add(31 * time.Second)
+	if err := execute(); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("expected the trial statement to reach Ocient, got %v", err)
+	}
+	if err := execute(); !errors.As(err, &circuitErr) {
+		t.Fatalf("expected the circuit to open again, got %v", err)
+	}
+
+	// A successful trial closes it
+	now = now.Add(31 * time.Second)
+	if err := execute(); err != nil {
+		t.Fatal(err)
+	}
+	if err := execute(); err != nil || len(next.statements) != 5 {
+		t.Fatalf("expected the circuit to be closed, got %v after %d statements", err, len(next.statements))
+	}
+}
+
+func TestCircuitTransportStatementErrors(t *testing.T) {
+	notFound := &StatusError{Status: OcientStatus{Reason: "table not found", SQLState: "42S02"}}
+	next := &failingTransport{errs: []error{syscall.ECONNRESET, notFound, syscall.ECONNRESET}}
+	transport := newCircuitTransport(next, models.CircuitBreakerSettings{FailureThreshold: 2, CooldownSeconds: 30}, nil)
+	ds := Datasource{transport: transport}
+
+	for i := 0; i < 4; i++ {
+		resp, _ := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"queryText": "SELECT 1"}`)}},
+		})
+		if resp.Responses["A"].Status == backend.StatusBadGateway {
+			t.Fatalf("query %d: the circuit opened although Ocient answered in between", i+1)
+		}
+	}
+	if len(next.statements) != 4 {
+		t.Errorf("ran %d statements, want 4", len(next.statements))
+	}
+}
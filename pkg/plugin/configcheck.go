@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// Outcomes of a configuration check. A warning counts half towards the score.
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// maxSaneQueryTimeout is the longest interactive timeout considered sane;
+// dashboards waiting longer than this are better served by reporting mode.
+const maxSaneQueryTimeout = 600
+
+// configCheck is the outcome of one best practice check.
+type configCheck struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	Weight int    `json:"weight"`
+	// Hint tells the admin how to improve a check that did not pass
+	Hint string `json:"hint,omitempty"`
+}
+
+// configReport is the response of the /config-check resource.
+type configReport struct {
+	// Score is the weighted share of passed checks, from 0 to 100
+	Score  int           `json:"score"`
+	Checks []configCheck `json:"checks"`
+}
+
+// checkConfig evaluates the settings against best practices.
+func (d *Datasource) checkConfig() configReport {
+	s := d.settings
+	var checks []configCheck
+	add := func(id, title string, weight int, status, hint string) {
+		check := configCheck{ID: id, Title: title, Status: status, Weight: weight}
+		if status != checkPass {
+			check.Hint = hint
+		}
+		checks = append(checks, check)
+	}
+	passIf := func(ok bool, otherwise string) string {
+		if ok {
+			return checkPass
+		}
+		return otherwise
+	}
+
+	add("tls", "TLS certificate verification", 30, passIf(!s.InsecureSkipVerify, checkFail),
+		"Disable Skip TLS Verify and trust the Ocient certificate, adding its CA certificate if it comes from an internal CA and setting the TLS server name if Ocient is reached by IP; connections can be intercepted otherwise")
+	if s.Scheme == models.SchemeHTTP {
+		add("scheme", "Encrypted connection", 30, checkFail,
+			"Use the https scheme outside of development clusters; over plain HTTP credentials and results are sent unencrypted")
+	}
+	add("maxRows", "Interactive row limit", 15, passIf(s.MaxRows > 0, checkWarn),
+		"Set maxRows so that a missing WHERE clause can't load a whole table into the browser")
+	add("timeout", "Interactive query timeout", 15,
+		passIf(s.QueryTimeout > 0 && s.QueryTimeout <= maxSaneQueryTimeout, checkWarn),
+		fmt.Sprintf("Set queryTimeoutSeconds between 1 and %d; use reporting mode for long running exports", maxSaneQueryTimeout))
+	add("maxColumns", "Column limit", 5, passIf(s.MaxColumns <= models.DefaultMaxColumns, checkWarn),
+		fmt.Sprintf("Frames wider than %d columns make the browser unresponsive", models.DefaultMaxColumns))
+	if s.PublicDashboards != nil {
+		add("publicCredentials", "Restricted public dashboard credentials", 15,
+			passIf(s.Secrets != nil && s.Secrets.PublicUsername != "", checkFail),
+			"Public dashboards are enabled but no restricted credentials are set, so their queries fail")
+	}
+	add("chaos", "Fault injection disabled", 10, passIf(s.Chaos == nil, checkFail),
+		"Remove the chaos settings, they make queries fail on purpose")
+	if _, err := d.sessionLocation(""); err != nil {
+		add("timezone", "Session timezone", 10, checkFail, err.Error())
+	}
+
+	return configReport{Score: configScore(checks), Checks: checks}
+}
+
+// configScore returns the weighted share of passed checks as a percentage.
+func configScore(checks []configCheck) int {
+	total, earned := 0, 0.0
+	for _, check := range checks {
+		total += check.Weight
+		switch check.Status {
+		case checkPass:
+			earned += float64(check.Weight)
+		case checkWarn:
+			earned += float64(check.Weight) / 2
+		}
+	}
+	if total == 0 {
+		return 100
+	}
+	return int(math.Round(earned * 100 / float64(total)))
+}
+
+// handleConfigCheck serves the configuration report.
+func (d *Datasource) handleConfigCheck(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, d.checkConfig())
+}
@@ -0,0 +1,45 @@
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+func TestConfigCheck(t *testing.T) {
+	ds := &Datasource{settings: models.PluginSettings{
+		InsecureSkipVerify: true,
+		MaxColumns:         models.DefaultMaxColumns,
+		Transport:          models.TransportREST,
+		Secrets:            &models.SecretPluginSettings{},
+	}}
+
+	var report configReport
+	if err := json.Unmarshal(callResource(t, ds, "GET", "config-check").Body, &report); err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[string]string)
+	for _, check := range report.Checks {
+		statuses[check.ID] = check.Status
+		if check.Status != checkPass && check.Hint == "" {
+			t.Errorf("check %s has no hint", check.ID)
+		}
+	}
+	if statuses["tls"] != checkFail || statuses["maxColumns"] != checkPass || statuses["timeout"] != checkWarn {
+		t.Errorf("unexpected check results %v", statuses)
+	}
+	if report.Score <= 0 || report.Score >= 100 {
+		t.Errorf("expected a partial score, got %d", report.Score)
+	}
+
+	ds.settings = models.PluginSettings{
+		MaxRows:      10000,
+		QueryTimeout: 60,
+		MaxColumns:   100,
+		Transport:    models.TransportNative,
+	}
+	if report := ds.checkConfig(); report.Score != 100 {
+		t.Errorf("expected a perfect score, got %d: %+v", report.Score, report.Checks)
+	}
+}
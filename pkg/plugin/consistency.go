@@ -0,0 +1,41 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"time"
+)
+
+// Retries of statements returning no rows for queries with the retryEmpty
+// option. Rows loaded by streaming ingest take a moment to become visible to
+// queries, so a panel refreshed right after a load can briefly find nothing.
+const (
+	emptyResultRetries    = 3
+	emptyResultRetryDelay = 500 * time.Millisecond
+)
+
+// empty reports whether the result has no rows, without decoding them.
+func (r *QueryResult) empty() bool {
+	if r.encodedRows == nil {
+		return len(r.Rows) == 0
+	}
+	rows := bytes.TrimSpace(r.encodedRows)
+	return bytes.Equal(rows, []byte("[]")) || bytes.Equal(rows, []byte("null"))
+}
+
+// executeRetryingEmpty runs a read-only statement and runs it again, a few
+// times and briefly apart, while it returns no rows. The last result is
+// returned when the rows never show up, and the number of retries made.
+func executeRetryingEmpty(ctx context.Context, transport QueryTransport, statement string) (*QueryResult, int, error) {
+	result, err := transport.Execute(ctx, statement)
+	retries := 0
+	for ; err == nil && result.empty() && retries < emptyResultRetries; retries++ {
+		select {
+		case <-ctx.Done():
+			return result, retries, nil
+		case <-time.After(emptyResultRetryDelay):
+		}
+		result, err = transport.Execute(ctx, statement)
+	}
+	return result, retries, err
+}
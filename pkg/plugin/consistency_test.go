@@ -0,0 +1,64 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// sequenceTransport returns its results in turn, the last one repeatedly.
+type sequenceTransport struct {
+	results    []*QueryResult
+	statements []string
+}
+
+func (s *sequenceTransport) Execute(_ context.Context, statement string) (*QueryResult, error) {
+	s.statements = append(s.statements, statement)
+	result := s.results[min(len(s.statements), len(s.results))-1]
+	return result, nil
+}
+
+func (s *sequenceTransport) Close() error {
+	return nil
+}
+
+func TestQueryDataRetryEmpty(t *testing.T) {
+	columns := []Column{{Name: "value", Type: "INT"}}
+	transport := &sequenceTransport{results: []*QueryResult{
+		{Columns: columns, encodedRows: json.RawMessage(" [] ")},
+		{Columns: columns, Rows: [][]interface{}{{float64(1)}}},
+	}}
+	ds := Datasource{transport: transport}
+
+	body, _ := json.Marshal(map[string]interface{}{"queryText": "SELECT value FROM ingest.events", "retryEmpty": true})
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: body}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := resp.Responses["A"]
+	if r.Error != nil {
+		t.Fatal(r.Error)
+	}
+	if len(transport.statements) != 2 {
+		t.Errorf("ran %d statements, want 2", len(transport.statements))
+	}
+	if rows := r.Frames[0].Rows(); rows != 1 {
+		t.Errorf("got %d rows, want 1", rows)
+	}
+
+	// Statements that change data are never run again
+	transport.statements = nil
+	body, _ = json.Marshal(map[string]interface{}{"queryText": "DELETE FROM ingest.events", "retryEmpty": true})
+	if _, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: body}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.statements) != 1 {
+		t.Errorf("ran %d statements, want 1", len(transport.statements))
+	}
+}
@@ -0,0 +1,775 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Define the Ocient specific timestamp format (YYYY-MM-DD HH:MM:SS.SSSSSSSSS)
+const ocientTimestampFormat = "2006-01-02 15:04:05.999999999"
+
+// timestampLayouts are tried in order when parsing timestamp strings.
+var timestampLayouts = []string{
+	ocientTimestampFormat,
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+}
+
+// fieldKind is the type of Grafana field a column is converted into.
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindFloat
+	kindInt
+	kindBool
+	kindTime
+	kindJSON
+	// kindBinary columns are rendered according to the binary format option
+	kindBinary
+	// kindDuration columns hold intervals as nanoseconds, see toDuration
+	kindDuration
+)
+
+// String names the kind in conversion errors.
+func (k fieldKind) String() string {
+	switch k {
+	case kindFloat:
+		return "number"
+	case kindInt:
+		return "integer"
+	case kindBool:
+		return "boolean"
+	case kindTime:
+		return "timestamp"
+	case kindJSON:
+		return "JSON"
+	case kindBinary:
+		return "binary"
+	case kindDuration:
+		return "duration"
+	default:
+		return "string"
+	}
+}
+
+// conversionOptions are the per-query settings that control how a result is
+// converted into a frame.
+type conversionOptions struct {
+	// ArrayMode selects how array columns are converted, see arrayModeJSON.
+	ArrayMode string
+	// KeepStructsAsJSON returns tuple/struct columns as JSON strings instead of
+	// flattening them into one field per member.
+	KeepStructsAsJSON bool
+	// Distinct removes exact duplicate rows before conversion.
+	Distinct bool
+	// NumericIPs adds a numeric companion field for every IP address column.
+	NumericIPs bool
+	// BinaryFormat selects how binary columns are rendered, see binaryFormatHex.
+	BinaryFormat string
+	// MaxRows truncates the frame to this many rows when positive.
+	MaxRows int
+	// Location is the session timezone of timestamps without zone information,
+	// UTC when nil.
+	Location *time.Location
+	// TimestampLayouts are extra layouts tried after the known ones.
+	TimestampLayouts []string
+	// TimeColumn names the column used as the time axis. When set, untyped
+	// columns are no longer guessed to be timestamps.
+	TimeColumn string
+	// EpochUnit is the unit of numeric time values since the epoch,
+	// milliseconds when zero.
+	EpochUnit time.Duration
+	// Strict fails the conversion on the first value that can't be converted
+	// to its column type, instead of writing the zero value.
+	Strict bool
+	// NullPolicy selects what null and unconvertible values become, see
+	// nullPolicyZero. Zero values are used when empty.
+	NullPolicy string
+	// NonFiniteAsNull treats NaN and infinite numbers as nulls, which then
+	// follow NullPolicy, instead of returning them as they are.
+	NonFiniteAsNull bool
+	// JSONColumn splits a column of JSON strings into a field per key.
+	JSONColumn *jsonColumnOptions
+	// Extractions add fields extracted from string columns by regular
+	// expressions.
+	Extractions []extraction
+}
+
+// kindForSQLType maps a declared Ocient SQL type onto a field kind. The second
+// return value is false for types the backend does not know about.
+func kindForSQLType(sqlType string) (fieldKind, bool) {
+	if _, ok := arrayElementType(sqlType); ok {
+		return kindJSON, true
+	}
+
+	switch baseSQLType(sqlType) {
+	case "TINYINT", "SMALLINT", "INT", "INTEGER", "BIGINT", "BYTE", "SHORT", "LONG":
+		return kindInt, true
+	case "REAL", "FLOAT", "DOUBLE", "DECIMAL", "NUMERIC":
+		return kindFloat, true
+	case "BOOLEAN", "BOOL":
+		return kindBool, true
+	case "TIMESTAMP", "DATETIME", "DATE", "TIME":
+		return kindTime, true
+	case "CHAR", "VARCHAR", "STRING", "TEXT", "CLOB", "UUID", "IP", "IPV4", "IPV6":
+		return kindString, true
+	case "ST_POINT", "ST_LINESTRING", "ST_POLYGON", "POINT", "GEOMETRY", "GEOGRAPHY":
+		// Geospatial values are well-known text
+		return kindString, true
+	case "BINARY", "VARBINARY", "BLOB", "BYTES":
+		return kindBinary, true
+	case "INTERVAL", "DURATION":
+		return kindDuration, true
+	default:
+		return kindString, false
+	}
+}
+
+// baseSQLType returns the upper case type name without length, precision or
+// modifiers, e.g. VARCHAR for varchar(255) and DECIMAL for DECIMAL(10, 2).
+func baseSQLType(sqlType string) string {
+	t := strings.ToUpper(strings.TrimSpace(sqlType))
+	if i := strings.IndexAny(t, "( "); i >= 0 {
+		t = t[:i]
+	}
+	return t
+}
+
+// sniffKind guesses the field kind from a sample value. It is only used when the
+// server did not report a column type.
+func sniffKind(v interface{}, parser timestampParser) fieldKind {
+	switch val := v.(type) {
+	case float64, json.Number:
+		return kindFloat
+	case bool:
+		return kindBool
+	case []interface{}:
+		return kindJSON
+	case []byte:
+		return kindBinary
+	case time.Time:
+		return kindTime
+	case string:
+		// Try to detect timestamp strings to convert them properly
+		if _, ok := parser.parse(val); ok {
+			return kindTime
+		}
+		return kindString
+	default:
+		// Default to string for unknown types
+		return kindString
+	}
+}
+
+// timestampParser parses timestamp strings with the known layouts followed by
+// the extra layouts configured for the datasource. Timestamps without zone
+// information are in loc, the session timezone. Numeric timestamps count
+// epochUnit since the epoch.
+type timestampParser struct {
+	layouts   []string
+	loc       *time.Location
+	epochUnit time.Duration
+}
+
+func newTimestampParser(opts conversionOptions) timestampParser {
+	p := timestampParser{layouts: timestampLayouts, loc: opts.Location, epochUnit: opts.EpochUnit}
+	if p.epochUnit <= 0 {
+		p.epochUnit = time.Millisecond
+	}
+	if len(opts.TimestampLayouts) > 0 {
+		p.layouts = append(append([]string(nil), timestampLayouts...), opts.TimestampLayouts...)
+	}
+	if p.loc == nil {
+		p.loc = time.UTC
+	}
+	return p
+}
+
+// validTimestampLayout reports whether layout is a usable Go time layout, that
+// is one with at least one layout element that parses the timestamps it formats.
+func validTimestampLayout(layout string) bool {
+	ref := time.Date(2024, 12, 31, 23, 59, 58, 123456789, time.UTC)
+	formatted := ref.Format(layout)
+	if formatted == layout {
+		return false
+	}
+	_, err := time.Parse(layout, formatted)
+	return err == nil
+}
+
+// parse parses s with the first layout that matches.
+func (p timestampParser) parse(s string) (time.Time, bool) {
+	for _, layout := range p.layouts {
+		if t, err := time.ParseInLocation(layout, s, p.loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// toFloat64 converts a decoded numeric value to float64. Numbers outside the
+// float64 range saturate to ±Inf rather than failing the whole response, and
+// the strings NaN and Infinity are converted too.
+func toFloat64(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case json.Number:
+		f, err := strconv.ParseFloat(string(val), 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return 0, false
+		}
+		return f, true
+	case int64:
+		return float64(val), true
+	case string:
+		return parseNonFinite(val)
+	default:
+		return 0, false
+	}
+}
+
+// parseNonFinite parses the NaN and infinity spellings that Ocient and other
+// databases use for values JSON numbers can't represent.
+func parseNonFinite(s string) (float64, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "nan", "-nan":
+		return math.NaN(), true
+	case "infinity", "+infinity", "inf", "+inf":
+		return math.Inf(1), true
+	case "-infinity", "-inf":
+		return math.Inf(-1), true
+	default:
+		return 0, false
+	}
+}
+
+// isNonFinite reports whether v is NaN or infinite, as a number or a string.
+func isNonFinite(v interface{}) bool {
+	switch val := v.(type) {
+	case float64:
+		return math.IsNaN(val) || math.IsInf(val, 0)
+	case string:
+		_, ok := parseNonFinite(val)
+		return ok
+	default:
+		return false
+	}
+}
+
+// toInt64 converts a decoded numeric value to int64. Integral json.Numbers are
+// converted exactly; other values are truncated and clamped to the int64 range.
+func toInt64(v interface{}) (int64, bool) {
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+	}
+	if i, ok := v.(int64); ok {
+		return i, true
+	}
+	f, ok := toFloat64(v)
+	if !ok || math.IsNaN(f) {
+		return 0, false
+	}
+	switch {
+	case f >= math.MaxInt64:
+		return math.MaxInt64, true
+	case f <= math.MinInt64:
+		return math.MinInt64, true
+	default:
+		return int64(f), true
+	}
+}
+
+// columnKind returns the field kind for a result column, falling back to the
+// first non-null value when no type was declared.
+func columnKind(result *QueryResult, index int, parser timestampParser) fieldKind {
+	if kind, ok := kindForSQLType(result.Columns[index].Type); ok {
+		return kind
+	}
+	for _, row := range result.Rows {
+		if index < len(row) && row[index] != nil {
+			return sniffKind(row[index], parser)
+		}
+	}
+	return kindString
+}
+
+// designatedTimeKind applies an explicit time column to the kind of col: the
+// time column is always a timestamp and the guess that another untyped column
+// holds timestamps is dropped.
+func designatedTimeKind(col Column, kind fieldKind, timeColumn string) fieldKind {
+	if col.Name == timeColumn {
+		return kindTime
+	}
+	if _, declared := kindForSQLType(col.Type); kind == kindTime && !declared {
+		return kindString
+	}
+	return kind
+}
+
+// newFieldForKind creates an empty field able to hold values of the given kind,
+// and nulls when nullable is set.
+func newFieldForKind(name string, kind fieldKind, capacity int, nullable bool) *data.Field {
+	if kind == kindDuration {
+		field := newFieldForKind(name, kindInt, capacity, nullable)
+		return field.SetConfig(&data.FieldConfig{Unit: durationUnit})
+	}
+	if nullable {
+		switch kind {
+		case kindFloat:
+			return data.NewField(name, nil, make([]*float64, 0, capacity))
+		case kindInt:
+			return data.NewField(name, nil, make([]*int64, 0, capacity))
+		case kindBool:
+			return data.NewField(name, nil, make([]*bool, 0, capacity))
+		case kindTime:
+			return data.NewField(name, nil, make([]*time.Time, 0, capacity))
+		case kindJSON:
+			return data.NewField(name, nil, make([]*json.RawMessage, 0, capacity))
+		default:
+			return data.NewField(name, nil, make([]*string, 0, capacity))
+		}
+	}
+	switch kind {
+	case kindFloat:
+		return data.NewField(name, nil, make([]float64, 0, capacity))
+	case kindInt:
+		return data.NewField(name, nil, make([]int64, 0, capacity))
+	case kindBool:
+		return data.NewField(name, nil, make([]bool, 0, capacity))
+	case kindTime:
+		return data.NewField(name, nil, make([]time.Time, 0, capacity))
+	case kindJSON:
+		return data.NewField(name, nil, make([]json.RawMessage, 0, capacity))
+	default:
+		return data.NewField(name, nil, make([]string, 0, capacity))
+	}
+}
+
+// convertValue converts v to the value type of the field kind. Values that
+// can't be converted become the zero value of the type and are reported by
+// returning false; null values become the zero value too but always convert.
+func convertValue(kind fieldKind, v interface{}, parser timestampParser) (interface{}, bool) {
+	var out interface{}
+	ok := true
+	switch kind {
+	case kindFloat:
+		out, ok = toFloat64(v)
+	case kindInt:
+		out, ok = toInt64(v)
+	case kindBool:
+		out, ok = v.(bool)
+	case kindDuration:
+		var d time.Duration
+		d, ok = toDuration(v)
+		out = int64(d)
+	case kindTime:
+		var t time.Time
+		if s, isString := v.(string); isString {
+			if t, ok = parser.parse(s); !ok {
+				// Epoch numbers too large for JSON numbers may come as strings
+				t, ok = epochTime(s, parser.epochUnit)
+			}
+		} else if native, isTime := v.(time.Time); isTime {
+			// The native driver decodes timestamps itself
+			t = native
+		} else {
+			t, ok = epochTime(v, parser.epochUnit)
+		}
+		out = t
+	case kindJSON:
+		b, err := json.Marshal(v)
+		if err != nil {
+			b, ok = []byte("null"), false
+		}
+		out = json.RawMessage(b)
+	default:
+		out = stringify(v)
+	}
+	return out, ok || v == nil
+}
+
+// appendConverted appends a value returned by convertValue, or a null when v is
+// nil, to field.
+func appendConverted(field *data.Field, v interface{}) {
+	switch {
+	case !field.Nullable():
+		field.Append(v)
+	case v == nil:
+		field.Extend(1)
+	default:
+		field.Append(pointerTo(v))
+	}
+}
+
+// stringify renders a decoded value for a string field. Nested arrays and objects
+// are rendered as JSON.
+func stringify(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case []interface{}, map[string]interface{}:
+		if b, err := json.Marshal(val); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// conversionChunkSize is the number of rows decoded at a time when a result is
+// converted while its rows are still encoded.
+const conversionChunkSize = 10000
+
+// errMalformedResult is wrapped by the errors of conversions that panicked.
+var errMalformedResult = errors.New("malformed result")
+
+// convertToDataFrames converts the API response into Grafana data frames. Field
+// types follow the column types declared by Ocient. Rows may be shorter or longer
+// than the column list and contain values of any decoded JSON type.
+func convertToDataFrames(result *QueryResult, opts conversionOptions) (frame *data.Frame, err error) {
+	// The conversion must never take the plugin down because of a malformed
+	// response, so any remaining panic is reported as a conversion error.
+	defer func() {
+		if r := recover(); r != nil {
+			frame, err = nil, fmt.Errorf("%w: %v", errMalformedResult, r)
+		}
+	}()
+
+	// The time column may also be one of the keys of the JSON column
+	jsonKey := opts.JSONColumn != nil && strings.HasPrefix(opts.TimeColumn, opts.JSONColumn.Column+".")
+	if opts.TimeColumn != "" && len(result.Columns) > 0 && !hasColumn(result, opts.TimeColumn) && !jsonKey {
+		return nil, fmt.Errorf("time column %q is not in the result", opts.TimeColumn)
+	}
+
+	// Large responses are decoded in chunks straight into the frame fields so the
+	// decoded rows never exist all at once. That needs every column type up front
+	// and no processing that looks at the whole result.
+	if canStreamConversion(result, opts) {
+		builder := newFrameBuilder(result, opts, 0)
+		if err := result.streamRows(conversionChunkSize, builder.appendRows); err != nil {
+			return nil, fmt.Errorf("error parsing response: %w", err)
+		}
+		return builder.finish()
+	}
+
+	if err := result.decodeRows(); err != nil {
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	removed := 0
+	if opts.Distinct {
+		result, removed = removeDuplicateRows(result)
+	}
+	invalidJSON := 0
+	if opts.JSONColumn != nil && opts.JSONColumn.Column != "" {
+		if result, invalidJSON, err = expandJSONColumn(result, *opts.JSONColumn); err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.Extractions) > 0 {
+		if result, err = applyExtractions(result, opts.Extractions); err != nil {
+			return nil, err
+		}
+	}
+	if !opts.KeepStructsAsJSON {
+		result = flattenStructs(result)
+	}
+	if opts.ArrayMode == arrayModeExplode {
+		result = explodeArrays(result)
+	}
+
+	builder := newFrameBuilder(result, opts, len(result.Rows))
+	builder.appendRows(result.Rows)
+	if frame, err = builder.finish(); err != nil {
+		return nil, err
+	}
+
+	if opts.Distinct {
+		appendStat(frame, "Duplicate rows removed", float64(removed))
+	}
+	if invalidJSON > 0 {
+		frame.AppendNotices(invalidJSONNotice(opts.JSONColumn.Column, invalidJSON))
+	}
+
+	return frame, nil
+}
+
+// hasColumn reports whether the result has a column with the given name.
+func hasColumn(result *QueryResult, name string) bool {
+	for _, col := range result.Columns {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// canStreamConversion reports whether a result can be converted chunk by chunk.
+func canStreamConversion(result *QueryResult, opts conversionOptions) bool {
+	if result.encodedRows == nil || opts.Distinct || opts.ArrayMode == arrayModeExplode || opts.JSONColumn != nil || len(opts.Extractions) > 0 {
+		return false
+	}
+	for _, col := range result.Columns {
+		if _, ok := kindForSQLType(col.Type); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// frameBuilder appends rows to the fields of a frame as they are decoded.
+type frameBuilder struct {
+	frame   *data.Frame
+	columns int
+	kinds   []fieldKind
+	// ipFields holds the numeric companions of IP columns by column index
+	ipFields map[int]*data.Field
+	// geoFields holds the latitude and longitude of geospatial columns
+	geoFields map[int][2]*data.Field
+	// binary marks the binary columns, rendered with binaryFormat
+	binary       map[int]bool
+	binaryFormat string
+	// maxRows limits the rows appended when positive; dropped counts the rows
+	// left out because of it
+	maxRows int
+	dropped int
+	parser  timestampParser
+	// rawValues buffers the values of columns that may turn out not to hold a
+	// single type, by column index, so that such a column can be returned as
+	// strings once a value fails to convert
+	rawValues map[int][]interface{}
+	// promoted holds a notice per column that was returned as strings
+	promoted []data.Notice
+	// strict makes the first value that fails to convert an error, kept in err
+	strict bool
+	err    error
+	// nullPolicy selects what null and unconvertible values become; skipped
+	// counts the rows left out by nullPolicySkip
+	nullPolicy string
+	skipped    int
+	// seen counts the rows passed to appendRows, to number rows in errors
+	seen int
+	// nonFiniteAsNull turns NaN and infinite numbers into nulls
+	nonFiniteAsNull bool
+	// raw and values hold the current row before and after conversion
+	raw, values []interface{}
+}
+
+// newFrameBuilder creates the fields for the result columns. Untyped columns are
+// typed from the rows already decoded in result.
+func newFrameBuilder(result *QueryResult, opts conversionOptions, capacity int) *frameBuilder {
+	b := &frameBuilder{
+		frame:           data.NewFrame("response"),
+		columns:         len(result.Columns),
+		kinds:           make([]fieldKind, len(result.Columns)),
+		ipFields:        make(map[int]*data.Field),
+		geoFields:       make(map[int][2]*data.Field),
+		binary:          make(map[int]bool),
+		binaryFormat:    opts.BinaryFormat,
+		maxRows:         opts.MaxRows,
+		parser:          newTimestampParser(opts),
+		rawValues:       make(map[int][]interface{}),
+		strict:          opts.Strict,
+		nullPolicy:      opts.NullPolicy,
+		nonFiniteAsNull: opts.NonFiniteAsNull,
+		raw:             make([]interface{}, len(result.Columns)),
+		values:          make([]interface{}, len(result.Columns)),
+	}
+
+	for i, col := range result.Columns {
+		b.kinds[i] = columnKind(result, i, b.parser)
+		if opts.TimeColumn != "" {
+			b.kinds[i] = designatedTimeKind(col, b.kinds[i], opts.TimeColumn)
+		}
+		if b.kinds[i] == kindBinary {
+			b.binary[i] = true
+			b.kinds[i] = binaryFieldKind(opts.BinaryFormat)
+		}
+		// Timestamps and the types guessed from the first value of untyped
+		// columns can turn out wrong in later rows. Strict conversions fail
+		// rather than falling back to strings.
+		_, declared := kindForSQLType(col.Type)
+		if !b.strict && !b.binary[i] && (b.kinds[i] == kindTime || b.kinds[i] == kindDuration || !declared && promotable(b.kinds[i])) {
+			b.rawValues[i] = make([]interface{}, 0, capacity)
+		}
+		field := newFieldForKind(col.Name, b.kinds[i], capacity, b.nullPolicy == nullPolicyNull)
+		hint := columnTypeHint(result, i)
+		if hint != "" {
+			setTypeHint(field, hint)
+		}
+		// Numeric companions of IP columns are appended after the regular fields
+		if opts.NumericIPs && (hint == typeHintIPv4 || hint == typeHintIPv6) {
+			b.ipFields[i] = newIPNumericField(field.Name, capacity)
+		}
+		if hint == typeHintPoint || hint == typeHintGeometry {
+			b.geoFields[i] = newGeoFields(field.Name, capacity)
+		}
+		b.frame.Fields = append(b.frame.Fields, field)
+	}
+	return b
+}
+
+// appendRows converts and appends rows to the frame. A row is converted
+// completely before it is appended, so the null policy can leave it out.
+func (b *frameBuilder) appendRows(rows [][]interface{}) {
+	for _, row := range rows {
+		if b.err != nil {
+			return
+		}
+		b.seen++
+		if b.maxRows > 0 && b.frame.Rows() >= b.maxRows {
+			b.dropped++
+			continue
+		}
+		skip := false
+		for i := 0; i < b.columns; i++ {
+			var v interface{}
+			if i < len(row) {
+				v = row[i]
+			}
+			if b.binary[i] {
+				v = renderBinary(v, b.binaryFormat)
+			}
+			if b.nonFiniteAsNull && (b.kinds[i] == kindFloat || b.kinds[i] == kindInt) && isNonFinite(v) {
+				v = nil
+			}
+			converted, ok := convertValue(b.kinds[i], v, b.parser)
+			if _, buffered := b.rawValues[i]; buffered && !ok {
+				b.promoteToStrings(i, v)
+				converted, ok = convertValue(kindString, v, b.parser)
+			}
+			if !ok && b.strict {
+				b.err = fmt.Errorf("row %d, column %q: cannot convert %q to a %s",
+					b.seen, b.frame.Fields[i].Name, stringify(v), b.kinds[i])
+				return
+			}
+			if !ok || v == nil {
+				switch b.nullPolicy {
+				case nullPolicyNull:
+					converted = nil
+				case nullPolicySkip:
+					skip = true
+				}
+			}
+			b.raw[i], b.values[i] = v, converted
+		}
+		if skip {
+			b.skipped++
+			continue
+		}
+		for i := 0; i < b.columns; i++ {
+			appendConverted(b.frame.Fields[i], b.values[i])
+			if raw, ok := b.rawValues[i]; ok {
+				b.rawValues[i] = append(raw, b.raw[i])
+			}
+			if numeric, ok := b.ipFields[i]; ok {
+				numeric.Append(ipToFloat64(b.raw[i]))
+			}
+			if geo, ok := b.geoFields[i]; ok {
+				lat, lon := pointCoordinates(b.raw[i])
+				geo[0].Append(lat)
+				geo[1].Append(lon)
+			}
+		}
+	}
+}
+
+// promotable reports whether columns of a kind are returned as strings when a
+// value doesn't convert. Strings always convert and JSON holds any value.
+func promotable(kind fieldKind) bool {
+	return kind == kindFloat || kind == kindInt || kind == kindBool || kind == kindTime || kind == kindDuration
+}
+
+// promoteToStrings turns the column at index into a string column, refilled
+// from the buffered values, after value v failed to convert. A timestamp
+// column with other values would otherwise plot them at 1970, and a column
+// mixing types would silently show zeroes.
+func (b *frameBuilder) promoteToStrings(index int, v interface{}) {
+	old := b.frame.Fields[index]
+	text := fmt.Sprintf("Column %q has values of mixed types, such as %q, it was returned as text", old.Name, stringify(v))
+	switch b.kinds[index] {
+	case kindTime:
+		text = fmt.Sprintf("Column %q has values that are not timestamps, such as %q, it was returned as text", old.Name, stringify(v))
+	case kindDuration:
+		text = fmt.Sprintf("Column %q has values that are not intervals, such as %q, it was returned as text", old.Name, stringify(v))
+	}
+	b.promoted = append(b.promoted, data.Notice{Severity: data.NoticeSeverityWarning, Text: text})
+
+	nullable := b.nullPolicy == nullPolicyNull
+	field := newFieldForKind(old.Name, kindString, len(b.rawValues[index]), nullable)
+	field.Labels, field.Config = old.Labels, old.Config
+	if b.kinds[index] == kindDuration {
+		// Text isn't in nanoseconds
+		field.Config = nil
+	}
+	for _, v := range b.rawValues[index] {
+		converted, _ := convertValue(kindString, v, b.parser)
+		if v == nil && nullable {
+			converted = nil
+		}
+		appendConverted(field, converted)
+	}
+	b.frame.Fields[index] = field
+	b.kinds[index] = kindString
+	delete(b.rawValues, index)
+}
+
+// finish returns the completed frame, or the conversion error of a strict
+// conversion. A frame without rows keeps its typed fields, so that panels and
+// alerts see the same schema whether or not rows came back; untyped columns
+// are strings then.
+func (b *frameBuilder) finish() (*data.Frame, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	for i := 0; i < b.columns; i++ {
+		if numeric, ok := b.ipFields[i]; ok {
+			b.frame.Fields = append(b.frame.Fields, numeric)
+		}
+		if geo, ok := b.geoFields[i]; ok {
+			b.frame.Fields = append(b.frame.Fields, geo[0], geo[1])
+		}
+	}
+	if len(b.promoted) > 0 {
+		b.frame.AppendNotices(b.promoted...)
+	}
+	if b.skipped > 0 {
+		b.frame.AppendNotices(skippedRowsNotice(b.skipped))
+	}
+	if b.dropped > 0 {
+		b.frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text: fmt.Sprintf("Results were truncated to the first %d of %d rows by the row limit of the datasource; "+
+				"narrow the query or raise the limit", b.maxRows, b.maxRows+b.dropped),
+		})
+	}
+	return b.frame, nil
+}
+
+// appendStat records a query statistic in the frame metadata, where it is shown
+// by the query inspector.
+func appendStat(frame *data.Frame, name string, value float64) {
+	if frame.Meta == nil {
+		frame.Meta = &data.FrameMeta{}
+	}
+	frame.Meta.Stats = append(frame.Meta.Stats, data.QueryStat{
+		FieldConfig: data.FieldConfig{DisplayName: name},
+		Value:       value,
+	})
+}
@@ -0,0 +1,532 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestConvertUsesDeclaredTypes(t *testing.T) {
+	frame, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{
+			{Name: "ts", Type: "TIMESTAMP"},
+			{Name: "count", Type: "BIGINT"},
+			{Name: "ratio", Type: "DECIMAL(10, 2)"},
+			{Name: "code", Type: "VARCHAR(16)"},
+			{Name: "ok", Type: "BOOLEAN"},
+		},
+		Rows: [][]interface{}{
+			// code looks like a date but is declared as VARCHAR
+			{"2024-01-02 03:04:05.123", float64(7), 0.5, "2024-01-01 00:00:00", true},
+		},
+	}, conversionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []data.FieldType{data.FieldTypeTime, data.FieldTypeInt64, data.FieldTypeFloat64, data.FieldTypeString, data.FieldTypeBool}
+	for i, typ := range want {
+		if got := frame.Fields[i].Type(); got != typ {
+			t.Errorf("field %s: got %s, want %s", frame.Fields[i].Name, got, typ)
+		}
+	}
+	if got := frame.Fields[0].At(0).(time.Time); got != time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.UTC) {
+		t.Errorf("unexpected timestamp %v", got)
+	}
+}
+
+func TestConvertEmptyResultKeepsSchema(t *testing.T) {
+	frame, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{{Name: "ts", Type: "TIMESTAMP"}, {Name: "value", Type: "DOUBLE"}, {Name: "note"}},
+	}, conversionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Rows() != 0 {
+		t.Fatalf("expected no rows, got %d", frame.Rows())
+	}
+	for i, want := range []data.FieldType{data.FieldTypeTime, data.FieldTypeFloat64, data.FieldTypeString} {
+		if len(frame.Fields) != 3 || frame.Fields[i].Type() != want {
+			t.Fatalf("expected typed fields from the column metadata, got %v", frame.Fields)
+		}
+	}
+}
+
+func TestConvertSniffsUntypedColumns(t *testing.T) {
+	frame, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{{Name: "ts"}, {Name: "value"}, {Name: "name"}},
+		Rows: [][]interface{}{
+			{"2024-01-02T03:04:05Z", nil, "a"},
+			{"2024-01-02T03:05:05Z", float64(2), "b"},
+		},
+	}, conversionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []data.FieldType{data.FieldTypeTime, data.FieldTypeFloat64, data.FieldTypeString}
+	for i, typ := range want {
+		if got := frame.Fields[i].Type(); got != typ {
+			t.Errorf("field %s: got %s, want %s", frame.Fields[i].Name, got, typ)
+		}
+	}
+}
+
+func TestConvertSessionTimezone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{{Name: "local", Type: "TIMESTAMP"}, {Name: "zoned", Type: "TIMESTAMP"}},
+		Rows:    [][]interface{}{{"2024-01-02 09:00:00", "2024-01-02T09:00:00Z"}},
+	}, conversionOptions{Location: tokyo})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := frame.Fields[0].At(0).(time.Time); !got.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the local timestamp in the session timezone, got %v", got)
+	}
+	if got := frame.Fields[1].At(0).(time.Time); !got.Equal(time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the zoned timestamp to keep its zone, got %v", got)
+	}
+}
+
+func TestConvertExtraTimestampLayouts(t *testing.T) {
+	frame, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{{Name: "day"}, {Name: "micros", Type: "TIMESTAMP"}},
+		Rows:    [][]interface{}{{"2024-01-02", "02/01/2024 03:04:05.000123"}},
+	}, conversionOptions{TimestampLayouts: []string{"2006-01-02", "02/01/2006 15:04:05.000000"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := frame.Fields[0].At(0).(time.Time); got != time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("unexpected date %v", got)
+	}
+	if got := frame.Fields[1].At(0).(time.Time); got != time.Date(2024, 1, 2, 3, 4, 5, 123000, time.UTC) {
+		t.Errorf("unexpected timestamp %v", got)
+	}
+
+	if validTimestampLayout("yyyy-MM-dd") || !validTimestampLayout("2006-01-02") {
+		t.Error("unexpected timestamp layout validation")
+	}
+}
+
+func TestConvertTimestampFallback(t *testing.T) {
+	frame, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{{Name: "sniffed"}, {Name: "declared", Type: "TIMESTAMP"}},
+		Rows: [][]interface{}{
+			{"2024-01-02 09:00:00", "2024-01-02 09:00:00"},
+			{"2024-01-02 10:00:00", "2024-01-02 10:00:00"},
+			{"unknown", "2024-01-02 11:00:00"},
+		},
+	}, conversionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if frame.Fields[0].Type() != data.FieldTypeString {
+		t.Fatalf("expected the column to fall back to strings, got %s", frame.Fields[0].Type())
+	}
+	for row, want := range []string{"2024-01-02 09:00:00", "2024-01-02 10:00:00", "unknown"} {
+		if got := frame.Fields[0].At(row); got != want {
+			t.Errorf("row %d: got %v, want %s", row, got, want)
+		}
+	}
+	if frame.Fields[1].Type() != data.FieldTypeTime {
+		t.Errorf("expected the valid column to stay a timestamp, got %s", frame.Fields[1].Type())
+	}
+	if frame.Meta == nil || len(frame.Meta.Notices) != 1 || !strings.Contains(frame.Meta.Notices[0].Text, `"sniffed"`) {
+		t.Errorf("expected a notice about the fallback, got %+v", frame.Meta)
+	}
+}
+
+func TestConvertMixedTypes(t *testing.T) {
+	frame, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{{Name: "mixed"}, {Name: "flag"}, {Name: "declared", Type: "DOUBLE"}, {Name: "numbers"}},
+		Rows: [][]interface{}{
+			{float64(1), true, float64(1), float64(1)},
+			{"two", float64(0), "n/a", nil},
+			{float64(3), false, float64(3), float64(3)},
+		},
+	}, conversionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []data.FieldType{data.FieldTypeString, data.FieldTypeString, data.FieldTypeFloat64, data.FieldTypeFloat64} {
+		if got := frame.Fields[i].Type(); got != want {
+			t.Errorf("field %s: got %s, want %s", frame.Fields[i].Name, got, want)
+		}
+	}
+	for row, want := range []string{"1", "two", "3"} {
+		if got := frame.Fields[0].At(row); got != want {
+			t.Errorf("row %d: got %v, want %s", row, got, want)
+		}
+	}
+	if frame.Meta == nil || len(frame.Meta.Notices) != 2 || !strings.Contains(frame.Meta.Notices[0].Text, `"two"`) {
+		t.Errorf("expected a notice per promoted column, got %+v", frame.Meta)
+	}
+}
+
+func TestConvertStrict(t *testing.T) {
+	result := func() *QueryResult {
+		return &QueryResult{
+			Columns: []Column{{Name: "value", Type: "DOUBLE"}, {Name: "ts", Type: "TIMESTAMP"}},
+			Rows: [][]interface{}{
+				{float64(1), "2024-01-02 09:00:00"},
+				{nil, nil},
+				{"n/a", "2024-01-02 10:00:00"},
+			},
+		}
+	}
+
+	frame, err := convertToDataFrames(result(), conversionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := frame.Fields[0].At(2); got != 0.0 {
+		t.Errorf("expected a zero value outside strict mode, got %v", got)
+	}
+
+	_, err = convertToDataFrames(result(), conversionOptions{Strict: true})
+	if err == nil || err.Error() != `row 3, column "value": cannot convert "n/a" to a number` {
+		t.Errorf("unexpected strict error: %v", err)
+	}
+
+	badTime := &QueryResult{
+		Columns: []Column{{Name: "ts", Type: "TIMESTAMP"}},
+		Rows:    [][]interface{}{{"2024-01-02 09:00:00"}, {"yesterday"}},
+	}
+	if _, err := convertToDataFrames(badTime, conversionOptions{Strict: true}); err == nil || !strings.Contains(err.Error(), "timestamp") {
+		t.Errorf("expected an error for an unparseable timestamp, got %v", err)
+	}
+}
+
+func TestConvertMaxRows(t *testing.T) {
+	frame, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{{Name: "value", Type: "BIGINT"}},
+		Rows:    [][]interface{}{{float64(1)}, {float64(2)}, {float64(3)}, {float64(4)}},
+	}, conversionOptions{MaxRows: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Rows() != 3 {
+		t.Errorf("expected 3 rows, got %d", frame.Rows())
+	}
+	if frame.Meta == nil || len(frame.Meta.Notices) != 1 || !strings.Contains(frame.Meta.Notices[0].Text, "first 3 of 4 rows") {
+		t.Errorf("expected a truncation notice with the row counts, got %+v", frame.Meta)
+	}
+}
+
+func TestConvertTimeColumn(t *testing.T) {
+	result := &QueryResult{
+		Columns: []Column{{Name: "code"}, {Name: "at"}},
+		Rows:    [][]interface{}{{"2024-01-01 00:00:00", json.Number("1704067200000")}},
+	}
+	frame, err := convertToDataFrames(result, conversionOptions{TimeColumn: "at"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := frame.Fields[0].Type(); got != data.FieldTypeString {
+		t.Errorf("expected the date-like code column to stay a string, got %s", got)
+	}
+	if got := frame.Fields[1].At(0).(time.Time); !got.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected epoch timestamp %v", got)
+	}
+
+	if _, err := convertToDataFrames(result, conversionOptions{TimeColumn: "missing"}); err == nil {
+		t.Error("expected an error for a time column that is not in the result")
+	}
+}
+
+func TestConvertEpochTimeColumn(t *testing.T) {
+	result, err := parseResponse([]byte(`{"status": {"sql_state": "00000"},
+		"columns": [{"name": "ts_ns", "type": "BIGINT"}, {"name": "value", "type": "DOUBLE"}],
+		"data": [[1704067200123456789, 1], ["1704067200123456790", 2], [1704067200123456791.5, 3]]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, err := convertToDataFrames(result, conversionOptions{TimeColumn: "ts_ns", EpochUnit: time.Nanosecond, Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{
+		time.Unix(1704067200, 123456789).UTC(),
+		time.Unix(1704067200, 123456790).UTC(),
+		time.Unix(1704067200, 123456791).UTC(),
+	}
+	for i, w := range want {
+		if got := frame.Fields[0].At(i).(time.Time); !got.Equal(w) {
+			t.Errorf("row %d: got %v, want %v", i, got.Format(time.RFC3339Nano), w.Format(time.RFC3339Nano))
+		}
+	}
+
+	tests := []struct {
+		value interface{}
+		unit  time.Duration
+		want  time.Time
+	}{
+		{json.Number("1704067200"), time.Second, time.Unix(1704067200, 0)},
+		{json.Number("1704067200.25"), time.Second, time.Unix(1704067200, 250000000)},
+		{json.Number("1704067200000123"), time.Microsecond, time.Unix(1704067200, 123000)},
+		{json.Number("1704067200000.5"), time.Millisecond, time.Unix(1704067200, 500000)},
+		{json.Number("-1500"), time.Millisecond, time.Unix(-1, -500000000)},
+		{float64(1704067200000), time.Millisecond, time.Unix(1704067200, 0)},
+	}
+	for _, tt := range tests {
+		got, ok := epochTime(tt.value, tt.unit)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("epochTime(%v, %s) = %v, %v, want %v", tt.value, tt.unit, got, ok, tt.want)
+		}
+	}
+	if _, err := parseEpochUnit("minutes"); err == nil {
+		t.Error("expected an error for an unknown unit")
+	}
+}
+
+func TestConvertPreservesLargeIntegers(t *testing.T) {
+	result, err := parseResponse([]byte(`{"status": {"sql_state": "00000"},
+		"columns": [{"name": "id", "type": "BIGINT"}, {"name": "huge", "type": "DOUBLE"}],
+		"data": [[9007199254740993, 1e400]]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, err := convertToDataFrames(result, conversionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := frame.Fields[0].At(0).(int64); got != 9007199254740993 {
+		t.Errorf("got %d, want 9007199254740993", got)
+	}
+	if got := frame.Fields[1].At(0).(float64); !math.IsInf(got, 1) {
+		t.Errorf("got %v, want +Inf", got)
+	}
+}
+
+func FuzzParseAndConvert(f *testing.F) {
+	f.Add([]byte(`{"status": {"sql_state": "00000"}, "columns": [{"name": "a", "type": "INT"}], "data": [[1], ["x"], [], [1, 2, 3]]}`))
+	f.Add([]byte(`{"status": {"sql_state": "00000"}, "data": [{"a": 1}, {"a": "x", "b": [1, {"c": []}]}]}`))
+	f.Add([]byte(`{"status": {"sql_state": "00000"}, "columns": [{"name": "t", "type": "TIMESTAMP"}], "data": [["2024-01-01 00:00:00"], [1e999], [null]]}`))
+	f.Add([]byte(`{"status": {"sql_state": "00000"}, "columns": [{"name": "b", "type": "BOOLEAN"}], "data": [[[[[[[]]]]]]]}`))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		result, err := parseResponse(body)
+		if err != nil {
+			return
+		}
+		frame, err := convertToDataFrames(result, conversionOptions{})
+		if errors.Is(err, errMalformedResult) {
+			// The recover only keeps the plugin up; the panic is still a bug
+			t.Fatalf("conversion panicked: %v", err)
+		}
+		if err != nil {
+			return
+		}
+		if _, err := frame.RowLen(); err != nil {
+			t.Fatalf("inconsistent frame: %v", err)
+		}
+	})
+}
+
+func TestConvertArrays(t *testing.T) {
+	result := &QueryResult{
+		Columns: []Column{{Name: "id", Type: "INT"}, {Name: "tags", Type: "VARCHAR[]"}, {Name: "vals"}},
+		Rows: [][]interface{}{
+			{float64(1), []interface{}{"a", "b"}, []interface{}{float64(1)}},
+			{float64(2), []interface{}{}, nil},
+		},
+	}
+
+	frame, err := convertToDataFrames(result, conversionOptions{ArrayMode: arrayModeJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Fields[1].Type() != data.FieldTypeJSON || frame.Fields[2].Type() != data.FieldTypeJSON {
+		t.Fatalf("expected JSON fields, got %s and %s", frame.Fields[1].Type(), frame.Fields[2].Type())
+	}
+	if got := string(frame.Fields[1].At(0).(json.RawMessage)); got != `["a","b"]` {
+		t.Errorf("unexpected JSON value %s", got)
+	}
+
+	frame, err = convertToDataFrames(result, conversionOptions{ArrayMode: arrayModeExplode})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Rows() != 3 {
+		t.Fatalf("expected 3 exploded rows, got %d", frame.Rows())
+	}
+	if frame.Fields[1].Type() != data.FieldTypeString || frame.Fields[2].Type() != data.FieldTypeFloat64 {
+		t.Fatalf("unexpected element fields %s and %s", frame.Fields[1].Type(), frame.Fields[2].Type())
+	}
+	if frame.Fields[0].At(1).(int64) != 1 || frame.Fields[1].At(1).(string) != "b" || frame.Fields[0].At(2).(int64) != 2 {
+		t.Errorf("unexpected exploded rows: %v", frame.Fields)
+	}
+}
+
+func TestConvertStructs(t *testing.T) {
+	result := &QueryResult{
+		Columns: []Column{{Name: "id", Type: "INT"}, {Name: "point", Type: "TUPLE<<DOUBLE,DOUBLE>>"}},
+		Rows: [][]interface{}{
+			{float64(1), map[string]interface{}{"x": float64(1), "y": float64(2), "meta": map[string]interface{}{"src": "gps"}}},
+			{float64(2), nil},
+		},
+	}
+
+	frame, err := convertToDataFrames(result, conversionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(frame.Fields))
+	for i, f := range frame.Fields {
+		names[i] = f.Name
+	}
+	if want := []string{"id", "point.meta.src", "point.x", "point.y"}; strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("got fields %v, want %v", names, want)
+	}
+	if frame.Fields[2].Type() != data.FieldTypeFloat64 || frame.Fields[3].At(0).(float64) != 2 {
+		t.Errorf("unexpected member field %v", frame.Fields[3])
+	}
+
+	frame, err = convertToDataFrames(result, conversionOptions{KeepStructsAsJSON: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frame.Fields) != 2 || frame.Fields[1].At(0).(string) != `{"meta":{"src":"gps"},"x":1,"y":2}` {
+		t.Errorf("expected the tuple as a JSON string, got %v", frame.Fields[1].At(0))
+	}
+}
+
+func TestConvertDistinct(t *testing.T) {
+	frame, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{{Name: "a", Type: "INT"}, {Name: "b", Type: "VARCHAR"}},
+		Rows:    [][]interface{}{{float64(1), "x"}, {float64(1), "x"}, {float64(1), "y"}, {float64(1), "x"}},
+	}, conversionOptions{Distinct: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Rows() != 2 {
+		t.Fatalf("expected 2 rows, got %d", frame.Rows())
+	}
+	if len(frame.Meta.Stats) != 1 || frame.Meta.Stats[0].Value != 2 {
+		t.Errorf("expected 2 removed rows in the stats, got %+v", frame.Meta.Stats)
+	}
+}
+
+func TestConvertUUIDTypeHint(t *testing.T) {
+	frame, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{{Name: "id", Type: "UUID"}, {Name: "untyped"}, {Name: "name", Type: "VARCHAR"}},
+		Rows:    [][]interface{}{{"8c3a4c0e-5b0f-4f3e-9d8e-2f1b6a7c9d00", "8C3A4C0E-5B0F-4F3E-9D8E-2F1B6A7C9D00", "8c3a4c0e-5b0f-4f3e-9d8e-2f1b6a7c9d00"}},
+	}, conversionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []interface{}{typeHintUUID, typeHintUUID, nil} {
+		var got interface{}
+		if frame.Fields[i].Config != nil {
+			got = frame.Fields[i].Config.Custom[typeHintKey]
+		}
+		if got != want {
+			t.Errorf("field %s: got hint %v, want %v", frame.Fields[i].Name, got, want)
+		}
+	}
+	if frame.Fields[0].Type() != data.FieldTypeString {
+		t.Errorf("expected a string field, got %s", frame.Fields[0].Type())
+	}
+}
+
+func TestConvertIPAddresses(t *testing.T) {
+	frame, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{{Name: "src", Type: "IPV4"}, {Name: "dst", Type: "IPV6"}},
+		Rows:    [][]interface{}{{"10.0.0.1", "::1"}, {"bogus", nil}},
+	}, conversionOptions{NumericIPs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frame.Fields) != 4 || frame.Fields[2].Name != "src.numeric" || frame.Fields[3].Name != "dst.numeric" {
+		t.Fatalf("unexpected fields %v", frame.Fields)
+	}
+	if frame.Fields[0].Config.Custom[typeHintKey] != typeHintIPv4 || frame.Fields[1].Config.Custom[typeHintKey] != typeHintIPv6 {
+		t.Error("expected IP type hints")
+	}
+	if got := *frame.Fields[2].At(0).(*float64); got != 167772161 {
+		t.Errorf("got %v, want 167772161", got)
+	}
+	if got := *frame.Fields[3].At(0).(*float64); got != 1 {
+		t.Errorf("got %v, want 1", got)
+	}
+	if frame.Fields[2].At(1).(*float64) != nil {
+		t.Error("expected null for an invalid address")
+	}
+}
+
+func TestStreamRowsInChunks(t *testing.T) {
+	result, err := parseResponse([]byte(`{"status": {"sql_state": "00000"},
+		"columns": [{"name": "ts", "type": "TIMESTAMP"}, {"name": "v", "type": "INT"}],
+		"data": [["2024-01-01 00:00:00", 1], ["2024-01-01 00:01:00", 2], ["2024-01-01 00:02:00", 3]]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !canStreamConversion(result, conversionOptions{}) {
+		t.Fatal("expected a streamable result")
+	}
+
+	builder := newFrameBuilder(result, conversionOptions{}, 0)
+	var chunks []int
+	err = result.streamRows(2, func(rows [][]interface{}) {
+		chunks = append(chunks, len(rows))
+		builder.appendRows(rows)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 || chunks[0] != 2 || chunks[1] != 1 {
+		t.Errorf("unexpected chunks %v", chunks)
+	}
+	frame, err := builder.finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Rows() != 3 || frame.Fields[1].At(2).(int64) != 3 {
+		t.Errorf("unexpected frame %v", frame.Fields)
+	}
+	if result.encodedRows != nil {
+		t.Error("encoded rows must be released once streamed")
+	}
+}
+
+func TestConvertBinary(t *testing.T) {
+	result := func() *QueryResult {
+		return &QueryResult{
+			Columns: []Column{{Name: "b", Type: "VARBINARY(16)"}},
+			Rows:    [][]interface{}{{"0xdeadbeef"}, {[]byte{0x01, 0x02}}, {"AQID"}},
+		}
+	}
+	tests := []struct {
+		format string
+		want   []interface{}
+	}{
+		{binaryFormatHex, []interface{}{"deadbeef", "0102", "010203"}},
+		{binaryFormatBase64, []interface{}{"3q2+7w==", "AQI=", "AQID"}},
+		{binaryFormatLength, []interface{}{int64(4), int64(2), int64(3)}},
+	}
+	for _, tt := range tests {
+		frame, err := convertToDataFrames(result(), conversionOptions{BinaryFormat: tt.format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, want := range tt.want {
+			if got := frame.Fields[0].At(i); got != want {
+				t.Errorf("%s row %d: got %v, want %v", tt.format, i, got, want)
+			}
+		}
+	}
+}
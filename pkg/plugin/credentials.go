@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// mappedCredentialKey is the context key of the credential mapping of the
+// signed-in Grafana user, see withMappedCredentials.
+type mappedCredentialKey struct{}
+
+// credentialMapping returns the first credential mapping of the settings
+// matching a Grafana user, by login or email and then by organization role.
+func credentialMapping(settings models.PluginSettings, user *backend.User) (models.CredentialMapping, bool) {
+	if user == nil || user.Login == "" {
+		return models.CredentialMapping{}, false
+	}
+	for _, mapping := range settings.CredentialMappings {
+		for _, u := range mapping.Users {
+			if strings.EqualFold(u, user.Login) || (user.Email != "" && strings.EqualFold(u, user.Email)) {
+				return mapping, true
+			}
+		}
+		for _, role := range mapping.Roles {
+			if strings.EqualFold(role, user.Role) {
+				return mapping, true
+			}
+		}
+	}
+	return models.CredentialMapping{}, false
+}
+
+// withMappedCredentials returns ctx carrying the credential mapping of the
+// Grafana user of the request, if any. Statements of that request are then
+// sent with its credentials to the default cluster and to environments
+// without credentials of their own. Users no mapping matches keep the
+// credentials of the datasource.
+func (d *Datasource) withMappedCredentials(ctx context.Context) context.Context {
+	mapping, ok := credentialMapping(d.settings, backend.PluginConfigFromContext(ctx).User)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, mappedCredentialKey{}, mapping)
+}
+
+// mappedCredentials returns the credential mapping carried by ctx.
+func mappedCredentials(ctx context.Context) (models.CredentialMapping, bool) {
+	mapping, ok := ctx.Value(mappedCredentialKey{}).(models.CredentialMapping)
+	return mapping, ok
+}
+
+// credentials returns the username and password statements of ctx are sent
+// with: those of the credential mapping of its user when the settings map
+// credentials, else those of the settings. A mapping without credentials, as
+// when they couldn't be decrypted, is an error rather than a fall back to the
+// credentials of the datasource, which may read more.
+func (t *restTransport) credentials(ctx context.Context) (string, string, error) {
+	if mapping, ok := mappedCredentials(ctx); ok && len(t.settings.CredentialMappings) > 0 {
+		if mapping.Username == "" {
+			return "", "", fmt.Errorf("credential mapping %s has no username", mapping.Name)
+		}
+		return mapping.Username, mapping.Password, nil
+	}
+	return t.settings.Secrets.Username, t.settings.Secrets.Password, nil
+}
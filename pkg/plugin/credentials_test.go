@@ -0,0 +1,104 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/ocient/ocient-datasource/pkg/internal/fakeocient"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+func TestCredentialMapping(t *testing.T) {
+	settings := models.PluginSettings{CredentialMappings: []models.CredentialMapping{
+		{Name: "finance", Users: []string{"alice", "carol@example.com"}},
+		{Name: "editors", Roles: []string{"Editor"}},
+	}}
+	cases := []struct {
+		user *backend.User
+		want string
+	}{
+		{&backend.User{Login: "Alice", Role: "Editor"}, "finance"},
+		{&backend.User{Login: "carol", Email: "carol@example.com", Role: "Viewer"}, "finance"},
+		{&backend.User{Login: "dave", Role: "Editor"}, "editors"},
+		{&backend.User{Login: "erin", Role: "Viewer"}, ""},
+		{nil, ""},
+	}
+	for _, c := range cases {
+		mapping, _ := credentialMapping(settings, c.user)
+		if mapping.Name != c.want {
+			t.Errorf("%+v: got mapping %q, want %q", c.user, mapping.Name, c.want)
+		}
+	}
+}
+
+func TestQueryDataCredentialMapping(t *testing.T) {
+	transport, server := newFakeRESTTransport(t,
+		fakeocient.WithCredentials("finance_reader", "secret"),
+		fakeocient.WithDatasets(fakeocient.Dataset{Match: "from t", Rows: []map[string]interface{}{{"a": float64(1)}}}))
+	transport.settings.CredentialMappings = []models.CredentialMapping{
+		{Name: "finance", Users: []string{"alice"}, Username: "finance_reader", Password: "secret"},
+		{Name: "broken", Users: []string{"bob"}},
+	}
+	ds := Datasource{settings: transport.settings, transport: transport}
+
+	query := func(login string) backend.DataResponse {
+		ctx := backend.WithPluginContext(context.Background(), backend.PluginContext{User: &backend.User{Login: login}})
+		resp, err := ds.QueryData(ctx, &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: json.RawMessage(`{"queryText": "SELECT a FROM t"}`)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Responses["A"]
+	}
+
+	if r := query("alice"); r.Error != nil {
+		t.Fatal(r.Error)
+	}
+	if r := query("erin"); r.Error != nil {
+		t.Fatal(r.Error)
+	}
+	// A mapping without credentials never falls back to those of the datasource
+	if r := query("bob"); r.Error == nil {
+		t.Error("expected an error for a mapping without credentials")
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 || requests[0].Username != "finance_reader" || requests[1].Username != "user" {
+		t.Errorf("got requests %+v, want one as finance_reader and one as user", requests)
+	}
+}
+
+func TestEnvironmentCredentialsNotMapped(t *testing.T) {
+	base := models.PluginSettings{
+		Secrets:            &models.SecretPluginSettings{Username: "user"},
+		CredentialMappings: []models.CredentialMapping{{Name: "finance", Users: []string{"alice"}}},
+	}
+	if settings := environmentSettings(base, models.EnvironmentSettings{Name: "stage"}); len(settings.CredentialMappings) != 1 {
+		t.Error("an environment inheriting the default credentials should map them")
+	}
+	if settings := environmentSettings(base, models.EnvironmentSettings{Name: "prod", Username: "prod_user"}); settings.CredentialMappings != nil {
+		t.Error("an environment with credentials of its own should not map them")
+	}
+}
+
+func TestMetadataUserCredentialMapping(t *testing.T) {
+	ds := Datasource{settings: models.PluginSettings{CredentialMappings: []models.CredentialMapping{
+		{Name: "finance", Users: []string{"alice"}, Username: "finance_reader"},
+	}}}
+	ctx := ds.withMappedCredentials(backend.WithPluginContext(context.Background(), backend.PluginContext{User: &backend.User{Login: "alice"}}))
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/tables", nil)
+	if user, filter := ds.metadataUser(r); user != "finance_reader" || !filter {
+		t.Errorf("got metadata user %q, %v, want finance_reader", user, filter)
+	}
+
+	// Forwarded identities are filtered alike, as whoever Ocient names
+	ds.settings.OAuthPassThru = true
+	r, _ = http.NewRequestWithContext(ds.withForwardedIdentity(ctx, "Bearer alice-token"), http.MethodGet, "/tables", nil)
+	if user, filter := ds.metadataUser(r); user != "" || !filter {
+		t.Errorf("got metadata user %q, %v for a forwarded identity, want it asked of Ocient", user, filter)
+	}
+}
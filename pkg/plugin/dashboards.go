@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// bundledDashboards are the dashboards offered for import from the config page.
+//
+//go:embed dashboards/*.json
+var bundledDashboards embed.FS
+
+// dashboardDatasourcePlaceholder is the datasource UID used in the bundled
+// dashboards, replaced with the UID of the datasource serving them.
+const dashboardDatasourcePlaceholder = "${DS_OCIENT}"
+
+// dashboardSummary describes a bundled dashboard in the /dashboards listing.
+type dashboardSummary struct {
+	ID          string   `json:"id"`
+	UID         string   `json:"uid"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+}
+
+// dashboardImport is the body accepted by Grafana's /api/dashboards/db, so the
+// config page can import a dashboard by posting the response unchanged. Bundled
+// dashboards have fixed UIDs, so importing again updates the existing copy.
+type dashboardImport struct {
+	Dashboard json.RawMessage `json:"dashboard"`
+	Overwrite bool            `json:"overwrite"`
+	Message   string          `json:"message"`
+}
+
+// handleDashboards lists the bundled dashboards.
+func (d *Datasource) handleDashboards(w http.ResponseWriter, _ *http.Request) {
+	entries, err := bundledDashboards.ReadDir("dashboards")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	summaries := make([]dashboardSummary, 0, len(entries))
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		raw, err := bundledDashboards.ReadFile(path.Join("dashboards", entry.Name()))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		summary := dashboardSummary{ID: id}
+		if err := json.Unmarshal(raw, &summary); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		summary.ID = id
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Title < summaries[j].Title })
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+// handleDashboard returns a bundled dashboard bound to this datasource, ready to
+// be imported.
+func (d *Datasource) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	raw, err := bundledDashboards.ReadFile(path.Join("dashboards", path.Base(id)+".json"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "dashboard not found: "+id)
+		return
+	}
+	uid, _ := json.Marshal(d.uid)
+	raw = bytes.ReplaceAll(raw, []byte(`"`+dashboardDatasourcePlaceholder+`"`), uid)
+	writeJSON(w, http.StatusOK, dashboardImport{
+		Dashboard: raw,
+		Overwrite: true,
+		Message:   "Imported from the Ocient data source",
+	})
+}
@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// callResource sends a resource request to the datasource and returns the response.
+func callResource(t *testing.T, ds *Datasource, method, path string) *backend.CallResourceResponse {
+	t.Helper()
+	return callResourceWithBody(t, ds, method, path, nil)
+}
+
+// callResourceWithBody sends a resource request with a body to the datasource.
+func callResourceWithBody(t *testing.T, ds *Datasource, method, path string, body []byte) *backend.CallResourceResponse {
+	t.Helper()
+	return callResourceAs(t, ds, nil, method, path, body)
+}
+
+// callResourceAs sends a resource request on behalf of a Grafana user.
+func callResourceAs(t *testing.T, ds *Datasource, user *backend.User, method, path string, body []byte) *backend.CallResourceResponse {
+	t.Helper()
+	if ds.resourceHandler == nil {
+		ds.resourceHandler = newResourceHandler(ds)
+	}
+	var resp *backend.CallResourceResponse
+	resourcePath, _, _ := strings.Cut(path, "?")
+	req := &backend.CallResourceRequest{PluginContext: backend.PluginContext{User: user}, Method: method, Path: resourcePath, URL: path, Body: body}
+	err := ds.CallResource(context.Background(), req,
+		backend.CallResourceResponseSenderFunc(func(r *backend.CallResourceResponse) error {
+			// Flushed responses arrive in chunks after the first
+			if resp == nil {
+				resp = r
+			} else {
+				resp.Body = append(resp.Body, r.Body...)
+			}
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestDashboardsResource(t *testing.T) {
+	ds := &Datasource{uid: "ocient-uid"}
+
+	resp := callResource(t, ds, "GET", "dashboards")
+	var summaries []dashboardSummary
+	if err := json.Unmarshal(resp.Body, &summaries); err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 3 {
+		t.Fatalf("expected 3 bundled dashboards, got %d", len(summaries))
+	}
+
+	for _, summary := range summaries {
+		resp := callResource(t, ds, "GET", "dashboards/"+summary.ID)
+		if resp.Status != 200 {
+			t.Fatalf("dashboard %s: status %d", summary.ID, resp.Status)
+		}
+		var imported dashboardImport
+		if err := json.Unmarshal(resp.Body, &imported); err != nil {
+			t.Fatal(err)
+		}
+		dashboard := string(imported.Dashboard)
+		if strings.Contains(dashboard, dashboardDatasourcePlaceholder) || !strings.Contains(dashboard, `"ocient-uid"`) {
+			t.Errorf("dashboard %s does not reference the datasource", summary.ID)
+		}
+	}
+
+	if resp := callResource(t, ds, "GET", "dashboards/missing"); resp.Status != 404 {
+		t.Errorf("expected 404 for an unknown dashboard, got %d", resp.Status)
+	}
+}
@@ -1,47 +1,51 @@
 package plugin
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/proxy"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/ocient/ocient-datasource/pkg/internal/fakeocient"
 	"github.com/ocient/ocient-datasource/pkg/models"
 )
 
 // Make sure Datasource implements required interfaces. This is important to do
 // since otherwise we will only get a "not implemented" error response from the plugin at
-// runtime. The Ocient datasource implements backend.QueryDataHandler and
-// backend.CheckHealthHandler interfaces to provide query execution and health checking
-// capabilities.
+// runtime. The Ocient datasource implements backend.QueryDataHandler,
+// backend.CheckHealthHandler and backend.CallResourceHandler interfaces to provide query
+// execution, health checking and resources for the frontend.
 var (
 	_ backend.QueryDataHandler      = (*Datasource)(nil)
 	_ backend.CheckHealthHandler    = (*Datasource)(nil)
+	_ backend.CallResourceHandler   = (*Datasource)(nil)
 	_ instancemgmt.InstanceDisposer = (*Datasource)(nil)
 )
 
 // NewDatasource creates a new datasource instance.
-func NewDatasource(_ context.Context, settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
-	backend.Logger.Info("Creating new Ocient datasource instance", 
+func NewDatasource(ctx context.Context, settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
+	backend.Logger.Info("Creating new Ocient datasource instance",
 		"id", settings.ID,
 		"uid", settings.UID,
 		"name", settings.Name,
 		"type", settings.Type,
 		"jsonData length", len(settings.JSONData))
-	
+
 	config, err := models.LoadPluginSettings(settings)
 	if err != nil {
 		backend.Logger.Error("Failed to load plugin settings", "error", err.Error())
 		return nil, err
 	}
-	
+
 	backend.Logger.Info("Loaded plugin settings",
 		"host", config.Host,
 		"port", config.Port,
@@ -49,13 +53,163 @@ func NewDatasource(_ context.Context, settings backend.DataSourceInstanceSetting
 		"insecureSkipVerify", config.InsecureSkipVerify,
 		"hasUsername", config.Secrets.Username != "",
 		"hasPassword", config.Secrets.Password != "")
-	
-	return &Datasource{settings: *config}, nil
+	if len(config.UndecryptedSecrets) > 0 {
+		backend.Logger.Warn("Secure fields could not be decrypted", "secrets", config.UndecryptedSecrets)
+	}
+
+	// Developer mode: serve queries from an in-process fake Ocient API
+	var fakeServer *fakeocient.Server
+	if config.DevFakeServer {
+		fakeServer = fakeocient.NewServer(fakeocient.WithDatasets(fakeocient.DemoDatasets()...))
+		if err := useFakeServer(config, fakeServer); err != nil {
+			fakeServer.Close()
+			return nil, err
+		}
+		backend.Logger.Warn("Using fake Ocient server", "url", fakeServer.URL)
+	}
+
+	clientOpts, err := settings.HTTPClientOptions(ctx)
+	if err != nil {
+		backend.Logger.Error("Failed to read HTTP client options", "error", err.Error())
+		if fakeServer != nil {
+			fakeServer.Close()
+		}
+		return nil, err
+	}
+	// Grafana Cloud reaches clusters in private networks through the secure
+	// socks proxy of Private Data source Connect, which the HTTP client dials
+	secureSocksProxy := proxy.New(clientOpts.ProxyOptions).SecureSocksProxyEnabled()
+	if secureSocksProxy {
+		if config.Transport == models.TransportNative {
+			if fakeServer != nil {
+				fakeServer.Close()
+			}
+			return nil, fmt.Errorf("the secure socks proxy is only supported by the REST transport")
+		}
+		backend.Logger.Info("Connecting to Ocient through the secure socks proxy")
+	}
+	client, err := newHTTPClient(*config, clientOpts)
+	if err != nil {
+		backend.Logger.Error("Failed to create HTTP client", "error", err.Error())
+		if fakeServer != nil {
+			fakeServer.Close()
+		}
+		return nil, err
+	}
+	// Discovered SQL nodes replace the configured ones once resolved
+	var discovery *endpointDiscovery
+	if config.Discovery != nil {
+		discovery = newEndpointDiscovery(*config, client)
+		if err := discovery.refresh(ctx); err != nil {
+			backend.Logger.Warn("Failed to discover SQL nodes, using the configured hosts", "error", err.Error())
+		}
+	}
+	sessions := newSessionCache()
+	transport, err := newTransport(*config, client, discovery, sessions)
+	if err != nil {
+		backend.Logger.Error("Failed to create query transport", "transport", config.Transport, "error", err.Error())
+		if fakeServer != nil {
+			fakeServer.Close()
+		}
+		return nil, err
+	}
+
+	ds := &Datasource{uid: settings.UID, settings: *config, transport: transport, httpClient: client, secureSocksProxy: secureSocksProxy, discovery: discovery, sessions: sessions, fakeServer: fakeServer}
+	ds.resourceHandler = newResourceHandler(ds)
+	if config.PublicDashboards != nil && config.Secrets.PublicUsername != "" {
+		publicConfig := *config
+		publicConfig.Secrets = &models.SecretPluginSettings{
+			Username: config.Secrets.PublicUsername,
+			Password: config.Secrets.PublicPassword,
+		}
+		publicConfig.CredentialMappings = nil
+		if ds.publicTransport, err = newTransport(publicConfig, client, discovery, sessions); err != nil {
+			backend.Logger.Error("Failed to create public dashboard transport", "error", err.Error())
+			ds.Dispose()
+			return nil, err
+		}
+	}
+	for _, env := range config.Environments {
+		transport, err := newTransport(environmentSettings(*config, env), client, ds.environmentDiscovery(env.Name), sessions)
+		if err != nil {
+			backend.Logger.Error("Failed to create environment transport", "environment", env.Name, "error", err.Error())
+			ds.Dispose()
+			return nil, err
+		}
+		if ds.environments == nil {
+			ds.environments = make(map[string]QueryTransport)
+		}
+		ds.environments[env.Name] = transport
+	}
+	ds.lookupCache = newTTLCache[data.ValueMapper](lookupCacheTTL, lookupCacheEntries)
+	ds.extentCache = newTTLCache[timeExtent](extentCacheTTL, extentCacheEntries)
+	ds.columnTypeCache = newTTLCache[map[string]string](columnTypeCacheTTL, columnTypeCacheEntries)
+	ds.catalogCache = newTTLCache[[]string](catalogCacheTTL, catalogCacheEntries)
+	if discovery != nil && config.Discovery.RefreshSeconds > 0 {
+		ds.startDiscovery()
+	}
+	if config.WarmUp && !ds.blockInsecureTLS() {
+		ds.startWarmUp()
+	}
+	return ds, nil
+}
+
+// useFakeServer points the settings at a fake Ocient server. The fake server uses
+// a self-signed certificate so verification is always skipped.
+func useFakeServer(config *models.PluginSettings, server *fakeocient.Server) error {
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		return fmt.Errorf("invalid fake server URL: %w", err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return fmt.Errorf("invalid fake server port: %w", err)
+	}
+	config.Scheme = u.Scheme
+	config.Host = u.Hostname()
+	config.Port = port
+	config.Transport = models.TransportREST
+	config.InsecureSkipVerify = true
+	return nil
 }
 
 // Datasource is an implementation of the Ocient datasource which can respond to data queries.
-type Datasource struct{
-	settings models.PluginSettings
+type Datasource struct {
+	uid        string
+	settings   models.PluginSettings
+	transport  QueryTransport
+	fakeServer *fakeocient.Server
+	// publicTransport runs public dashboard queries with the restricted credentials
+	publicTransport QueryTransport
+	// httpClient is shared by the REST transports, which reuse its connections
+	httpClient *http.Client
+	// secureSocksProxy is set when the HTTP client reaches Ocient through the
+	// secure socks proxy of Grafana, as with Private Data source Connect
+	secureSocksProxy bool
+	// environments are the transports of the named environments
+	environments map[string]QueryTransport
+	// lookupCache holds the value mappings of lookup statements
+	lookupCache *ttlCache[data.ValueMapper]
+	// extentCache holds the time extents of tables for empty range notices
+	extentCache *ttlCache[timeExtent]
+	// columnTypeCache holds the column types of tables for ad-hoc filters
+	columnTypeCache *ttlCache[map[string]string]
+	// catalogCache holds the names listed by metadata variable queries
+	catalogCache *ttlCache[[]string]
+	// capture records queries for the /debug/capture support bundle
+	capture queryCapture
+	// activity tracks the queries in flight for the /activity resource
+	activity activityTracker
+	// resourceHandler serves the resources of the datasource, see newResourceHandler
+	resourceHandler backend.CallResourceHandler
+	// stopWarmUp cancels the warm-up and waits for it, see startWarmUp
+	stopWarmUp func()
+	// discovery resolves the SQL nodes when configured; stopDiscovery stops
+	// its refreshes, see startDiscovery
+	discovery     *endpointDiscovery
+	stopDiscovery func()
+	// sessions holds the session tokens of its transports, by SQL node and user
+	sessions *sessionCache
 }
 
 // Dispose here tells plugin SDK that plugin wants to clean up resources when a new instance
@@ -63,6 +217,33 @@ type Datasource struct{
 // be disposed and a new one will be created using NewSampleDatasource factory function.
 func (d *Datasource) Dispose() {
 	// Clean up datasource instance resources.
+	if d.stopWarmUp != nil {
+		d.stopWarmUp()
+	}
+	if d.stopDiscovery != nil {
+		d.stopDiscovery()
+	}
+	if d.transport != nil {
+		if err := d.transport.Close(); err != nil {
+			backend.Logger.Warn("Failed to close query transport", "error", err.Error())
+		}
+	}
+	if d.publicTransport != nil {
+		if err := d.publicTransport.Close(); err != nil {
+			backend.Logger.Warn("Failed to close public dashboard transport", "error", err.Error())
+		}
+	}
+	for name, transport := range d.environments {
+		if err := transport.Close(); err != nil {
+			backend.Logger.Warn("Failed to close environment transport", "environment", name, "error", err.Error())
+		}
+	}
+	if d.httpClient != nil {
+		d.httpClient.CloseIdleConnections()
+	}
+	if d.fakeServer != nil {
+		d.fakeServer.Close()
+	}
 }
 
 // QueryData handles multiple queries and returns multiple responses.
@@ -72,10 +253,35 @@ func (d *Datasource) Dispose() {
 func (d *Datasource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
 	// create response struct
 	response := backend.NewQueryDataResponse()
+	ctx = d.withMappedCredentials(d.withForwardedIdentity(ctx, req.GetHTTPHeader(backend.OAuthIdentityTokenHeaderName)))
+	ctx = withAuditPanel(ctx, req)
+	// Grafana asks for fresh data when its query cache is bypassed
+	ctx = withCacheBypass(ctx, req)
+	mode := d.executionMode(req)
+
+	// Queries run one after the other, the later ones are queued meanwhile
+	active := make([]*activeQuery, len(req.Queries))
+	for i, q := range req.Queries {
+		active[i] = d.activity.add(q.RefID, mode)
+	}
+	defer func() {
+		for _, a := range active {
+			a.done()
+		}
+	}()
 
 	// loop over queries and execute them individually.
-	for _, q := range req.Queries {
-		res := d.query(ctx, req.PluginContext, q)
+	for i, q := range req.Queries {
+		res := d.query(ctx, mode, q, active[i])
+		active[i].done()
+		// Transformations and alert expressions find frames by the query they answer
+		for _, frame := range res.Frames {
+			frame.RefID = q.RefID
+		}
+		if res.Error != nil && errorFrameRequested(q) {
+			res = errorFrameResponse(res, time.Now())
+			res.Frames[0].RefID = q.RefID
+		}
 
 		// save the response in a hashmap
 		// based on with RefID as identifier
@@ -87,318 +293,478 @@ func (d *Datasource) QueryData(ctx context.Context, req *backend.QueryDataReques
 
 type queryModel struct {
 	QueryText string `json:"queryText"`
+	// ArrayMode controls how array columns are returned: "json" (default) or "explode".
+	ArrayMode string `json:"arrayMode"`
+	// KeepStructsAsJSON returns tuple/struct columns as JSON strings instead of
+	// flattening them into dotted fields such as point.x and point.y.
+	KeepStructsAsJSON bool `json:"keepStructsAsJson"`
+	// Distinct removes exact duplicate rows from the result after it is fetched,
+	// for denormalized tables where a SQL DISTINCT is too expensive.
+	Distinct bool `json:"distinct"`
+	// NumericIPs adds a numeric field next to every IPV4/IPV6 column so panels
+	// can sort and filter addresses by range.
+	NumericIPs bool `json:"numericIps"`
+	// BinaryFormat renders BINARY/VARBINARY columns as "hex" (default), "base64"
+	// or "length" (the number of bytes).
+	BinaryFormat string `json:"binaryFormat"`
+	// TimeColumn names the column used as the time axis, instead of guessing
+	// which string columns hold timestamps.
+	TimeColumn string `json:"timeColumn"`
+	// TimeColumnUnit is the unit of numeric time values since the epoch: "s",
+	// "ms" (default), "us" or "ns".
+	TimeColumnUnit string `json:"timeColumnUnit"`
+	// Strict fails the query on values that can't be converted to the type of
+	// their column, instead of returning zero values in their place.
+	Strict bool `json:"strict"`
+	// JSONColumn splits a column of JSON strings into a field per key.
+	JSONColumn *jsonColumnOptions `json:"jsonColumn"`
+	// Extract adds fields for the named groups of regular expressions
+	// matched against string columns.
+	Extract []extractionRule `json:"extract"`
+	// NullPolicy overrides the null policy of the datasource: "zero", "null"
+	// or "skip".
+	NullPolicy string `json:"nullPolicy"`
+	// Format shapes the response as "table" (default), "time_series", "logs",
+	// "heatmap" or "trace".
+	Format string `json:"format"`
+	// Heatmap names the bucket columns for the heatmap format.
+	Heatmap *heatmapOptions `json:"heatmap"`
+	// Logs names the body and level columns for the logs format.
+	Logs *logsOptions `json:"logs"`
+	// Trace names the span columns for the trace format.
+	Trace *traceOptions `json:"trace"`
+	// LongToWide converts long results (time, labels, values) into wide time
+	// series with one field per label combination, like the time_series format.
+	// LabelColumns selects the label columns, by default every string and
+	// boolean column.
+	LongToWide   bool     `json:"longToWide"`
+	LabelColumns []string `json:"labelColumns"`
+	// SplitBy returns one frame per distinct value of this column, labeled
+	// with the value, instead of a single frame.
+	SplitBy string `json:"splitBy"`
+	// Environment selects the named environment, usually through a dashboard
+	// variable, instead of the default cluster.
+	Environment string `json:"environment"`
+	// Selection runs only the selected statement of a multi-statement query
+	// text, or the one at the cursor when nothing is selected.
+	Selection *textRange `json:"selection"`
+	// Timezone overrides the session timezone of the datasource for this query.
+	Timezone string `json:"timezone"`
+	// ProbeEmptyRange looks up the time extent of the table when the query
+	// returns no rows, to tell when the data lies outside the time range.
+	ProbeEmptyRange bool `json:"probeEmptyRange"`
+	// FieldOptions sets the unit, display name and decimals of columns by name.
+	FieldOptions map[string]fieldOptions `json:"fieldOptions"`
+	// Fields lists the columns the panel actually displays. When set, SELECT *
+	// statements are narrowed to these columns before execution.
+	Fields []string `json:"fields"`
+	// LogContext returns the log lines around a line instead of the result of
+	// the query, for "show context" in Explore.
+	LogContext *logContextOptions `json:"logContext"`
+	// TopK counts the most frequent values of a column instead of running
+	// the query text.
+	TopK *topKOptions `json:"topK"`
+	// AdhocFilters are the filters of the ad-hoc filters variables of the
+	// dashboard, applied where the query uses the $__adhocFilters() macro.
+	AdhocFilters []adhocFilter `json:"adhocFilters"`
+	// QueryTimeout cancels the query after this many seconds, instead of the
+	// timeout of the datasource.
+	QueryTimeout int `json:"queryTimeoutSeconds"`
+	// Alias names the frames of the query instead of its RefID.
+	Alias string `json:"alias"`
+	// IntervalMs is the interval Grafana suggests for the panel, which
+	// $__timeGroup groups by unless given one.
+	IntervalMs int64 `json:"intervalMs"`
+	// RetryEmpty runs read-only statements again, briefly, while they return
+	// no rows, for tables loaded by streaming ingest.
+	RetryEmpty bool `json:"retryEmpty"`
+	// NoData decides what a query returning no rows answers: an "empty"
+	// frame (default), which alert rules evaluate as NoData, or a "zero"
+	// point at the end of the time range.
+	NoData string `json:"noData"`
+	// ErrorFrame answers a failed query with a frame of a single row holding
+	// the error, instead of an error, for table panels on kiosk dashboards.
+	ErrorFrame bool `json:"errorFrame"`
+	// Options are the feature flags of the query, see queryFeatures.
+	Options map[string]interface{} `json:"options"`
 }
 
-// OcientStatus represents the status of an Ocient API response as defined in the OpenAPI spec
-type OcientStatus struct {
-	Reason     string `json:"reason"`
-	SQLState   string `json:"sql_state"`
-	VendorCode int    `json:"vendor_code"`
+// conversionOptions returns the frame conversion options selected by the query.
+func (qm queryModel) conversionOptions() conversionOptions {
+	return conversionOptions{
+		ArrayMode:         qm.ArrayMode,
+		KeepStructsAsJSON: qm.KeepStructsAsJSON,
+		Distinct:          qm.Distinct,
+		NumericIPs:        qm.NumericIPs,
+		BinaryFormat:      qm.BinaryFormat,
+		TimeColumn:        qm.TimeColumn,
+		Strict:            qm.Strict,
+		JSONColumn:        qm.JSONColumn,
+	}
 }
 
-// CollectionResponse represents the "collection" format response from the Ocient API
-type CollectionResponse struct {
-	QueryID string                   `json:"query_id"`
-	Status  OcientStatus             `json:"status"`
-	Data    []map[string]interface{} `json:"data"`
-}
+func (d *Datasource) query(ctx context.Context, mode executionMode, query backend.DataQuery, active *activeQuery) (response backend.DataResponse) {
+	// Record the outcome while a debug capture is running
+	start := time.Now()
+	var statement string
+	defer func() {
+		d.capture.record(query.RefID, statement, time.Since(start), response)
+	}()
 
-// executeQuery sends an SQL query to the Ocient API and returns the result
-func (d *Datasource) executeQuery(ctx context.Context, query string) ([]map[string]interface{}, *OcientStatus, error) {
-	// Construct the API URL using HTTPS
-	url := fmt.Sprintf("https://%s:%d/v1/execute", d.settings.Host, d.settings.Port)
-	backend.Logger.Info("API request URL", "url", url, "database", d.settings.Database)
+	// Unmarshal the JSON into our queryModel.
+	var qm queryModel
 
-	// Create request body with format=collection as specified in the OpenAPI spec
-	queryRequest := map[string]interface{}{
-		"database":  d.settings.Database,
-		"statement": query,
-		"format":    "collection",
+	err := json.Unmarshal(query.JSON, &qm)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("json unmarshal: %v", err.Error()))
 	}
 
-	payload, err := json.Marshal(queryRequest)
-	if err != nil {
-		return nil, nil, fmt.Errorf("error marshaling query: %w", err)
+	if qm.TopK != nil {
+		if qm.QueryText, err = topKQueryText(*qm.TopK); err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+		}
 	}
-	
-	// Log the full API request details
-	backend.Logger.Info("API request details", 
-		"url", url, 
-		"database", d.settings.Database,
-		"statement", query,
-		"username", d.settings.Secrets.Username,
-		"payload", string(payload))
 
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(payload))
-	if err != nil {
-		return nil, nil, fmt.Errorf("error creating request: %w", err)
+	// Return error if no query is provided
+	if qm.QueryText == "" {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "query text is empty")
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.SetBasicAuth(d.settings.Secrets.Username, d.settings.Secrets.Password)
+	if d.blockInsecureTLS() {
+		return backend.ErrDataResponse(backend.StatusForbidden, insecureTLSMessage+"; enable verification to run queries")
+	}
 
-	// Create HTTP client with optional TLS verification skip
-	transport := &http.Transport{}
-	if d.settings.InsecureSkipVerify {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	if err := d.undecryptedSecrets(); err != nil {
+		return backend.ErrDataResponse(backend.StatusUnauthorized, err.Error())
 	}
-	client := &http.Client{Transport: transport}
-	
-	// Execute request
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, nil, fmt.Errorf("error executing query: %w", err)
+	if err := forwardedIdentityError(ctx); err != nil {
+		return backend.ErrDataResponse(backend.StatusUnauthorized, err.Error())
+	}
+
+	// Navigation variables list databases, schemas and tables without SQL
+	if catalog, ok := parseCatalogQuery(qm.QueryText); ok {
+		if mode == modePublic {
+			return backend.ErrDataResponse(backend.StatusForbidden, "metadata queries can't be run from a public dashboard")
+		}
+		return d.catalogResponse(ctx, qm, catalog)
 	}
-	defer resp.Body.Close()
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	// Capacity functions return typed frames of the storage system tables
+	if function, ok := parseCapacityQuery(qm.QueryText); ok {
+		if mode == modePublic {
+			return backend.ErrDataResponse(backend.StatusForbidden, "capacity queries can't be run from a public dashboard")
+		}
+		return d.capacityResponse(ctx, qm, function)
+	}
+
+	var loc *time.Location
+	timeRange := query.TimeRange
+	if qm.LogContext != nil {
+		timeRange = qm.LogContext.timeRange()
+	}
+	statement, loc, err = d.prepareStatement(qm, timeRange, d.adhocColumnTypes(ctx, mode, qm))
 	if err != nil {
-		return nil, nil, fmt.Errorf("error reading response: %w", err)
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
 	}
-	
-	// Log full response body for debugging
-	if len(body) > 2000 {
-		backend.Logger.Debug("Response body (truncated)", "body", string(body[:2000]), "status", resp.Status)
-	} else {
-		backend.Logger.Debug("Response body", "body", string(body), "status", resp.Status)
+	if qm.LogContext != nil {
+		if statement, err = logContextStatement(statement, qm, loc); err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+		}
 	}
 
-	// Parse response
-	var response CollectionResponse
-	err = json.Unmarshal(body, &response)
+	if err := validateFieldOptions(qm.FieldOptions); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	extractions, err := compileExtractions(qm.Extract)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error parsing response: %w", err)
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
 	}
-	
-	// Log parsed response details
-	backend.Logger.Info("Parsed response", "query_id", response.QueryID, "status", response.Status, "rows", len(response.Data))
 
-	// Check for error status
-	if response.Status.SQLState != "00000" {
-		return nil, &response.Status, fmt.Errorf("query error: %s (SQL state: %s, vendor code: %d)", 
-			response.Status.Reason, response.Status.SQLState, response.Status.VendorCode)
+	if err := validateNoData(qm.NoData); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	optionNotices, err := validateOptions(qm.Options)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
 	}
 
-	return response.Data, &response.Status, nil
-}
+	if qm.QueryTimeout < 0 {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "queryTimeoutSeconds must not be negative")
+	}
 
-// convertToDataFrames converts the API response into Grafana data frames
-func convertToDataFrames(results []map[string]interface{}) (*data.Frame, error) {
-	// Define the Ocient specific timestamp format (YYYY-MM-DD HH:MM:SS.SSSSSSSSS)
-	ocientTimestampFormat := "2006-01-02 15:04:05.999999999"
-	if len(results) == 0 {
-		return data.NewFrame("response"), nil
-	}
-
-	// Create a new frame
-	frame := data.NewFrame("response")
-
-	// Extract column names from the first result
-	columns := make([]string, 0, len(results[0]))
-	for k := range results[0] {
-		columns = append(columns, k)
-	}
-
-	// Create a map for each column type
-	columnValues := make(map[string]interface{})
-	columnTypes := make(map[string]string)
-	
-	for _, col := range columns {
-		valueType := results[0][col]
-		
-		// Try to detect timestamp strings to convert them properly
-		if strVal, ok := valueType.(string); ok {
-			// Check for Ocient's specific timestamp format (YYYY-MM-DD HH:MM:SS.SSSSSSSSS)
-			_, err := time.Parse(ocientTimestampFormat, strVal)
-			if err == nil {
-				// This is an Ocient timestamp
-				values := make([]time.Time, 0, len(results))
-				columnValues[col] = values
-				columnTypes[col] = "timestamp"
-				continue
-			}
-			
-			// Check if the string looks like an ISO timestamp
-			_, err = time.Parse(time.RFC3339, strVal)
-			if err == nil {
-				// This is likely a timestamp
-				values := make([]time.Time, 0, len(results))
-				columnValues[col] = values
-				columnTypes[col] = "timestamp"
-				continue
-			}
-			
-			// Try other timestamp formats
-			_, err = time.Parse("2006-01-02 15:04:05", strVal)
-			if err == nil {
-				values := make([]time.Time, 0, len(results))
-				columnValues[col] = values
-				columnTypes[col] = "timestamp"
-				continue
-			}
-		}
-		
-		// Handle regular types
-		switch valueType.(type) {
-		case float64:
-			values := make([]float64, 0, len(results))
-			columnValues[col] = values
-			columnTypes[col] = "float64"
-		case string:
-			values := make([]string, 0, len(results))
-			columnValues[col] = values
-			columnTypes[col] = "string"
-		case bool:
-			values := make([]bool, 0, len(results))
-			columnValues[col] = values
-			columnTypes[col] = "bool"
-		default:
-			// Default to string for unknown types
-			values := make([]string, 0, len(results))
-			columnValues[col] = values
-			columnTypes[col] = "string"
-		}
-	}
-
-	// Fill the values
-	for _, result := range results {
-		for _, col := range columns {
-			val := result[col]
-			switch columnTypes[col] {
-			case "float64":
-				values := columnValues[col].([]float64)
-				if v, ok := val.(float64); ok {
-					columnValues[col] = append(values, v)
-				} else {
-					columnValues[col] = append(values, 0)
-				}
-			case "string":
-				values := columnValues[col].([]string)
-				if v, ok := val.(string); ok {
-					columnValues[col] = append(values, v)
-				} else {
-					columnValues[col] = append(values, fmt.Sprintf("%v", val))
-				}
-			case "bool":
-				values := columnValues[col].([]bool)
-				if v, ok := val.(bool); ok {
-					columnValues[col] = append(values, v)
-				} else {
-					columnValues[col] = append(values, false)
-				}
-			case "timestamp":
-				values := columnValues[col].([]time.Time)
-				if strVal, ok := val.(string); ok {
-					// Try Ocient's specific timestamp format first (YYYY-MM-DD HH:MM:SS.SSSSSSSSS)
-					t, err := time.Parse(ocientTimestampFormat, strVal)
-					if err == nil {
-						columnValues[col] = append(values, t)
-						continue
-					}
-					
-					// Try RFC3339 format
-					t, err = time.Parse(time.RFC3339, strVal)
-					if err == nil {
-						columnValues[col] = append(values, t)
-						continue
-					}
-					
-					// Try other common datetime format
-					t, err = time.Parse("2006-01-02 15:04:05", strVal)
-					if err == nil {
-						columnValues[col] = append(values, t)
-						continue
-					}
-					
-					// If parsing fails, add zero time
-					columnValues[col] = append(values, time.Time{})
-				} else {
-					columnValues[col] = append(values, time.Time{})
-				}
-			}
-		}
+	limits := d.limits(mode, qm.QueryTimeout)
+	opts := qm.conversionOptions()
+	opts.Location = loc
+	opts.TimestampLayouts = d.settings.TimestampFormats
+	opts.MaxRows = limits.maxRows
+	opts.Extractions = extractions
+	if opts.NullPolicy, err = resolveNullPolicy(qm.NullPolicy, d.settings.NullPolicy); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	if opts.NonFiniteAsNull, err = nonFiniteAsNull(d.settings.NonFiniteNumbers); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	if opts.EpochUnit, err = parseEpochUnit(qm.TimeColumnUnit); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
 	}
 
-	// Add fields to the frame
-	for _, col := range columns {
-		switch columnTypes[col] {
-		case "float64":
-			values := columnValues[col].([]float64)
-			frame.Fields = append(frame.Fields, data.NewField(col, nil, values))
-		case "string":
-			values := columnValues[col].([]string)
-			frame.Fields = append(frame.Fields, data.NewField(col, nil, values))
-		case "bool":
-			values := columnValues[col].([]bool)
-			frame.Fields = append(frame.Fields, data.NewField(col, nil, values))
-		case "timestamp":
-			values := columnValues[col].([]time.Time)
-			frame.Fields = append(frame.Fields, data.NewField(col, nil, values))
+	// Public dashboards run restricted and read-only
+	transport, err := d.environmentTransport(qm.Environment)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	if mode == modePublic {
+		if transport != d.transport {
+			return backend.ErrDataResponse(backend.StatusForbidden, "public dashboards can only query the default environment")
+		}
+		if d.publicTransport == nil {
+			return backend.ErrDataResponse(backend.StatusForbidden, "public dashboard credentials are not configured for this data source")
+		}
+		if !isReadOnlyStatement(statement) {
+			return backend.ErrDataResponse(backend.StatusForbidden, "only a single read-only statement can be run from a public dashboard")
 		}
+		transport = d.publicTransport
 	}
 
-	return frame, nil
-}
-
-func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery) backend.DataResponse {
-	var response backend.DataResponse
-
-	// Unmarshal the JSON into our queryModel.
-	var qm queryModel
-
-	err := json.Unmarshal(query.JSON, &qm)
-	if err != nil {
-		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("json unmarshal: %v", err.Error()))
+	requestCtx := ctx
+	if limits.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.timeout)
+		defer cancel()
 	}
 
-	// Return error if no query is provided
-	if qm.QueryText == "" {
-		return backend.ErrDataResponse(backend.StatusBadRequest, "query text is empty")
+	// Earlier queries of the request may have used up the deadline of Grafana
+	if ctx.Err() != nil {
+		return deadlineResponse(requestCtx, limits.timeout)
 	}
 
 	// Execute the query
-	backend.Logger.Info("Executing query:", "query", qm.QueryText, "refId", query.RefID)
-	results, status, err := d.executeQuery(ctx, qm.QueryText)
+	backend.Logger.Info("Executing query:", "query", statement, "refId", query.RefID)
+	active.running(statement)
+	executeStart := time.Now()
+	var result *QueryResult
+	if qm.RetryEmpty && isReadOnlyStatement(statement) {
+		var retries int
+		result, retries, err = executeRetryingEmpty(ctx, transport, statement)
+		if retries > 0 {
+			backend.Logger.Debug("Retried query returning no rows", "retries", retries, "refId", query.RefID)
+		}
+	} else {
+		result, err = transport.Execute(ctx, statement)
+	}
+	active.streaming()
+	executed := time.Since(executeStart)
 	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+			backend.Logger.Error("Query timed out", "timeout", limits.timeout, "refId", query.RefID, "query", statement)
+			return deadlineResponse(requestCtx, limits.timeout)
+		}
 		// If we have a status, use it to provide more detailed error information
-		if status != nil {
-			errMsg := fmt.Sprintf("Query failed: %s (SQL state: %s, vendor code: %d)", 
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			status := statusErr.Status
+			errMsg := fmt.Sprintf("Query failed: %s (SQL state: %s, vendor code: %d)",
 				status.Reason, status.SQLState, status.VendorCode)
-			backend.Logger.Error("Query failed with status", "error", errMsg, "refId", query.RefID, "query", qm.QueryText)
+			backend.Logger.Error("Query failed with status", "error", errMsg, "refId", query.RefID, "query", statement)
 			return backend.ErrDataResponse(backend.StatusInternal, errMsg)
 		}
-		backend.Logger.Error("Query execution error", "error", err.Error(), "refId", query.RefID, "query", qm.QueryText)
+		backend.Logger.Error("Query execution error", "error", err.Error(), "refId", query.RefID, "query", statement)
+		var circuitErr *CircuitOpenError
+		if errors.As(err, &circuitErr) {
+			return backend.ErrDataResponse(backend.StatusBadGateway, circuitErr.Error())
+		}
+		var gatewayErr *GatewayError
+		if errors.As(err, &gatewayErr) {
+			return backend.ErrDataResponse(backend.StatusBadGateway, fmt.Sprintf("query execution error: %v", err.Error()))
+		}
 		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("query execution error: %v", err.Error()))
 	}
-	
-	// Log the results
-	backend.Logger.Info("Query results", "count", len(results), "refId", query.RefID)
-	
-	// For schema queries, log the actual data
+
+	// Utility statements often answer with a text blob meant for a terminal
+	if isUtilityStatement(statement) {
+		if result, err = prettyUtilityResult(result); err != nil {
+			return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error parsing response: %v", err))
+		}
+	}
+
+	// For schema queries, log the actual data. These are small so decoding the
+	// rows ahead of the conversion is cheap.
 	if query.RefID == "schemas" || query.RefID == "tables" {
-		if len(results) > 0 {
-			resultJSON, _ := json.Marshal(results)
+		if err := result.decodeRows(); err == nil && len(result.Rows) > 0 {
+			resultJSON, _ := json.Marshal(result.Rows)
 			backend.Logger.Info("Schema/Table query results", "data", string(resultJSON), "refId", query.RefID)
 		} else {
 			backend.Logger.Info("Schema/Table query returned no results", "refId", query.RefID)
 		}
 	}
 
+	// Refuse results too wide to render before spending time converting them
+	if d.settings.MaxColumns > 0 && len(result.Columns) > d.settings.MaxColumns {
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf(
+			"query returned %d columns, more than the maximum of %d; select only the columns you need instead of using SELECT *",
+			len(result.Columns), d.settings.MaxColumns))
+	}
+
+	// The configured time column of the table is the time axis when selected
+	if column := d.defaultTimeColumn(qm.QueryText); qm.TimeColumn == "" && column != "" {
+		if i := findColumn(result.Columns, []string{column}); i >= 0 {
+			qm.TimeColumn = result.Columns[i].Name
+			opts.TimeColumn = qm.TimeColumn
+		}
+	}
+
 	// Convert results to data frames
-	frame, err := convertToDataFrames(results)
+	frame, err := convertToDataFrames(result, opts)
 	if err != nil {
 		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error converting results: %v", err.Error()))
 	}
+	converted := time.Since(executeStart) - executed
+
+	// Log the results
+	backend.Logger.Info("Query results", "count", frame.Rows(), "columns", len(result.Columns), "refId", query.RefID)
+
+	// A result exactly as long as the LIMIT of the query was probably cut short by it
+	if limit, ok := statementLimit(statement); ok && limit > 0 && frame.Rows() == limit {
+		frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text:     fmt.Sprintf("The query returned exactly as many rows as its LIMIT %d, more rows may match", limit),
+		})
+	}
+
+	// An empty result usually means the time range missed the data
+	if qm.ProbeEmptyRange && frame.Rows() == 0 {
+		column := qm.TimeColumn
+		if column == "" {
+			column = timeFieldName(frame)
+		}
+		if notice, ok := d.emptyRangeNotice(ctx, transport, qm.Environment, statement, column, query.TimeRange, opts); ok {
+			frame.AppendNotices(notice)
+		}
+	}
 
-	// Add the frames to the response
-	response.Frames = append(response.Frames, frame)
+	// Alert rules over sparse data stay at OK instead of flapping to NoData
+	if qm.NoData == noDataZero && frame.Rows() == 0 {
+		appendZeroPoint(frame, query.TimeRange.To)
+	}
+
+	mappings, lookupNotices := d.queryLookups(ctx, transport, qm, macroContext{timeRange: query.TimeRange, loc: loc})
+
+	frames := data.Frames{frame}
+	if qm.SplitBy != "" {
+		if frames, err = splitFrame(frame, qm.SplitBy); err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+		}
+	}
+
+	for i, frame := range frames {
+		if frame, err = shapeFrame(frame, qm, d.settings.LogLevels); err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+		}
+		// Split frames are named by their value, which legends show
+		if qm.SplitBy == "" {
+			frame.Name = frameName(qm.Alias, query.RefID, i)
+		}
+		applyFieldOptions(frame, qm.FieldOptions, mappings)
+		frame.AppendNotices(lookupNotices...)
+		frame.AppendNotices(optionNotices...)
+		if qm.enabled(featureProfiling) {
+			appendProfile(frame, executed, converted, time.Since(start))
+		}
+		if d.insecureTLS() {
+			frame.AppendNotices(insecureTLSNotice())
+		}
+		// Shown by the query inspector as the statement sent to Ocient
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		frame.Meta.ExecutedQueryString = statement
+
+		// Add the frames to the response
+		response.Frames = append(response.Frames, frame)
+	}
 
 	return response
 }
 
+// frameName names the frames of a query after its alias, or else its RefID,
+// numbering the frames after the first such as A/2.
+func frameName(alias, refID string, index int) string {
+	name := alias
+	if name == "" {
+		name = refID
+	}
+	if index == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s/%d", name, index+1)
+}
+
+// prepareStatement returns the statement a query sends to Ocient and the
+// session timezone it was written in. columnTypes type the values of ad-hoc
+// filters, which are typed by how they look when it is nil.
+func (d *Datasource) prepareStatement(qm queryModel, timeRange backend.TimeRange, columnTypes map[string]string) (string, *time.Location, error) {
+	loc, err := d.sessionLocation(qm.Timezone)
+	if err != nil {
+		return "", nil, err
+	}
+
+	text := qm.QueryText
+	if qm.Selection != nil {
+		if text, err = selectStatement(text, *qm.Selection); err != nil {
+			return "", nil, err
+		}
+	}
+
+	statement, err := expandMacros(text, macroContext{
+		timeRange:   timeRange,
+		loc:         loc,
+		filters:     qm.AdhocFilters,
+		columnTypes: columnTypes,
+		interval:    time.Duration(qm.IntervalMs) * time.Millisecond,
+		minInterval: d.minInterval(text),
+		timeColumn:  d.defaultTimeColumn(text),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Only fetch the columns the panel displays
+	statement = projectColumns(statement, qm.Fields)
+
+	// Let queries name tables without the schema of the environment
+	if d.settings.DefaultSchema != "" {
+		statement = qualifyTables(statement, d.settings.DefaultSchema)
+	}
+	return statement, loc, nil
+}
+
+// sessionLocation resolves the timezone of a query, falling back to the
+// datasource timezone and then UTC.
+func (d *Datasource) sessionLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		timezone = d.settings.Timezone
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+	return loc, nil
+}
+
+// isPublicRequest reports whether a query comes from a public dashboard, which
+// Grafana runs as its anonymous public dashboard viewer: a user with neither a
+// login nor an organization role. Requests without a user at all, such as
+// background work, and alert rule evaluations, which Grafana marks with the
+// FromAlert header, are never public. It is always false unless public
+// dashboard support is enabled in the settings.
+func (d *Datasource) isPublicRequest(req *backend.QueryDataRequest) bool {
+	if d.settings.PublicDashboards == nil || strings.EqualFold(req.GetHTTPHeader(fromAlertHeader), "true") {
+		return false
+	}
+	user := req.PluginContext.User
+	return user != nil && user.Login == "" && user.Role == ""
+}
+
 // CheckHealth handles health checks sent from Grafana to the plugin.
 // The main use case for these health checks is the test button on the
 // datasource configuration page which allows users to verify that
@@ -406,11 +772,12 @@ func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, quer
 func (d *Datasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
 	// Create a health check result
 	res := &backend.CheckHealthResult{}
+	ctx = d.withMappedCredentials(d.withForwardedIdentity(ctx, req.GetHTTPHeader(backend.OAuthIdentityTokenHeaderName)))
 
 	// Log current settings
-	backend.Logger.Info("CheckHealth - current settings", 
+	backend.Logger.Info("CheckHealth - current settings",
 		"host", d.settings.Host,
-		"port", d.settings.Port, 
+		"port", d.settings.Port,
 		"database", d.settings.Database,
 		"insecureSkipVerify", d.settings.InsecureSkipVerify,
 		"hasUsername", d.settings.Secrets.Username != "",
@@ -435,31 +802,88 @@ func (d *Datasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRe
 		return res, nil
 	}
 
-	if d.settings.Secrets.Username == "" {
+	if err := d.undecryptedSecrets(); err != nil {
+		res.Status = backend.HealthStatusError
+		res.Message = err.Error()
+		return res, nil
+	}
+	if err := forwardedIdentityError(ctx); err != nil {
+		res.Status = backend.HealthStatusError
+		res.Message = err.Error()
+		return res, nil
+	}
+
+	// Statements of signed-in users are sent with their own token
+	if d.settings.Secrets.Username == "" && !d.settings.OAuthPassThru {
 		res.Status = backend.HealthStatusError
 		res.Message = "Username is missing"
 		return res, nil
 	}
 
-	if d.settings.Secrets.Password == "" {
+	if d.settings.Secrets.Password == "" && !d.settings.OAuthPassThru {
 		res.Status = backend.HealthStatusError
 		res.Message = "Password is missing"
 		return res, nil
 	}
 
+	if _, err := d.sessionLocation(""); err != nil {
+		res.Status = backend.HealthStatusError
+		res.Message = err.Error()
+		return res, nil
+	}
+
+	for _, layout := range d.settings.TimestampFormats {
+		if !validTimestampLayout(layout) {
+			res.Status = backend.HealthStatusError
+			res.Message = fmt.Sprintf("Invalid timestamp format %q, expected a Go layout such as 2006-01-02", layout)
+			return res, nil
+		}
+	}
+
+	if _, err := resolveNullPolicy("", d.settings.NullPolicy); err != nil {
+		res.Status = backend.HealthStatusError
+		res.Message = err.Error()
+		return res, nil
+	}
+
+	if _, err := nonFiniteAsNull(d.settings.NonFiniteNumbers); err != nil {
+		res.Status = backend.HealthStatusError
+		res.Message = err.Error()
+		return res, nil
+	}
+
+	if _, err := newLevelMapper(d.settings.LogLevels, nil); err != nil {
+		res.Status = backend.HealthStatusError
+		res.Message = err.Error()
+		return res, nil
+	}
+
+	if _, err := parseMinIntervals(d.settings.MinIntervals); err != nil {
+		res.Status = backend.HealthStatusError
+		res.Message = err.Error()
+		return res, nil
+	}
+
+	if d.blockInsecureTLS() {
+		res.Status = backend.HealthStatusError
+		res.Message = insecureTLSMessage + "; enable verification or change the insecure TLS policy"
+		return res, nil
+	}
+
 	backend.Logger.Info("CheckHealth - executing test query")
 
 	// Try to execute a simple query to check the connection
-	_, status, err := d.executeQuery(ctx, "SELECT 1")
+	_, err := d.transport.Execute(ctx, "SELECT 1")
 	if err != nil {
 		errMsg := "Connection test failed"
-		if status != nil {
-			errMsg = fmt.Sprintf("Connection test failed: %s (SQL state: %s)", 
-				status.Reason, status.SQLState)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			errMsg = fmt.Sprintf("Connection test failed: %s (SQL state: %s)",
+				statusErr.Status.Reason, statusErr.Status.SQLState)
 		} else {
 			errMsg = fmt.Sprintf("Connection test failed: %s", err.Error())
 		}
-		
+
 		backend.Logger.Error("CheckHealth - connection test failed", "error", errMsg)
 		res.Status = backend.HealthStatusError
 		res.Message = errMsg
@@ -467,8 +891,25 @@ func (d *Datasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRe
 	}
 
 	backend.Logger.Info("CheckHealth - connection test succeeded")
-	return &backend.CheckHealthResult{
+	message := "Data source is working"
+	if d.insecureTLS() {
+		message += ", but " + insecureTLSMessage
+	}
+	res = &backend.CheckHealthResult{
 		Status:  backend.HealthStatusOk,
-		Message: "Data source is working",
-	}, nil
+		Message: message,
+	}
+
+	// Catch missing privileges on the objects dashboards rely on
+	if len(d.settings.HealthCheckObjects) > 0 {
+		details, failed := objectAccessDetails(d.checkObjectAccess(ctx))
+		res.JSONDetails = details
+		if len(failed) > 0 {
+			backend.Logger.Warn("CheckHealth - objects can't be read", "objects", failed)
+			res.Status = backend.HealthStatusError
+			res.Message = fmt.Sprintf("Connected, but %d of %d objects can't be read: %s",
+				len(failed), len(d.settings.HealthCheckObjects), strings.Join(failed, ", "))
+		}
+	}
+	return res, nil
 }
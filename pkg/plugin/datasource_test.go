@@ -2,9 +2,14 @@ package plugin
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/ocient/ocient-datasource/pkg/internal/fakeocient"
+	"github.com/ocient/ocient-datasource/pkg/models"
 )
 
 func TestQueryData(t *testing.T) {
@@ -26,3 +31,443 @@ func TestQueryData(t *testing.T) {
 		t.Fatal("QueryData must return a response")
 	}
 }
+
+// fakeTransport records executed statements and returns a canned result.
+type fakeTransport struct {
+	result     *QueryResult
+	err        error
+	statements []string
+}
+
+func (f *fakeTransport) Execute(_ context.Context, statement string) (*QueryResult, error) {
+	f.statements = append(f.statements, statement)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.result, nil
+}
+
+func (f *fakeTransport) Close() error {
+	return nil
+}
+
+func TestQueryDataUsesTransport(t *testing.T) {
+	transport := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "value", Type: "DOUBLE"}},
+		Rows:    [][]interface{}{{float64(1)}, {float64(2)}},
+	}}
+	ds := Datasource{transport: transport}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			Queries: []backend.DataQuery{
+				{RefID: "A", JSON: []byte(`{"queryText": "SELECT value FROM t"}`)},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatalf("unexpected error: %v", res.Error)
+	}
+	if len(transport.statements) != 1 || transport.statements[0] != "SELECT value FROM t" {
+		t.Fatalf("unexpected statements: %v", transport.statements)
+	}
+	if len(res.Frames) != 1 || res.Frames[0].Rows() != 2 {
+		t.Fatalf("expected one frame with two rows, got %v", res.Frames)
+	}
+}
+
+func TestQueryDataExecutedQueryString(t *testing.T) {
+	transport := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "value", Type: "DOUBLE"}},
+		Rows:    [][]interface{}{{float64(1)}},
+	}}
+	ds := Datasource{transport: transport}
+
+	resp, err := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				JSON:      []byte(`{"queryText": "SELECT value FROM t WHERE $__timeFilter(ts)"}`),
+				TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(60, 0)},
+			}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatalf("unexpected error: %v", res.Error)
+	}
+	want := "SELECT value FROM t WHERE ts >= '1970-01-01 00:00:00' AND ts <= '1970-01-01 00:01:00'"
+	if got := res.Frames[0].Meta.ExecutedQueryString; got != want {
+		t.Errorf("got executed query %q, want %q", got, want)
+	}
+}
+
+func TestQueryDataFrameNames(t *testing.T) {
+	transport := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "host", Type: "VARCHAR"}, {Name: "value", Type: "DOUBLE"}},
+		Rows:    [][]interface{}{{"a", float64(1)}, {"b", float64(2)}},
+	}}
+	ds := Datasource{transport: transport}
+
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{RefID: "A", JSON: []byte(`{"queryText": "SELECT host, value FROM t"}`)},
+			{RefID: "B", JSON: []byte(`{"queryText": "SELECT host, value FROM t", "alias": "load"}`)},
+			{RefID: "C", JSON: []byte(`{"queryText": "SELECT host, value FROM t", "splitBy": "host"}`)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{"A": {"A"}, "B": {"load"}, "C": {"a", "b"}}
+	for refID, names := range want {
+		res := resp.Responses[refID]
+		if res.Error != nil {
+			t.Fatalf("%s: %v", refID, res.Error)
+		}
+		if len(res.Frames) != len(names) {
+			t.Fatalf("%s: expected %d frames, got %d", refID, len(names), len(res.Frames))
+		}
+		for i, frame := range res.Frames {
+			if frame.Name != names[i] || frame.RefID != refID {
+				t.Errorf("%s: frame %d is named %q with RefID %q", refID, i, frame.Name, frame.RefID)
+			}
+		}
+	}
+	if got := frameName("", "A", 1); got != "A/2" {
+		t.Errorf("expected the second frame to be named A/2, got %q", got)
+	}
+}
+
+func TestQueryDataStatusError(t *testing.T) {
+	transport := &fakeTransport{err: &StatusError{Status: OcientStatus{Reason: "table not found", SQLState: "42S02"}}}
+	ds := Datasource{transport: transport}
+
+	resp, _ := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			Queries: []backend.DataQuery{
+				{RefID: "A", JSON: []byte(`{"queryText": "SELECT * FROM missing"}`)},
+			},
+		},
+	)
+
+	res := resp.Responses["A"]
+	if res.Error == nil || res.Error.Error() != "Query failed: table not found (SQL state: 42S02, vendor code: 0)" {
+		t.Fatalf("unexpected error: %v", res.Error)
+	}
+}
+
+func TestQueryDataMaxColumns(t *testing.T) {
+	transport := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+	}}
+	ds := Datasource{transport: transport, settings: models.PluginSettings{MaxColumns: 2}}
+
+	resp, _ := ds.QueryData(
+		context.Background(),
+		&backend.QueryDataRequest{
+			Queries: []backend.DataQuery{
+				{RefID: "A", JSON: []byte(`{"queryText": "SELECT * FROM wide"}`)},
+			},
+		},
+	)
+
+	res := resp.Responses["A"]
+	if res.Error == nil || res.Status != backend.StatusBadRequest {
+		t.Fatalf("expected a bad request error, got %v", res.Error)
+	}
+}
+
+func TestQueryDataNotCached(t *testing.T) {
+	transport := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "value", Type: "DOUBLE"}},
+		Rows:    [][]interface{}{{float64(1)}},
+	}}
+	ds := Datasource{transport: transport}
+	req := &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{RefID: "A", JSON: []byte(`{"queryText": "SELECT value FROM t"}`)},
+		},
+	}
+
+	// Caching responses is left to Grafana's query caching
+	for i := 0; i < 2; i++ {
+		if _, err := ds.QueryData(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(transport.statements) != 2 {
+		t.Fatalf("expected every query to run, got %d executions", len(transport.statements))
+	}
+}
+
+func TestQueryDataPublicDashboard(t *testing.T) {
+	private := &fakeTransport{}
+	public := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "value", Type: "DOUBLE"}},
+		Rows:    [][]interface{}{{float64(1)}, {float64(2)}, {float64(3)}},
+	}}
+	ds := Datasource{
+		settings:        models.PluginSettings{PublicDashboards: &models.PublicDashboardSettings{MaxRows: 2}},
+		transport:       private,
+		publicTransport: public,
+	}
+	req := &backend.QueryDataRequest{
+		// Grafana's anonymous public dashboard viewer
+		PluginContext: backend.PluginContext{User: &backend.User{}},
+		Queries: []backend.DataQuery{
+			{RefID: "A", JSON: []byte(`{"queryText": "SELECT value FROM t"}`)},
+			{RefID: "B", JSON: []byte(`{"queryText": "DELETE FROM t"}`)},
+		},
+	}
+
+	resp, err := ds.QueryData(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(private.statements) != 0 || len(public.statements) != 1 {
+		t.Fatalf("expected only the read-only statement on the public transport, got %v and %v", private.statements, public.statements)
+	}
+	frame := resp.Responses["A"].Frames[0]
+	if frame.Rows() != 2 || len(frame.Meta.Notices) != 1 {
+		t.Errorf("expected 2 rows and a truncation notice, got %d rows and %v", frame.Rows(), frame.Meta)
+	}
+	if resp.Responses["B"].Status != backend.StatusForbidden {
+		t.Errorf("expected the write statement to be forbidden, got %v", resp.Responses["B"].Status)
+	}
+
+	// Signed-in users keep using the regular transport
+	req.PluginContext.User = &backend.User{Login: "admin"}
+	private.result = public.result
+	if _, err := ds.QueryData(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if len(private.statements) != 2 {
+		t.Errorf("expected signed-in queries on the regular transport, got %v", private.statements)
+	}
+}
+
+func TestAlertQueriesNotPublic(t *testing.T) {
+	ds := Datasource{settings: models.PluginSettings{PublicDashboards: &models.PublicDashboardSettings{MaxRows: 2}}}
+
+	// Alert rule evaluations come without a user, or as the anonymous one
+	for _, user := range []*backend.User{nil, {}} {
+		req := &backend.QueryDataRequest{PluginContext: backend.PluginContext{User: user}}
+		req.SetHTTPHeader(fromAlertHeader, "true")
+		req.SetHTTPHeader(cacheSkipHeader, "true")
+		if mode := ds.executionMode(req); mode != modeInteractive {
+			t.Errorf("alert query as %+v: got mode %s, want interactive", user, mode)
+		}
+	}
+	// Background requests have no user at all
+	if mode := ds.executionMode(&backend.QueryDataRequest{}); mode != modeInteractive {
+		t.Errorf("background query: got mode %s, want interactive", mode)
+	}
+	if mode := ds.executionMode(&backend.QueryDataRequest{PluginContext: backend.PluginContext{User: &backend.User{}}}); mode != modePublic {
+		t.Errorf("public dashboard query: got mode %s, want public", mode)
+	}
+}
+
+func TestQueryDataReportingLimits(t *testing.T) {
+	transport := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "value", Type: "DOUBLE"}},
+		Rows:    [][]interface{}{{float64(1)}, {float64(2)}, {float64(3)}},
+	}}
+	ds := Datasource{
+		settings: models.PluginSettings{
+			MaxRows:   1,
+			Reporting: &models.ReportingSettings{Header: models.DefaultReportingHeader, MaxRows: 10},
+		},
+		transport: transport,
+	}
+	req := &backend.QueryDataRequest{
+		PluginContext: backend.PluginContext{User: &backend.User{Login: "admin"}},
+		Queries: []backend.DataQuery{
+			{RefID: "A", JSON: []byte(`{"queryText": "SELECT value FROM t"}`)},
+		},
+	}
+
+	resp, _ := ds.QueryData(context.Background(), req)
+	if rows := resp.Responses["A"].Frames[0].Rows(); rows != 1 {
+		t.Errorf("expected the interactive limit of 1 row, got %d", rows)
+	}
+
+	req.SetHTTPHeader(models.DefaultReportingHeader, "true")
+	resp, _ = ds.QueryData(context.Background(), req)
+	if rows := resp.Responses["A"].Frames[0].Rows(); rows != 3 {
+		t.Errorf("expected complete data for reports, got %d rows", rows)
+	}
+}
+
+func TestQueryTimeoutOverride(t *testing.T) {
+	ds := Datasource{settings: models.PluginSettings{
+		QueryTimeout:     60,
+		PublicDashboards: &models.PublicDashboardSettings{MaxRows: 10},
+		Reporting:        &models.ReportingSettings{QueryTimeout: 300},
+	}}
+	tests := []struct {
+		mode         executionMode
+		queryTimeout int
+		want         time.Duration
+	}{
+		{modeInteractive, 0, time.Minute},
+		{modeInteractive, 10, 10 * time.Second},
+		{modeInteractive, 120, 2 * time.Minute},
+		{modeReporting, 0, 5 * time.Minute},
+		{modeReporting, 30, 30 * time.Second},
+		{modePublic, 10, 10 * time.Second},
+		{modePublic, 120, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := ds.limits(tt.mode, tt.queryTimeout).timeout; got != tt.want {
+			t.Errorf("%s query with a %ds timeout: got %s, want %s", tt.mode, tt.queryTimeout, got, tt.want)
+		}
+	}
+
+	ds.transport = &fakeTransport{}
+	resp, _ := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"queryText": "SELECT 1", "queryTimeoutSeconds": -1}`)}},
+	})
+	if resp.Responses["A"].Status != backend.StatusBadRequest {
+		t.Errorf("expected a negative timeout to be rejected, got %v", resp.Responses["A"].Status)
+	}
+}
+
+// hangingTransport runs statements until their context is done.
+type hangingTransport struct{}
+
+func (hangingTransport) Execute(ctx context.Context, _ string) (*QueryResult, error) {
+	<-ctx.Done()
+	return nil, fmt.Errorf("post: %w", ctx.Err())
+}
+
+func (hangingTransport) Close() error {
+	return nil
+}
+
+func TestQueryDataDeadline(t *testing.T) {
+	ds := Datasource{transport: hangingTransport{}}
+	query := func(ctx context.Context, body string) backend.DataResponse {
+		resp, err := ds.QueryData(ctx, &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(body)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Responses["A"]
+	}
+
+	// The deadline of the Grafana request
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	res := query(ctx, `{"queryText": "SELECT 1"}`)
+	if res.Status != backend.StatusTimeout || !strings.Contains(res.Error.Error(), "deadline of the Grafana request") {
+		t.Errorf("got %v: %v", res.Status, res.Error)
+	}
+	// Queries after the deadline passed are not sent
+	if res = query(ctx, `{"queryText": "SELECT 1"}`); res.Status != backend.StatusTimeout {
+		t.Errorf("got %v: %v", res.Status, res.Error)
+	}
+
+	// The timeout of the query, before the deadline
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	res = query(ctx, `{"queryText": "SELECT 1", "queryTimeoutSeconds": 1}`)
+	if res.Status != backend.StatusTimeout || !strings.Contains(res.Error.Error(), "timed out after 1s") {
+		t.Errorf("got %v: %v", res.Status, res.Error)
+	}
+}
+
+func TestQueryDataInsecureTLS(t *testing.T) {
+	transport := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "value", Type: "DOUBLE"}},
+		Rows:    [][]interface{}{{float64(1)}},
+	}}
+	ds := Datasource{
+		settings:  models.PluginSettings{InsecureSkipVerify: true, InsecurePolicy: models.InsecurePolicyWarn},
+		transport: transport,
+	}
+	req := &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{RefID: "A", JSON: []byte(`{"queryText": "SELECT value FROM t"}`)},
+		},
+	}
+
+	resp, _ := ds.QueryData(context.Background(), req)
+	if meta := resp.Responses["A"].Frames[0].Meta; meta == nil || len(meta.Notices) != 1 {
+		t.Fatalf("expected an insecure TLS notice, got %v", meta)
+	}
+
+	ds.settings.InsecurePolicy = models.InsecurePolicyBlock
+	resp, _ = ds.QueryData(context.Background(), req)
+	if resp.Responses["A"].Status != backend.StatusForbidden || len(transport.statements) != 1 {
+		t.Errorf("expected the query to be blocked, got status %v", resp.Responses["A"].Status)
+	}
+}
+
+func TestUndecryptedSecrets(t *testing.T) {
+	settings, err := models.LoadPluginSettings(backend.DataSourceInstanceSettings{
+		JSONData:                []byte(`{"host": "ocient", "database": "system", "configuredSecrets": ["username", "password"]}`),
+		DecryptedSecureJSONData: map[string]string{"username": "admin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &fakeTransport{result: &QueryResult{Columns: []Column{{Name: "value", Type: "DOUBLE"}}}}
+	ds := Datasource{settings: *settings, transport: transport}
+
+	health, _ := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if health.Status != backend.HealthStatusError || !strings.Contains(health.Message, "could not decrypt the saved password") {
+		t.Errorf("got health %v %q", health.Status, health.Message)
+	}
+
+	resp, _ := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"queryText": "SELECT value FROM t"}`)}},
+	})
+	if resp.Responses["A"].Status != backend.StatusUnauthorized || len(transport.statements) != 0 {
+		t.Errorf("got status %v, want the query refused", resp.Responses["A"].Status)
+	}
+
+	ds.settings.UndecryptedSecrets = nil
+	if err := ds.undecryptedSecrets(); err != nil {
+		t.Errorf("got %v with every secret decrypted", err)
+	}
+}
+
+func TestDefaultQueryTimeout(t *testing.T) {
+	settings, err := models.LoadPluginSettings(backend.DataSourceInstanceSettings{JSONData: []byte(`{}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.QueryTimeout != models.DefaultQueryTimeoutSeconds {
+		t.Fatalf("got a query timeout of %ds, want the default of %ds", settings.QueryTimeout, models.DefaultQueryTimeoutSeconds)
+	}
+
+	// A hung node fails the query instead of blocking it
+	settings.QueryTimeout = 1
+	transport, _ := newFakeRESTTransport(t, fakeocient.WithLatency(time.Minute))
+	ds := Datasource{settings: *settings, transport: transport}
+	start := time.Now()
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"queryText": "SELECT 1"}`)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := resp.Responses["A"]; res.Status != backend.StatusTimeout || time.Since(start) > 10*time.Second {
+		t.Errorf("expected the query to time out after a second, got %v after %s", res.Error, time.Since(start))
+	}
+}
@@ -0,0 +1,158 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// discoveryTimeout bounds a resolution of the SQL nodes.
+const discoveryTimeout = 10 * time.Second
+
+// endpointDiscovery resolves the SQL nodes of a datasource from DNS SRV
+// records or a discovery URL, so that hosts don't need to be configured in
+// every datasource. The nodes resolved last are kept when a refresh fails.
+type endpointDiscovery struct {
+	settings models.DiscoverySettings
+	port     int
+	client   *http.Client
+	// lookupSRV is replaced by tests
+	lookupSRV func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+	set atomic.Pointer[endpointSet]
+}
+
+func newEndpointDiscovery(settings models.PluginSettings, client *http.Client) *endpointDiscovery {
+	return &endpointDiscovery{settings: *settings.Discovery, port: settings.Port, client: client, lookupSRV: net.DefaultResolver.LookupSRV}
+}
+
+// endpointSet returns the SQL nodes discovered last, nil before any are.
+func (d *endpointDiscovery) endpointSet() *endpointSet {
+	if d == nil {
+		return nil
+	}
+	return d.set.Load()
+}
+
+// refresh resolves the SQL nodes again. Statements in flight keep the nodes
+// they were sent to.
+func (d *endpointDiscovery) refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+	var addrs []string
+	var err error
+	if d.settings.SRV != "" {
+		addrs, err = d.resolveSRV(ctx)
+	} else {
+		addrs, err = d.resolveURL(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no SQL nodes discovered")
+	}
+	if current := d.set.Load(); current == nil || !slices.Equal(current.addrs, addrs) {
+		backend.Logger.Info("Discovered SQL nodes", "endpoints", addrs)
+		d.set.Store(newEndpointSet(addrs))
+	}
+	return nil
+}
+
+// resolveSRV returns the targets of the SRV records, by priority. Records of
+// the same priority are ordered by name rather than weight, so that the
+// nodes only change when the records do.
+func (d *endpointDiscovery) resolveSRV(ctx context.Context) ([]string, error) {
+	_, records, err := d.lookupSRV(ctx, "", "", d.settings.SRV)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", d.settings.SRV, err)
+	}
+	slices.SortStableFunc(records, func(a, b *net.SRV) int {
+		if a.Priority != b.Priority {
+			return int(a.Priority) - int(b.Priority)
+		}
+		return strings.Compare(a.Target, b.Target)
+	})
+	addrs := make([]string, 0, len(records))
+	for _, r := range records {
+		addrs = append(addrs, net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port))))
+	}
+	return addrs, nil
+}
+
+// resolveURL fetches the JSON array of host or host:port strings served by
+// the discovery URL. Hosts without a port take the port of the settings.
+func (d *endpointDiscovery) resolveURL(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.settings.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent(ctx))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", d.settings.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", d.settings.URL, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", d.settings.URL, err)
+	}
+	var hosts []string
+	if err := json.Unmarshal(body, &hosts); err != nil {
+		return nil, fmt.Errorf("%s must answer a JSON array of hosts: %w", d.settings.URL, err)
+	}
+	port := strconv.Itoa(d.port)
+	addrs := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, port)
+		}
+		addrs = append(addrs, host)
+	}
+	return addrs, nil
+}
+
+// startDiscovery resolves the SQL nodes again every refresh interval in the
+// background. Dispose stops it.
+func (d *Datasource) startDiscovery() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	d.stopDiscovery = func() {
+		cancel()
+		<-done
+	}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(time.Duration(d.settings.Discovery.RefreshSeconds) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := d.discovery.refresh(ctx); err != nil && ctx.Err() == nil {
+					backend.Logger.Warn("Failed to refresh the discovered SQL nodes, keeping the previous ones", "error", err.Error())
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/ocient/ocient-datasource/pkg/internal/fakeocient"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+func TestDiscoverySRV(t *testing.T) {
+	settings := models.PluginSettings{Port: 443, Discovery: &models.DiscoverySettings{SRV: "_ocient._tcp.example.com"}}
+	discovery := newEndpointDiscovery(settings, http.DefaultClient)
+	var records []*net.SRV
+	var lookupErr error
+	discovery.lookupSRV = func(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+		if name != "_ocient._tcp.example.com" {
+			t.Errorf("looked up %q", name)
+		}
+		return "", records, lookupErr
+	}
+
+	records = []*net.SRV{
+		{Target: "sql2.example.com.", Port: 4050, Priority: 10},
+		{Target: "sql1.example.com.", Port: 4050, Priority: 10},
+		{Target: "backup.example.com.", Port: 4051, Priority: 20},
+	}
+	if err := discovery.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := "sql1.example.com:4050,sql2.example.com:4050,backup.example.com:4051"
+	if got := strings.Join(discovery.endpointSet().addrs, ","); got != want {
+		t.Errorf("got endpoints %s, want %s", got, want)
+	}
+
+	// A failed refresh keeps the nodes discovered before
+	lookupErr = errors.New("no such host")
+	if err := discovery.refresh(context.Background()); err == nil {
+		t.Error("expected the refresh to fail")
+	}
+	if got := strings.Join(discovery.endpointSet().addrs, ","); got != want {
+		t.Errorf("got endpoints %s after a failed refresh, want %s", got, want)
+	}
+}
+
+func TestDiscoveryURL(t *testing.T) {
+	server := fakeocient.NewServer(fakeocient.WithDatasets(fakeocient.Dataset{
+		Rows: []map[string]interface{}{{"a": float64(1)}},
+	}))
+	t.Cleanup(server.Close)
+	discoveryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`["` + server.Listener.Addr().String() + `"]`))
+	}))
+	t.Cleanup(discoveryServer.Close)
+
+	settings := models.PluginSettings{Secrets: &models.SecretPluginSettings{}}
+	if err := useFakeServer(&settings, server); err != nil {
+		t.Fatal(err)
+	}
+	// The configured host is never contacted once nodes are discovered
+	settings.Host = "unconfigured.invalid"
+	settings.Discovery = &models.DiscoverySettings{URL: discoveryServer.URL}
+	client, err := newHTTPClient(settings, httpclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	discovery := newEndpointDiscovery(settings, client)
+	if err := discovery.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	transport, err := newTransport(settings, client, discovery, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transport.Execute(context.Background(), "SELECT a FROM t"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("the discovered node received %d statements, want 1", n)
+	}
+}
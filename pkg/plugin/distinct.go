@@ -0,0 +1,31 @@
+package plugin
+
+import (
+	"encoding/json"
+)
+
+// removeDuplicateRows drops rows that are exact duplicates of an earlier row,
+// keeping the first occurrence. It returns the result and the number of rows removed.
+func removeDuplicateRows(result *QueryResult) (*QueryResult, int) {
+	seen := make(map[string]struct{}, len(result.Rows))
+	rows := make([][]interface{}, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		key, err := json.Marshal(row)
+		if err != nil {
+			// Rows that can't be keyed are never considered duplicates
+			rows = append(rows, row)
+			continue
+		}
+		if _, dup := seen[string(key)]; dup {
+			continue
+		}
+		seen[string(key)] = struct{}{}
+		rows = append(rows, row)
+	}
+
+	removed := len(result.Rows) - len(rows)
+	if removed == 0 {
+		return result, 0
+	}
+	return &QueryResult{QueryID: result.QueryID, Columns: result.Columns, Rows: rows}, removed
+}
@@ -0,0 +1,68 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// defaultEnvironment selects the default cluster, like an empty environment,
+// so that a dashboard variable can offer it next to the named environments.
+const defaultEnvironment = "default"
+
+// environmentSettings returns the settings for queries against env, which
+// inherits whatever it doesn't set from the default cluster.
+func environmentSettings(base models.PluginSettings, env models.EnvironmentSettings) models.PluginSettings {
+	settings := base
+	if env.Host != "" {
+		settings.Host, settings.Hosts = env.Host, nil
+	}
+	if env.Port != 0 {
+		settings.Port = env.Port
+	}
+	if env.Database != "" {
+		settings.Database = env.Database
+	}
+	secrets := models.SecretPluginSettings{}
+	if base.Secrets != nil {
+		secrets = *base.Secrets
+	}
+	// Credentials of the environment's own aren't mapped per user
+	if env.Username != "" {
+		secrets.Username, secrets.Password = env.Username, env.Password
+		settings.CredentialMappings = nil
+	}
+	settings.Secrets = &secrets
+	return settings
+}
+
+// environmentDiscovery returns the discovery of the SQL nodes of the named
+// environment: that of the default cluster, unless the environment has a
+// host of its own.
+func (d *Datasource) environmentDiscovery(name string) *endpointDiscovery {
+	for _, env := range d.settings.Environments {
+		if env.Name == name && env.Host != "" {
+			return nil
+		}
+	}
+	return d.discovery
+}
+
+// environmentTransport returns the transport for the named environment. The
+// name usually comes from a dashboard variable.
+func (d *Datasource) environmentTransport(name string) (QueryTransport, error) {
+	if name == "" || name == defaultEnvironment {
+		return d.transport, nil
+	}
+	if transport, ok := d.environments[name]; ok {
+		return transport, nil
+	}
+	names := []string{defaultEnvironment}
+	for env := range d.environments {
+		names = append(names, env)
+	}
+	sort.Strings(names[1:])
+	return nil, fmt.Errorf("unknown environment %q, expected one of %s", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+func TestEnvironmentSettings(t *testing.T) {
+	base := models.PluginSettings{
+		Host: "prod.example.com", Port: 4050, Database: "metrics",
+		Secrets: &models.SecretPluginSettings{Username: "grafana", Password: "secret"},
+	}
+
+	got := environmentSettings(base, models.EnvironmentSettings{Name: "dev", Host: "dev.example.com"})
+	if got.Host != "dev.example.com" || got.Port != 4050 || got.Database != "metrics" {
+		t.Errorf("unexpected endpoint %s:%d/%s", got.Host, got.Port, got.Database)
+	}
+	if got.Secrets.Username != "grafana" || got.Secrets.Password != "secret" {
+		t.Errorf("expected inherited credentials, got %+v", got.Secrets)
+	}
+
+	got = environmentSettings(base, models.EnvironmentSettings{Name: "stage", Username: "stage", Password: "pw"})
+	if got.Secrets.Username != "stage" || got.Secrets.Password != "pw" {
+		t.Errorf("expected environment credentials, got %+v", got.Secrets)
+	}
+	if base.Secrets.Username != "grafana" {
+		t.Error("the default credentials must not be modified")
+	}
+}
+
+func TestQueryDataEnvironment(t *testing.T) {
+	result := &QueryResult{Columns: []Column{{Name: "value", Type: "INT"}}, Rows: [][]interface{}{{1}}}
+	prod := &fakeTransport{result: result}
+	dev := &fakeTransport{result: result}
+	ds := Datasource{transport: prod, environments: map[string]QueryTransport{"dev": dev}}
+
+	query := func(body string, headers map[string]string) backend.DataResponse {
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Headers: headers,
+			Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(body)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Responses["A"]
+	}
+
+	if res := query(`{"queryText": "SELECT 1", "environment": "dev"}`, nil); res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if res := query(`{"queryText": "SELECT 2", "environment": "default"}`, nil); res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(dev.statements) != 1 || len(prod.statements) != 1 {
+		t.Errorf("expected one statement per environment, got dev %q and prod %q", dev.statements, prod.statements)
+	}
+
+	res := query(`{"queryText": "SELECT 1", "environment": "qa"}`, nil)
+	if res.Status != backend.StatusBadRequest || !strings.Contains(res.Error.Error(), "default, dev") {
+		t.Errorf("expected an unknown environment error listing the environments, got %v", res.Error)
+	}
+}
@@ -0,0 +1,94 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// epochUnits are the units of numeric time values, by the name the
+// timeColumnUnit query option takes. Numeric time values are milliseconds
+// unless the option says otherwise.
+var epochUnits = map[string]time.Duration{
+	"s":  time.Second,
+	"ms": time.Millisecond,
+	"us": time.Microsecond,
+	"ns": time.Nanosecond,
+}
+
+// parseEpochUnit returns the unit of numeric time values named by the
+// timeColumnUnit query option, milliseconds when it is empty.
+func parseEpochUnit(name string) (time.Duration, error) {
+	if name == "" {
+		return time.Millisecond, nil
+	}
+	unit, ok := epochUnits[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown time column unit %q, expected s, ms, us or ns", name)
+	}
+	return unit, nil
+}
+
+// epochTime converts a number of units since the epoch to a time. Telemetry
+// tables often keep nanoseconds in BIGINT or DECIMAL columns, beyond the
+// precision of float64, so integral and decimal values are converted exactly
+// from their digits; other numbers go through float64.
+func epochTime(v interface{}, unit time.Duration) (time.Time, bool) {
+	switch val := v.(type) {
+	case int64:
+		return epochInt(val, unit), true
+	case json.Number:
+		if t, ok := epochDecimal(string(val), unit); ok {
+			return t, true
+		}
+	case string:
+		if t, ok := epochDecimal(strings.TrimSpace(val), unit); ok {
+			return t, true
+		}
+		return time.Time{}, false
+	}
+	f, ok := toFloat64(v)
+	if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+		return time.Time{}, false
+	}
+	sec, frac := math.Modf(f * float64(unit) / float64(time.Second))
+	return time.Unix(int64(sec), int64(frac*float64(time.Second))).UTC(), true
+}
+
+// epochInt converts an integral number of units since the epoch to a time.
+func epochInt(n int64, unit time.Duration) time.Time {
+	perSecond := int64(time.Second / unit)
+	return time.Unix(n/perSecond, n%perSecond*int64(unit)).UTC()
+}
+
+// epochDecimal converts a decimal number of units such as
+// 1700000000123456789 or 1700000000.123456 to a time without rounding to
+// float64. Digits below a nanosecond are dropped.
+func epochDecimal(s string, unit time.Duration) (time.Time, bool) {
+	whole, frac, _ := strings.Cut(s, ".")
+	n, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || strings.Trim(frac, "0123456789") != "" {
+		return time.Time{}, false
+	}
+	t := epochInt(n, unit)
+	if frac == "" {
+		return t, true
+	}
+	// The fraction of a unit, in nanoseconds
+	digits := len(strconv.FormatInt(int64(unit), 10)) - 1
+	frac = (frac + strings.Repeat("0", digits))[:digits]
+	if frac == "" {
+		return t, true
+	}
+	nanos, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if strings.HasPrefix(whole, "-") {
+		nanos = -nanos
+	}
+	return t.Add(time.Duration(nanos)), true
+}
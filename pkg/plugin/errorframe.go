@@ -0,0 +1,38 @@
+package plugin
+
+import (
+	"encoding/json"
+	"regexp"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// sqlStatePattern finds the SQL state in the message of a failed query.
+var sqlStatePattern = regexp.MustCompile(`SQL state: ([0-9A-Z]{5})`)
+
+// errorFrameRequested reports whether a query asks for its errors as an
+// error frame, with the errorFrame option.
+func errorFrameRequested(query backend.DataQuery) bool {
+	var qm queryModel
+	return json.Unmarshal(query.JSON, &qm) == nil && qm.ErrorFrame
+}
+
+// errorFrameResponse turns a failed response into a successful one with a
+// single row holding the time of the failure, the error message and its SQL
+// state, for table panels on kiosk dashboards where a panel error shows
+// nothing but an icon.
+func errorFrameResponse(res backend.DataResponse, at time.Time) backend.DataResponse {
+	sqlState := ""
+	if match := sqlStatePattern.FindStringSubmatch(res.Error.Error()); match != nil {
+		sqlState = match[1]
+	}
+	frame := data.NewFrame("error",
+		data.NewField("time", nil, []time.Time{at}),
+		data.NewField("message", nil, []string{res.Error.Error()}),
+		data.NewField("sqlstate", nil, []string{sqlState}),
+	)
+	setFrameType(frame, data.FrameTypeTable, data.VisTypeTable)
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
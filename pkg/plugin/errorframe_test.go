@@ -0,0 +1,44 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryDataErrorFrame(t *testing.T) {
+	ds := Datasource{transport: &fakeTransport{err: &StatusError{Status: OcientStatus{Reason: "table not found", SQLState: "42S02", VendorCode: -2101}}}}
+
+	body, _ := json.Marshal(map[string]interface{}{"queryText": "SELECT * FROM missing", "errorFrame": true})
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: body}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := resp.Responses["A"]
+	if r.Error != nil {
+		t.Fatalf("expected an error frame, got error %v", r.Error)
+	}
+	frame := r.Frames[0]
+	if frame.RefID != "A" || frame.Rows() != 1 {
+		t.Fatalf("unexpected frame %s with %d rows", frame.RefID, frame.Rows())
+	}
+	if msg := frame.Fields[1].At(0).(string); msg != "Query failed: table not found (SQL state: 42S02, vendor code: -2101)" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if state := frame.Fields[2].At(0).(string); state != "42S02" {
+		t.Errorf("got SQL state %q, want 42S02", state)
+	}
+
+	// Without the option the panel gets the error
+	body, _ = json.Marshal(map[string]interface{}{"queryText": "SELECT * FROM missing"})
+	resp, _ = ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: body}},
+	})
+	if resp.Responses["A"].Error == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,213 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// estimateTimeout bounds the EXPLAIN statement of a cost estimate, which is
+// run from the editor and shouldn't keep it waiting.
+const estimateTimeout = 15 * time.Second
+
+// Cost classes of an estimate, by the bytes or else the rows it is expected
+// to scan.
+const (
+	costUnknown  = "unknown"
+	costLow      = "low"
+	costMedium   = "medium"
+	costHigh     = "high"
+	costVeryHigh = "very high"
+)
+
+// Estimates in EXPLAIN output, such as "rows=1200", "estimated_rows: 1200" or
+// "estimatedBytes": 4.2e12. Any key containing rows or bytes counts.
+var (
+	explainRows  = regexp.MustCompile(`(?i)\w*rows\w*"?\s*[:=]\s*"?(\d+(?:\.\d+)?(?:e[+-]?\d+)?)`)
+	explainBytes = regexp.MustCompile(`(?i)\w*bytes\w*"?\s*[:=]\s*"?(\d+(?:\.\d+)?(?:e[+-]?\d+)?)`)
+)
+
+// estimateRequest is the body of the /estimate resource: the query as sent
+// by the editor and the time range its macros are expanded against.
+type estimateRequest struct {
+	queryModel
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// costEstimate is the response of the /estimate resource.
+type costEstimate struct {
+	Statement string   `json:"statement"`
+	Rows      *float64 `json:"rows,omitempty"`
+	Bytes     *float64 `json:"bytes,omitempty"`
+	CostClass string   `json:"costClass"`
+	// Summary is shown by the editor, such as "This will scan ~4.2 TB"
+	Summary string `json:"summary"`
+}
+
+// handleEstimate estimates the cost of a query from the plan Ocient returns
+// for it, without running it.
+func (d *Datasource) handleEstimate(w http.ResponseWriter, r *http.Request) {
+	var req estimateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if req.QueryText == "" {
+		writeError(w, http.StatusBadRequest, "query text is empty")
+		return
+	}
+	if d.blockInsecureTLS() {
+		writeError(w, http.StatusForbidden, insecureTLSMessage+"; enable verification to run queries")
+		return
+	}
+
+	statement, _, err := d.prepareStatement(req.queryModel, backend.TimeRange{From: req.From, To: req.To}, nil)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !isReadOnlyStatement(statement) || isUtilityStatement(statement) {
+		writeError(w, http.StatusBadRequest, "only a single SELECT statement can be estimated")
+		return
+	}
+	transport, err := d.environmentTransport(req.Environment)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), estimateTimeout)
+	defer cancel()
+	result, err := transport.Execute(ctx, "EXPLAIN "+strings.TrimRight(strings.TrimSpace(statement), ";"))
+	if err == nil {
+		err = result.decodeRows()
+	}
+	if err != nil {
+		backend.Logger.Error("Cost estimate failed", "error", err.Error(), "query", statement)
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("EXPLAIN failed: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, estimateCost(statement, explainText(result)))
+}
+
+// explainText renders an EXPLAIN result as "name: value" lines, so that
+// estimates are found whether the plan is text, a key and value table or a
+// table with a column per estimate.
+func explainText(result *QueryResult) string {
+	var b strings.Builder
+	for _, row := range result.Rows {
+		if key, ok := row[0].(string); ok && len(row) == 2 && len(result.Columns) == 2 && result.Columns[0].Name == "key" {
+			fmt.Fprintf(&b, "%s: %s\n", key, explainValue(row[1]))
+			continue
+		}
+		for i, value := range row {
+			name := ""
+			if i < len(result.Columns) {
+				name = result.Columns[i].Name
+			}
+			fmt.Fprintf(&b, "%s: %s\n", name, explainValue(value))
+		}
+	}
+	return b.String()
+}
+
+// explainValue formats a cell of an EXPLAIN result, keeping JSON plans as JSON.
+func explainValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// estimateCost reads the row and byte estimates from a plan. Plans carry an
+// estimate per operator, so the largest one is taken as the cost of the query.
+func estimateCost(statement, plan string) costEstimate {
+	estimate := costEstimate{Statement: statement, CostClass: costUnknown}
+	estimate.Rows = largestEstimate(explainRows, plan)
+	estimate.Bytes = largestEstimate(explainBytes, plan)
+
+	var parts []string
+	switch {
+	case estimate.Bytes != nil:
+		estimate.CostClass = costClass(*estimate.Bytes, 1<<30, 100<<30, 1<<40)
+		parts = append(parts, "scan ~"+formatBytes(*estimate.Bytes))
+		if estimate.Rows != nil {
+			parts = append(parts, "read ~"+formatCount(*estimate.Rows)+" rows")
+		}
+	case estimate.Rows != nil:
+		estimate.CostClass = costClass(*estimate.Rows, 1e6, 1e8, 1e10)
+		parts = append(parts, "read ~"+formatCount(*estimate.Rows)+" rows")
+	}
+	if len(parts) == 0 {
+		estimate.Summary = "The plan has no row or byte estimates"
+	} else {
+		estimate.Summary = "This will " + strings.Join(parts, " and ")
+	}
+	return estimate
+}
+
+// largestEstimate returns the largest number pattern captures in plan.
+func largestEstimate(pattern *regexp.Regexp, plan string) *float64 {
+	var largest *float64
+	for _, match := range pattern.FindAllStringSubmatch(plan, -1) {
+		n, err := strconv.ParseFloat(match[1], 64)
+		if err != nil || math.IsInf(n, 0) {
+			continue
+		}
+		if largest == nil || n > *largest {
+			largest = &n
+		}
+	}
+	return largest
+}
+
+// costClass classifies n against the upper bounds of the low, medium and high
+// classes.
+func costClass(n, low, medium, high float64) string {
+	switch {
+	case n < low:
+		return costLow
+	case n < medium:
+		return costMedium
+	case n < high:
+		return costHigh
+	default:
+		return costVeryHigh
+	}
+}
+
+// formatBytes formats n bytes with a decimal unit, such as 4.2 TB.
+func formatBytes(n float64) string {
+	return formatScaled(n, 1000, []string{" B", " KB", " MB", " GB", " TB", " PB", " EB"})
+}
+
+// formatCount formats a count with a suffix, such as 1.2M.
+func formatCount(n float64) string {
+	return formatScaled(n, 1000, []string{"", "K", "M", "B", "T"})
+}
+
+func formatScaled(n, base float64, units []string) string {
+	unit := 0
+	for n >= base && unit < len(units)-1 {
+		n /= base
+		unit++
+	}
+	if unit == 0 || n >= 10 {
+		return fmt.Sprintf("%.0f%s", n, units[unit])
+	}
+	return fmt.Sprintf("%.1f%s", n, units[unit])
+}
@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEstimateCost(t *testing.T) {
+	tests := []struct {
+		plan      string
+		rows      float64
+		bytes     float64
+		costClass string
+		summary   string
+	}{
+		{"line: Scan demo.metrics rows=4000000000 bytes=4200000000000\nline: Filter rows=1200", 4e9, 4.2e12, costVeryHigh,
+			"This will scan ~4.2 TB and read ~4.0B rows"},
+		{`plan: {"operator":"Scan","estimatedRows":1500,"estimatedBytes":2.5e6}`, 1500, 2.5e6, costLow,
+			"This will scan ~2.5 MB and read ~1.5K rows"},
+		{"key: estimated_rows\nestimated_rows: 250000000", 2.5e8, 0, costHigh, "This will read ~250M rows"},
+		{"line: Project a, b", 0, 0, costUnknown, "The plan has no row or byte estimates"},
+	}
+	for _, tt := range tests {
+		got := estimateCost("SELECT 1", tt.plan)
+		if got.CostClass != tt.costClass || got.Summary != tt.summary {
+			t.Errorf("estimateCost(%q) = %s %q, want %s %q", tt.plan, got.CostClass, got.Summary, tt.costClass, tt.summary)
+		}
+		if tt.rows != 0 && (got.Rows == nil || *got.Rows != tt.rows) {
+			t.Errorf("estimateCost(%q) rows = %v, want %v", tt.plan, got.Rows, tt.rows)
+		}
+		if tt.bytes != 0 && (got.Bytes == nil || *got.Bytes != tt.bytes) {
+			t.Errorf("estimateCost(%q) bytes = %v, want %v", tt.plan, got.Bytes, tt.bytes)
+		}
+	}
+}
+
+func TestEstimateResource(t *testing.T) {
+	transport := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "key", Type: "VARCHAR"}, {Name: "value"}},
+		Rows:    [][]interface{}{{"rows", float64(1e6)}, {"bytes", float64(3e9)}},
+	}}
+	ds := &Datasource{transport: transport}
+
+	body := `{"queryText": "SELECT * FROM t WHERE $__timeFilter(ts);", "from": "2024-01-02T15:00:00Z", "to": "2024-01-02T16:00:00Z"}`
+	resp := callResourceWithBody(t, ds, "POST", "estimate", []byte(body))
+	if resp.Status != 200 {
+		t.Fatalf("status %d: %s", resp.Status, resp.Body)
+	}
+	var estimate costEstimate
+	if err := json.Unmarshal(resp.Body, &estimate); err != nil {
+		t.Fatal(err)
+	}
+	if estimate.CostClass != costMedium || estimate.Summary != "This will scan ~3.0 GB and read ~1.0M rows" {
+		t.Errorf("unexpected estimate %+v", estimate)
+	}
+	want := "EXPLAIN SELECT * FROM t WHERE ts >= '2024-01-02 15:00:00' AND ts <= '2024-01-02 16:00:00'"
+	if len(transport.statements) != 1 || transport.statements[0] != want {
+		t.Errorf("executed %q, want %q", transport.statements, want)
+	}
+
+	resp = callResourceWithBody(t, ds, "POST", "estimate", []byte(`{"queryText": "DELETE FROM t"}`))
+	if resp.Status != 400 || !strings.Contains(string(resp.Body), "SELECT") {
+		t.Errorf("expected a write statement to be refused, got %d %s", resp.Status, resp.Body)
+	}
+}
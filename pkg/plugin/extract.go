@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// extractionRule extracts the named groups of a regular expression from a
+// string column into new fields, to structure log-like text without regex
+// functions in every query.
+type extractionRule struct {
+	Column  string `json:"column"`
+	Pattern string `json:"pattern"`
+}
+
+// extraction is a compiled extraction rule.
+type extraction struct {
+	column string
+	re     *regexp.Regexp
+	// groups are the names of the named groups, in order
+	groups []string
+}
+
+// compileExtractions compiles the rules of a query. Every pattern needs at
+// least one named group, such as (?P<status>\d+).
+func compileExtractions(rules []extractionRule) ([]extraction, error) {
+	extractions := make([]extraction, 0, len(rules))
+	for i, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("extraction %d: invalid pattern: %w", i+1, err)
+		}
+		var groups []string
+		for _, name := range re.SubexpNames() {
+			if name != "" {
+				groups = append(groups, name)
+			}
+		}
+		if rule.Column == "" || len(groups) == 0 {
+			return nil, fmt.Errorf("extraction %d needs a column and a pattern with named groups such as (?P<name>...)", i+1)
+		}
+		extractions = append(extractions, extraction{column: rule.Column, re: re, groups: groups})
+	}
+	return extractions, nil
+}
+
+// applyExtractions adds a column per named group after the source column of
+// each extraction. Rows that don't match get nulls. Extracted numbers are
+// returned as numbers, anything else as text whose type is guessed like that
+// of an untyped column.
+func applyExtractions(result *QueryResult, extractions []extraction) (*QueryResult, error) {
+	names := make(map[string]bool, len(result.Columns))
+	for _, col := range result.Columns {
+		names[col.Name] = true
+	}
+	// after holds, per source column, the extractions placed after it
+	after := make(map[int][]extraction)
+	for _, ex := range extractions {
+		index := -1
+		for i, col := range result.Columns {
+			if col.Name == ex.column {
+				index = i
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("extraction column %q is not in the result", ex.column)
+		}
+		for _, group := range ex.groups {
+			if names[group] {
+				return nil, fmt.Errorf("extracted field %q has the name of another field", group)
+			}
+			names[group] = true
+		}
+		after[index] = append(after[index], ex)
+	}
+
+	var columns []Column
+	for i, col := range result.Columns {
+		columns = append(columns, col)
+		for _, ex := range after[i] {
+			for _, group := range ex.groups {
+				columns = append(columns, Column{Name: group})
+			}
+		}
+	}
+
+	out := &QueryResult{QueryID: result.QueryID, Columns: columns, Rows: make([][]interface{}, len(result.Rows))}
+	for r, row := range result.Rows {
+		values := make([]interface{}, 0, len(columns))
+		for i := range result.Columns {
+			var v interface{}
+			if i < len(row) {
+				v = row[i]
+			}
+			values = append(values, v)
+			for _, ex := range after[i] {
+				values = append(values, ex.extract(v)...)
+			}
+		}
+		out.Rows[r] = values
+	}
+	return out, nil
+}
+
+// extract returns the values of the named groups in v, or nulls when v isn't
+// a string matching the pattern.
+func (ex extraction) extract(v interface{}) []interface{} {
+	values := make([]interface{}, len(ex.groups))
+	s, ok := v.(string)
+	if !ok {
+		return values
+	}
+	match := ex.re.FindStringSubmatchIndex(s)
+	if match == nil {
+		return values
+	}
+	k := 0
+	for i, name := range ex.re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if start := match[2*i]; start >= 0 {
+			text := s[start:match[2*i+1]]
+			if _, err := strconv.ParseFloat(text, 64); err == nil {
+				values[k] = json.Number(text)
+			} else {
+				values[k] = text
+			}
+		}
+		k++
+	}
+	return values
+}
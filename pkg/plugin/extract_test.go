@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestCompileExtractions(t *testing.T) {
+	for _, rule := range []extractionRule{
+		{Column: "message", Pattern: `status=(\d+)`},
+		{Column: "message", Pattern: `(?P<status>\d+`},
+		{Pattern: `(?P<status>\d+)`},
+	} {
+		if _, err := compileExtractions([]extractionRule{rule}); err == nil {
+			t.Errorf("expected an error for %+v", rule)
+		}
+	}
+}
+
+func TestConvertExtractions(t *testing.T) {
+	extractions, err := compileExtractions([]extractionRule{
+		{Column: "message", Pattern: `(?P<method>[A-Z]+) (?P<path>\S+) status=(?P<status>\d+)`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{{Name: "message", Type: "VARCHAR"}, {Name: "host", Type: "VARCHAR"}},
+		Rows: [][]interface{}{
+			{"GET /index.html status=200", "web-1"},
+			{"POST /login status=401", "web-2"},
+			{"connection reset", "web-1"},
+		},
+	}, conversionOptions{Extractions: extractions, NullPolicy: nullPolicyNull})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantFields := []struct {
+		name string
+		typ  data.FieldType
+	}{
+		{"message", data.FieldTypeNullableString}, {"method", data.FieldTypeNullableString},
+		{"path", data.FieldTypeNullableString}, {"status", data.FieldTypeNullableFloat64}, {"host", data.FieldTypeNullableString},
+	}
+	if len(frame.Fields) != len(wantFields) {
+		t.Fatalf("expected %d fields, got %d", len(wantFields), len(frame.Fields))
+	}
+	for i, want := range wantFields {
+		if frame.Fields[i].Name != want.name || frame.Fields[i].Type() != want.typ {
+			t.Errorf("field %d: got %s %s, want %s %s", i, frame.Fields[i].Name, frame.Fields[i].Type(), want.name, want.typ)
+		}
+	}
+	if status, ok := frame.Fields[3].ConcreteAt(1); !ok || status != float64(401) {
+		t.Errorf("unexpected status %v", status)
+	}
+	if _, ok := frame.Fields[1].ConcreteAt(2); ok {
+		t.Error("expected a null for a row that doesn't match")
+	}
+
+	clash, _ := compileExtractions([]extractionRule{{Column: "message", Pattern: `(?P<host>\S+)`}})
+	if _, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{{Name: "message"}, {Name: "host"}},
+		Rows:    [][]interface{}{{"a", "b"}},
+	}, conversionOptions{Extractions: clash}); err == nil {
+		t.Error("expected an error for an extracted field named like a column")
+	}
+}
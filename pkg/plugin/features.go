@@ -0,0 +1,91 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// featureKind is the type of the value of a query option.
+type featureKind string
+
+const (
+	featureBool   featureKind = "boolean"
+	featureNumber featureKind = "number"
+	featureString featureKind = "string"
+)
+
+// Query options of the feature registry.
+const (
+	// featureProfiling adds the time spent running and converting the query
+	// to the statistics of its frames.
+	featureProfiling = "profiling"
+)
+
+// queryFeatures is the registry of the options queries can set. New
+// capabilities roll out behind an option, so that saved queries keep their
+// behavior until they opt in. Options retired from the registry are ignored
+// with a warning rather than failing the queries that still set them.
+var queryFeatures = map[string]featureKind{
+	featureProfiling: featureBool,
+}
+
+// validateOptions checks the options of a query against the registry. It
+// returns a warning notice for every unknown option.
+func validateOptions(options map[string]interface{}) ([]data.Notice, error) {
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var notices []data.Notice
+	for _, name := range names {
+		kind, ok := queryFeatures[name]
+		if !ok {
+			notices = append(notices, data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text:     fmt.Sprintf("Unknown query option %q ignored", name),
+			})
+			continue
+		}
+		var valid bool
+		switch options[name].(type) {
+		case bool:
+			valid = kind == featureBool
+		case float64:
+			valid = kind == featureNumber
+		case string:
+			valid = kind == featureString
+		}
+		if !valid {
+			return nil, fmt.Errorf("query option %q must be a %s", name, kind)
+		}
+	}
+	return notices, nil
+}
+
+// enabled reports whether a boolean query option is set.
+func (qm queryModel) enabled(name string) bool {
+	v, _ := qm.Options[name].(bool)
+	return v
+}
+
+// appendProfile records how long a query took to run on Ocient and to be
+// converted into frames, and in total, in the statistics of a frame.
+func appendProfile(frame *data.Frame, executed, converted, total time.Duration) {
+	if frame.Meta == nil {
+		frame.Meta = &data.FrameMeta{}
+	}
+	for _, stat := range []struct {
+		name     string
+		duration time.Duration
+	}{{"Execution time", executed}, {"Conversion time", converted}, {"Total time", total}} {
+		frame.Meta.Stats = append(frame.Meta.Stats, data.QueryStat{
+			FieldConfig: data.FieldConfig{DisplayName: stat.name, Unit: "ms"},
+			Value:       float64(stat.duration.Microseconds()) / 1000,
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestValidateOptions(t *testing.T) {
+	notices, err := validateOptions(map[string]interface{}{"profiling": true, "columnar": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notices) != 1 || notices[0].Text != `Unknown query option "columnar" ignored` {
+		t.Errorf("got notices %v, want one for columnar", notices)
+	}
+	if _, err := validateOptions(map[string]interface{}{"profiling": "yes"}); err == nil {
+		t.Error("expected an error for a string profiling option")
+	}
+	if notices, err := validateOptions(nil); err != nil || notices != nil {
+		t.Errorf("got %v, %v without options", notices, err)
+	}
+}
+
+func TestQueryDataProfiling(t *testing.T) {
+	columns := []Column{{Name: "n", Type: "BIGINT"}}
+	ds := Datasource{transport: &fakeTransport{result: &QueryResult{Columns: columns, encodedRows: json.RawMessage("[[1]]")}}}
+
+	query := func(options map[string]interface{}) backend.DataResponse {
+		body, _ := json.Marshal(map[string]interface{}{"queryText": "SELECT 1 AS n", "options": options})
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: body}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Responses["A"]
+	}
+
+	r := query(map[string]interface{}{"profiling": true})
+	if r.Error != nil {
+		t.Fatal(r.Error)
+	}
+	var names []string
+	for _, stat := range r.Frames[0].Meta.Stats {
+		names = append(names, stat.DisplayName)
+	}
+	want := []string{"Execution time", "Conversion time", "Total time"}
+	for _, name := range want {
+		found := false
+		for _, got := range names {
+			found = found || got == name
+		}
+		if !found {
+			t.Errorf("missing stat %q in %v", name, names)
+		}
+	}
+
+	r = query(nil)
+	if r.Error != nil {
+		t.Fatal(r.Error)
+	}
+	if meta := r.Frames[0].Meta; meta != nil {
+		for _, stat := range meta.Stats {
+			if stat.DisplayName == "Total time" {
+				t.Error("got profiling stats without the option")
+			}
+		}
+	}
+
+	if r = query(map[string]interface{}{"profiling": 1}); r.Status != backend.StatusBadRequest {
+		t.Errorf("got status %v for a mistyped option, want bad request", r.Status)
+	}
+}
@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// fieldOptions are display settings for one column of a query, written into
+// the field config so that every panel using the query shows it the same way
+// without per-panel overrides.
+type fieldOptions struct {
+	Unit        string  `json:"unit"`
+	DisplayName string  `json:"displayName"`
+	Decimals    *uint16 `json:"decimals"`
+	// Lookup is a statement returning values of the column and the text to
+	// show them as, applied as value mappings. See lookupMappings.
+	Lookup string `json:"lookup"`
+	// Links are data links of the field. Their URLs are Grafana templates,
+	// such as https://admin.example.com/queries/${__value.raw}.
+	Links []data.DataLink `json:"links"`
+}
+
+// validateFieldOptions checks the options of every column.
+func validateFieldOptions(options map[string]fieldOptions) error {
+	for column, opts := range options {
+		for i, link := range opts.Links {
+			if link.URL == "" && link.Internal == nil {
+				return fmt.Errorf("link %d of column %q has no url", i+1, column)
+			}
+		}
+	}
+	return nil
+}
+
+// applyFieldOptions sets the options of each field named in options, keyed by
+// column name, along with the value mappings of their lookups. Fields derived
+// from a column, such as the series of a wide frame, keep their column name and
+// so get its options too.
+func applyFieldOptions(frame *data.Frame, options map[string]fieldOptions, mappings map[string]data.ValueMapper) {
+	if len(options) == 0 {
+		return
+	}
+	for _, field := range frame.Fields {
+		opts, ok := options[field.Name]
+		if !ok {
+			continue
+		}
+		if field.Config == nil {
+			field.Config = &data.FieldConfig{}
+		}
+		if opts.Unit != "" {
+			field.Config.Unit = opts.Unit
+		}
+		if opts.DisplayName != "" {
+			field.Config.DisplayName = opts.DisplayName
+		}
+		if opts.Decimals != nil {
+			decimals := *opts.Decimals
+			field.Config.Decimals = &decimals
+		}
+		if len(opts.Links) > 0 {
+			field.Config.Links = append(field.Config.Links, opts.Links...)
+		}
+		if mapper, ok := mappings[field.Name]; ok {
+			field.Config.Mappings = append(field.Config.Mappings, mapper)
+		}
+	}
+}
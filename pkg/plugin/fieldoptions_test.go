@@ -0,0 +1,43 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestApplyFieldOptions(t *testing.T) {
+	two := uint16(2)
+	frame := data.NewFrame("",
+		data.NewField("latency", nil, []float64{1.5}),
+		data.NewField("host", nil, []string{"web-1"}),
+		data.NewField("bytes", nil, []int64{10}).SetConfig(&data.FieldConfig{Custom: map[string]interface{}{"x": 1}}),
+	)
+	applyFieldOptions(frame, map[string]fieldOptions{
+		"latency": {Unit: "ms", DisplayName: "Latency", Decimals: &two},
+		"host":    {Links: []data.DataLink{{Title: "Host", URL: "https://admin.example.com/hosts/${__value.raw}"}}},
+		"bytes":   {Unit: "bytes"},
+		"missing": {Unit: "s"},
+	}, nil)
+
+	latency := frame.Fields[0].Config
+	if latency == nil || latency.Unit != "ms" || latency.DisplayName != "Latency" || latency.Decimals == nil || *latency.Decimals != 2 {
+		t.Errorf("unexpected latency config %+v", latency)
+	}
+	if host := frame.Fields[1].Config; host == nil || len(host.Links) != 1 || host.Links[0].URL != "https://admin.example.com/hosts/${__value.raw}" {
+		t.Errorf("expected a data link for host, got %+v", host)
+	}
+	bytes := frame.Fields[2].Config
+	if bytes.Unit != "bytes" || bytes.Custom["x"] != 1 {
+		t.Errorf("expected the unit added to the existing config, got %+v", bytes)
+	}
+}
+
+func TestValidateFieldOptions(t *testing.T) {
+	if err := validateFieldOptions(map[string]fieldOptions{"query_id": {Links: []data.DataLink{{Title: "Profile"}}}}); err == nil {
+		t.Error("expected an error for a link without a url")
+	}
+	if err := validateFieldOptions(map[string]fieldOptions{"query_id": {Links: []data.DataLink{{URL: "/d/queries?var-id=${__value.raw}"}}}}); err != nil {
+		t.Error(err)
+	}
+}
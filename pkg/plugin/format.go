@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Query formats, selecting the shape of the returned frames. Time series are
+// wide by default; the long shape keeps a row per time and label combination.
+const (
+	formatTable          = "table"
+	formatTimeSeries     = "time_series"
+	formatTimeSeriesLong = "time_series_long"
+	formatLogs           = "logs"
+	formatHeatmap        = "heatmap"
+	formatTrace          = "trace"
+)
+
+// shapeFrame shapes a converted frame for the format selected by the query and
+// tags it with the dataplane type of the shape, which expressions, alerting
+// and transformations read. Empty time series are tagged too, so that they
+// count as no data rather than as a table.
+// logLevels is the level mapping of the datasource for the logs format.
+func shapeFrame(frame *data.Frame, qm queryModel, logLevels map[string]string) (*data.Frame, error) {
+	format := qm.Format
+	if format == "" {
+		format = formatTable
+		if qm.LongToWide {
+			format = formatTimeSeries
+		}
+	}
+
+	switch format {
+	case formatTable:
+		setFrameType(frame, data.FrameTypeTable, data.VisTypeTable)
+		return frame, nil
+	case formatTimeSeries:
+		wide, err := toWideFrame(frame, qm.LabelColumns)
+		if err != nil {
+			return nil, err
+		}
+		setFrameType(wide, data.FrameTypeTimeSeriesWide, data.VisTypeGraph)
+		return wide, nil
+	case formatTimeSeriesLong:
+		long, err := toLongFrame(frame, qm.LabelColumns)
+		if err != nil {
+			return nil, err
+		}
+		setFrameType(long, data.FrameTypeTimeSeriesLong, data.VisTypeGraph)
+		return long, nil
+	case formatLogs:
+		return toLogLines(frame, qm.Logs, logLevels)
+	case formatHeatmap:
+		return toHeatmapCells(frame, qm.Heatmap)
+	case formatTrace:
+		return toTraceFrame(frame, qm.Trace)
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected %s, %s, %s, %s, %s or %s",
+			format, formatTable, formatTimeSeries, formatTimeSeriesLong, formatLogs, formatHeatmap, formatTrace)
+	}
+}
+
+// setFrameType records the dataplane type and preferred visualization of a frame.
+func setFrameType(frame *data.Frame, typ data.FrameType, vis data.VisType) {
+	if frame.Meta == nil {
+		frame.Meta = &data.FrameMeta{}
+	}
+	if typ != data.FrameTypeUnknown {
+		frame.Meta.Type = typ
+		frame.Meta.TypeVersion = data.FrameTypeVersion{0, 1}
+	}
+	frame.Meta.PreferredVisualization = vis
+}
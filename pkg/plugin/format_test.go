@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestShapeFrame(t *testing.T) {
+	newFrame := func() *data.Frame {
+		return data.NewFrame("response",
+			data.NewField("time", nil, []time.Time{time.Unix(60, 0), time.Unix(0, 0)}),
+			data.NewField("host", nil, []string{"a", "b"}),
+			data.NewField("value", nil, []float64{1, 2}),
+		)
+	}
+
+	table, err := shapeFrame(newFrame(), queryModel{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Meta.Type != data.FrameTypeTable || table.Meta.PreferredVisualization != data.VisTypeTable {
+		t.Errorf("unexpected table meta %+v", table.Meta)
+	}
+
+	series, err := shapeFrame(newFrame(), queryModel{Format: formatTimeSeries}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series.Meta.Type != data.FrameTypeTimeSeriesWide || len(series.Fields) != 3 {
+		t.Errorf("expected a wide series per host, got %s with %d fields", series.Meta.Type, len(series.Fields))
+	}
+
+	long, err := shapeFrame(newFrame(), queryModel{Format: formatTimeSeriesLong}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if long.Meta.Type != data.FrameTypeTimeSeriesLong || long.Rows() != 2 {
+		t.Errorf("expected a long series, got %s with %d rows", long.Meta.Type, long.Rows())
+	}
+	if first, _ := long.Fields[0].ConcreteAt(0); !first.(time.Time).Equal(time.Unix(0, 0)) {
+		t.Errorf("expected the long series to be ordered by time, starting with %v", first)
+	}
+
+	empty, err := shapeFrame(data.NewFrame("response", data.NewField("value", nil, []float64{})), queryModel{Format: formatTimeSeries}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Meta == nil || empty.Meta.Type != data.FrameTypeTimeSeriesWide {
+		t.Errorf("expected an empty series to be typed, got meta %+v", empty.Meta)
+	}
+
+	logs, err := shapeFrame(newFrame(), queryModel{Format: formatLogs}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logs.Meta.Type != data.FrameTypeLogLines || logs.Meta.PreferredVisualization != data.VisTypeLogs {
+		t.Errorf("unexpected logs meta %+v", logs.Meta)
+	}
+
+	noTime := data.NewFrame("response", data.NewField("value", nil, []float64{1}))
+	if _, err := shapeFrame(noTime, queryModel{Format: formatLogs}, nil); err == nil {
+		t.Error("expected an error for logs without a time column")
+	}
+	if _, err := shapeFrame(newFrame(), queryModel{Format: "graph"}, nil); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
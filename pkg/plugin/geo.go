@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// wktPoint matches a point in well-known text, optionally with an SRID prefix
+// as in EWKT: SRID=4326;POINT(-73.98 40.75). Coordinates are longitude first.
+var wktPoint = regexp.MustCompile(`(?i)^\s*(?:SRID=\d+;)?\s*POINT\s*\(\s*([-+0-9.eE]+)\s+([-+0-9.eE]+)(?:\s+[-+0-9.eE]+)*\s*\)\s*$`)
+
+// isGeoSQLType reports whether a declared type is one of the Ocient geospatial
+// types, which are returned as well-known text.
+func isGeoSQLType(t string) bool {
+	return strings.HasPrefix(t, "ST_") || t == "POINT" || t == "GEOMETRY" || t == "GEOGRAPHY"
+}
+
+// newGeoFields creates the latitude and longitude companions of a point or
+// geometry column, named after it with .latitude and .longitude suffixes that
+// the geomap panel picks up in its auto location mode.
+func newGeoFields(name string, capacity int) [2]*data.Field {
+	return [2]*data.Field{
+		data.NewField(name+".latitude", nil, make([]*float64, 0, capacity)),
+		data.NewField(name+".longitude", nil, make([]*float64, 0, capacity)),
+	}
+}
+
+// pointCoordinates returns the latitude and longitude of a point given as
+// well-known text or as a [longitude, latitude] array. Other geometries and
+// unparseable values yield nulls.
+func pointCoordinates(v interface{}) (lat, lon *float64) {
+	var x, y string
+	switch val := v.(type) {
+	case string:
+		match := wktPoint.FindStringSubmatch(val)
+		if match == nil {
+			return nil, nil
+		}
+		x, y = match[1], match[2]
+	case []interface{}:
+		if len(val) < 2 {
+			return nil, nil
+		}
+		lon, okX := toFloat64(val[0])
+		lat, okY := toFloat64(val[1])
+		if !okX || !okY {
+			return nil, nil
+		}
+		return &lat, &lon
+	default:
+		return nil, nil
+	}
+	longitude, errX := strconv.ParseFloat(x, 64)
+	latitude, errY := strconv.ParseFloat(y, 64)
+	if errX != nil || errY != nil {
+		return nil, nil
+	}
+	return &latitude, &longitude
+}
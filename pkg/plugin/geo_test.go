@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"testing"
+)
+
+func TestPointCoordinates(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		lat, lon float64
+		ok       bool
+	}{
+		{"POINT(-73.98 40.75)", 40.75, -73.98, true},
+		{"SRID=4326;point ( 2.35 48.86 35 )", 48.86, 2.35, true},
+		{[]interface{}{float64(139.69), float64(35.69)}, 35.69, 139.69, true},
+		{"LINESTRING(0 0, 1 1)", 0, 0, false},
+		{"POINT EMPTY", 0, 0, false},
+		{nil, 0, 0, false},
+	}
+	for _, tt := range tests {
+		lat, lon := pointCoordinates(tt.value)
+		if !tt.ok {
+			if lat != nil || lon != nil {
+				t.Errorf("pointCoordinates(%v) = %v, %v, want nulls", tt.value, *lat, *lon)
+			}
+			continue
+		}
+		if lat == nil || lon == nil || *lat != tt.lat || *lon != tt.lon {
+			t.Errorf("pointCoordinates(%v) = %v, %v, want %v, %v", tt.value, lat, lon, tt.lat, tt.lon)
+		}
+	}
+}
+
+func TestConvertGeoColumns(t *testing.T) {
+	frame, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{{Name: "location", Type: "ST_POINT"}, {Name: "area", Type: "ST_POLYGON"}, {Name: "site"}},
+		Rows: [][]interface{}{
+			{"POINT(-73.98 40.75)", "POLYGON((0 0, 1 0, 1 1, 0 0))", "POINT(2.35 48.86)"},
+			{nil, nil, nil},
+		},
+	}, conversionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, field := range frame.Fields {
+		names = append(names, field.Name)
+	}
+	want := []string{"location", "area", "site", "location.latitude", "location.longitude",
+		"area.latitude", "area.longitude", "site.latitude", "site.longitude"}
+	if len(names) != len(want) {
+		t.Fatalf("got fields %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got fields %v, want %v", names, want)
+		}
+	}
+
+	if got := frame.Fields[0].Config.Custom[typeHintKey]; got != typeHintPoint {
+		t.Errorf("expected the point type hint, got %v", got)
+	}
+	if got := frame.Fields[1].Config.Custom[typeHintKey]; got != typeHintGeometry {
+		t.Errorf("expected the geometry type hint, got %v", got)
+	}
+	if lat, ok := frame.Fields[3].ConcreteAt(0); !ok || lat != 40.75 {
+		t.Errorf("unexpected latitude %v", lat)
+	}
+	if lon, ok := frame.Fields[8].ConcreteAt(0); !ok || lon != 2.35 {
+		t.Errorf("unexpected longitude of the untyped point column %v", lon)
+	}
+	if _, ok := frame.Fields[5].ConcreteAt(0); ok {
+		t.Error("expected no coordinates for a polygon")
+	}
+	if _, ok := frame.Fields[3].ConcreteAt(1); ok {
+		t.Error("expected no coordinates for a null point")
+	}
+}
@@ -0,0 +1,343 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// The /geometries resource serves the geometries of a table within a bounding
+// box as GeoJSON for geomap layers, shaped for the zoom they are shown at, so
+// that maps over huge geometry tables don't pull millions of raw polygons.
+// Below geometryClusterZoom, geometries are counted in a grid of cells and
+// served as a point per cell; from it on, they are simplified to a tolerance
+// of about a pixel.
+const (
+	geometryClusterZoom = 8
+	geometryMaxZoom     = 24
+	// geometryTilePixels is the width of a map tile, which covers the whole
+	// longitude range at zoom 0
+	geometryTilePixels = 256
+	// geometryCellPixels is the width of the grid cells geometries are
+	// counted in below geometryClusterZoom
+	geometryCellPixels  = 64
+	geometryMaxFeatures = 10000
+	geometryTimeout     = 30 * time.Second
+)
+
+// geometryRequest holds the parameters of a /geometries request.
+type geometryRequest struct {
+	Table       string
+	Column      string
+	Properties  []string
+	Environment string
+	// MinLon, MinLat, MaxLon and MaxLat bound the box geometries intersect
+	MinLon, MinLat, MaxLon, MaxLat float64
+	Zoom                           int
+}
+
+// parseGeometryRequest reads the table, column, bbox (minLon,minLat,maxLon,maxLat),
+// zoom and optional properties (columns carried by simplified features) and
+// environment parameters.
+func parseGeometryRequest(r *http.Request) (geometryRequest, error) {
+	params := r.URL.Query()
+	req := geometryRequest{Table: params.Get("table"), Column: params.Get("column"), Environment: params.Get("environment")}
+	if req.Table == "" || req.Column == "" {
+		return req, fmt.Errorf("table and column are required")
+	}
+	bbox := strings.Split(params.Get("bbox"), ",")
+	if len(bbox) != 4 {
+		return req, fmt.Errorf("bbox must be minLon,minLat,maxLon,maxLat")
+	}
+	var coords [4]float64
+	for i, s := range bbox {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return req, fmt.Errorf("invalid bbox coordinate %q", s)
+		}
+		coords[i] = v
+	}
+	req.MinLon, req.MinLat, req.MaxLon, req.MaxLat = coords[0], coords[1], coords[2], coords[3]
+	if req.MinLon >= req.MaxLon || req.MinLat >= req.MaxLat {
+		return req, fmt.Errorf("bbox must have its minimum before its maximum")
+	}
+	zoom, err := strconv.Atoi(params.Get("zoom"))
+	if err != nil || zoom < 0 || zoom > geometryMaxZoom {
+		return req, fmt.Errorf("zoom must be an integer from 0 to %d", geometryMaxZoom)
+	}
+	req.Zoom = zoom
+	for _, name := range strings.Split(params.Get("properties"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			req.Properties = append(req.Properties, name)
+		}
+	}
+	return req, nil
+}
+
+// degreesPerPixel returns the width of a pixel in degrees of longitude at
+// the zoom of the request.
+func (g geometryRequest) degreesPerPixel() float64 {
+	return 360 / (geometryTilePixels * math.Exp2(float64(g.Zoom)))
+}
+
+// intersects returns the condition selecting the geometries in the box.
+func (g geometryRequest) intersects() string {
+	box := fmt.Sprintf("POLYGON((%[1]g %[2]g, %[3]g %[2]g, %[3]g %[4]g, %[1]g %[4]g, %[1]g %[2]g))", g.MinLon, g.MinLat, g.MaxLon, g.MaxLat)
+	return fmt.Sprintf("ST_INTERSECTS(%s, ST_GEOMFROMTEXT(%s))", quoteIdentifier(g.Column), stringLiteral(box))
+}
+
+// clustered reports whether geometries are counted in grid cells at the
+// zoom of the request.
+func (g geometryRequest) clustered() bool {
+	return g.Zoom < geometryClusterZoom
+}
+
+// statement returns the statement of the request: the count and mean
+// centroid of the geometries of every grid cell when clustered, else the
+// simplified geometries with their properties. One more row than
+// geometryMaxFeatures is asked for, to tell whether the result is truncated.
+func (g geometryRequest) statement() string {
+	table := quoteQualifiedIdentifier(g.Table)
+	if g.clustered() {
+		cell := g.degreesPerPixel() * geometryCellPixels
+		x := "ST_X(ST_CENTROID(" + quoteIdentifier(g.Column) + "))"
+		y := "ST_Y(ST_CENTROID(" + quoteIdentifier(g.Column) + "))"
+		cellX, cellY := fmt.Sprintf("FLOOR(%s / %g)", x, cell), fmt.Sprintf("FLOOR(%s / %g)", y, cell)
+		return fmt.Sprintf("SELECT AVG(%s) AS longitude, AVG(%s) AS latitude, COUNT(*) AS count FROM %s WHERE %s GROUP BY %s, %s LIMIT %d",
+			x, y, table, g.intersects(), cellX, cellY, geometryMaxFeatures+1)
+	}
+	columns := []string{fmt.Sprintf("ST_SIMPLIFY(%s, %g) AS geometry", quoteIdentifier(g.Column), g.degreesPerPixel())}
+	for _, name := range g.Properties {
+		columns = append(columns, quoteIdentifier(name))
+	}
+	return fmt.Sprintf("SELECT %s FROM %s WHERE %s LIMIT %d", strings.Join(columns, ", "), table, g.intersects(), geometryMaxFeatures+1)
+}
+
+// geoJSONFeature is a feature of a GeoJSON feature collection.
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   interface{}            `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// geoJSONCollection is the answer of the /geometries resource. Truncated is
+// set when the box held more than geometryMaxFeatures features.
+type geoJSONCollection struct {
+	Type      string           `json:"type"`
+	Features  []geoJSONFeature `json:"features"`
+	Truncated bool             `json:"truncated"`
+}
+
+// handleGeometries serves the geometries of a table within a bounding box.
+func (d *Datasource) handleGeometries(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGeometryRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if d.blockInsecureTLS() {
+		writeError(w, http.StatusForbidden, insecureTLSMessage+"; enable verification to run queries")
+		return
+	}
+	transport, err := d.environmentTransport(req.Environment)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), geometryTimeout)
+	defer cancel()
+	statement := req.statement()
+	result, err := transport.Execute(ctx, statement)
+	if err == nil {
+		err = result.decodeRows()
+	}
+	if err != nil {
+		backend.Logger.Error("Geometry listing failed", "error", err.Error(), "query", statement)
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("listing geometries failed: %v", err))
+		return
+	}
+
+	collection := geoJSONCollection{Type: "FeatureCollection", Features: make([]geoJSONFeature, 0, len(result.Rows))}
+	rows := result.Rows
+	if len(rows) > geometryMaxFeatures {
+		rows, collection.Truncated = rows[:geometryMaxFeatures], true
+	}
+	for _, row := range rows {
+		feature, ok := req.feature(row)
+		if ok {
+			collection.Features = append(collection.Features, feature)
+		}
+	}
+	writeJSON(w, http.StatusOK, collection)
+}
+
+// feature returns the GeoJSON feature of a result row. Rows whose geometry
+// can't be read are skipped.
+func (g geometryRequest) feature(row []interface{}) (geoJSONFeature, bool) {
+	feature := geoJSONFeature{Type: "Feature", Properties: map[string]interface{}{}}
+	if g.clustered() {
+		if len(row) < 3 {
+			return feature, false
+		}
+		lon, okX := toFloat64(row[0])
+		lat, okY := toFloat64(row[1])
+		if !okX || !okY {
+			return feature, false
+		}
+		feature.Geometry = map[string]interface{}{"type": "Point", "coordinates": []float64{lon, lat}}
+		feature.Properties["count"] = row[2]
+		return feature, true
+	}
+	wkt, ok := row[0].(string)
+	if !ok {
+		return feature, false
+	}
+	geometry, err := wktGeoJSON(wkt)
+	if err != nil {
+		return feature, false
+	}
+	feature.Geometry = geometry
+	for i, name := range g.Properties {
+		if i+1 < len(row) {
+			feature.Properties[name] = row[i+1]
+		}
+	}
+	return feature, true
+}
+
+// wktGeometry matches the type of a geometry in well-known text, with an
+// optional EWKT SRID prefix and dimension suffix, and captures its
+// coordinates.
+var wktGeometry = regexp.MustCompile(`(?is)^\s*(?:SRID=\d+;)?\s*(POINT|LINESTRING|POLYGON|MULTIPOINT|MULTILINESTRING|MULTIPOLYGON)\s*(?:ZM|Z|M)?\s*(EMPTY|\(.*\))\s*$`)
+
+// geoJSONTypes maps well-known text geometry types to GeoJSON ones.
+var geoJSONTypes = map[string]string{
+	"POINT":           "Point",
+	"LINESTRING":      "LineString",
+	"POLYGON":         "Polygon",
+	"MULTIPOINT":      "MultiPoint",
+	"MULTILINESTRING": "MultiLineString",
+	"MULTIPOLYGON":    "MultiPolygon",
+}
+
+// wktGeoJSON converts a geometry in well-known text into a GeoJSON geometry
+// object, keeping the longitude and latitude of its coordinates. Empty
+// geometries convert to nil, a feature without geometry.
+func wktGeoJSON(wkt string) (interface{}, error) {
+	match := wktGeometry.FindStringSubmatch(wkt)
+	if match == nil {
+		return nil, fmt.Errorf("unsupported geometry")
+	}
+	kind := strings.ToUpper(match[1])
+	if strings.EqualFold(match[2], "EMPTY") {
+		return nil, nil
+	}
+	p := &wktParser{s: match[2]}
+	coords, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.i != len(p.s) {
+		return nil, fmt.Errorf("unexpected %q in geometry", p.s[p.i:])
+	}
+	var coordinates interface{} = coords
+	switch kind {
+	case "POINT":
+		if len(coords) != 1 {
+			return nil, fmt.Errorf("a point needs a single position")
+		}
+		coordinates = coords[0]
+	case "MULTIPOINT":
+		// Points of a multipoint may be parenthesized on their own
+		for i, c := range coords {
+			if nested, ok := c.([]interface{}); ok && len(nested) == 1 {
+				coords[i] = nested[0]
+			}
+		}
+	}
+	return map[string]interface{}{"type": geoJSONTypes[kind], "coordinates": coordinates}, nil
+}
+
+// wktParser reads the nested, parenthesized coordinate lists of well-known
+// text. Positions read as []float64, lists as []interface{}.
+type wktParser struct {
+	s string
+	i int
+}
+
+func (p *wktParser) skipSpace() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t' || p.s[p.i] == '\n' || p.s[p.i] == '\r') {
+		p.i++
+	}
+}
+
+// list reads a parenthesized list of positions or of lists.
+func (p *wktParser) list() ([]interface{}, error) {
+	p.skipSpace()
+	if p.i >= len(p.s) || p.s[p.i] != '(' {
+		return nil, fmt.Errorf("expected ( in geometry")
+	}
+	p.i++
+	var items []interface{}
+	for {
+		p.skipSpace()
+		if p.i < len(p.s) && p.s[p.i] == '(' {
+			nested, err := p.list()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, nested)
+		} else {
+			position, err := p.position()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, position)
+		}
+		p.skipSpace()
+		if p.i >= len(p.s) {
+			return nil, fmt.Errorf("unterminated geometry")
+		}
+		switch p.s[p.i] {
+		case ',':
+			p.i++
+		case ')':
+			p.i++
+			return items, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q in geometry", p.s[p.i])
+		}
+	}
+}
+
+// position reads the coordinates of a position up to the next comma or
+// closing parenthesis. Coordinates past the longitude and latitude, as
+// elevations and measures, are dropped.
+func (p *wktParser) position() ([]float64, error) {
+	end := strings.IndexAny(p.s[p.i:], ",)")
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated geometry")
+	}
+	fields := strings.Fields(p.s[p.i : p.i+end])
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("a position needs two coordinates")
+	}
+	position := make([]float64, 2)
+	for j := range position {
+		v, err := strconv.ParseFloat(fields[j], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid coordinate %q", fields[j])
+		}
+		position[j] = v
+	}
+	p.i += end
+	return position, nil
+}
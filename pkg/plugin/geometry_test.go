@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWKTGeoJSON(t *testing.T) {
+	cases := []struct {
+		wkt  string
+		want string
+	}{
+		{"POINT(-73.98 40.75)", `{"coordinates":[-73.98,40.75],"type":"Point"}`},
+		{"SRID=4326;LINESTRING Z (0 0 1, 1 1 2)", `{"coordinates":[[0,0],[1,1]],"type":"LineString"}`},
+		{"POLYGON((0 0, 1 0, 1 1, 0 0), (0.2 0.2, 0.4 0.2, 0.4 0.4, 0.2 0.2))", `{"coordinates":[[[0,0],[1,0],[1,1],[0,0]],[[0.2,0.2],[0.4,0.2],[0.4,0.4],[0.2,0.2]]],"type":"Polygon"}`},
+		{"MULTIPOINT((1 2), (3 4))", `{"coordinates":[[1,2],[3,4]],"type":"MultiPoint"}`},
+		{"multipolygon(((0 0, 1 0, 1 1, 0 0)))", `{"coordinates":[[[[0,0],[1,0],[1,1],[0,0]]]],"type":"MultiPolygon"}`},
+		{"POLYGON EMPTY", `null`},
+	}
+	for _, c := range cases {
+		geometry, err := wktGeoJSON(c.wkt)
+		if err != nil {
+			t.Errorf("%s: %v", c.wkt, err)
+			continue
+		}
+		b, _ := json.Marshal(geometry)
+		if string(b) != c.want {
+			t.Errorf("%s: got %s, want %s", c.wkt, b, c.want)
+		}
+	}
+
+	for _, wkt := range []string{"CIRCLE(0 0, 1)", "POINT(1)", "POLYGON((0 0, 1 1)", "POINT(1 2) junk"} {
+		if _, err := wktGeoJSON(wkt); err == nil {
+			t.Errorf("%s: expected an error", wkt)
+		}
+	}
+}
+
+func TestGeometriesResource(t *testing.T) {
+	transport := &statementTransport{results: map[string]*QueryResult{
+		"SELECT AVG(": {
+			Columns: []Column{{Name: "longitude", Type: "DOUBLE"}, {Name: "latitude", Type: "DOUBLE"}, {Name: "count", Type: "BIGINT"}},
+			Rows:    [][]interface{}{{float64(-73.9), float64(40.7), float64(1200)}},
+		},
+		"SELECT ST_SIMPLIFY(": {
+			Columns: []Column{{Name: "geometry", Type: "ST_POLYGON"}, {Name: "name", Type: "VARCHAR"}},
+			Rows:    [][]interface{}{{"POLYGON((0 0, 1 0, 1 1, 0 0))", "a"}, {"not a geometry", "b"}},
+		},
+	}}
+	ds := &Datasource{transport: transport}
+
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type string `json:"type"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+		Truncated bool `json:"truncated"`
+	}
+
+	// Zoomed out, geometries are counted in grid cells
+	resp := callResource(t, ds, "GET", "geometries?table=geo.parcels&column=shape&bbox=-74.1,40.6,-73.8,40.9&zoom=4")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("got status %d: %s", resp.Status, resp.Body)
+	}
+	if err := json.Unmarshal(resp.Body, &collection); err != nil {
+		t.Fatal(err)
+	}
+	if len(collection.Features) != 1 || collection.Features[0].Geometry.Type != "Point" || collection.Features[0].Properties["count"] != float64(1200) {
+		t.Errorf("unexpected clustered features %+v", collection)
+	}
+	if !strings.Contains(transport.statements[0], "ST_INTERSECTS(shape, ST_GEOMFROMTEXT('POLYGON((-74.1 40.6, -73.8 40.6, -73.8 40.9, -74.1 40.9, -74.1 40.6))'))") {
+		t.Errorf("statement doesn't select the box: %s", transport.statements[0])
+	}
+
+	// Zoomed in, they are simplified and carry their properties
+	resp = callResource(t, ds, "GET", "geometries?table=geo.parcels&column=shape&bbox=0,0,1,1&zoom=12&properties=name")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("got status %d: %s", resp.Status, resp.Body)
+	}
+	if err := json.Unmarshal(resp.Body, &collection); err != nil {
+		t.Fatal(err)
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 1 || collection.Features[0].Geometry.Type != "Polygon" ||
+		collection.Features[0].Properties["name"] != "a" {
+		t.Errorf("unexpected simplified features %+v", collection)
+	}
+	if want := "SELECT ST_SIMPLIFY(shape, 0.00034332275390625) AS geometry, name FROM geo.parcels"; !strings.HasPrefix(transport.statements[1], want) {
+		t.Errorf("got statement %q, want it to start with %q", transport.statements[1], want)
+	}
+
+	for _, path := range []string{
+		"geometries?table=geo.parcels&bbox=0,0,1,1&zoom=3",
+		"geometries?table=geo.parcels&column=shape&bbox=1,0,0,1&zoom=3",
+		"geometries?table=geo.parcels&column=shape&bbox=0,0,1,1&zoom=30",
+	} {
+		if resp := callResource(t, ds, "GET", path); resp.Status != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want bad request", path, resp.Status)
+		}
+	}
+}
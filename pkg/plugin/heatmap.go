@@ -0,0 +1,111 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// frameTypeHeatmapCells is the frame type of Grafana's heatmap panel for data
+// that is already bucketed, with one row per time and bucket.
+const frameTypeHeatmapCells data.FrameType = "heatmap-cells"
+
+// heatmapOptions names the columns of a bucketed result. Unset names default to
+// bucket_low, bucket_high and count.
+type heatmapOptions struct {
+	LowColumn   string `json:"lowColumn"`
+	HighColumn  string `json:"highColumn"`
+	CountColumn string `json:"countColumn"`
+}
+
+// toHeatmapCells converts a bucketed result (time, bucket low and high bounds,
+// count) into the xMin, yMin, yMax and count fields the heatmap panel expects,
+// ordered by time and bucket. The bounds of every bucket are kept in yMin and
+// yMax, so buckets of varying size are drawn correctly, and the columns they
+// came from are recorded in the frame metadata.
+func toHeatmapCells(frame *data.Frame, opts *heatmapOptions) (*data.Frame, error) {
+	if frame.Rows() == 0 {
+		return frame, nil
+	}
+	names := heatmapOptions{LowColumn: "bucket_low", HighColumn: "bucket_high", CountColumn: "count"}
+	if opts != nil {
+		if opts.LowColumn != "" {
+			names.LowColumn = opts.LowColumn
+		}
+		if opts.HighColumn != "" {
+			names.HighColumn = opts.HighColumn
+		}
+		if opts.CountColumn != "" {
+			names.CountColumn = opts.CountColumn
+		}
+	}
+
+	var timeField *data.Field
+	for _, field := range frame.Fields {
+		if field.Type().Time() {
+			timeField = field
+			break
+		}
+	}
+	if timeField == nil {
+		return nil, fmt.Errorf("a time column is required for the heatmap format")
+	}
+	numeric := make(map[string]*data.Field)
+	for _, name := range []string{names.LowColumn, names.HighColumn, names.CountColumn} {
+		field, _ := frame.FieldByName(name)
+		if field == nil || !field.Type().Numeric() {
+			return nil, fmt.Errorf("heatmap column %q is missing or not numeric", name)
+		}
+		numeric[name] = field
+	}
+
+	type cell struct {
+		x                time.Time
+		low, high, count float64
+	}
+	cells := make([]cell, frame.Rows())
+	for row := range cells {
+		if v, ok := timeField.ConcreteAt(row); ok {
+			cells[row].x = v.(time.Time)
+		}
+		cells[row].low, _ = numeric[names.LowColumn].FloatAt(row)
+		cells[row].high, _ = numeric[names.HighColumn].FloatAt(row)
+		cells[row].count, _ = numeric[names.CountColumn].FloatAt(row)
+	}
+	sort.SliceStable(cells, func(i, j int) bool {
+		if !cells[i].x.Equal(cells[j].x) {
+			return cells[i].x.Before(cells[j].x)
+		}
+		return cells[i].low < cells[j].low
+	})
+
+	x := make([]time.Time, len(cells))
+	low := make([]float64, len(cells))
+	high := make([]float64, len(cells))
+	count := make([]float64, len(cells))
+	for i, c := range cells {
+		x[i], low[i], high[i], count[i] = c.x, c.low, c.high, c.count
+	}
+
+	out := data.NewFrame(frame.Name,
+		data.NewField("xMin", nil, x),
+		data.NewField("yMin", nil, low).SetConfig(numeric[names.LowColumn].Config),
+		data.NewField("yMax", nil, high).SetConfig(numeric[names.HighColumn].Config),
+		data.NewField("count", nil, count).SetConfig(numeric[names.CountColumn].Config),
+	)
+	meta := data.FrameMeta{}
+	if frame.Meta != nil {
+		meta = *frame.Meta
+	}
+	meta.Type = frameTypeHeatmapCells
+	meta.PreferredVisualization = data.VisType("heatmap")
+	meta.Custom = map[string]string{
+		"yMinColumn":  names.LowColumn,
+		"yMaxColumn":  names.HighColumn,
+		"countColumn": names.CountColumn,
+	}
+	out.Meta = &meta
+	return out, nil
+}
@@ -0,0 +1,42 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestToHeatmapCells(t *testing.T) {
+	t0 := time.Unix(0, 0).UTC()
+	frame := data.NewFrame("response",
+		data.NewField("time", nil, []time.Time{t0.Add(time.Minute), t0, t0}),
+		data.NewField("lo", nil, []int64{0, 10, 0}),
+		data.NewField("hi", nil, []int64{10, 20, 10}),
+		data.NewField("n", nil, []int64{5, 2, 1}),
+	)
+
+	cells, err := toHeatmapCells(frame, &heatmapOptions{LowColumn: "lo", HighColumn: "hi", CountColumn: "n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cells.Meta.Type != frameTypeHeatmapCells {
+		t.Errorf("unexpected frame type %s", cells.Meta.Type)
+	}
+	for i, name := range []string{"xMin", "yMin", "yMax", "count"} {
+		if cells.Fields[i].Name != name {
+			t.Errorf("field %d: got %s, want %s", i, cells.Fields[i].Name, name)
+		}
+	}
+	// Ordered by time, then bucket
+	if low, _ := cells.Fields[1].FloatAt(1); low != 10 {
+		t.Errorf("expected the second cell to be the upper bucket at t0, got %v", low)
+	}
+	if count, _ := cells.Fields[3].FloatAt(2); count != 5 {
+		t.Errorf("expected the last cell to be at t0+1m, got count %v", count)
+	}
+
+	if _, err := toHeatmapCells(frame, nil); err == nil {
+		t.Error("expected an error for missing default bucket columns")
+	}
+}
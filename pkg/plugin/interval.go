@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationUnit is the Grafana unit of interval fields, which hold nanoseconds.
+const durationUnit = "ns"
+
+// Calendar units have no fixed length; like Postgres, a month counts as 30
+// days and a year as 365.25 days.
+const (
+	day   = 24 * time.Hour
+	month = 30 * day
+	year  = day * 36525 / 100
+)
+
+// intervalUnits are the unit words of SQL interval literals, singular, plural
+// and abbreviated.
+var intervalUnits = map[string]time.Duration{
+	"ns": time.Nanosecond, "nanosecond": time.Nanosecond, "nanoseconds": time.Nanosecond,
+	"us": time.Microsecond, "microsecond": time.Microsecond, "microseconds": time.Microsecond,
+	"ms": time.Millisecond, "millisecond": time.Millisecond, "milliseconds": time.Millisecond,
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": day, "day": day, "days": day,
+	"w": 7 * day, "week": 7 * day, "weeks": 7 * day,
+	"mon": month, "mons": month, "month": month, "months": month,
+	"y": year, "year": year, "years": year,
+}
+
+// isoDuration matches ISO 8601 durations such as P1DT2H30M or PT0.5S.
+var isoDuration = regexp.MustCompile(`(?i)^([+-])?P(?:([\d.]+)Y)?(?:([\d.]+)M)?(?:([\d.]+)W)?(?:([\d.]+)D)?` +
+	`(?:T(?:([\d.]+)H)?(?:([\d.]+)M)?(?:([\d.]+)S)?)?$`)
+
+// isoUnits are the units of the isoDuration groups, in order.
+var isoUnits = []time.Duration{year, month, 7 * day, day, time.Hour, time.Minute, time.Second}
+
+// toDuration converts an interval value to a duration. Strings may be SQL
+// intervals such as "1 day 02:03:04.5" or "3 hours", ISO 8601 durations or Go
+// durations; numbers are nanoseconds.
+func toDuration(v interface{}) (time.Duration, bool) {
+	if s, ok := v.(string); ok {
+		return parseInterval(s)
+	}
+	n, ok := toInt64(v)
+	return time.Duration(n), ok
+}
+
+// parseInterval parses the textual forms of an interval accepted by toDuration.
+func parseInterval(s string) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, true
+	}
+	if match := isoDuration.FindStringSubmatch(s); match != nil && len(s) > 2 {
+		var total float64
+		for i, unit := range isoUnits {
+			if match[i+2] == "" {
+				continue
+			}
+			n, err := strconv.ParseFloat(match[i+2], 64)
+			if err != nil {
+				return 0, false
+			}
+			total += n * float64(unit)
+		}
+		if match[1] == "-" {
+			total = -total
+		}
+		return clampDuration(total)
+	}
+	return parseSQLInterval(s)
+}
+
+// parseSQLInterval parses a list of amounts with units, optionally followed by
+// a [-]HH:MM[:SS[.fraction]] clock, where a bare amount before the clock is a
+// number of days, as in "-1 02:00:00".
+func parseSQLInterval(s string) (time.Duration, bool) {
+	fields := strings.Fields(strings.ToLower(s))
+	var total float64
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if strings.Contains(field, ":") {
+			clock, ok := parseClock(field)
+			if !ok || i != len(fields)-1 {
+				return 0, false
+			}
+			total += clock
+			continue
+		}
+		n, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return 0, false
+		}
+		switch {
+		case i+1 < len(fields) && intervalUnits[strings.TrimSuffix(fields[i+1], ",")] != 0:
+			total += n * float64(intervalUnits[strings.TrimSuffix(fields[i+1], ",")])
+			i++
+		case i+1 < len(fields) && strings.Contains(fields[i+1], ":"):
+			total += n * float64(day)
+		default:
+			return 0, false
+		}
+	}
+	return clampDuration(total)
+}
+
+// parseClock parses [-]HH:MM[:SS[.fraction]] into nanoseconds.
+func parseClock(s string) (float64, bool) {
+	sign := 1.0
+	if strings.HasPrefix(s, "-") {
+		sign, s = -1, s[1:]
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	var total float64
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second}[:len(parts)] {
+		n, err := strconv.ParseFloat(parts[i], 64)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		total += n * float64(unit)
+	}
+	return sign * total, true
+}
+
+// clampDuration converts nanoseconds to a duration, saturating at the bounds of
+// the duration range like numbers outside the float64 range do.
+func clampDuration(ns float64) (time.Duration, bool) {
+	switch {
+	case math.IsNaN(ns):
+		return 0, false
+	case ns >= math.MaxInt64:
+		return math.MaxInt64, true
+	case ns <= math.MinInt64:
+		return math.MinInt64, true
+	default:
+		return time.Duration(ns), true
+	}
+}
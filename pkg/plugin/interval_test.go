@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInterval(t *testing.T) {
+	tests := map[string]time.Duration{
+		"1h30m":             90 * time.Minute,
+		"PT0.5S":            500 * time.Millisecond,
+		"P1DT2H":            26 * time.Hour,
+		"-P1W":              -7 * day,
+		"1 day 02:03:04.5":  day + 2*time.Hour + 3*time.Minute + 4500*time.Millisecond,
+		"-1 02:00:00":       -22 * time.Hour,
+		"00:00:01":          time.Second,
+		"3 hours":           3 * time.Hour,
+		"1 year 2 mons":     year + 2*month,
+		"2 days, 5 minutes": 2*day + 5*time.Minute,
+	}
+	for s, want := range tests {
+		got, ok := parseInterval(s)
+		if !ok || got != want {
+			t.Errorf("parseInterval(%q) = %v, %v, want %v", s, got, ok, want)
+		}
+	}
+	for _, s := range []string{"", "P", "soon", "5", "1:2:3:4", "3 parsecs"} {
+		if _, ok := parseInterval(s); ok {
+			t.Errorf("parseInterval(%q) should fail", s)
+		}
+	}
+}
+
+func TestConvertIntervals(t *testing.T) {
+	frame, err := convertToDataFrames(&QueryResult{
+		Columns: []Column{{Name: "elapsed", Type: "INTERVAL DAY TO SECOND"}, {Name: "wait", Type: "INTERVAL"}},
+		Rows: [][]interface{}{
+			{"00:00:01.25", float64(1000)},
+			{"2 minutes", "soon"},
+		},
+	}, conversionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	elapsed := frame.Fields[0]
+	if elapsed.Config == nil || elapsed.Config.Unit != durationUnit {
+		t.Errorf("expected the %s unit on interval fields, got %+v", durationUnit, elapsed.Config)
+	}
+	for row, want := range []int64{int64(1250 * time.Millisecond), int64(2 * time.Minute)} {
+		if got := elapsed.At(row); got != want {
+			t.Errorf("row %d: got %v, want %d", row, got, want)
+		}
+	}
+
+	wait := frame.Fields[1]
+	if got := wait.At(0); got != "1000" || wait.Config != nil {
+		t.Errorf("expected an interval column with other values to be returned as text, got %v with %+v", got, wait.Config)
+	}
+	if frame.Meta == nil || len(frame.Meta.Notices) != 1 {
+		t.Errorf("expected a notice for the promoted column, got %+v", frame.Meta)
+	}
+}
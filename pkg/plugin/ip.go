@@ -0,0 +1,35 @@
+package plugin
+
+import (
+	"math/big"
+	"net/netip"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// newIPNumericField creates the numeric companion of an IP address column, named
+// after it with a ".numeric" suffix.
+func newIPNumericField(name string, capacity int) *data.Field {
+	field := data.NewField(name+".numeric", nil, make([]*float64, 0, capacity))
+	field.Config = &data.FieldConfig{DisplayName: name + " (numeric)"}
+	return field
+}
+
+// ipToFloat64 returns the numeric form of an IP address for sorting and range
+// filtering. IPv4 addresses are exact; IPv6 addresses don't fit a float64 and
+// are rounded, which keeps their order. Unparseable values yield null.
+func ipToFloat64(v interface{}) *float64 {
+	s, ok := v.(string)
+	if !ok {
+		return nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+
+	b := addr.AsSlice()
+	f, _ := new(big.Float).SetInt(new(big.Int).SetBytes(b)).Float64()
+	return &f
+}
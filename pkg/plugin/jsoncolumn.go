@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// jsonColumnOptions select a string column holding JSON objects, such as the
+// payload of an event table, to split into one field per key.
+type jsonColumnOptions struct {
+	Column string `json:"column"`
+	// Keys is the allowlist of dotted key paths to extract, in order. When
+	// empty every leaf of the objects is extracted.
+	Keys []string `json:"keys"`
+}
+
+// expandJSONColumn replaces the JSON column with a column per key, named like
+// flattened structs, e.g. payload.user.id. Their types are guessed from the
+// values. It also returns how many non-null values were not JSON objects.
+func expandJSONColumn(result *QueryResult, opts jsonColumnOptions) (*QueryResult, int, error) {
+	index := -1
+	for i, col := range result.Columns {
+		if col.Name == opts.Column {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, 0, fmt.Errorf("JSON column %q is not in the result", opts.Column)
+	}
+
+	invalid := 0
+	objects := make([]interface{}, len(result.Rows))
+	for r, row := range result.Rows {
+		if index >= len(row) || row[index] == nil {
+			continue
+		}
+		var obj map[string]interface{}
+		switch v := row[index].(type) {
+		case map[string]interface{}:
+			obj = v
+		case string:
+			if err := decodeJSON([]byte(v), &obj); err != nil {
+				obj = nil
+			}
+		}
+		if obj == nil {
+			invalid++
+			continue
+		}
+		objects[r] = obj
+	}
+
+	paths := opts.Keys
+	if len(paths) == 0 {
+		parsed := &QueryResult{Columns: []Column{{Name: opts.Column}}, Rows: make([][]interface{}, len(objects))}
+		for r, obj := range objects {
+			parsed.Rows[r] = []interface{}{obj}
+		}
+		paths = structPaths(parsed, 0)
+	}
+
+	columns := append([]Column(nil), result.Columns[:index]...)
+	for _, path := range paths {
+		columns = append(columns, Column{Name: opts.Column + "." + path})
+	}
+	columns = append(columns, result.Columns[index+1:]...)
+
+	out := &QueryResult{QueryID: result.QueryID, Columns: columns, Rows: make([][]interface{}, len(result.Rows))}
+	for r, row := range result.Rows {
+		values := make([]interface{}, 0, len(columns))
+		values = append(values, row[:min(index, len(row))]...)
+		for _, path := range paths {
+			var v interface{}
+			if objects[r] != nil {
+				v = lookupPath(objects[r], strings.Split(path, "."))
+			}
+			values = append(values, v)
+		}
+		if index+1 < len(row) {
+			values = append(values, row[index+1:]...)
+		}
+		out.Rows[r] = values
+	}
+	return out, invalid, nil
+}
+
+// invalidJSONNotice reports the values of the JSON column that weren't objects.
+func invalidJSONNotice(column string, count int) data.Notice {
+	return data.Notice{
+		Severity: data.NoticeSeverityWarning,
+		Text:     fmt.Sprintf("%d values of column %q are not JSON objects, their fields are empty", count, column),
+	}
+}
@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestConvertJSONColumn(t *testing.T) {
+	result := func() *QueryResult {
+		return &QueryResult{
+			Columns: []Column{{Name: "id", Type: "INT"}, {Name: "payload", Type: "VARCHAR"}, {Name: "source", Type: "VARCHAR"}},
+			Rows: [][]interface{}{
+				{float64(1), `{"ts": "2024-01-02 09:00:00", "user": {"id": 7, "name": "ada"}, "ok": true}`, "web"},
+				{float64(2), `{"ts": "2024-01-02 10:00:00", "user": {"id": 8}, "ok": false, "extra": 1}`, "api"},
+				{float64(3), `not json`, "api"},
+			},
+		}
+	}
+
+	frame, err := convertToDataFrames(result(), conversionOptions{
+		JSONColumn: &jsonColumnOptions{Column: "payload", Keys: []string{"ts", "user.id", "ok"}},
+		TimeColumn: "payload.ts",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFields := []struct {
+		name string
+		typ  data.FieldType
+	}{
+		{"id", data.FieldTypeInt64}, {"payload.ts", data.FieldTypeTime}, {"payload.user.id", data.FieldTypeFloat64},
+		{"payload.ok", data.FieldTypeBool}, {"source", data.FieldTypeString},
+	}
+	if len(frame.Fields) != len(wantFields) {
+		t.Fatalf("expected %d fields, got %d", len(wantFields), len(frame.Fields))
+	}
+	for i, want := range wantFields {
+		if frame.Fields[i].Name != want.name || frame.Fields[i].Type() != want.typ {
+			t.Errorf("field %d: got %s %s, want %s %s", i, frame.Fields[i].Name, frame.Fields[i].Type(), want.name, want.typ)
+		}
+	}
+	if got := frame.Fields[1].At(1); got != time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC) {
+		t.Errorf("unexpected timestamp %v", got)
+	}
+	if got := frame.Fields[2].At(0); got != float64(7) {
+		t.Errorf("unexpected user id %v", got)
+	}
+	if frame.Meta == nil || len(frame.Meta.Notices) != 1 {
+		t.Errorf("expected a notice for the value that isn't JSON, got %+v", frame.Meta)
+	}
+
+	// Without an allowlist every leaf becomes a field
+	frame, err = convertToDataFrames(result(), conversionOptions{JSONColumn: &jsonColumnOptions{Column: "payload"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, field := range frame.Fields {
+		names = append(names, field.Name)
+	}
+	want := []string{"id", "payload.extra", "payload.ok", "payload.ts", "payload.user.id", "payload.user.name", "source"}
+	if len(names) != len(want) {
+		t.Fatalf("got fields %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("got fields %v, want %v", names, want)
+			break
+		}
+	}
+
+	if _, err := convertToDataFrames(result(), conversionOptions{JSONColumn: &jsonColumnOptions{Column: "missing"}}); err == nil {
+		t.Error("expected an error for a missing JSON column")
+	}
+}
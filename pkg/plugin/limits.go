@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// fromAlertHeader is set by Grafana on the queries of alert rule evaluations.
+const fromAlertHeader = "FromAlert"
+
+// executionMode selects the credentials and limits a request runs with.
+type executionMode int
+
+const (
+	// modeInteractive is used for dashboards and Explore.
+	modeInteractive executionMode = iota
+	// modeReporting is used for PDF reports and CSV exports, which need
+	// complete data and may wait longer for it.
+	modeReporting
+	// modePublic is used for public dashboards, see isPublicRequest.
+	modePublic
+)
+
+func (m executionMode) String() string {
+	switch m {
+	case modeReporting:
+		return "reporting"
+	case modePublic:
+		return "public"
+	default:
+		return "interactive"
+	}
+}
+
+// queryLimits bound a single query. Zero values mean no limit.
+type queryLimits struct {
+	maxRows int
+	timeout time.Duration
+}
+
+// executionMode returns the mode of a request.
+func (d *Datasource) executionMode(req *backend.QueryDataRequest) executionMode {
+	if d.isPublicRequest(req) {
+		return modePublic
+	}
+	if reporting := d.settings.Reporting; reporting != nil &&
+		strings.EqualFold(req.GetHTTPHeader(reporting.Header), "true") {
+		return modeReporting
+	}
+	return modeInteractive
+}
+
+// limits returns the limits of queries run in mode. A query may set its own
+// timeout in seconds, replacing the one of the datasource; public dashboard
+// queries may only shorten it.
+func (d *Datasource) limits(mode executionMode, queryTimeout int) queryLimits {
+	limits := queryLimits{
+		maxRows: d.settings.MaxRows,
+		timeout: time.Duration(d.settings.QueryTimeout) * time.Second,
+	}
+	switch mode {
+	case modePublic:
+		if public := d.settings.PublicDashboards.MaxRows; limits.maxRows == 0 || public < limits.maxRows {
+			limits.maxRows = public
+		}
+	case modeReporting:
+		// Reports may only raise limits; an unlimited interactive setting stays unlimited
+		reporting := d.settings.Reporting
+		if limits.maxRows > 0 && reporting.MaxRows > limits.maxRows {
+			limits.maxRows = reporting.MaxRows
+		}
+		if timeout := time.Duration(reporting.QueryTimeout) * time.Second; limits.timeout > 0 && timeout > limits.timeout {
+			limits.timeout = timeout
+		}
+	}
+	if timeout := time.Duration(queryTimeout) * time.Second; timeout > 0 &&
+		(mode != modePublic || limits.timeout == 0 || timeout < limits.timeout) {
+		limits.timeout = timeout
+	}
+	return limits
+}
+
+// deadlineResponse returns the response of a query stopped by its timeout or,
+// when that came first, by the deadline of the Grafana request ctx carries.
+func deadlineResponse(ctx context.Context, timeout time.Duration) backend.DataResponse {
+	switch {
+	case ctx.Err() == context.Canceled:
+		return backend.ErrDataResponse(backend.StatusTimeout, "query cancelled by Grafana")
+	case ctx.Err() != nil || timeout == 0:
+		return backend.ErrDataResponse(backend.StatusTimeout, "query exceeded the deadline of the Grafana request")
+	}
+	return backend.ErrDataResponse(backend.StatusTimeout, fmt.Sprintf("query timed out after %s", timeout))
+}
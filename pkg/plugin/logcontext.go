@@ -0,0 +1,108 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// Log context directions, as Grafana names them.
+const (
+	logContextBackward = "backward"
+	logContextForward  = "forward"
+)
+
+const (
+	// logContextWindow bounds the time range that the macros of a log context
+	// query are expanded against, on the side of its direction.
+	logContextWindow       = 6 * time.Hour
+	defaultLogContextLimit = 50
+	maxLogContextLimit     = 1000
+	// logContextTimestampFormat keeps the fraction of the line's timestamp, so
+	// lines logged within the same second are ordered around it.
+	logContextTimestampFormat = "2006-01-02 15:04:05.999999999"
+)
+
+// logContextOptions asks for the log lines before or after a line, for "show
+// context" in Explore, instead of the result of the query.
+type logContextOptions struct {
+	// Time is the timestamp of the line.
+	Time time.Time `json:"time"`
+	// Direction is "backward" (default) for earlier lines or "forward" for
+	// later ones.
+	Direction string `json:"direction"`
+	Limit     int    `json:"limit"`
+	// TimeColumn is the column the line's timestamp came from, defaults to the
+	// time column of the query.
+	TimeColumn string `json:"timeColumn"`
+	// Labels are the labels of the line. Those named by the contextLabels
+	// logs option must be equal for the lines of the context.
+	Labels map[string]string `json:"labels"`
+}
+
+// timeRange returns the range that the macros of a log context query are
+// expanded against, reaching from the line in the direction of the context.
+func (o *logContextOptions) timeRange() backend.TimeRange {
+	if o.Direction == logContextForward {
+		return backend.TimeRange{From: o.Time, To: o.Time.Add(logContextWindow)}
+	}
+	return backend.TimeRange{From: o.Time.Add(-logContextWindow), To: o.Time}
+}
+
+// logContextStatement wraps the statement of a logs query into one returning
+// the lines just before or after the line of the context, nearest first, with
+// the same values of the context labels.
+func logContextStatement(statement string, qm queryModel, loc *time.Location) (string, error) {
+	opts := qm.LogContext
+	if !isReadOnlyStatement(statement) || isUtilityStatement(statement) {
+		return "", fmt.Errorf("log context is only available for SELECT queries")
+	}
+	column := opts.TimeColumn
+	if column == "" {
+		column = qm.TimeColumn
+	}
+	if column == "" {
+		return "", fmt.Errorf("log context needs the time column of the query; set timeColumn")
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var op, order string
+	switch opts.Direction {
+	case "", logContextBackward:
+		op, order = "<", "DESC"
+	case logContextForward:
+		op, order = ">", "ASC"
+	default:
+		return "", fmt.Errorf("unknown log context direction %q, expected %s or %s", opts.Direction, logContextBackward, logContextForward)
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultLogContextLimit
+	}
+	limit = min(limit, maxLogContextLimit)
+
+	col := quoteIdentifier(column)
+	conditions := []string{fmt.Sprintf("%s %s '%s'", col, op, opts.Time.In(loc).Format(logContextTimestampFormat))}
+	var labels []string
+	if qm.Logs != nil {
+		labels = append(labels, qm.Logs.ContextLabels...)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		value, ok := opts.Labels[label]
+		if !ok {
+			conditions = append(conditions, quoteIdentifier(label)+" IS NULL")
+			continue
+		}
+		conditions = append(conditions, fmt.Sprintf("CAST(%s AS VARCHAR) = '%s'",
+			quoteIdentifier(label), strings.ReplaceAll(value, "'", "''")))
+	}
+
+	return fmt.Sprintf("SELECT * FROM (%s) AS log_context WHERE %s ORDER BY %s %s LIMIT %d",
+		strings.TrimRight(strings.TrimSpace(statement), ";"), strings.Join(conditions, " AND "), col, order, limit), nil
+}
@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestLogContextStatement(t *testing.T) {
+	line := time.Date(2024, 3, 1, 12, 0, 0, 500000000, time.UTC)
+	qm := queryModel{
+		Logs: &logsOptions{ContextLabels: []string{"host", "user"}},
+		LogContext: &logContextOptions{
+			Time:       line,
+			TimeColumn: "ts",
+			Labels:     map[string]string{"host": "o'neil", "node": "n1"},
+		},
+	}
+
+	statement, err := logContextStatement("SELECT * FROM logs;", qm, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SELECT * FROM (SELECT * FROM logs) AS log_context WHERE ts < '2024-03-01 12:00:00.5' " +
+		"AND CAST(host AS VARCHAR) = 'o''neil' AND \"user\" IS NULL ORDER BY ts DESC LIMIT 50"
+	if statement != want {
+		t.Errorf("got %q, want %q", statement, want)
+	}
+
+	qm.LogContext.Direction = logContextForward
+	qm.LogContext.Limit = 5000
+	qm.Logs = nil
+	statement, err = logContextStatement("SELECT * FROM logs", qm, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "SELECT * FROM (SELECT * FROM logs) AS log_context WHERE ts > '2024-03-01 12:00:00.5' ORDER BY ts ASC LIMIT 1000"
+	if statement != want {
+		t.Errorf("got %q, want %q", statement, want)
+	}
+
+	qm.LogContext.TimeColumn = ""
+	if _, err := logContextStatement("SELECT * FROM logs", qm, time.UTC); err == nil {
+		t.Error("expected an error without a time column")
+	}
+	qm.LogContext.TimeColumn = "ts"
+	if _, err := logContextStatement("DELETE FROM logs", qm, time.UTC); err == nil {
+		t.Error("expected an error for a statement that isn't a SELECT")
+	}
+}
+
+func TestQueryDataLogContext(t *testing.T) {
+	transport := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "ts", Type: "TIMESTAMP"}, {Name: "msg", Type: "VARCHAR"}},
+		Rows:    [][]interface{}{{"2024-03-01 11:59:00", "earlier"}},
+	}}
+	ds := Datasource{transport: transport}
+
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID: "A",
+			JSON: []byte(`{"queryText": "SELECT ts, msg FROM logs WHERE $__timeFilter(ts)", "format": "logs",
+				"logContext": {"time": "2024-03-01T12:00:00Z", "timeColumn": "ts", "limit": 10}}`),
+			TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(60, 0)},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatalf("unexpected error: %v", res.Error)
+	}
+	want := "SELECT * FROM (SELECT ts, msg FROM logs WHERE ts >= '2024-03-01 06:00:00' AND ts <= '2024-03-01 12:00:00') " +
+		"AS log_context WHERE ts < '2024-03-01 12:00:00' ORDER BY ts DESC LIMIT 10"
+	if len(transport.statements) != 1 || transport.statements[0] != want {
+		t.Fatalf("unexpected statements: %q", transport.statements)
+	}
+	if custom, _ := res.Frames[0].Meta.Custom.(map[string]string); custom["timeColumn"] != "ts" {
+		t.Errorf("expected the time column in the frame meta, got %v", res.Frames[0].Meta.Custom)
+	}
+}
@@ -0,0 +1,186 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// logsOptions names the columns of a log table. The body defaults to a column
+// named body, or else the first string column; the level defaults to a column
+// named level when there is one.
+type logsOptions struct {
+	BodyColumn  string `json:"bodyColumn"`
+	LevelColumn string `json:"levelColumn"`
+	// Levels maps level values, compared case-insensitively, to Grafana log
+	// levels. It is merged over the logLevels datasource setting.
+	Levels map[string]string `json:"levels"`
+	// ContextLabels are the label columns that the lines shown as the context
+	// of a line share with it, such as the host that logged them.
+	ContextLabels []string `json:"contextLabels"`
+}
+
+// logLevels are the log levels Grafana colors, by the names and abbreviations
+// commonly found in log tables.
+var logLevels = map[string]string{
+	"emerg":       "critical",
+	"emergency":   "critical",
+	"alert":       "critical",
+	"crit":        "critical",
+	"critical":    "critical",
+	"fatal":       "critical",
+	"panic":       "critical",
+	"err":         "error",
+	"eror":        "error",
+	"error":       "error",
+	"warn":        "warning",
+	"warning":     "warning",
+	"notice":      "info",
+	"info":        "info",
+	"information": "info",
+	"debug":       "debug",
+	"dbug":        "debug",
+	"trace":       "trace",
+	"verbose":     "trace",
+	"unknown":     "unknown",
+}
+
+// levelMapper maps the values of a level column to Grafana log levels.
+type levelMapper map[string]string
+
+// newLevelMapper merges the level mapping of a query over the one of the
+// datasource. Both map values to Grafana log levels or their aliases.
+func newLevelMapper(settings, query map[string]string) (levelMapper, error) {
+	mapper := make(levelMapper, len(settings)+len(query))
+	for _, mapping := range []map[string]string{settings, query} {
+		for value, level := range mapping {
+			canonical, ok := logLevels[strings.ToLower(strings.TrimSpace(level))]
+			if !ok {
+				return nil, fmt.Errorf("log level %q of value %q is not a Grafana log level, expected critical, error, warning, info, debug, trace or unknown", level, value)
+			}
+			mapper[strings.ToLower(strings.TrimSpace(value))] = canonical
+		}
+	}
+	return mapper, nil
+}
+
+// level returns the Grafana log level of a value. Values that are neither
+// mapped nor known level names are returned as they are.
+func (m levelMapper) level(value string) string {
+	key := strings.ToLower(strings.TrimSpace(value))
+	if level, ok := m[key]; ok {
+		return level
+	}
+	if level, ok := logLevels[key]; ok {
+		return level
+	}
+	return value
+}
+
+// toLogLines converts a result into a dataplane log-lines frame with
+// timestamp, body and, when a level column is found, severity fields. Every
+// other column is kept as a label of its line in a JSON labels field, so it
+// can be filtered on in Explore. Levels are mapped to the names Grafana colors
+// by the level mapping of the datasource and the query.
+func toLogLines(frame *data.Frame, opts *logsOptions, settingsLevels map[string]string) (*data.Frame, error) {
+	if frame.Rows() == 0 {
+		return frame, nil
+	}
+	if opts == nil {
+		opts = &logsOptions{}
+	}
+	levelMap, err := newLevelMapper(settingsLevels, opts.Levels)
+	if err != nil {
+		return nil, err
+	}
+
+	var timeField, bodyField, levelField *data.Field
+	for _, field := range frame.Fields {
+		if field.Type().Time() {
+			timeField = field
+			break
+		}
+	}
+	if timeField == nil {
+		return nil, fmt.Errorf("a time column is required for the logs format")
+	}
+
+	if opts.BodyColumn != "" {
+		if bodyField, _ = frame.FieldByName(opts.BodyColumn); bodyField == nil {
+			return nil, fmt.Errorf("logs body column %q is not in the result", opts.BodyColumn)
+		}
+	} else if bodyField, _ = frame.FieldByName("body"); bodyField == nil {
+		for _, field := range frame.Fields {
+			if field.Type() == data.FieldTypeString || field.Type() == data.FieldTypeNullableString {
+				bodyField = field
+				break
+			}
+		}
+		if bodyField == nil {
+			return nil, fmt.Errorf("no body column found for the logs format; set the body column")
+		}
+	}
+	if opts.LevelColumn != "" {
+		if levelField, _ = frame.FieldByName(opts.LevelColumn); levelField == nil {
+			return nil, fmt.Errorf("logs level column %q is not in the result", opts.LevelColumn)
+		}
+	} else if levelField, _ = frame.FieldByName("level"); levelField == bodyField {
+		levelField = nil
+	}
+
+	rows := frame.Rows()
+	timestamps := make([]time.Time, rows)
+	bodies := make([]string, rows)
+	levels := make([]string, rows)
+	labels := make([]json.RawMessage, rows)
+	for row := 0; row < rows; row++ {
+		if v, ok := timeField.ConcreteAt(row); ok {
+			timestamps[row] = v.(time.Time)
+		}
+		if v, ok := bodyField.ConcreteAt(row); ok {
+			bodies[row] = stringify(v)
+		}
+		if levelField != nil {
+			if v, ok := levelField.ConcreteAt(row); ok {
+				levels[row] = levelMap.level(stringify(v))
+			}
+		}
+		lineLabels := make(map[string]string)
+		for _, field := range frame.Fields {
+			if field == timeField || field == bodyField || field == levelField {
+				continue
+			}
+			if v, ok := field.ConcreteAt(row); ok {
+				lineLabels[field.Name] = stringify(v)
+			}
+		}
+		encoded, err := json.Marshal(lineLabels)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding log labels: %w", err)
+		}
+		labels[row] = encoded
+	}
+
+	out := data.NewFrame(frame.Name,
+		data.NewField("timestamp", nil, timestamps),
+		data.NewField("body", nil, bodies),
+	)
+	if levelField != nil {
+		out.Fields = append(out.Fields, data.NewField("severity", nil, levels))
+	}
+	out.Fields = append(out.Fields, data.NewField("labels", nil, labels))
+
+	meta := data.FrameMeta{}
+	if frame.Meta != nil {
+		meta = *frame.Meta
+	}
+	// Log context queries filter on the column the timestamps came from
+	meta.Custom = map[string]string{"timeColumn": timeField.Name}
+	out.Meta = &meta
+	setFrameType(out, data.FrameTypeLogLines, data.VisTypeLogs)
+	out.Meta.TypeVersion = data.FrameTypeVersion{0, 0}
+	return out, nil
+}
@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestToLogLines(t *testing.T) {
+	t0 := time.Unix(0, 0).UTC()
+	node := "n1"
+	frame := data.NewFrame("response",
+		data.NewField("ts", nil, []time.Time{t0, t0.Add(time.Second)}),
+		data.NewField("sev", nil, []string{"error", "info"}),
+		data.NewField("msg", nil, []string{"disk full", "started"}),
+		data.NewField("node", nil, []*string{&node, nil}),
+	)
+
+	logs, err := toLogLines(frame, &logsOptions{BodyColumn: "msg", LevelColumn: "sev"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logs.Meta.Type != data.FrameTypeLogLines {
+		t.Errorf("unexpected frame type %s", logs.Meta.Type)
+	}
+	for i, name := range []string{"timestamp", "body", "severity", "labels"} {
+		if logs.Fields[i].Name != name {
+			t.Errorf("field %d: got %s, want %s", i, logs.Fields[i].Name, name)
+		}
+	}
+	if body := logs.Fields[1].At(0); body != "disk full" {
+		t.Errorf("unexpected body %v", body)
+	}
+	if level := logs.Fields[2].At(0); level != "error" {
+		t.Errorf("unexpected severity %v", level)
+	}
+	var labels map[string]string
+	if err := json.Unmarshal(logs.Fields[3].At(0).(json.RawMessage), &labels); err != nil || labels["node"] != "n1" || len(labels) != 1 {
+		t.Errorf("unexpected labels %v (%v)", labels, err)
+	}
+	if second := string(logs.Fields[3].At(1).(json.RawMessage)); second != "{}" {
+		t.Errorf("expected null labels to be left out, got %s", second)
+	}
+
+	// Without options the first string column is the body and there is no level
+	defaulted, err := toLogLines(frame, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defaulted.Fields) != 3 || defaulted.Fields[1].At(0) != "error" {
+		t.Errorf("unexpected default mapping %v", defaulted.Fields)
+	}
+
+	if _, err := toLogLines(frame, &logsOptions{LevelColumn: "missing"}, nil); err == nil {
+		t.Error("expected an error for a missing level column")
+	}
+}
+
+func TestToLogLinesLevelMapping(t *testing.T) {
+	t0 := time.Unix(0, 0).UTC()
+	frame := data.NewFrame("response",
+		data.NewField("ts", nil, []time.Time{t0, t0, t0, t0}),
+		data.NewField("msg", nil, []string{"a", "b", "c", "d"}),
+		data.NewField("level", nil, []int64{3, 4, 6, 9}),
+	)
+
+	logs, err := toLogLines(frame, &logsOptions{Levels: map[string]string{"6": "INFO"}},
+		map[string]string{"3": "error", "4": "warn", "6": "debug"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for row, want := range []string{"error", "warning", "info", "9"} {
+		if got := logs.Fields[2].At(row); got != want {
+			t.Errorf("row %d: got severity %v, want %s", row, got, want)
+		}
+	}
+
+	// Known level names are normalized without a mapping
+	named := data.NewFrame("response",
+		data.NewField("ts", nil, []time.Time{t0, t0}),
+		data.NewField("msg", nil, []string{"a", "b"}),
+		data.NewField("level", nil, []string{"WARNING", "Fatal"}),
+	)
+	logs, err = toLogLines(named, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logs.Fields[2].At(0) != "warning" || logs.Fields[2].At(1) != "critical" {
+		t.Errorf("unexpected severities %v, %v", logs.Fields[2].At(0), logs.Fields[2].At(1))
+	}
+
+	if _, err := toLogLines(frame, &logsOptions{Levels: map[string]string{"3": "loud"}}, nil); err == nil {
+		t.Error("expected an error for an unknown log level")
+	}
+}
@@ -0,0 +1,111 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Lookup results rarely change, so they are cached for a while, apart from the
+// responses Grafana's query caching keeps.
+const (
+	lookupCacheTTL     = 5 * time.Minute
+	lookupCacheEntries = 100
+	// maxLookupValues bounds the value mappings of one column, which Grafana
+	// keeps in the field config of every frame.
+	maxLookupValues = 10000
+)
+
+// lookupMappings returns the value mappings of a lookup statement, whose first
+// column holds the values of a field, such as ids, and whose second column the
+// text they are shown as, such as names.
+func (d *Datasource) lookupMappings(ctx context.Context, transport QueryTransport, environment, statement string) (data.ValueMapper, error) {
+	if !isReadOnlyStatement(statement) {
+		return nil, fmt.Errorf("a lookup must be a single SELECT statement")
+	}
+	key := cacheScope(ctx) + environment + "\x00" + statement
+	if d.lookupCache != nil && !cacheBypassed(ctx) {
+		if mapper, _, ok := d.lookupCache.get(key); ok {
+			return mapper, nil
+		}
+	}
+
+	result, err := transport.Execute(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+	if err := result.decodeRows(); err != nil {
+		return nil, err
+	}
+	if len(result.Columns) < 2 {
+		return nil, fmt.Errorf("a lookup must return a value and a text column, got %d columns", len(result.Columns))
+	}
+	if len(result.Rows) > maxLookupValues {
+		return nil, fmt.Errorf("a lookup can return at most %d rows, got %d", maxLookupValues, len(result.Rows))
+	}
+
+	mapper := make(data.ValueMapper, len(result.Rows))
+	for i, row := range result.Rows {
+		if len(row) < 2 || row[0] == nil {
+			continue
+		}
+		text := ""
+		if row[1] != nil {
+			text = lookupKey(row[1])
+		}
+		mapper[lookupKey(row[0])] = data.ValueMappingResult{Text: text, Index: i}
+	}
+	if d.lookupCache != nil {
+		d.lookupCache.set(key, mapper)
+	}
+	return mapper, nil
+}
+
+// lookupKey formats a lookup value the way Grafana matches value mappings,
+// which compares the displayed value of a field as text.
+func lookupKey(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// queryLookups runs the lookups of the query, keyed by column. A failed lookup
+// doesn't fail the query, its column is returned unmapped with a warning.
+func (d *Datasource) queryLookups(ctx context.Context, transport QueryTransport, qm queryModel, mc macroContext) (map[string]data.ValueMapper, []data.Notice) {
+	var mappings map[string]data.ValueMapper
+	var notices []data.Notice
+	for column, opts := range qm.FieldOptions {
+		if opts.Lookup == "" {
+			continue
+		}
+		statement, err := expandMacros(opts.Lookup, mc)
+		if err == nil {
+			if d.settings.DefaultSchema != "" {
+				statement = qualifyTables(statement, d.settings.DefaultSchema)
+			}
+			var mapper data.ValueMapper
+			if mapper, err = d.lookupMappings(ctx, transport, qm.Environment, statement); err == nil {
+				if mappings == nil {
+					mappings = make(map[string]data.ValueMapper)
+				}
+				mappings[column] = mapper
+				continue
+			}
+		}
+		backend.Logger.Warn("Lookup failed", "column", column, "error", err.Error())
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("The lookup of column %q failed, its values are not mapped: %v", column, err),
+		})
+	}
+	return mappings, notices
+}
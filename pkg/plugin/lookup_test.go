@@ -0,0 +1,106 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// statementTransport returns a result per statement prefix.
+type statementTransport struct {
+	results    map[string]*QueryResult
+	statements []string
+}
+
+func (s *statementTransport) Execute(_ context.Context, statement string) (*QueryResult, error) {
+	s.statements = append(s.statements, statement)
+	for prefix, result := range s.results {
+		if strings.HasPrefix(statement, prefix) {
+			return result, nil
+		}
+	}
+	return nil, errors.New("table not found")
+}
+
+func (s *statementTransport) Close() error {
+	return nil
+}
+
+func TestQueryDataLookup(t *testing.T) {
+	transport := &statementTransport{results: map[string]*QueryResult{
+		"SELECT host_id": {
+			Columns: []Column{{Name: "host_id", Type: "INT"}, {Name: "site_id", Type: "INT"}},
+			Rows:    [][]interface{}{{float64(1), float64(7)}, {float64(2), float64(7)}},
+		},
+		"SELECT id, name FROM hosts": {
+			Columns: []Column{{Name: "id", Type: "INT"}, {Name: "name", Type: "VARCHAR"}},
+			Rows:    [][]interface{}{{float64(1), "web-1"}, {float64(2), "web-2"}},
+		},
+	}}
+	ds := Datasource{transport: transport, lookupCache: newTTLCache[data.ValueMapper](lookupCacheTTL, lookupCacheEntries)}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"queryText": "SELECT host_id, site_id FROM metrics",
+		"fieldOptions": map[string]interface{}{
+			"host_id": map[string]string{"lookup": "SELECT id, name FROM hosts"},
+			"site_id": map[string]string{"lookup": "SELECT id, name FROM sites"},
+		},
+	})
+	for i := 0; i < 2; i++ {
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: body}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := resp.Responses["A"]
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		frame := res.Frames[0]
+		config := frame.Fields[0].Config
+		if config == nil || len(config.Mappings) != 1 {
+			t.Fatalf("expected a value mapping for host_id, got %+v", config)
+		}
+		mapper := config.Mappings[0].(data.ValueMapper)
+		if mapper["1"].Text != "web-1" || mapper["2"].Text != "web-2" {
+			t.Errorf("unexpected mappings %+v", mapper)
+		}
+		if len(frame.Meta.Notices) != 1 || !strings.Contains(frame.Meta.Notices[0].Text, `"site_id"`) {
+			t.Errorf("expected a warning for the failed site_id lookup, got %+v", frame.Meta.Notices)
+		}
+	}
+
+	hosts := 0
+	for _, statement := range transport.statements {
+		if strings.HasPrefix(statement, "SELECT id, name FROM hosts") {
+			hosts++
+		}
+	}
+	if hosts != 1 {
+		t.Errorf("expected the host lookup to run once and then be cached, ran %d times", hosts)
+	}
+
+	// Bypassing Grafana's query cache reads the lookup again
+	req := &backend.QueryDataRequest{Queries: []backend.DataQuery{{RefID: "A", JSON: body}}}
+	req.SetHTTPHeader(cacheSkipHeader, "true")
+	if _, err := ds.QueryData(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(transport.statements); !strings.HasPrefix(strings.Join(transport.statements[n-3:], "\n"), "SELECT host_id") ||
+		!strings.Contains(strings.Join(transport.statements[n-3:], "\n"), "SELECT id, name FROM hosts") {
+		t.Errorf("expected the host lookup to run again when the cache is bypassed, ran %q", transport.statements)
+	}
+}
+
+func TestLookupMappingsReadOnly(t *testing.T) {
+	ds := Datasource{}
+	if _, err := ds.lookupMappings(context.Background(), &fakeTransport{}, "", "DELETE FROM hosts"); err == nil {
+		t.Error("expected a write statement to be refused as a lookup")
+	}
+}
@@ -0,0 +1,179 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// macroTimestampFormat is the layout of timestamp literals produced by macros.
+const macroTimestampFormat = "2006-01-02 15:04:05"
+
+// macroPattern matches a macro call such as $__timeFilter(column). Arguments
+// may be quoted literals containing parentheses.
+var macroPattern = regexp.MustCompile(`\$__(\w+)\(((?:'(?:[^']|'')*'|[^)'])*)\)`)
+
+// macroContext holds what macros are expanded against.
+type macroContext struct {
+	timeRange backend.TimeRange
+	// loc is the session timezone that timestamp literals are written in
+	loc *time.Location
+	// filters are the ad-hoc filters of the dashboard, typed by columnTypes
+	filters     []adhocFilter
+	columnTypes map[string]string
+	// interval is the interval Grafana suggests for the panel, and
+	// minInterval the smallest that $__timeGroup groups the table by
+	interval    time.Duration
+	minInterval time.Duration
+	// timeColumn is the configured time column of the table, used by
+	// $__timeFilter() and $__timeGroup() when they are given no column
+	timeColumn string
+}
+
+// macroFunc expands a macro given its comma separated arguments.
+type macroFunc func(mc macroContext, args []string) (string, error)
+
+// macros are the supported macros by name, without the $__ prefix.
+var macros = map[string]macroFunc{
+	"timeFrom": func(mc macroContext, args []string) (string, error) {
+		return mc.literal(mc.timeRange.From), nil
+	},
+	"timeTo": func(mc macroContext, args []string) (string, error) {
+		return mc.literal(mc.timeRange.To), nil
+	},
+	"quoteIdentifier": func(mc macroContext, args []string) (string, error) {
+		if len(args) != 1 || args[0] == "" {
+			return "", fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		return quoteQualifiedIdentifier(args[0]), nil
+	},
+	"timeFilter": func(mc macroContext, args []string) (string, error) {
+		args = mc.withTimeColumn(args)
+		if len(args) != 1 || args[0] == "" {
+			return "", fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		return fmt.Sprintf("%s >= %s AND %s <= %s",
+			args[0], mc.literal(mc.timeRange.From), args[0], mc.literal(mc.timeRange.To)), nil
+	},
+	"timeGroup": func(mc macroContext, args []string) (string, error) {
+		args = mc.withTimeColumn(args)
+		if len(args) < 1 || len(args) > 2 || args[0] == "" {
+			return "", fmt.Errorf("expected a column and an optional interval, got %d arguments", len(args))
+		}
+		interval := mc.interval
+		if len(args) == 2 {
+			d, ok := parseInterval(strings.Trim(args[1], "'\""))
+			if !ok || d <= 0 {
+				return "", fmt.Errorf("invalid interval %s", args[1])
+			}
+			interval = d
+		}
+		interval = max(interval, mc.minInterval)
+		if interval <= 0 {
+			return "", fmt.Errorf("no interval given and the panel has none")
+		}
+		return timeGroupExpression(args[0], interval), nil
+	},
+	"adhocFilters": func(mc macroContext, args []string) (string, error) {
+		if len(args) != 0 {
+			return "", fmt.Errorf("expected no arguments, got %d", len(args))
+		}
+		return adhocFiltersCondition(mc.filters, mc.columnTypes, mc.loc)
+	},
+	"equalsIgnoreCase": ignoreCaseComparison("="),
+	"likeIgnoreCase":   ignoreCaseComparison("LIKE"),
+	"inIgnoreCase": func(mc macroContext, args []string) (string, error) {
+		if len(args) < 2 {
+			return "", fmt.Errorf("expected a column and at least 1 value, got %d arguments", len(args))
+		}
+		values := make([]string, len(args)-1)
+		for i, value := range args[1:] {
+			values[i] = "LOWER(" + value + ")"
+		}
+		return fmt.Sprintf("LOWER(%s) IN (%s)", args[0], strings.Join(values, ", ")), nil
+	},
+}
+
+// ignoreCaseComparison returns a macro comparing a column with a value using
+// op, with both sides lowercased. Ocient compares strings case sensitively, so
+// this is how variable driven filters match regardless of case.
+func ignoreCaseComparison(op string) macroFunc {
+	return func(mc macroContext, args []string) (string, error) {
+		if len(args) != 2 {
+			return "", fmt.Errorf("expected 2 arguments, got %d", len(args))
+		}
+		return fmt.Sprintf("LOWER(%s) %s LOWER(%s)", args[0], op, args[1]), nil
+	}
+}
+
+// withTimeColumn returns the arguments of a time macro with the configured
+// time column of the table in place of an omitted column, as in
+// $__timeFilter() or $__timeGroup(, '5m').
+func (mc macroContext) withTimeColumn(args []string) []string {
+	if mc.timeColumn == "" {
+		return args
+	}
+	if len(args) == 0 {
+		return []string{mc.timeColumn}
+	}
+	if args[0] == "" {
+		return append([]string{mc.timeColumn}, args[1:]...)
+	}
+	return args
+}
+
+// literal formats t as a timestamp literal in the session timezone.
+func (mc macroContext) literal(t time.Time) string {
+	return "'" + t.In(mc.loc).Format(macroTimestampFormat) + "'"
+}
+
+// expandMacros replaces every known macro in statement. Unknown macros are
+// left untouched so Ocient reports them.
+func expandMacros(statement string, mc macroContext) (string, error) {
+	if mc.loc == nil {
+		mc.loc = time.UTC
+	}
+	var expandErr error
+	expanded := macroPattern.ReplaceAllStringFunc(statement, func(call string) string {
+		match := macroPattern.FindStringSubmatch(call)
+		fn, ok := macros[match[1]]
+		if !ok || expandErr != nil {
+			return call
+		}
+		out, err := fn(mc, splitMacroArgs(match[2]))
+		if err != nil {
+			expandErr = fmt.Errorf("macro $__%s: %w", match[1], err)
+			return call
+		}
+		return out
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return expanded, nil
+}
+
+// splitMacroArgs splits the arguments of a macro call on commas outside quoted
+// literals, such as the values of a multi-value variable.
+func splitMacroArgs(list string) []string {
+	if strings.TrimSpace(list) == "" {
+		return nil
+	}
+	var args []string
+	start := 0
+	for i := 0; i < len(list); i++ {
+		switch list[i] {
+		case '\'', '"':
+			if end := skipQuoted(list, i); end > 0 {
+				i = end - 1
+			}
+		case ',':
+			args = append(args, strings.TrimSpace(list[start:i]))
+			start = i + 1
+		}
+	}
+	return append(args, strings.TrimSpace(list[start:]))
+}
@@ -0,0 +1,54 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestExpandMacros(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := macroContext{
+		timeRange: backend.TimeRange{
+			From: time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC),
+			To:   time.Date(2024, 1, 2, 16, 30, 0, 0, time.UTC),
+		},
+	}
+
+	tests := []struct {
+		statement string
+		loc       *time.Location
+		want      string
+	}{
+		{"SELECT * FROM t WHERE ts >= $__timeFrom() AND ts <= $__timeTo()", nil,
+			"SELECT * FROM t WHERE ts >= '2024-01-02 15:00:00' AND ts <= '2024-01-02 16:30:00'"},
+		{"SELECT * FROM t WHERE $__timeFilter(ts)", newYork,
+			"SELECT * FROM t WHERE ts >= '2024-01-02 10:00:00' AND ts <= '2024-01-02 11:30:00'"},
+		{"SELECT $__unknown(x)", nil, "SELECT $__unknown(x)"},
+		{"SELECT $__quoteIdentifier(order) FROM $__quoteIdentifier(demo.user)", nil, `SELECT "order" FROM demo."user"`},
+		{"SELECT * FROM t WHERE $__equalsIgnoreCase(host, 'Web-1')", nil, "SELECT * FROM t WHERE LOWER(host) = LOWER('Web-1')"},
+		{"SELECT * FROM t WHERE $__likeIgnoreCase(msg, '%Error (a, b)%')", nil, "SELECT * FROM t WHERE LOWER(msg) LIKE LOWER('%Error (a, b)%')"},
+		{"SELECT * FROM t WHERE $__inIgnoreCase(host, 'A','it''s, b')", nil, "SELECT * FROM t WHERE LOWER(host) IN (LOWER('A'), LOWER('it''s, b'))"},
+	}
+	for _, tt := range tests {
+		mc.loc = tt.loc
+		got, err := expandMacros(tt.statement, mc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("expandMacros(%q) = %q, want %q", tt.statement, got, tt.want)
+		}
+	}
+
+	if _, err := expandMacros("SELECT $__timeFilter()", mc); err == nil {
+		t.Error("expected an error for a missing macro argument")
+	}
+	if _, err := expandMacros("SELECT $__inIgnoreCase(host)", mc); err == nil {
+		t.Error("expected an error for $__inIgnoreCase without values")
+	}
+}
@@ -0,0 +1,243 @@
+package plugin
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// The /tables and /columns resources list the catalog a page at a time, by
+// name after a cursor and optionally searched, since clusters with hundreds
+// of thousands of tables can't be listed at once. Pages are written to the
+// response as they are encoded rather than built in memory first.
+const (
+	metadataPageSize    = 500
+	metadataMaxPageSize = 5000
+	metadataTimeout     = 30 * time.Second
+	// metadataFlushItems is how many items are written between flushes
+	metadataFlushItems = 200
+)
+
+// metadataColumn is an item of the /columns resource, in the shape of
+// information_schema.columns.
+type metadataColumn struct {
+	Name     string  `json:"column_name"`
+	Type     string  `json:"data_type"`
+	Nullable string  `json:"is_nullable"`
+	Default  *string `json:"column_default"`
+}
+
+// metadataPage holds the parameters of a page request: names after Cursor,
+// containing Search case-insensitively, at most Limit of them.
+type metadataPage struct {
+	Search string
+	Cursor string
+	Limit  int
+}
+
+// parseMetadataPage reads the search, cursor and limit parameters of a page
+// request. Cursors are the last name of the previous page, encoded.
+func parseMetadataPage(r *http.Request) (metadataPage, error) {
+	params := r.URL.Query()
+	page := metadataPage{Search: params.Get("search"), Limit: metadataPageSize}
+	if cursor := params.Get("cursor"); cursor != "" {
+		name, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return page, fmt.Errorf("invalid cursor")
+		}
+		page.Cursor = string(name)
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return page, fmt.Errorf("invalid limit %q", limit)
+		}
+		page.Limit = min(n, metadataMaxPageSize)
+	}
+	return page, nil
+}
+
+// conditions returns the conditions selecting the names of the page in the
+// expression name. One more row than the limit is asked for, to tell whether
+// another page follows. % and _ in the search match any characters.
+func (p metadataPage) conditions(name string) string {
+	var conditions []string
+	if p.Cursor != "" {
+		conditions = append(conditions, name+" > "+stringLiteral(p.Cursor))
+	}
+	if p.Search != "" {
+		conditions = append(conditions, "LOWER("+name+") LIKE "+stringLiteral("%"+strings.ToLower(p.Search)+"%"))
+	}
+	var sb strings.Builder
+	for _, c := range conditions {
+		sb.WriteString(" AND " + c)
+	}
+	fmt.Fprintf(&sb, " ORDER BY %s LIMIT %d", name, p.Limit+1)
+	return sb.String()
+}
+
+// tablesStatement lists a page of the tables of schema, or of every table
+// qualified by its schema when schema is empty.
+func tablesStatement(schema string, page metadataPage) string {
+	if schema == "" {
+		return "SELECT table_schema || '.' || table_name FROM information_schema.tables WHERE 1=1" +
+			page.conditions("table_schema || '.' || table_name")
+	}
+	return "SELECT table_name FROM information_schema.tables WHERE table_schema = " + stringLiteral(schema) +
+		page.conditions("table_name")
+}
+
+// columnsStatement lists a page of the columns of a table.
+func columnsStatement(schema, table string, page metadataPage) string {
+	return "SELECT column_name, data_type, is_nullable, column_default FROM information_schema.columns WHERE table_schema = " +
+		stringLiteral(schema) + " AND table_name = " + stringLiteral(table) + page.conditions("column_name")
+}
+
+// handleTables lists a page of the tables of the schema parameter, by
+// default the default schema of the datasource.
+func (d *Datasource) handleTables(w http.ResponseWriter, r *http.Request) {
+	page, err := parseMetadataPage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	schema := r.URL.Query().Get("schema")
+	if schema == "" {
+		schema = d.settings.DefaultSchema
+	}
+	result, visibility, ok := d.metadataResult(w, r, tablesStatement(schema, page))
+	if !ok {
+		return
+	}
+	names := make([]string, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		names = append(names, rowString(row, 0))
+	}
+	writeMetadataPage(w, names, page.Limit, func(name string) string { return name }, func(name string) bool {
+		if schema == "" {
+			return visibility.visible(name)
+		}
+		return visibility.visible(schema + "." + name)
+	})
+}
+
+// handleColumns lists a page of the columns of the table parameter.
+func (d *Datasource) handleColumns(w http.ResponseWriter, r *http.Request) {
+	page, err := parseMetadataPage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	schema, table := r.URL.Query().Get("schema"), r.URL.Query().Get("table")
+	if schema == "" {
+		schema = d.settings.DefaultSchema
+	}
+	if schema == "" || table == "" {
+		writeError(w, http.StatusBadRequest, "schema and table are required")
+		return
+	}
+	result, visibility, ok := d.metadataResult(w, r, columnsStatement(schema, table, page))
+	if !ok {
+		return
+	}
+	if !visibility.visible(schema + "." + table) {
+		result.Rows = nil
+	}
+	columns := make([]metadataColumn, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		column := metadataColumn{Name: rowString(row, 0), Type: rowString(row, 1), Nullable: rowString(row, 2)}
+		if len(row) > 3 && row[3] != nil {
+			value := stringify(row[3])
+			column.Default = &value
+		}
+		columns = append(columns, column)
+	}
+	writeMetadataPage(w, columns, page.Limit, func(c metadataColumn) string { return c.Name }, nil)
+}
+
+// metadataResult runs a catalog statement in the environment parameter,
+// writing an error response when it fails. The visibility filters the
+// result to the tables the user of the request can read.
+func (d *Datasource) metadataResult(w http.ResponseWriter, r *http.Request, statement string) (*QueryResult, tableVisibility, bool) {
+	if d.blockInsecureTLS() {
+		writeError(w, http.StatusForbidden, insecureTLSMessage+"; enable verification to run queries")
+		return nil, nil, false
+	}
+	if err := d.undecryptedSecrets(); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return nil, nil, false
+	}
+	if err := forwardedIdentityError(r.Context()); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return nil, nil, false
+	}
+	environment := r.URL.Query().Get("environment")
+	transport, err := d.environmentTransport(environment)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, nil, false
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), metadataTimeout)
+	defer cancel()
+	result, err := transport.Execute(ctx, statement)
+	if err == nil {
+		err = result.decodeRows()
+	}
+	if err != nil {
+		backend.Logger.Error("Metadata listing failed", "path", r.URL.Path, "error", err.Error())
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("listing failed: %v", err))
+		return nil, nil, false
+	}
+	user, filter := d.metadataUser(r)
+	visibility, err := d.userTableVisibility(ctx, transport, environment, user, filter)
+	if err != nil {
+		backend.Logger.Error("Listing table privileges failed", "error", err.Error())
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("listing privileges failed: %v", err))
+		return nil, nil, false
+	}
+	return result, visibility, true
+}
+
+// writeMetadataPage writes a page of items as {"items": [...], "nextCursor": ...},
+// flushing as it goes. A result longer than limit has a next page, which
+// starts after the name of the last item of the page. Items that keep
+// rejects are left out, so filtered pages may be short.
+func writeMetadataPage[T any](w http.ResponseWriter, items []T, limit int, name func(T) string, keep func(T) bool) {
+	next := ""
+	if len(items) > limit {
+		items = items[:limit]
+		next = base64.RawURLEncoding.EncodeToString([]byte(name(items[limit-1])))
+	}
+	if keep != nil {
+		items = slices.DeleteFunc(items, func(item T) bool { return !keep(item) })
+	}
+	flusher, _ := w.(http.Flusher)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`{"items":[`))
+	for i, item := range items {
+		b, err := json.Marshal(item)
+		if err != nil {
+			backend.Logger.Error("Failed to write resource response", "error", err.Error())
+			return
+		}
+		if i > 0 {
+			_, _ = w.Write([]byte(","))
+		}
+		_, _ = w.Write(b)
+		if flusher != nil && (i+1)%metadataFlushItems == 0 {
+			flusher.Flush()
+		}
+	}
+	nextCursor, _ := json.Marshal(next)
+	_, _ = w.Write([]byte(`],"nextCursor":` + string(nextCursor) + "}\n"))
+}
@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTablesResource(t *testing.T) {
+	transport := &statementTransport{results: map[string]*QueryResult{
+		"SELECT table_name FROM information_schema.tables": {
+			Columns: []Column{{Name: "table_name", Type: "VARCHAR"}},
+			Rows:    [][]interface{}{{"events"}, {"hosts"}, {"metrics"}},
+		},
+	}}
+	ds := &Datasource{transport: transport}
+
+	var page struct {
+		Items      []string `json:"items"`
+		NextCursor string   `json:"nextCursor"`
+	}
+	resp := callResource(t, ds, "GET", "tables?schema=web&search=E&limit=2")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("got status %d: %s", resp.Status, resp.Body)
+	}
+	if err := json.Unmarshal(resp.Body, &page); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(page.Items, ",") != "events,hosts" || page.NextCursor == "" {
+		t.Errorf("unexpected page %+v", page)
+	}
+	want := "SELECT table_name FROM information_schema.tables WHERE table_schema = 'web' AND LOWER(table_name) LIKE '%e%' ORDER BY table_name LIMIT 3"
+	if transport.statements[0] != want {
+		t.Errorf("got statement %q, want %q", transport.statements[0], want)
+	}
+
+	// The next page starts after the last name of the previous one
+	callResource(t, ds, "GET", "tables?schema=web&limit=2&cursor="+page.NextCursor)
+	if !strings.Contains(transport.statements[1], "table_name > 'hosts'") {
+		t.Errorf("expected the statement to start after hosts, got %q", transport.statements[1])
+	}
+
+	if resp := callResource(t, ds, "GET", "tables?cursor=!"); resp.Status != http.StatusBadRequest {
+		t.Errorf("got status %d for an invalid cursor, want 400", resp.Status)
+	}
+}
+
+func TestTablesResourceStreamsLargePages(t *testing.T) {
+	rows := make([][]interface{}, 450)
+	for i := range rows {
+		rows[i] = []interface{}{fmt.Sprintf("web.t%03d", i)}
+	}
+	ds := &Datasource{transport: &statementTransport{results: map[string]*QueryResult{
+		"SELECT table_schema": {Columns: []Column{{Name: "name", Type: "VARCHAR"}}, Rows: rows},
+	}}}
+
+	var page struct {
+		Items      []string `json:"items"`
+		NextCursor string   `json:"nextCursor"`
+	}
+	if err := json.Unmarshal(callResource(t, ds, "GET", "tables").Body, &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 450 || page.NextCursor != "" {
+		t.Errorf("got %d items and cursor %q, want 450 and none", len(page.Items), page.NextCursor)
+	}
+}
+
+func TestColumnsResource(t *testing.T) {
+	transport := &statementTransport{results: map[string]*QueryResult{
+		"SELECT column_name": {
+			Columns: []Column{{Name: "column_name"}, {Name: "data_type"}, {Name: "is_nullable"}, {Name: "column_default"}},
+			Rows:    [][]interface{}{{"id", "INT", "NO", "0"}, {"name", "VARCHAR", "YES", nil}},
+		},
+	}}
+	ds := &Datasource{transport: transport}
+
+	var page struct {
+		Items []metadataColumn `json:"items"`
+	}
+	if err := json.Unmarshal(callResource(t, ds, "GET", "columns?schema=web&table=hosts").Body, &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 2 || page.Items[0].Default == nil || *page.Items[0].Default != "0" || page.Items[1].Default != nil {
+		t.Errorf("unexpected columns %+v", page.Items)
+	}
+	if !strings.Contains(transport.statements[0], "table_schema = 'web' AND table_name = 'hosts'") {
+		t.Errorf("unexpected statement %q", transport.statements[0])
+	}
+
+	if resp := callResource(t, ds, "GET", "columns?schema=web"); resp.Status != http.StatusBadRequest {
+		t.Errorf("got status %d without a table, want 400", resp.Status)
+	}
+}
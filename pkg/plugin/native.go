@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// nativeTransport executes statements through the Ocient native protocol using
+// the database/sql driver.
+type nativeTransport struct {
+	db *sql.DB
+}
+
+func newNativeTransport(settings models.PluginSettings) (*nativeTransport, error) {
+	db, err := sql.Open(models.NativeDriverName, nativeDSN(settings))
+	if err != nil {
+		return nil, fmt.Errorf("native transport unavailable: %w", err)
+	}
+	return &nativeTransport{db: db}, nil
+}
+
+// nativeDSN builds the connection string understood by the native driver.
+func nativeDSN(settings models.PluginSettings) string {
+	dsn := url.URL{
+		Scheme: "ocient",
+		Host:   net.JoinHostPort(settings.Host, strconv.Itoa(settings.Port)),
+		Path:   "/" + settings.Database,
+	}
+	if settings.Secrets != nil {
+		dsn.User = url.UserPassword(settings.Secrets.Username, settings.Secrets.Password)
+	}
+	return dsn.String()
+}
+
+// Execute runs the statement on a pooled connection and decodes every row into
+// the same shapes produced by the REST API so that frame conversion is shared.
+func (t *nativeTransport) Execute(ctx context.Context, statement string) (*QueryResult, error) {
+	rows, err := t.db.QueryContext(ctx, statement)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("error reading columns: %w", err)
+	}
+
+	result := &QueryResult{Columns: make([]Column, len(columnTypes))}
+	for i, ct := range columnTypes {
+		result.Columns[i] = Column{Name: ct.Name(), Type: ct.DatabaseTypeName()}
+	}
+
+	values := make([]interface{}, len(columnTypes))
+	pointers := make([]interface{}, len(values))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		row := make([]interface{}, len(values))
+		for i, v := range values {
+			row[i] = normalizeNativeValue(v)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// normalizeNativeValue maps driver values onto the JSON types the REST API
+// returns. Integers and timestamps are kept as they are, since float64 would
+// round large integers and a formatted timestamp would lose its zone.
+func normalizeNativeValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case float32:
+		return float64(val)
+	case []byte:
+		// Copy since the driver may reuse the buffer for the next row
+		return append([]byte(nil), val...)
+	default:
+		return val
+	}
+}
+
+// Close closes the connection pool.
+func (t *nativeTransport) Close() error {
+	return t.db.Close()
+}
@@ -0,0 +1,145 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// fakeNativeDriver stands in for the Ocient database/sql driver, answering
+// every query with the columns and rows of the driver.
+type fakeNativeDriver struct {
+	columns []string
+	types   []string
+	rows    [][]driver.Value
+}
+
+// nativeSeen is the timestamp the fake driver returns, decoded in UTC.
+var nativeSeen = time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+func init() {
+	sql.Register(models.NativeDriverName, &fakeNativeDriver{
+		columns: []string{"host", "cpu", "id", "seen"},
+		types:   []string{"VARCHAR", "BIGINT", "BIGINT", "TIMESTAMP"},
+		rows: [][]driver.Value{
+			{"web-1", int64(42), int64(1<<60 + 1), nativeSeen},
+			{"web-2", int64(7), int64(1<<60 + 2), nativeSeen},
+		},
+	})
+}
+
+func (d *fakeNativeDriver) Open(string) (driver.Conn, error) { return fakeNativeConn{d}, nil }
+
+type fakeNativeConn struct{ driver *fakeNativeDriver }
+
+func (c fakeNativeConn) Prepare(string) (driver.Stmt, error) { return fakeNativeStmt(c), nil }
+func (c fakeNativeConn) Close() error                        { return nil }
+func (c fakeNativeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+type fakeNativeStmt struct{ driver *fakeNativeDriver }
+
+func (s fakeNativeStmt) Close() error  { return nil }
+func (s fakeNativeStmt) NumInput() int { return -1 }
+func (s fakeNativeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s fakeNativeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &fakeNativeRows{driver: s.driver}, nil
+}
+
+type fakeNativeRows struct {
+	driver *fakeNativeDriver
+	next   int
+}
+
+func (r *fakeNativeRows) Columns() []string                       { return r.driver.columns }
+func (r *fakeNativeRows) ColumnTypeDatabaseTypeName(i int) string { return r.driver.types[i] }
+func (r *fakeNativeRows) Close() error                            { return nil }
+func (r *fakeNativeRows) Next(dest []driver.Value) error {
+	if r.next == len(r.driver.rows) {
+		return io.EOF
+	}
+	copy(dest, r.driver.rows[r.next])
+	r.next++
+	return nil
+}
+
+func TestNativeTransport(t *testing.T) {
+	instance, err := NewDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData:                []byte(`{"host": "ocient", "database": "db", "transport": "native"}`),
+		DecryptedSecureJSONData: map[string]string{"username": "user", "password": "pass"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := instance.(*Datasource)
+	defer ds.Dispose()
+
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"queryText": "SELECT host, cpu FROM hosts"}`)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	frame := res.Frames[0]
+	if frame.Rows() != 2 || len(frame.Fields) != 4 {
+		t.Fatalf("unexpected frame %+v", frame)
+	}
+	if host, _ := frame.Fields[0].ConcreteAt(0); host != "web-1" {
+		t.Errorf("got host %v, want web-1", host)
+	}
+	if cpu, _ := frame.Fields[1].FloatAt(1); cpu != 7 {
+		t.Errorf("got cpu %v, want 7", cpu)
+	}
+}
+
+func TestNativeTransportValues(t *testing.T) {
+	// A session timezone other than UTC must not shift timestamps the driver
+	// has already decoded
+	instance, err := NewDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData:                []byte(`{"host": "ocient", "database": "db", "transport": "native", "timezone": "America/Chicago"}`),
+		DecryptedSecureJSONData: map[string]string{"username": "user", "password": "pass"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := instance.(*Datasource)
+	defer ds.Dispose()
+
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"queryText": "SELECT host, cpu, id, seen FROM hosts"}`)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	frame := res.Frames[0]
+	if id, _ := frame.Fields[2].ConcreteAt(0); id != int64(1<<60+1) {
+		t.Errorf("got id %v, want %d", id, int64(1<<60+1))
+	}
+	seen, _ := frame.Fields[3].ConcreteAt(0)
+	if at, ok := seen.(time.Time); !ok || !at.Equal(nativeSeen) {
+		t.Errorf("got seen %v, want %v", seen, nativeSeen)
+	}
+}
+
+func TestUnsupportedTransport(t *testing.T) {
+	_, err := models.LoadPluginSettings(backend.DataSourceInstanceSettings{JSONData: []byte(`{"transport": "odbc"}`)})
+	if err == nil {
+		t.Error("expected an unknown transport to be rejected")
+	}
+}
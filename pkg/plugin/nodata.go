@@ -0,0 +1,40 @@
+package plugin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Values of the noData query option, which decides what a query returning no
+// rows answers. Alert rules evaluate an empty frame as NoData, so rules over
+// sparse data flap between NoData and OK; a zero point keeps them at OK.
+const (
+	noDataEmpty = "empty"
+	noDataZero  = "zero"
+)
+
+// validateNoData checks the noData option of a query.
+func validateNoData(mode string) error {
+	switch mode {
+	case "", noDataEmpty, noDataZero:
+		return nil
+	}
+	return fmt.Errorf("unknown noData option %q, expected %q or %q", mode, noDataEmpty, noDataZero)
+}
+
+// appendZeroPoint appends a row to an empty frame with its time fields set to
+// at, the end of the time range, and every other field to its zero value.
+func appendZeroPoint(frame *data.Frame, at time.Time) {
+	for _, field := range frame.Fields {
+		field.Extend(1)
+		i := field.Len() - 1
+		switch field.Type().NonNullableType() {
+		case data.FieldTypeTime:
+			field.SetConcrete(i, at)
+		default:
+			field.SetConcrete(i, data.NewFieldFromFieldType(field.Type().NonNullableType(), 1).At(0))
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryDataNoDataZero(t *testing.T) {
+	columns := []Column{{Name: "ts", Type: "TIMESTAMP"}, {Name: "host", Type: "VARCHAR"}, {Name: "errors", Type: "BIGINT"}}
+	ds := Datasource{transport: &fakeTransport{result: &QueryResult{Columns: columns, encodedRows: json.RawMessage("[]")}}}
+	to := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	query := func(noData string) backend.DataResponse {
+		body, _ := json.Marshal(map[string]interface{}{"queryText": "SELECT ts, host, errors FROM logs.events", "noData": noData})
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: body, TimeRange: backend.TimeRange{From: to.Add(-time.Hour), To: to}}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Responses["A"]
+	}
+
+	r := query("")
+	if r.Error != nil {
+		t.Fatal(r.Error)
+	}
+	if rows := r.Frames[0].Rows(); rows != 0 {
+		t.Errorf("got %d rows by default, want 0", rows)
+	}
+
+	r = query("zero")
+	if r.Error != nil {
+		t.Fatal(r.Error)
+	}
+	frame := r.Frames[0]
+	if rows := frame.Rows(); rows != 1 {
+		t.Fatalf("got %d rows, want 1", rows)
+	}
+	if ts, ok := frame.Fields[0].ConcreteAt(0); !ok || !ts.(time.Time).Equal(to) {
+		t.Errorf("got time %v, want %v", ts, to)
+	}
+	if v, ok := frame.Fields[2].ConcreteAt(0); !ok || v != int64(0) {
+		t.Errorf("got errors %v, want 0", v)
+	}
+
+	if r = query("previous"); r.Status != backend.StatusBadRequest {
+		t.Errorf("got status %v for an unknown option, want bad request", r.Status)
+	}
+}
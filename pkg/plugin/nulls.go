@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Null policies, selecting what is returned for null values and values that
+// can't be converted to the type of their column.
+const (
+	// nullPolicyZero returns the zero value of the column type: 0, "" or false.
+	nullPolicyZero = "zero"
+	// nullPolicyNull returns nullable fields holding nulls, which panels leave
+	// out of aggregates instead of counting them as zeroes.
+	nullPolicyNull = "null"
+	// nullPolicySkip leaves out every row with a null or unconvertible value.
+	nullPolicySkip = "skip"
+)
+
+// Treatments of NaN and infinite numbers.
+const (
+	// nonFiniteKeep returns NaN and ±Infinity values as they are.
+	nonFiniteKeep = "keep"
+	// nonFiniteNull treats them as nulls, returned according to the null policy.
+	nonFiniteNull = "null"
+)
+
+// nonFiniteAsNull reports whether the nonFiniteNumbers setting treats NaN and
+// infinite numbers as nulls. They are kept by default.
+func nonFiniteAsNull(setting string) (bool, error) {
+	switch setting {
+	case "", nonFiniteKeep:
+		return false, nil
+	case nonFiniteNull:
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown nonFiniteNumbers setting %q, expected %s or %s", setting, nonFiniteKeep, nonFiniteNull)
+	}
+}
+
+// resolveNullPolicy returns the null policy of a query, which defaults to the
+// datasource policy and then to zero values.
+func resolveNullPolicy(query, datasource string) (string, error) {
+	policy := query
+	if policy == "" {
+		policy = datasource
+	}
+	switch policy {
+	case "":
+		return nullPolicyZero, nil
+	case nullPolicyZero, nullPolicyNull, nullPolicySkip:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown null policy %q, expected %s, %s or %s", policy, nullPolicyZero, nullPolicyNull, nullPolicySkip)
+	}
+}
+
+// pointerTo returns a pointer to a converted value, as nullable fields hold.
+func pointerTo(v interface{}) interface{} {
+	switch val := v.(type) {
+	case float64:
+		return &val
+	case int64:
+		return &val
+	case bool:
+		return &val
+	case time.Time:
+		return &val
+	case json.RawMessage:
+		return &val
+	case string:
+		return &val
+	default:
+		return v
+	}
+}
+
+// skippedRowsNotice tells the user how many rows nullPolicySkip left out.
+func skippedRowsNotice(skipped int) data.Notice {
+	return data.Notice{
+		Severity: data.NoticeSeverityInfo,
+		Text:     fmt.Sprintf("%d rows with null or invalid values were left out", skipped),
+	}
+}
@@ -0,0 +1,107 @@
+package plugin
+
+import (
+	"math"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestConvertNullPolicy(t *testing.T) {
+	result := func() *QueryResult {
+		return &QueryResult{
+			Columns: []Column{{Name: "host", Type: "VARCHAR"}, {Name: "value", Type: "DOUBLE"}},
+			Rows: [][]interface{}{
+				{"a", float64(1)},
+				{"b", nil},
+				{"c", "n/a"},
+				{nil, float64(4)},
+			},
+		}
+	}
+
+	zero, err := convertToDataFrames(result(), conversionOptions{NullPolicy: nullPolicyZero})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zero.Fields[1].Type() != data.FieldTypeFloat64 || zero.Fields[1].At(1) != 0.0 {
+		t.Errorf("expected zero values, got %s %v", zero.Fields[1].Type(), zero.Fields[1].At(1))
+	}
+
+	nulls, err := convertToDataFrames(result(), conversionOptions{NullPolicy: nullPolicyNull})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nulls.Fields[1].Type() != data.FieldTypeNullableFloat64 {
+		t.Fatalf("expected a nullable field, got %s", nulls.Fields[1].Type())
+	}
+	for row, want := range []bool{true, false, false, true} {
+		if _, ok := nulls.Fields[1].ConcreteAt(row); ok != want {
+			t.Errorf("row %d: got a value %v, want %v", row, ok, want)
+		}
+	}
+	if _, ok := nulls.Fields[0].ConcreteAt(3); ok {
+		t.Error("expected a null string")
+	}
+
+	skipped, err := convertToDataFrames(result(), conversionOptions{NullPolicy: nullPolicySkip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped.Rows() != 1 || skipped.Fields[0].At(0) != "a" {
+		t.Errorf("expected only the complete row, got %d rows", skipped.Rows())
+	}
+	if skipped.Meta == nil || len(skipped.Meta.Notices) != 1 {
+		t.Errorf("expected a notice about the skipped rows, got %+v", skipped.Meta)
+	}
+}
+
+func TestResolveNullPolicy(t *testing.T) {
+	if policy, err := resolveNullPolicy("", ""); err != nil || policy != nullPolicyZero {
+		t.Errorf("expected zero values by default, got %q, %v", policy, err)
+	}
+	if policy, err := resolveNullPolicy(nullPolicySkip, nullPolicyNull); err != nil || policy != nullPolicySkip {
+		t.Errorf("expected the query policy to win, got %q, %v", policy, err)
+	}
+	if _, err := resolveNullPolicy("", "empty"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+func TestConvertNonFinite(t *testing.T) {
+	result := func() *QueryResult {
+		return &QueryResult{
+			Columns: []Column{{Name: "value", Type: "DOUBLE"}},
+			Rows:    [][]interface{}{{"NaN"}, {"Infinity"}, {"-Infinity"}, {math.NaN()}, {float64(1)}},
+		}
+	}
+
+	kept, err := convertToDataFrames(result(), conversionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := kept.Fields[0]
+	if v := values.At(0).(float64); !math.IsNaN(v) {
+		t.Errorf("expected NaN, got %v", v)
+	}
+	if v := values.At(1).(float64); !math.IsInf(v, 1) {
+		t.Errorf("expected +Inf, got %v", v)
+	}
+	if v := values.At(2).(float64); !math.IsInf(v, -1) {
+		t.Errorf("expected -Inf, got %v", v)
+	}
+
+	nulls, err := convertToDataFrames(result(), conversionOptions{NonFiniteAsNull: true, NullPolicy: nullPolicyNull})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for row, want := range []bool{false, false, false, false, true} {
+		if _, ok := nulls.Fields[0].ConcreteAt(row); ok != want {
+			t.Errorf("row %d: got a value %v, want %v", row, ok, want)
+		}
+	}
+
+	if _, err := nonFiniteAsNull("drop"); err == nil {
+		t.Error("expected an error for an unknown setting")
+	}
+}
@@ -0,0 +1,81 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// forwardedIdentityKey is the context key of the identity of the signed-in
+// Grafana user, see withForwardedIdentity.
+type forwardedIdentityKey struct{}
+
+// forwardedIdentity is the OAuth access token of the signed-in Grafana user,
+// and their login, which scopes cached results to them. A signed-in user
+// without a token has only the login.
+type forwardedIdentity struct {
+	token string
+	login string
+}
+
+// withForwardedIdentity returns ctx carrying the OAuth access token Grafana
+// passed in the Authorization header of a request, when the datasource
+// forwards the identity of its users. Statements of that request are then
+// sent with the token instead of the datasource credentials, so that the
+// authorization policies of Ocient apply to the user. A signed-in user
+// without a token, as when their OAuth session has lapsed, is refused rather
+// than given the datasource credentials, see forwardedIdentityError. Only
+// requests without a user, as from public dashboards and background work,
+// keep the datasource credentials.
+func (d *Datasource) withForwardedIdentity(ctx context.Context, authorization string) context.Context {
+	if !d.settings.OAuthPassThru {
+		return ctx
+	}
+	var identity forwardedIdentity
+	if user := backend.PluginConfigFromContext(ctx).User; user != nil {
+		identity.login = user.Login
+	}
+	if token, ok := strings.CutPrefix(authorization, "Bearer "); ok {
+		identity.token = token
+	}
+	if identity == (forwardedIdentity{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, forwardedIdentityKey{}, identity)
+}
+
+// forwardedIdentityError returns an error when ctx carries a signed-in user
+// whose identity should be forwarded but came without an OAuth token.
+func forwardedIdentityError(ctx context.Context) error {
+	identity, ok := ctx.Value(forwardedIdentityKey{}).(forwardedIdentity)
+	if !ok || identity.token != "" {
+		return nil
+	}
+	return fmt.Errorf("Grafana sent no OAuth token for user %s, whose identity this data source forwards to Ocient; sign in again through the OAuth provider", identity.login)
+}
+
+// forwardedToken returns the OAuth access token forwarded with ctx, if any.
+func forwardedToken(ctx context.Context) string {
+	identity, _ := ctx.Value(forwardedIdentityKey{}).(forwardedIdentity)
+	return identity.token
+}
+
+// cacheScope prefixes the keys of what is cached for a request, so that the
+// results of a user whose identity is forwarded, or of a credential mapping,
+// are never served to another. Users without a login are scoped by their
+// token.
+func cacheScope(ctx context.Context) string {
+	identity, ok := ctx.Value(forwardedIdentityKey{}).(forwardedIdentity)
+	switch {
+	case ok && identity.login != "":
+		return "user:" + identity.login + "\x00"
+	case ok:
+		return "token:" + identity.token + "\x00"
+	}
+	if mapping, ok := mappedCredentials(ctx); ok {
+		return "credential:" + mapping.Name + "\x00"
+	}
+	return ""
+}
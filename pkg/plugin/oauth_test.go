@@ -0,0 +1,85 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/ocient/ocient-datasource/pkg/internal/fakeocient"
+)
+
+func TestQueryDataOAuthPassThru(t *testing.T) {
+	transport, server := newFakeRESTTransport(t,
+		fakeocient.WithBearerTokens(map[string]string{"alice-token": "alice", "bob-token": "bob"}),
+		fakeocient.WithDatasets(fakeocient.Dataset{Match: "from t", Rows: []map[string]interface{}{{"a": float64(1)}}}))
+	transport.settings.OAuthPassThru = true
+	ds := Datasource{settings: transport.settings, transport: transport}
+
+	query := func(login, token string) backend.DataResponse {
+		ctx := backend.WithPluginContext(context.Background(), backend.PluginContext{User: &backend.User{Login: login}})
+		req := &backend.QueryDataRequest{Queries: []backend.DataQuery{{RefID: "A", JSON: json.RawMessage(`{"queryText": "SELECT a FROM t"}`)}}}
+		if token != "" {
+			req.SetHTTPHeader(backend.OAuthIdentityTokenHeaderName, "Bearer "+token)
+		}
+		resp, err := ds.QueryData(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := resp.Responses["A"]; r.Error != nil {
+			t.Fatal(r.Error)
+		}
+		return resp.Responses["A"]
+	}
+
+	query("alice", "alice-token")
+	query("bob", "bob-token")
+	// Without a user, as from public dashboards, the datasource credentials are used
+	query("", "")
+
+	var users []string
+	for _, r := range server.Requests() {
+		users = append(users, r.Username)
+	}
+	want := []string{"alice", "bob", "user"}
+	if len(users) != len(want) {
+		t.Fatalf("got statements run as %v, want %v: cached results must not be shared between users", users, want)
+	}
+	for i := range want {
+		if users[i] != want[i] {
+			t.Errorf("got statements run as %v, want %v", users, want)
+			break
+		}
+	}
+}
+
+func TestQueryDataOAuthPassThruWithoutToken(t *testing.T) {
+	transport, server := newFakeRESTTransport(t,
+		fakeocient.WithBearerTokens(map[string]string{"alice-token": "alice"}),
+		fakeocient.WithDatasets(fakeocient.Dataset{Match: "from t", Rows: []map[string]interface{}{{"a": float64(1)}}}))
+	transport.settings.OAuthPassThru = true
+	ds := Datasource{settings: transport.settings, transport: transport}
+
+	ctx := backend.WithPluginContext(context.Background(), backend.PluginContext{User: &backend.User{Login: "alice"}})
+	resp, err := ds.QueryData(ctx, &backend.QueryDataRequest{Queries: []backend.DataQuery{{RefID: "A", JSON: json.RawMessage(`{"queryText": "SELECT a FROM t"}`)}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := resp.Responses["A"]; r.Error == nil || r.Status != backend.StatusUnauthorized {
+		t.Errorf("got status %v and error %v, want the signed-in user without a token refused", r.Status, r.Error)
+	}
+	if requests := server.Requests(); len(requests) != 0 {
+		t.Errorf("got %d statements run with the datasource credentials for a signed-in user without a token", len(requests))
+	}
+}
+
+func TestForwardedIdentityDisabled(t *testing.T) {
+	ds := Datasource{}
+	ctx := ds.withForwardedIdentity(context.Background(), "Bearer alice-token")
+	if token := forwardedToken(ctx); token != "" {
+		t.Errorf("got token %q forwarded without oauthPassThru", token)
+	}
+	if scope := cacheScope(ctx); scope != "" {
+		t.Errorf("got cache scope %q without a forwarded identity", scope)
+	}
+}
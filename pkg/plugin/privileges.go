@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// objectAccess is the outcome of checking that the datasource user can read
+// an object of the healthCheckObjects setting.
+type objectAccess struct {
+	Object string `json:"object"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
+// accessStatement returns the statement checking access to an object. Tables,
+// named with their schema such as sales.orders, are read without fetching
+// rows; schemas must have a table the user can see in the catalog.
+func accessStatement(object string) (statement string, schema bool) {
+	if len(splitQualifiedName(object)) > 1 {
+		return "SELECT * FROM " + quoteQualifiedIdentifier(object) + " LIMIT 0", false
+	}
+	name := splitQualifiedName(object)[0]
+	return "SELECT table_name FROM information_schema.tables WHERE LOWER(table_schema) = " +
+		stringLiteral(strings.ToLower(name)) + " LIMIT 1", true
+}
+
+// checkObjectAccess checks access to every object of the healthCheckObjects
+// setting, so that a test of the datasource catches missing privileges
+// before dashboards run into them.
+func (d *Datasource) checkObjectAccess(ctx context.Context) []objectAccess {
+	results := make([]objectAccess, 0, len(d.settings.HealthCheckObjects))
+	for _, object := range d.settings.HealthCheckObjects {
+		access := objectAccess{Object: object}
+		statement, schema := accessStatement(object)
+		result, err := d.transport.Execute(ctx, statement)
+		if err == nil && schema {
+			if err = result.decodeRows(); err == nil && len(result.Rows) == 0 {
+				err = errors.New("no tables of the schema are visible")
+			}
+		}
+		var statusErr *StatusError
+		switch {
+		case errors.As(err, &statusErr):
+			access.Error = fmt.Sprintf("%s (SQL state: %s)", statusErr.Status.Reason, statusErr.Status.SQLState)
+		case err != nil:
+			access.Error = err.Error()
+		default:
+			access.OK = true
+		}
+		results = append(results, access)
+	}
+	return results
+}
+
+// objectAccessDetails returns the JSON details of a health check listing the
+// access to every object, with a verbose message Grafana shows on the
+// datasource page, and the objects that can't be read.
+func objectAccessDetails(results []objectAccess) ([]byte, []string) {
+	var failed []string
+	lines := make([]string, len(results))
+	for i, r := range results {
+		if r.OK {
+			lines[i] = r.Object + ": ok"
+			continue
+		}
+		failed = append(failed, r.Object)
+		lines[i] = r.Object + ": " + r.Error
+	}
+	details, _ := json.Marshal(map[string]interface{}{
+		"objects":        results,
+		"verboseMessage": strings.Join(lines, "\n"),
+	})
+	return details, failed
+}
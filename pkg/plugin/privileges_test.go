@@ -0,0 +1,56 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+func TestCheckObjectAccess(t *testing.T) {
+	transport := &statementTransport{results: map[string]*QueryResult{
+		"SELECT * FROM " + quoteQualifiedIdentifier("sales.orders"): {},
+		"SELECT table_name FROM information_schema.tables WHERE LOWER(table_schema) = 'sales'": {
+			Columns: []Column{{Name: "table_name", Type: "VARCHAR"}},
+			Rows:    [][]interface{}{{"orders"}},
+		},
+		"SELECT table_name FROM information_schema.tables WHERE LOWER(table_schema) = 'hr'": {
+			Columns: []Column{{Name: "table_name", Type: "VARCHAR"}},
+		},
+	}}
+	ds := Datasource{transport: transport, settings: models.PluginSettings{
+		HealthCheckObjects: []string{"sales.orders", "Sales", "hr", "finance.ledger"},
+	}}
+
+	results := ds.checkObjectAccess(context.Background())
+	want := []objectAccess{
+		{Object: "sales.orders", OK: true},
+		{Object: "Sales", OK: true},
+		{Object: "hr", Error: "no tables of the schema are visible"},
+		{Object: "finance.ledger", Error: "table not found"},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+
+	details, failed := objectAccessDetails(results)
+	if len(failed) != 2 || failed[0] != "hr" || failed[1] != "finance.ledger" {
+		t.Errorf("failed = %v", failed)
+	}
+	var decoded struct {
+		Objects        []objectAccess `json:"objects"`
+		VerboseMessage string         `json:"verboseMessage"`
+	}
+	if err := json.Unmarshal(details, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Objects) != 4 || decoded.VerboseMessage == "" {
+		t.Errorf("details = %s", details)
+	}
+}
@@ -0,0 +1,212 @@
+package plugin
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// probeTimeout bounds the probe of a single endpoint.
+const probeTimeout = 10 * time.Second
+
+// probeStage is the outcome of one stage of an endpoint probe.
+type probeStage struct {
+	Name       string  `json:"name"`
+	OK         bool    `json:"ok"`
+	Skipped    bool    `json:"skipped,omitempty"`
+	DurationMs float64 `json:"durationMs"`
+	Detail     string  `json:"detail,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// probeResult holds the stages of probing one endpoint. Stages after a failed
+// one are skipped.
+type probeResult struct {
+	Endpoint string       `json:"endpoint"`
+	OK       bool         `json:"ok"`
+	Stages   []probeStage `json:"stages"`
+}
+
+// endpoints returns the host:port addresses that queries are sent to.
+func (d *Datasource) endpoints() []string {
+	if set := d.discovery.endpointSet(); set != nil {
+		return set.addrs
+	}
+	return apiEndpoints(d.settings)
+}
+
+// probeEndpoint checks DNS resolution, the TCP connection, the TLS handshake
+// and authentication of one endpoint in turn, timing every stage, so that
+// network, TLS and Ocient problems can be told apart. Through a proxy,
+// which resolves and connects to the endpoint itself, only authentication
+// is checked.
+func (d *Datasource) probeEndpoint(ctx context.Context, endpoint string) probeResult {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	result := probeResult{Endpoint: endpoint}
+	proxied := ""
+	if d.secureSocksProxy {
+		proxied = "the secure socks proxy"
+	} else if proxy := d.endpointProxy(endpoint); proxy != nil {
+		proxied = "the proxy " + proxy.Host
+	}
+	failed := false
+	stage := func(name string, fn func() (string, error)) {
+		if failed {
+			result.Stages = append(result.Stages, probeStage{Name: name, Skipped: true})
+			return
+		}
+		if proxied != "" && name != "auth" {
+			result.Stages = append(result.Stages, probeStage{Name: name, OK: true, Detail: "not checked, connections go through " + proxied})
+			return
+		}
+		start := time.Now()
+		detail, err := fn()
+		s := probeStage{Name: name, OK: err == nil, Detail: detail, DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+		if err != nil {
+			s.Error = err.Error()
+			failed = true
+		}
+		result.Stages = append(result.Stages, s)
+	}
+
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		result.Stages = append(result.Stages, probeStage{Name: "dns", Error: err.Error()})
+		return result
+	}
+
+	var addrs []string
+	stage("dns", func() (string, error) {
+		addrs, err = net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("resolved to %v", addrs), nil
+	})
+
+	var conn net.Conn
+	stage("tcp", func() (string, error) {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(addrs[0], port))
+		if err != nil {
+			return "", err
+		}
+		return "connected to " + conn.RemoteAddr().String(), nil
+	})
+	if conn != nil {
+		defer conn.Close()
+	}
+
+	stage("tls", func() (string, error) {
+		if apiScheme(d.settings) == models.SchemeHTTP {
+			return "not used, the scheme is http", nil
+		}
+		config, err := newTLSConfig(d.settings)
+		if err != nil {
+			return "", err
+		}
+		if config.ServerName == "" {
+			config.ServerName = host
+		}
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return "", err
+		}
+		state := tlsConn.ConnectionState()
+		detail := tls.VersionName(state.Version)
+		if len(state.PeerCertificates) > 0 {
+			detail += fmt.Sprintf(", certificate expires %s", state.PeerCertificates[0].NotAfter.Format(time.DateOnly))
+		}
+		if d.settings.InsecureSkipVerify {
+			detail += ", not verified"
+		}
+		return detail, nil
+	})
+
+	stage("auth", func() (string, error) {
+		if d.settings.Transport == models.TransportNative {
+			return "not checked for the native transport", nil
+		}
+		return d.probeAuth(ctx, endpoint)
+	})
+
+	result.OK = !failed
+	return result
+}
+
+// probeAuth checks that Ocient accepts the credentials without running a
+// statement: the API rejects unauthenticated requests before looking at them.
+// With session authentication, a session is opened instead.
+func (d *Datasource) probeAuth(ctx context.Context, endpoint string) (string, error) {
+	client := d.httpClient
+	if !d.secureSocksProxy {
+		tlsConfig, err := newTLSConfig(d.settings)
+		if err != nil {
+			return "", err
+		}
+		client = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: proxyFunc(d.settings)}}
+		defer client.CloseIdleConnections()
+	}
+
+	if d.settings.AuthMethod == models.AuthSession && d.settings.Secrets != nil {
+		_, ttl, err := openSession(ctx, client, d.settings, endpoint, d.settings.Secrets.Username, d.settings.Secrets.Password)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("session opened, expires in %s", ttl), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiScheme(d.settings)+"://"+endpoint+"/v1/execute", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", userAgent(ctx))
+	if d.settings.Secrets != nil {
+		req.SetBasicAuth(d.settings.Secrets.Username, d.settings.Secrets.Password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return "", fmt.Errorf("credentials rejected: %s", resp.Status)
+	}
+	return "credentials accepted", nil
+}
+
+// endpointProxy returns the proxy that requests to endpoint go through, nil
+// when they connect directly.
+func (d *Datasource) endpointProxy(endpoint string) *url.URL {
+	proxy := proxyFunc(d.settings)
+	if proxy == nil {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodGet, apiScheme(d.settings)+"://"+endpoint+"/v1/execute", nil)
+	if err != nil {
+		return nil
+	}
+	proxyURL, err := proxy(req)
+	if err != nil {
+		return nil
+	}
+	return proxyURL
+}
+
+// handleProbe probes every endpoint of the datasource.
+func (d *Datasource) handleProbe(w http.ResponseWriter, r *http.Request) {
+	endpoints := d.endpoints()
+	results := make([]probeResult, len(endpoints))
+	for i, endpoint := range endpoints {
+		results[i] = d.probeEndpoint(r.Context(), endpoint)
+	}
+	writeJSON(w, http.StatusOK, results)
+}
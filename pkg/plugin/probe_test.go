@@ -0,0 +1,55 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestProbeResource(t *testing.T) {
+	transport, _ := newFakeRESTTransport(t)
+	ds := &Datasource{settings: transport.settings}
+
+	var results []probeResult
+	if err := json.Unmarshal(callResource(t, ds, "GET", "probe").Body, &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].OK {
+		t.Fatalf("expected a successful probe, got %+v", results)
+	}
+	for i, name := range []string{"dns", "tcp", "tls", "auth"} {
+		if results[0].Stages[i].Name != name || !results[0].Stages[i].OK {
+			t.Errorf("unexpected stage %+v", results[0].Stages[i])
+		}
+	}
+
+	ds.settings.Secrets.Password = "wrong"
+	result := ds.probeEndpoint(context.Background(), ds.endpoints()[0])
+	if result.OK || result.Stages[3].OK || result.Stages[3].Error == "" {
+		t.Errorf("expected the auth stage to fail, got %+v", result.Stages[3])
+	}
+
+	ds.settings.Port = 1
+	result = ds.probeEndpoint(context.Background(), ds.endpoints()[0])
+	if result.OK || result.Stages[1].OK || !result.Stages[2].Skipped {
+		t.Errorf("expected the tcp stage to fail and later stages to be skipped, got %+v", result.Stages)
+	}
+}
+
+func TestProbeSecureSocksProxy(t *testing.T) {
+	transport, _ := newFakeRESTTransport(t)
+	ds := &Datasource{settings: transport.settings, httpClient: transport.client, secureSocksProxy: true}
+
+	result := ds.probeEndpoint(context.Background(), ds.endpoints()[0])
+	if !result.OK {
+		t.Fatalf("expected a successful probe, got %+v", result.Stages)
+	}
+	for _, stage := range result.Stages[:3] {
+		if stage.DurationMs != 0 || stage.Detail == "" {
+			t.Errorf("expected stage %s not to be checked, got %+v", stage.Name, stage)
+		}
+	}
+	if result.Stages[3].Detail != "credentials accepted" {
+		t.Errorf("unexpected auth stage %+v", result.Stages[3])
+	}
+}
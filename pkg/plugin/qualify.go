@@ -0,0 +1,156 @@
+package plugin
+
+import "strings"
+
+// Kinds of SQL tokens.
+const (
+	tokenSpace  = iota // whitespace and comments
+	tokenWord          // bare identifiers and keywords
+	tokenQuoted        // double quoted identifiers
+	tokenString        // single quoted literals
+	tokenPunct         // any other single character
+)
+
+type sqlToken struct {
+	kind int
+	text string
+}
+
+// tokenizeSQL splits statement into tokens whose texts concatenate back into
+// statement.
+func tokenizeSQL(statement string) []sqlToken {
+	var tokens []sqlToken
+	for i := 0; i < len(statement); {
+		start := i
+		kind := tokenPunct
+		c := statement[i]
+		switch {
+		case c == '\'' || c == '"':
+			kind = tokenString
+			if c == '"' {
+				kind = tokenQuoted
+			}
+			if i = skipQuoted(statement, i); i < 0 {
+				i = len(statement)
+			}
+		case strings.HasPrefix(statement[i:], "--"):
+			kind = tokenSpace
+			if end := strings.IndexByte(statement[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(statement)
+			}
+		case strings.HasPrefix(statement[i:], "/*"):
+			kind = tokenSpace
+			if end := strings.Index(statement[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(statement)
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			kind = tokenSpace
+			for i < len(statement) && strings.IndexByte(" \t\n\r", statement[i]) >= 0 {
+				i++
+			}
+		case isWordByte(c):
+			kind = tokenWord
+			for i < len(statement) && isWordByte(statement[i]) {
+				i++
+			}
+		default:
+			i++
+		}
+		tokens = append(tokens, sqlToken{kind: kind, text: statement[start:i]})
+	}
+	return tokens
+}
+
+// fromFunctions are functions whose arguments use FROM without naming a table,
+// as in EXTRACT(YEAR FROM ts).
+var fromFunctions = map[string]bool{"EXTRACT": true, "SUBSTRING": true, "TRIM": true, "POSITION": true, "OVERLAY": true}
+
+// fromClauseEnd are the keywords that end the table list of a FROM clause.
+var fromClauseEnd = map[string]bool{
+	"WHERE": true, "GROUP": true, "HAVING": true, "ORDER": true, "LIMIT": true, "OFFSET": true, "FETCH": true,
+	"UNION": true, "EXCEPT": true, "INTERSECT": true, "WINDOW": true, "ON": true, "USING": true,
+}
+
+// qualifyTables prefixes the unqualified table names following FROM and JOIN
+// in statement with schema, so that a query written against one environment
+// runs unchanged in another. Qualified names, subqueries, table functions and
+// the names of common table expressions are left alone.
+func qualifyTables(statement, schema string) string {
+	tokens := tokenizeSQL(statement)
+	var sig []int
+	for i, tok := range tokens {
+		if tok.kind != tokenSpace {
+			sig = append(sig, i)
+		}
+	}
+	at := func(k int) sqlToken {
+		if k >= 0 && k < len(sig) {
+			return tokens[sig[k]]
+		}
+		return sqlToken{kind: tokenSpace}
+	}
+	name := func(tok sqlToken) string {
+		if tok.kind == tokenWord {
+			return strings.ToUpper(tok.text)
+		}
+		return tok.text
+	}
+
+	// Common table expressions are introduced as "name AS ("
+	ctes := make(map[string]bool)
+	for k := range sig {
+		if tok := at(k); (tok.kind == tokenWord || tok.kind == tokenQuoted) &&
+			strings.EqualFold(at(k+1).text, "AS") && at(k+2).text == "(" {
+			ctes[name(tok)] = true
+		}
+	}
+
+	prefix := quoteIdentifier(schema) + "."
+	// parens records, per open parenthesis, whether it holds function arguments
+	// in which FROM doesn't name a table
+	var parens []bool
+	expect, fromDepth := false, -1
+	for k, index := range sig {
+		tok := tokens[index]
+		word := strings.ToUpper(tok.text)
+		switch {
+		case tok.text == "(":
+			parens = append(parens, fromFunctions[strings.ToUpper(at(k-1).text)])
+			expect = false
+		case tok.text == ")":
+			if len(parens) > 0 {
+				parens = parens[:len(parens)-1]
+			}
+			if len(parens) < fromDepth {
+				fromDepth = -1
+			}
+		case tok.text == ",":
+			expect = len(parens) == fromDepth
+		case tok.kind == tokenWord && word == "FROM":
+			if len(parens) == 0 || !parens[len(parens)-1] {
+				expect, fromDepth = true, len(parens)
+			}
+		case tok.kind == tokenWord && word == "JOIN":
+			expect = true
+		case tok.kind == tokenWord && fromClauseEnd[word] && len(parens) == fromDepth:
+			expect, fromDepth = false, -1
+		case expect:
+			expect = false
+			next := at(k + 1).text
+			if (tok.kind == tokenWord || tok.kind == tokenQuoted) && next != "." && next != "(" &&
+				!ctes[name(tok)] && !(tok.kind == tokenWord && reservedWords[word]) {
+				tokens[index].text = prefix + tok.text
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, tok := range tokens {
+		b.WriteString(tok.text)
+	}
+	return b.String()
+}
@@ -0,0 +1,29 @@
+package plugin
+
+import "testing"
+
+func TestQualifyTables(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      string
+	}{
+		{"SELECT * FROM metrics WHERE host = 'FROM x'", "SELECT * FROM prod.metrics WHERE host = 'FROM x'"},
+		{"SELECT * FROM sys.tables", "SELECT * FROM sys.tables"},
+		{"SELECT * FROM a x, b y JOIN c ON x.id = c.id", "SELECT * FROM prod.a x, prod.b y JOIN prod.c ON x.id = c.id"},
+		{`SELECT * FROM "order" LEFT JOIN "events"."day" d USING (id)`, `SELECT * FROM prod."order" LEFT JOIN "events"."day" d USING (id)`},
+		{"WITH recent AS (SELECT * FROM logs) SELECT * FROM recent", "WITH recent AS (SELECT * FROM prod.logs) SELECT * FROM recent"},
+		{"SELECT EXTRACT(YEAR FROM ts), SUBSTRING(name FROM 2) FROM t", "SELECT EXTRACT(YEAR FROM ts), SUBSTRING(name FROM 2) FROM prod.t"},
+		{"SELECT * FROM (SELECT 1) s, generate_series(1, 3) g", "SELECT * FROM (SELECT 1) s, generate_series(1, 3) g"},
+		{"SELECT a, b FROM t /* FROM u */ -- FROM v", "SELECT a, b FROM prod.t /* FROM u */ -- FROM v"},
+		{"SELECT * FROM t GROUP BY a, b", "SELECT * FROM prod.t GROUP BY a, b"},
+	}
+	for _, tt := range tests {
+		if got := qualifyTables(tt.statement, "prod"); got != tt.want {
+			t.Errorf("qualifyTables(%q)\n got %q\nwant %q", tt.statement, got, tt.want)
+		}
+	}
+
+	if got := qualifyTables("SELECT * FROM t", "My Schema"); got != `SELECT * FROM "My Schema".t` {
+		t.Errorf("expected the schema to be quoted, got %q", got)
+	}
+}
@@ -0,0 +1,42 @@
+package plugin
+
+import (
+	"context"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// cacheSkipHeader is sent by Grafana when query caching must be bypassed, for
+// example when a user refreshes a panel with caching disabled.
+const cacheSkipHeader = "X-Cache-Skip"
+
+// cacheBypassKey is the context key marking requests that asked for fresh
+// results, see withCacheBypass.
+type cacheBypassKey struct{}
+
+// cacheBypassRequested reports whether the request asks for fresh results.
+func cacheBypassRequested(req *backend.QueryDataRequest) bool {
+	if strings.EqualFold(req.GetHTTPHeader(cacheSkipHeader), "true") {
+		return true
+	}
+	cacheControl := strings.ToLower(req.GetHTTPHeader("Cache-Control"))
+	return strings.Contains(cacheControl, "no-cache") || strings.Contains(cacheControl, "no-store")
+}
+
+// withCacheBypass returns ctx marking its request as bypassing Grafana's
+// query cache, so that the lookups and time extents its queries use are read
+// again rather than from the caches of the datasource. They are still cached
+// for later requests.
+func withCacheBypass(ctx context.Context, req *backend.QueryDataRequest) context.Context {
+	if !cacheBypassRequested(req) {
+		return ctx
+	}
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+// cacheBypassed reports whether the request of ctx asked for fresh results.
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(cacheBypassKey{}).(bool)
+	return bypass
+}
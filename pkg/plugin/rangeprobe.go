@@ -0,0 +1,173 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Extents of time columns change slowly compared to how often an empty panel
+// is refreshed, so they are cached.
+const (
+	extentCacheTTL     = 5 * time.Minute
+	extentCacheEntries = 100
+)
+
+// timeExtent is the earliest and latest value of a time column. Empty is set
+// for a table without rows.
+type timeExtent struct {
+	From, To time.Time
+	Empty    bool
+}
+
+// singleTable returns the table a SELECT statement reads, when it reads
+// exactly one table by name: no joins, subqueries or common table expressions.
+func singleTable(statement string) (string, bool) {
+	var sig []sqlToken
+	for _, tok := range tokenizeSQL(statement) {
+		if tok.kind != tokenSpace {
+			sig = append(sig, tok)
+		}
+	}
+	if len(sig) == 0 || !strings.EqualFold(sig[0].text, "SELECT") {
+		return "", false
+	}
+
+	depth, from := 0, -1
+	for k, tok := range sig {
+		switch {
+		case tok.text == "(":
+			depth++
+		case tok.text == ")":
+			depth--
+		case depth == 0 && tok.kind == tokenWord && strings.EqualFold(tok.text, "JOIN"):
+			return "", false
+		case depth == 0 && tok.kind == tokenWord && strings.EqualFold(tok.text, "FROM") && from < 0:
+			from = k + 1
+		}
+	}
+	if from < 0 {
+		return "", false
+	}
+
+	var name strings.Builder
+	k := from
+	for ; k < len(sig); k++ {
+		tok := sig[k]
+		if tok.kind != tokenWord && tok.kind != tokenQuoted {
+			return "", false
+		}
+		name.WriteString(tok.text)
+		if k+1 < len(sig) && sig[k+1].text == "." {
+			name.WriteString(".")
+			k++
+			continue
+		}
+		break
+	}
+	if name.Len() == 0 || k >= len(sig) {
+		return "", false
+	}
+	// An alias may follow the name, then the end of the FROM clause
+	rest := sig[k+1:]
+	if len(rest) > 0 && strings.EqualFold(rest[0].text, "AS") {
+		rest = rest[1:]
+	}
+	if len(rest) > 0 && (rest[0].kind == tokenQuoted || rest[0].kind == tokenWord && !fromClauseEnd[strings.ToUpper(rest[0].text)]) {
+		rest = rest[1:]
+	}
+	if len(rest) > 0 && rest[0].text != ";" && !(rest[0].kind == tokenWord && fromClauseEnd[strings.ToUpper(rest[0].text)]) {
+		return "", false
+	}
+	return name.String(), true
+}
+
+// emptyRangeNotice explains why a query returned no rows when the table it
+// reads has no data in the time range at all, by looking up the extent of
+// its time column. It returns false when the extent can't be looked up or lies
+// within the time range, where the other filters of the query are the cause.
+func (d *Datasource) emptyRangeNotice(ctx context.Context, transport QueryTransport, environment, statement, column string, timeRange backend.TimeRange, opts conversionOptions) (data.Notice, bool) {
+	table, ok := singleTable(statement)
+	if !ok || column == "" {
+		return data.Notice{}, false
+	}
+	extent, err := d.timeExtent(ctx, transport, environment, table, column, opts)
+	if err != nil {
+		backend.Logger.Warn("Time extent lookup failed", "table", table, "column", column, "error", err.Error())
+		return data.Notice{}, false
+	}
+
+	notice := data.Notice{Severity: data.NoticeSeverityInfo}
+	switch {
+	case extent.Empty:
+		notice.Text = fmt.Sprintf("The table %s has no rows", table)
+	case extent.To.Before(timeRange.From) || extent.From.After(timeRange.To):
+		loc := opts.Location
+		if loc == nil {
+			loc = time.UTC
+		}
+		notice.Text = fmt.Sprintf("The table %s has data from %s to %s, outside the selected time range", table,
+			extent.From.In(loc).Format(macroTimestampFormat), extent.To.In(loc).Format(macroTimestampFormat))
+	default:
+		return data.Notice{}, false
+	}
+	return notice, true
+}
+
+// timeExtent returns the earliest and latest value of column in table.
+func (d *Datasource) timeExtent(ctx context.Context, transport QueryTransport, environment, table, column string, opts conversionOptions) (timeExtent, error) {
+	col := quoteIdentifier(column)
+	statement := fmt.Sprintf("SELECT MIN(%s) AS min_time, MAX(%s) AS max_time FROM %s", col, col, table)
+	key := cacheScope(ctx) + environment + "\x00" + statement
+	if d.extentCache != nil && !cacheBypassed(ctx) {
+		if extent, _, ok := d.extentCache.get(key); ok {
+			return extent, nil
+		}
+	}
+
+	result, err := transport.Execute(ctx, statement)
+	if err != nil {
+		return timeExtent{}, err
+	}
+	frame, err := convertToDataFrames(result, conversionOptions{
+		Location: opts.Location, TimestampLayouts: opts.TimestampLayouts, NullPolicy: nullPolicyNull,
+	})
+	if err != nil {
+		return timeExtent{}, err
+	}
+	if frame.Rows() != 1 || len(frame.Fields) != 2 {
+		return timeExtent{}, fmt.Errorf("expected one row with two columns, got %d rows and %d columns", frame.Rows(), len(frame.Fields))
+	}
+
+	var extent timeExtent
+	from, fromOK := frame.Fields[0].ConcreteAt(0)
+	to, toOK := frame.Fields[1].ConcreteAt(0)
+	if !fromOK && !toOK {
+		extent.Empty = true
+	} else {
+		if extent.From, fromOK = from.(time.Time); !fromOK {
+			return timeExtent{}, fmt.Errorf("column %q is not a time column", column)
+		}
+		if extent.To, toOK = to.(time.Time); !toOK {
+			return timeExtent{}, fmt.Errorf("column %q is not a time column", column)
+		}
+	}
+	if d.extentCache != nil {
+		d.extentCache.set(key, extent)
+	}
+	return extent, nil
+}
+
+// timeFieldName returns the name of the first time field of frame.
+func timeFieldName(frame *data.Frame) string {
+	for _, field := range frame.Fields {
+		if field.Type().Time() {
+			return field.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestSingleTable(t *testing.T) {
+	tests := map[string]string{
+		"SELECT ts, value FROM metrics WHERE ts >= '2024-01-01'":       "metrics",
+		"SELECT ts FROM demo.\"order\" o ORDER BY ts":                  `demo."order"`,
+		"SELECT EXTRACT(YEAR FROM ts) FROM demo.metrics AS m LIMIT 10": "demo.metrics",
+		"SELECT ts FROM demo.metrics;":                                 "demo.metrics",
+		"SELECT a.ts FROM a JOIN b ON a.id = b.id":                     "",
+		"SELECT ts FROM a, b":                                          "",
+		"SELECT ts FROM (SELECT ts FROM a) s":                          "",
+		"WITH s AS (SELECT ts FROM a) SELECT ts FROM s":                "",
+		"SELECT 1": "",
+	}
+	for statement, want := range tests {
+		got, ok := singleTable(statement)
+		if got != want || ok != (want != "") {
+			t.Errorf("singleTable(%q) = %q, %v, want %q", statement, got, ok, want)
+		}
+	}
+}
+
+func TestQueryDataProbeEmptyRange(t *testing.T) {
+	transport := &statementTransport{results: map[string]*QueryResult{
+		"SELECT ts, value FROM metrics": {
+			Columns: []Column{{Name: "ts", Type: "TIMESTAMP"}, {Name: "value", Type: "DOUBLE"}},
+		},
+		"SELECT MIN(ts) AS min_time, MAX(ts) AS max_time FROM metrics": {
+			Columns: []Column{{Name: "min_time", Type: "TIMESTAMP"}, {Name: "max_time", Type: "TIMESTAMP"}},
+			Rows:    [][]interface{}{{"2023-05-01T00:00:00Z", "2023-06-30T12:00:00Z"}},
+		},
+	}}
+	ds := Datasource{transport: transport, extentCache: newTTLCache[timeExtent](extentCacheTTL, extentCacheEntries)}
+
+	query := func(probe bool) backend.DataResponse {
+		body, _ := json.Marshal(map[string]interface{}{
+			"queryText":       "SELECT ts, value FROM metrics WHERE $__timeFilter(ts)",
+			"probeEmptyRange": probe,
+		})
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{Queries: []backend.DataQuery{{
+			RefID: "A", JSON: body,
+			TimeRange: backend.TimeRange{From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Responses["A"]
+	}
+
+	for i := 0; i < 2; i++ {
+		res := query(true)
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		notices := res.Frames[0].Meta.Notices
+		want := "The table metrics has data from 2023-05-01 00:00:00 to 2023-06-30 12:00:00, outside the selected time range"
+		if len(notices) != 1 || notices[0].Text != want {
+			t.Errorf("expected notice %q, got %+v", want, notices)
+		}
+	}
+	probes := 0
+	for _, statement := range transport.statements {
+		if strings.HasPrefix(statement, "SELECT MIN") {
+			probes++
+		}
+	}
+	if probes != 1 {
+		t.Errorf("expected the extent to be looked up once and then cached, looked up %d times", probes)
+	}
+
+	if res := query(false); len(res.Frames[0].Meta.Notices) != 0 {
+		t.Errorf("expected no notice without probeEmptyRange, got %+v", res.Frames[0].Meta.Notices)
+	}
+}
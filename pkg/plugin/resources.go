@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+)
+
+// newResourceHandler routes the resource calls of the datasource, made by the
+// frontend through /api/datasources/uid/<uid>/resources.
+func newResourceHandler(d *Datasource) backend.CallResourceHandler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /dashboards", d.handleDashboards)
+	mux.HandleFunc("GET /dashboards/{id}", d.handleDashboard)
+	mux.HandleFunc("GET /config-check", d.handleConfigCheck)
+	mux.HandleFunc("GET /probe", d.handleProbe)
+	mux.HandleFunc("GET /activity", d.handleActivity)
+	mux.HandleFunc("GET /cluster/topology", d.handleTopology)
+	mux.HandleFunc("GET /tables", d.handleTables)
+	mux.HandleFunc("GET /columns", d.handleColumns)
+	mux.HandleFunc("GET /geometries", d.handleGeometries)
+	mux.HandleFunc("POST /estimate", d.handleEstimate)
+	mux.HandleFunc("POST /debug/capture", requireOrgAdmin(d.handleStartCapture))
+	mux.HandleFunc("GET /debug/capture", requireOrgAdmin(d.handleCaptureBundle))
+	mux.HandleFunc("DELETE /debug/capture", requireOrgAdmin(d.handleStopCapture))
+	return httpadapter.New(mux)
+}
+
+// CallResource handles resource calls sent from Grafana to the plugin.
+func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	ctx = d.withMappedCredentials(d.withForwardedIdentity(ctx, req.GetHTTPHeader(backend.OAuthIdentityTokenHeaderName)))
+	return d.resourceHandler.CallResource(ctx, req, sender)
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		backend.Logger.Error("Failed to write resource response", "error", err.Error())
+	}
+}
+
+// writeError writes an error response in the {"message": ...} shape Grafana uses.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
@@ -0,0 +1,421 @@
+package plugin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// TableResponse represents the "table" format response from the Ocient API. Data
+// holds one array of values per row in the order of Columns. Servers that don't
+// support the table format answer in the "collection" format instead, without
+// Columns and with one object per row.
+type TableResponse struct {
+	QueryID string          `json:"query_id"`
+	Status  OcientStatus    `json:"status"`
+	Columns []Column        `json:"columns"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// restTransport executes statements through the Ocient REST API.
+type restTransport struct {
+	settings models.PluginSettings
+	// client is shared by the transports of a datasource instance, which
+	// owns it, so that connections are reused across queries
+	client *http.Client
+	// endpoints are the SQL nodes of the settings statements can be sent to,
+	// unless discovery has found others; current is the index of the one
+	// that last accepted a connection
+	endpoints *endpointSet
+	discovery *endpointDiscovery
+	current   atomic.Int32
+	// next counts statements for round-robin load balancing
+	next atomic.Uint32
+	// sessions holds the session tokens of the session authentication method
+	sessions *sessionCache
+}
+
+// endpointSet is a list of SQL nodes with the statements in flight to every
+// one of them, for least-outstanding load balancing.
+type endpointSet struct {
+	addrs       []string
+	outstanding []atomic.Int32
+}
+
+func newEndpointSet(addrs []string) *endpointSet {
+	return &endpointSet{addrs: addrs, outstanding: make([]atomic.Int32, len(addrs))}
+}
+
+func newRESTTransport(settings models.PluginSettings, client *http.Client) *restTransport {
+	return &restTransport{settings: settings, client: client, endpoints: newEndpointSet(apiEndpoints(settings)), sessions: newSessionCache()}
+}
+
+// endpointSet returns the SQL nodes statements are sent to: the discovered
+// ones, or those of the settings until any are discovered.
+func (t *restTransport) endpointSet() *endpointSet {
+	if set := t.discovery.endpointSet(); set != nil {
+		return set
+	}
+	return t.endpoints
+}
+
+// pick returns the index of the endpoint of set a statement is sent to
+// first, by the load balancing policy of the settings.
+func (t *restTransport) pick(set *endpointSet) int {
+	n := len(set.addrs)
+	current := int(t.current.Load()) % n
+	switch t.settings.LoadBalancing {
+	case models.LoadBalancingRoundRobin:
+		return int((t.next.Add(1) - 1) % uint32(n))
+	case models.LoadBalancingLeastOutstanding:
+		// Ties go to the endpoint that last accepted a connection
+		best := current
+		for i := range n {
+			endpoint := (current + i) % n
+			if set.outstanding[endpoint].Load() < set.outstanding[best].Load() {
+				best = endpoint
+			}
+		}
+		return best
+	default:
+		return current
+	}
+}
+
+// apiEndpoints returns the host:port addresses of the SQL nodes of the
+// settings, the host first and then the failover hosts. Hosts without a port
+// take the port of the settings.
+func apiEndpoints(settings models.PluginSettings) []string {
+	port := strconv.Itoa(settings.Port)
+	endpoints := []string{net.JoinHostPort(settings.Host, port)}
+	for _, host := range settings.Hosts {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, port)
+		}
+		endpoints = append(endpoints, host)
+	}
+	return endpoints
+}
+
+// newHTTPClient creates the HTTP client of a datasource instance with the SDK
+// httpclient, so requests pass through its middlewares for tracing, metrics
+// and the proxy and headers configured in Grafana. opts usually come from the
+// instance settings. The client keeps connections to Ocient alive, so that
+// queries don't each pay for a TCP connection and a TLS handshake. The pool is
+// sized by the settings; zero values keep the SDK defaults.
+//
+// Queries are bounded by their context rather than a client timeout, and
+// credentials are set per request since environments and public dashboards
+// share the client, so opts' timeout and basic auth are not used.
+func newHTTPClient(settings models.PluginSettings, opts httpclient.Options) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	timeouts := httpclient.DefaultTimeoutOptions
+	timeouts.Timeout = 0
+	if settings.MaxIdleConns > 0 {
+		timeouts.MaxIdleConns = settings.MaxIdleConns
+	}
+	if settings.MaxIdleConnsPerHost > 0 {
+		timeouts.MaxIdleConnsPerHost = settings.MaxIdleConnsPerHost
+	}
+	timeouts.MaxConnsPerHost = settings.MaxConnsPerHost
+	if settings.IdleConnTimeout > 0 {
+		timeouts.IdleConnTimeout = time.Duration(settings.IdleConnTimeout) * time.Second
+	}
+	opts.Timeouts = &timeouts
+	opts.BasicAuth = nil
+	opts.TLS = nil
+
+	configure := opts.ConfigureTransport
+	opts.ConfigureTransport = func(opts httpclient.Options, transport *http.Transport) {
+		transport.TLSClientConfig = tlsConfig
+		transport.Proxy = proxyFunc(settings)
+		if configure != nil {
+			configure(opts, transport)
+		}
+	}
+	return httpclient.New(opts)
+}
+
+// proxyFunc returns the proxy of requests to Ocient: the one named by the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables, unless the
+// settings ignore them, in which case it is nil.
+func proxyFunc(settings models.PluginSettings) func(*http.Request) (*url.URL, error) {
+	if settings.IgnoreProxyEnv {
+		return nil
+	}
+	return http.ProxyFromEnvironment
+}
+
+// apiScheme returns the URL scheme of the Ocient API, HTTPS unless plain HTTP
+// is configured.
+func apiScheme(settings models.PluginSettings) string {
+	if settings.Scheme == models.SchemeHTTP {
+		return models.SchemeHTTP
+	}
+	return models.SchemeHTTPS
+}
+
+// Execute sends an SQL query to the Ocient API and returns the result
+func (t *restTransport) Execute(ctx context.Context, query string) (*QueryResult, error) {
+	// Create request body with format=table so the response carries column metadata
+	queryRequest := map[string]interface{}{
+		"database":  t.settings.Database,
+		"statement": query,
+		"format":    "table",
+	}
+
+	payload, err := json.Marshal(queryRequest)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling query: %w", err)
+	}
+
+	// Log the full API request details
+	backend.Logger.Info("API request details",
+		"database", t.settings.Database,
+		"statement", query,
+		"username", t.settings.Secrets.Username,
+		"payload", string(payload))
+
+	// Statements with long IN lists can make for large payloads
+	compress := t.settings.GzipRequestsOver > 0 && len(payload) > t.settings.GzipRequestsOver
+	if compress {
+		if payload, err = gzipBytes(payload); err != nil {
+			return nil, fmt.Errorf("error compressing query: %w", err)
+		}
+	}
+
+	// Send the statement to the SQL node picked by the load balancing policy,
+	// by default the one that last accepted a connection, and fail over to
+	// the next one when it can't be reached. Statements are never sent twice:
+	// a refused connection means none was received.
+	set := t.endpointSet()
+	start := t.pick(set)
+	var resp *http.Response
+	for i := range set.addrs {
+		endpoint := (start + i) % len(set.addrs)
+		set.outstanding[endpoint].Add(1)
+		resp, err = t.post(ctx, set.addrs[endpoint], payload, compress)
+		if err == nil {
+			defer set.outstanding[endpoint].Add(-1)
+			if endpoint != start {
+				backend.Logger.Warn("Failed over to another SQL node", "endpoint", set.addrs[endpoint])
+				t.current.Store(int32(endpoint))
+			}
+			break
+		}
+		set.outstanding[endpoint].Add(-1)
+		if !isDialError(err) || ctx.Err() != nil {
+			break
+		}
+		backend.Logger.Warn("SQL node unreachable", "endpoint", set.addrs[endpoint], "error", err.Error())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	// Gateways in front of Ocient answer with their own pages while SQL nodes restart
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return nil, &GatewayError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	// Log full response body for debugging
+	if len(body) > 2000 {
+		backend.Logger.Debug("Response body (truncated)", "body", string(body[:2000]), "status", resp.Status)
+	} else {
+		backend.Logger.Debug("Response body", "body", string(body), "status", resp.Status)
+	}
+
+	result, err := parseResponse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Log parsed response details
+	backend.Logger.Info("Parsed response", "query_id", result.QueryID,
+		"columns", len(result.Columns), "bytes", len(body))
+
+	return result, nil
+}
+
+// post sends a statement payload to the API of a SQL node, with the OAuth
+// token of the signed-in user when it is forwarded, or else the session
+// token of the user when the settings authenticate with sessions. Sessions
+// can end before they expire, as when the SQL node restarts, so a rejected
+// token is replaced and the statement sent once more: Ocient rejects
+// unauthenticated requests before running them.
+func (t *restTransport) post(ctx context.Context, endpoint string, payload []byte, compress bool) (*http.Response, error) {
+	if err := forwardedIdentityError(ctx); err != nil {
+		return nil, err
+	}
+	if token := forwardedToken(ctx); token != "" {
+		return t.send(ctx, endpoint, payload, compress, token)
+	}
+	if t.settings.AuthMethod != models.AuthSession {
+		return t.send(ctx, endpoint, payload, compress, "")
+	}
+	token, err := t.sessionToken(ctx, endpoint, "")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.send(ctx, endpoint, payload, compress, token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	resp.Body.Close()
+	backend.Logger.Info("Session token rejected, logging in again", "endpoint", endpoint)
+	if token, err = t.sessionToken(ctx, endpoint, token); err != nil {
+		return nil, err
+	}
+	return t.send(ctx, endpoint, payload, compress, token)
+}
+
+// send sends a statement payload to the API of a SQL node, authenticated
+// with a bearer token, or with basic auth without one.
+func (t *restTransport) send(ctx context.Context, endpoint string, payload []byte, compress bool, token string) (*http.Response, error) {
+	url := fmt.Sprintf("%s://%s/v1/execute", apiScheme(t.settings), endpoint)
+	backend.Logger.Info("API request URL", "url", url, "database", t.settings.Database)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	// Set headers. Accept-Encoding is left to the HTTP client, which then asks
+	// for gzip compressed responses and decompresses them transparently.
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent(ctx))
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		username, password, err := t.credentials(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(username, password)
+	}
+	return t.client.Do(req)
+}
+
+// userAgent returns the User-Agent of requests to Ocient, naming the versions
+// of the plugin and of Grafana from the request context, so that Ocient logs
+// tell plugin traffic apart. Versions missing from the context, as for
+// background requests, are reported as 0.0.0.
+func userAgent(ctx context.Context) string {
+	pluginVersion := backend.PluginConfigFromContext(ctx).PluginVersion
+	if pluginVersion == "" {
+		pluginVersion = "0.0.0"
+	}
+	return "ocient-grafana-datasource/" + pluginVersion + " grafana/" + backend.UserAgentFromContext(ctx).GrafanaVersion()
+}
+
+// isDialError reports whether err is a failure to connect, after which the
+// request was certainly not received.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// gzipBytes compresses b with gzip.
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// parseResponse decodes a raw Ocient API response body. Numbers are kept as
+// json.Number so that huge or high precision values survive until conversion.
+// It is fed adversarial input by FuzzParseAndConvert and must never panic.
+func parseResponse(body []byte) (*QueryResult, error) {
+	var response TableResponse
+	if err := decodeJSON(body, &response); err != nil {
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	// Check for error status
+	if response.Status.SQLState != "00000" {
+		return nil, &StatusError{Status: response.Status}
+	}
+
+	result, err := decodeTableData(response)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+	return result, nil
+}
+
+// decodeJSON unmarshals data into v, decoding numbers as json.Number.
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// decodeTableData decodes the data of a response in either the table or the
+// collection format.
+func decodeTableData(response TableResponse) (*QueryResult, error) {
+	if len(response.Data) == 0 || string(response.Data) == "null" {
+		return &QueryResult{QueryID: response.QueryID, Columns: response.Columns}, nil
+	}
+
+	// Some utility statements are answered with text or a single object
+	if !bytes.HasPrefix(bytes.TrimSpace(response.Data), []byte("[")) {
+		return decodeUntabulated(response.QueryID, response.Data)
+	}
+
+	if len(response.Columns) == 0 {
+		var collection []map[string]interface{}
+		if err := decodeJSON(response.Data, &collection); err != nil {
+			// or with an array of lines
+			return decodeUntabulated(response.QueryID, response.Data)
+		}
+		return resultFromCollection(response.QueryID, collection), nil
+	}
+
+	// Rows in the table format are decoded during conversion, in chunks
+	return &QueryResult{QueryID: response.QueryID, Columns: response.Columns, encodedRows: response.Data}, nil
+}
+
+// Close is a no-op for the REST transport; the datasource that owns the HTTP
+// client closes its connections.
+func (t *restTransport) Close() error {
+	return nil
+}
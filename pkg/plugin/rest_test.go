@@ -0,0 +1,484 @@
+package plugin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/useragent"
+	"github.com/ocient/ocient-datasource/pkg/internal/fakeocient"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// newFakeRESTTransport starts a fake Ocient server and returns a REST transport
+// configured to talk to it.
+func newFakeRESTTransport(t *testing.T, opts ...fakeocient.Option) (*restTransport, *fakeocient.Server) {
+	t.Helper()
+	server := fakeocient.NewServer(append([]fakeocient.Option{fakeocient.WithCredentials("user", "pass")}, opts...)...)
+	t.Cleanup(server.Close)
+
+	settings := models.PluginSettings{
+		Database: "db",
+		Secrets:  &models.SecretPluginSettings{Username: "user", Password: "pass"},
+	}
+	if err := useFakeServer(&settings, server); err != nil {
+		t.Fatal(err)
+	}
+	client, err := newHTTPClient(settings, httpclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newRESTTransport(settings, client), server
+}
+
+func TestRESTTransportExecute(t *testing.T) {
+	transport, server := newFakeRESTTransport(t, fakeocient.WithDatasets(fakeocient.Dataset{
+		Match: "from t",
+		Rows:  []map[string]interface{}{{"a": float64(1)}, {"a": float64(2)}},
+	}))
+
+	result, err := transport.Execute(context.Background(), "SELECT a FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := result.decodeRows(); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rows) != 2 || result.QueryID == "" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0].Database != "db" || requests[0].Username != "user" {
+		t.Fatalf("unexpected requests: %+v", requests)
+	}
+}
+
+func TestRESTTransportUserAgent(t *testing.T) {
+	transport, server := newFakeRESTTransport(t, fakeocient.WithDatasets(fakeocient.Dataset{
+		Rows: []map[string]interface{}{{"a": float64(1)}},
+	}))
+	ua, err := useragent.New("11.5.3", "linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := backend.WithPluginContext(context.Background(), backend.PluginContext{PluginVersion: "1.4.0"})
+	ctx = backend.WithUserAgent(ctx, ua)
+
+	if _, err := transport.Execute(ctx, "SELECT a FROM t"); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.Requests()[0].UserAgent; got != "ocient-grafana-datasource/1.4.0 grafana/11.5.3" {
+		t.Errorf("got User-Agent %q", got)
+	}
+}
+
+func TestRESTTransportReusesConnections(t *testing.T) {
+	transport, server := newFakeRESTTransport(t, fakeocient.WithDatasets(fakeocient.Dataset{
+		Rows: []map[string]interface{}{{"a": float64(1)}},
+	}))
+	for i := 0; i < 3; i++ {
+		if _, err := transport.Execute(context.Background(), "SELECT a FROM t"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if conns := server.Connections(); conns != 1 {
+		t.Errorf("expected the queries to share one connection, got %d connections", conns)
+	}
+}
+
+func TestRESTTransportCompression(t *testing.T) {
+	transport, server := newFakeRESTTransport(t, fakeocient.WithGzip(), fakeocient.WithDatasets(fakeocient.Dataset{
+		Rows: []map[string]interface{}{{"a": float64(1)}},
+	}))
+	for _, over := range []int{0, 1 << 20, 16} {
+		transport.settings.GzipRequestsOver = over
+		result, err := transport.Execute(context.Background(), "SELECT a FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := result.decodeRows(); err != nil || len(result.Rows) != 1 {
+			t.Fatalf("expected the compressed response to be decoded, got %v rows and error %v", result.Rows, err)
+		}
+	}
+
+	requests := server.Requests()
+	if requests[0].Compressed || requests[1].Compressed || !requests[2].Compressed {
+		t.Errorf("expected only payloads over the threshold to be compressed: %+v", requests)
+	}
+	if requests[2].Statement != "SELECT a FROM t" {
+		t.Errorf("unexpected statement %q", requests[2].Statement)
+	}
+}
+
+func TestNewHTTPClientPoolSettings(t *testing.T) {
+	var transport *http.Transport
+	client, err := newHTTPClient(models.PluginSettings{
+		MaxIdleConns:        50,
+		MaxIdleConnsPerHost: 8,
+		MaxConnsPerHost:     32,
+		IdleConnTimeout:     30,
+	}, httpclient.Options{
+		// A timeout configured in Grafana must not cut long queries short
+		Timeouts: &httpclient.TimeoutOptions{Timeout: 5 * time.Second},
+		ConfigureTransport: func(_ httpclient.Options, t *http.Transport) {
+			transport = t
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.MaxIdleConns != 50 || transport.MaxIdleConnsPerHost != 8 || transport.MaxConnsPerHost != 32 {
+		t.Errorf("unexpected pool sizes: %d idle, %d idle per host, %d per host",
+			transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost)
+	}
+	if transport.IdleConnTimeout != 30*time.Second {
+		t.Errorf("expected a 30s idle timeout, got %s", transport.IdleConnTimeout)
+	}
+	if client.Timeout != 0 || transport.ResponseHeaderTimeout != 0 {
+		t.Errorf("expected no client timeout, got %s and a response header timeout of %s", client.Timeout, transport.ResponseHeaderTimeout)
+	}
+}
+
+func TestNewHTTPClientProxyEnvironment(t *testing.T) {
+	for _, ignore := range []bool{false, true} {
+		var transport *http.Transport
+		if _, err := newHTTPClient(models.PluginSettings{IgnoreProxyEnv: ignore}, httpclient.Options{
+			ConfigureTransport: func(_ httpclient.Options, t *http.Transport) {
+				transport = t
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if (transport.Proxy == nil) != ignore {
+			t.Errorf("ignoreProxyEnvironment %v: got a proxy func %v", ignore, transport.Proxy != nil)
+		}
+	}
+}
+
+func TestNewHTTPClientMiddlewares(t *testing.T) {
+	server := fakeocient.NewServer(fakeocient.WithDatasets(fakeocient.Dataset{
+		Rows: []map[string]interface{}{{"a": float64(1)}},
+	}))
+	t.Cleanup(server.Close)
+	settings := models.PluginSettings{Database: "db", Secrets: &models.SecretPluginSettings{}}
+	if err := useFakeServer(&settings, server); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int
+	counter := httpclient.MiddlewareFunc(func(_ httpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			requests++
+			return next.RoundTrip(req)
+		})
+	})
+	client, err := newHTTPClient(settings, httpclient.Options{
+		Middlewares: append(httpclient.DefaultMiddlewares(), counter),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newRESTTransport(settings, client).Execute(context.Background(), "SELECT a FROM t"); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("expected the request to pass through the middleware once, got %d", requests)
+	}
+}
+
+func TestRESTTransportPlainHTTP(t *testing.T) {
+	transport, server := newFakeRESTTransport(t, fakeocient.WithPlainHTTP(), fakeocient.WithDatasets(fakeocient.Dataset{
+		Rows: []map[string]interface{}{{"a": float64(1)}},
+	}))
+	if transport.settings.Scheme != models.SchemeHTTP {
+		t.Fatalf("expected the fake server to be reached over http, got %q", transport.settings.Scheme)
+	}
+	if _, err := transport.Execute(context.Background(), "SELECT a FROM t"); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.Requests()) != 1 {
+		t.Errorf("expected 1 request, got %d", len(server.Requests()))
+	}
+}
+
+func TestRESTTransportFailover(t *testing.T) {
+	server := fakeocient.NewServer(fakeocient.WithDatasets(fakeocient.Dataset{
+		Rows: []map[string]interface{}{{"a": float64(1)}},
+	}))
+	t.Cleanup(server.Close)
+	settings := models.PluginSettings{Secrets: &models.SecretPluginSettings{}}
+	if err := useFakeServer(&settings, server); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first host refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := listener.Addr().String()
+	listener.Close()
+	settings.Hosts = []string{net.JoinHostPort(settings.Host, strconv.Itoa(settings.Port))}
+	settings.Host, settings.Port = "127.0.0.1", listener.Addr().(*net.TCPAddr).Port
+
+	client, err := newHTTPClient(settings, httpclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := newRESTTransport(settings, client)
+	if transport.endpoints.addrs[0] != down {
+		t.Fatalf("unexpected endpoints %q", transport.endpoints.addrs)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := transport.Execute(context.Background(), "SELECT a FROM t"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The healthy host is remembered
+	if transport.current.Load() != 1 || len(server.Requests()) != 2 {
+		t.Errorf("expected both statements on the second host, current %d, %d requests", transport.current.Load(), len(server.Requests()))
+	}
+}
+
+func TestRESTTransportLoadBalancing(t *testing.T) {
+	var servers []*fakeocient.Server
+	var hosts []string
+	for i := 0; i < 2; i++ {
+		server := fakeocient.NewServer(fakeocient.WithDatasets(fakeocient.Dataset{
+			Rows: []map[string]interface{}{{"a": float64(1)}},
+		}))
+		t.Cleanup(server.Close)
+		servers = append(servers, server)
+		hosts = append(hosts, server.Listener.Addr().String())
+	}
+	settings := models.PluginSettings{Secrets: &models.SecretPluginSettings{}, LoadBalancing: models.LoadBalancingRoundRobin}
+	if err := useFakeServer(&settings, servers[0]); err != nil {
+		t.Fatal(err)
+	}
+	settings.Hosts = hosts[1:]
+	client, err := newHTTPClient(settings, httpclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := newRESTTransport(settings, client)
+	for i := 0; i < 4; i++ {
+		if _, err := transport.Execute(context.Background(), "SELECT a FROM t"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, server := range servers {
+		if n := len(server.Requests()); n != 2 {
+			t.Errorf("server %d received %d statements, want 2", i, n)
+		}
+	}
+
+	// Least outstanding picks the node with the fewest statements in flight
+	transport.settings.LoadBalancing = models.LoadBalancingLeastOutstanding
+	transport.endpoints.outstanding[0].Store(3)
+	transport.endpoints.outstanding[1].Store(1)
+	if got := transport.pick(transport.endpoints); got != 1 {
+		t.Errorf("picked endpoint %d, want 1", got)
+	}
+	transport.endpoints.outstanding[1].Store(3)
+	if got := transport.pick(transport.endpoints); got != 0 {
+		t.Errorf("picked endpoint %d on a tie, want the current one", got)
+	}
+}
+
+func TestRESTTransportCustomCA(t *testing.T) {
+	server := fakeocient.NewServer(fakeocient.WithDatasets(fakeocient.Dataset{
+		Rows: []map[string]interface{}{{"a": float64(1)}},
+	}))
+	t.Cleanup(server.Close)
+
+	settings := models.PluginSettings{Database: "db", Secrets: &models.SecretPluginSettings{}}
+	if err := useFakeServer(&settings, server); err != nil {
+		t.Fatal(err)
+	}
+	settings.InsecureSkipVerify = false
+	execute := func(settings models.PluginSettings) error {
+		client, err := newHTTPClient(settings, httpclient.Options{})
+		if err != nil {
+			return err
+		}
+		_, err = newRESTTransport(settings, client).Execute(context.Background(), "SELECT a FROM t")
+		return err
+	}
+
+	if err := execute(settings); err == nil {
+		t.Fatal("expected the self-signed certificate to be rejected without its CA")
+	}
+	settings.Secrets.TLSCACert = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	if err := execute(settings); err != nil {
+		t.Fatalf("expected the certificate to be trusted through the CA bundle: %v", err)
+	}
+	settings.Secrets.TLSCACert = "not a certificate"
+	if _, err := newHTTPClient(settings, httpclient.Options{}); err == nil {
+		t.Fatal("expected an error for an invalid CA bundle")
+	}
+}
+
+func TestRESTTransportServerName(t *testing.T) {
+	server := fakeocient.NewServer(fakeocient.WithDatasets(fakeocient.Dataset{
+		Rows: []map[string]interface{}{{"a": float64(1)}},
+	}))
+	t.Cleanup(server.Close)
+
+	settings := models.PluginSettings{Database: "db", Secrets: &models.SecretPluginSettings{}}
+	if err := useFakeServer(&settings, server); err != nil {
+		t.Fatal(err)
+	}
+	settings.InsecureSkipVerify = false
+	settings.Secrets.TLSCACert = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	execute := func(serverName string) error {
+		settings.TLSServerName = serverName
+		client, err := newHTTPClient(settings, httpclient.Options{})
+		if err != nil {
+			return err
+		}
+		_, err = newRESTTransport(settings, client).Execute(context.Background(), "SELECT a FROM t")
+		return err
+	}
+
+	// The test certificate is issued for example.com and the loopback addresses
+	if err := execute("example.com"); err != nil {
+		t.Fatalf("expected the certificate to be verified against the server name: %v", err)
+	}
+	if err := execute("ocient.internal"); err == nil {
+		t.Fatal("expected a certificate not issued for the server name to be rejected")
+	}
+}
+
+func TestRESTTransportClientCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "grafana"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	transport, _ := newFakeRESTTransport(t, fakeocient.WithClientCAs(pool), fakeocient.WithDatasets(fakeocient.Dataset{
+		Rows: []map[string]interface{}{{"a": float64(1)}},
+	}))
+	if _, err := transport.Execute(context.Background(), "SELECT a FROM t"); err == nil {
+		t.Fatal("expected the server to refuse a connection without a client certificate")
+	}
+
+	settings := transport.settings
+	secrets := *settings.Secrets
+	secrets.TLSClientCert = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	secrets.TLSClientKey = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	settings.Secrets = &secrets
+	client, err := newHTTPClient(settings, httpclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newRESTTransport(settings, client).Execute(context.Background(), "SELECT a FROM t"); err != nil {
+		t.Fatalf("expected the client certificate to be accepted: %v", err)
+	}
+
+	secrets.TLSClientKey = ""
+	if _, err := newHTTPClient(settings, httpclient.Options{}); err == nil {
+		t.Fatal("expected an error for a client certificate without its key")
+	}
+}
+
+func TestRESTTransportStatusError(t *testing.T) {
+	transport, _ := newFakeRESTTransport(t, fakeocient.WithDatasets(fakeocient.Dataset{
+		Match:  "missing",
+		Status: &fakeocient.Status{Reason: "table not found", SQLState: "42S02"},
+	}))
+
+	_, err := transport.Execute(context.Background(), "SELECT * FROM missing")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Status.SQLState != "42S02" {
+		t.Fatalf("expected status error, got %v", err)
+	}
+}
+
+func TestRESTTransportHTTPError(t *testing.T) {
+	transport, _ := newFakeRESTTransport(t, fakeocient.WithDatasets(fakeocient.Dataset{
+		HTTPStatus: http.StatusServiceUnavailable,
+	}))
+
+	_, err := transport.Execute(context.Background(), "SELECT 1")
+	var gatewayErr *GatewayError
+	if !errors.As(err, &gatewayErr) || gatewayErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected a gateway error, got %v", err)
+	}
+}
+
+func TestDevFakeServerHealth(t *testing.T) {
+	instance, err := NewDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		JSONData:                []byte(`{"database": "demo", "devFakeServer": true}`),
+		DecryptedSecureJSONData: map[string]string{"username": "demo", "password": "demo"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := instance.(*Datasource)
+	defer ds.Dispose()
+
+	res, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != backend.HealthStatusOk {
+		t.Fatalf("unexpected health result: %+v", res)
+	}
+}
+
+func TestDecodeTableDataCollectionFallback(t *testing.T) {
+	result, err := decodeTableData(TableResponse{
+		QueryID: "q",
+		Data:    []byte(`[{"b": "x", "a": 1}, {"a": 2}]`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Columns) != 2 || result.Columns[0].Name != "a" || result.Columns[1].Name != "b" {
+		t.Fatalf("unexpected columns: %+v", result.Columns)
+	}
+	if result.Rows[1][0] != json.Number("2") || result.Rows[1][1] != nil {
+		t.Fatalf("unexpected rows: %+v", result.Rows)
+	}
+}
@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// retryTransport wraps another transport and runs read-only statements again
+// when they fail with a transient error, waiting exponentially longer between
+// attempts. Statements that change data are never retried, as a failure
+// doesn't tell whether they took effect.
+type retryTransport struct {
+	next     QueryTransport
+	settings models.RetrySettings
+	// sleep waits between attempts, replaced by tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newRetryTransport(next QueryTransport, settings models.RetrySettings) *retryTransport {
+	return &retryTransport{next: next, settings: settings, sleep: sleepContext}
+}
+
+func (t *retryTransport) Execute(ctx context.Context, statement string) (*QueryResult, error) {
+	result, err := t.next.Execute(ctx, statement)
+	if err == nil || !isReadOnlyStatement(statement) {
+		return result, err
+	}
+	for attempt := 1; attempt < t.settings.MaxAttempts && isTransientError(err, t.settings.VendorCodes) && ctx.Err() == nil; attempt++ {
+		delay := t.backoff(attempt)
+		backend.Logger.Warn("Retrying statement after a transient error", "error", err.Error(), "attempt", attempt+1, "delay", delay)
+		if t.sleep(ctx, delay) != nil {
+			break
+		}
+		result, err = t.next.Execute(ctx, statement)
+	}
+	return result, err
+}
+
+func (t *retryTransport) Close() error {
+	return t.next.Close()
+}
+
+// backoff returns the delay before the attempt after the given one.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	delay := time.Duration(t.settings.InitialBackoffMs) * time.Millisecond
+	limit := time.Duration(t.settings.MaxBackoffMs) * time.Millisecond
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// isTransientError reports whether err tells of Ocient being unreachable for
+// a moment rather than of a problem with the statement: a dropped or refused
+// connection, a gateway error, a connection exception SQL state, one of
+// vendorCodes or a failure injected by the chaos transport.
+func isTransientError(err error, vendorCodes []int) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return strings.HasPrefix(statusErr.Status.SQLState, "08") ||
+			slices.Contains(vendorCodes, statusErr.Status.VendorCode)
+	}
+	var gatewayErr *GatewayError
+	return errors.As(err, &gatewayErr) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, errChaosFailure)
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// failingTransport fails the first statements with its errors in turn.
+type failingTransport struct {
+	errs       []error
+	statements []string
+}
+
+func (f *failingTransport) Execute(_ context.Context, statement string) (*QueryResult, error) {
+	f.statements = append(f.statements, statement)
+	if len(f.statements) <= len(f.errs) {
+		return nil, f.errs[len(f.statements)-1]
+	}
+	return &QueryResult{Columns: []Column{{Name: "value", Type: "INT"}}}, nil
+}
+
+func (f *failingTransport) Close() error {
+	return nil
+}
+
+func TestRetryTransport(t *testing.T) {
+	settings := models.RetrySettings{MaxAttempts: 3, InitialBackoffMs: 100, MaxBackoffMs: 150, VendorCodes: []int{-4001}}
+	reset := fmt.Errorf("error executing query: %w", syscall.ECONNRESET)
+	tests := []struct {
+		name      string
+		statement string
+		errs      []error
+		attempts  int
+		fails     bool
+	}{
+		{"connection reset", "SELECT 1", []error{reset, reset}, 3, false},
+		{"gateway", "SELECT 1", []error{&GatewayError{StatusCode: 503, Status: "503 Service Unavailable"}}, 2, false},
+		{"vendor code", "SELECT 1", []error{&StatusError{Status: OcientStatus{SQLState: "58000", VendorCode: -4001}}}, 2, false},
+		{"connection exception", "SELECT 1", []error{&StatusError{Status: OcientStatus{SQLState: "08006"}}}, 2, false},
+		{"attempts exhausted", "SELECT 1", []error{reset, reset, reset}, 3, true},
+		{"permanent", "SELECT 1", []error{&StatusError{Status: OcientStatus{SQLState: "42S02"}}}, 1, true},
+		{"write", "INSERT INTO t VALUES (1)", []error{reset}, 1, true},
+	}
+	for _, tt := range tests {
+		next := &failingTransport{errs: tt.errs}
+		transport := newRetryTransport(next, settings)
+		var delays []time.Duration
+		transport.sleep = func(_ context.Context, d time.Duration) error {
+			delays = append(delays, d)
+			return nil
+		}
+
+		_, err := transport.Execute(context.Background(), tt.statement)
+		if (err != nil) != tt.fails {
+			t.Errorf("%s: got error %v", tt.name, err)
+		}
+		if len(next.statements) != tt.attempts {
+			t.Errorf("%s: made %d attempts, want %d", tt.name, len(next.statements), tt.attempts)
+		}
+		if tt.attempts == 3 && (delays[0] != 100*time.Millisecond || delays[1] != 150*time.Millisecond) {
+			t.Errorf("%s: unexpected delays %v", tt.name, delays)
+		}
+	}
+}
+
+func TestRetryTransportCancelled(t *testing.T) {
+	next := &failingTransport{errs: []error{syscall.ECONNRESET, syscall.ECONNRESET}}
+	transport := newRetryTransport(next, models.RetrySettings{MaxAttempts: 3, InitialBackoffMs: 1000, MaxBackoffMs: 1000})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := transport.Execute(ctx, "SELECT 1"); !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("expected the last error, got %v", err)
+	}
+	if len(next.statements) != 1 {
+		t.Errorf("made %d attempts after the context was done", len(next.statements))
+	}
+}
@@ -0,0 +1,70 @@
+package plugin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// insecureTLSMessage explains the risk of a datasource that skips TLS verification.
+const insecureTLSMessage = "TLS certificate verification is disabled for this data source, so connections to Ocient can be intercepted"
+
+// insecureTLS reports whether queries reach Ocient without verifying its
+// certificate. The fake development server is exempt since it never is Ocient.
+func (d *Datasource) insecureTLS() bool {
+	return d.settings.InsecureSkipVerify && d.fakeServer == nil
+}
+
+// blockInsecureTLS reports whether queries must be refused because TLS
+// verification is disabled.
+func (d *Datasource) blockInsecureTLS() bool {
+	return d.insecureTLS() && d.settings.InsecurePolicy == models.InsecurePolicyBlock
+}
+
+// undecryptedSecrets returns the error of a datasource whose configured
+// secrets Grafana could not decrypt, typically after its secret key changed
+// or the datasource was copied from another instance. Ocient would otherwise
+// reject the empty credentials with a 401 that doesn't tell why.
+func (d *Datasource) undecryptedSecrets() error {
+	if len(d.settings.UndecryptedSecrets) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Grafana could not decrypt the saved %s of this data source; re-enter them on the data source settings page",
+		strings.Join(d.settings.UndecryptedSecrets, ", "))
+}
+
+// insecureTLSNotice returns the warning attached to frames of an insecure datasource.
+func insecureTLSNotice() data.Notice {
+	return data.Notice{Severity: data.NoticeSeverityWarning, Text: insecureTLSMessage}
+}
+
+// newTLSConfig returns the TLS configuration of connections to Ocient. When a
+// CA certificate bundle is configured, the server certificate must be signed by
+// one of its certificates instead of a system root, as for clusters with an
+// internal CA. A configured client certificate is presented to the server.
+// The server name overrides the host as the name sent with SNI and verified
+// against the certificate, for hosts reached through a load balancer or by IP.
+func newTLSConfig(settings models.PluginSettings) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: settings.InsecureSkipVerify, ServerName: settings.TLSServerName}
+	if settings.Secrets != nil && settings.Secrets.TLSCACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(settings.Secrets.TLSCACert)) {
+			return nil, errors.New("the TLS CA certificate contains no PEM encoded certificate")
+		}
+		config.RootCAs = pool
+	}
+	// Gateways terminating mutual TLS in front of Ocient ask for a client certificate
+	if settings.Secrets != nil && (settings.Secrets.TLSClientCert != "" || settings.Secrets.TLSClientKey != "") {
+		cert, err := tls.X509KeyPair([]byte(settings.Secrets.TLSClientCert), []byte(settings.Secrets.TLSClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS client certificate or key: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,64 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+)
+
+// textRange is a selection in the query editor, as offsets in UTF-16 code
+// units like the editor reports them. From equals To for a bare cursor.
+type textRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// selectStatement returns the statement of a multi-statement editor buffer to
+// run for a "run selection" request: the selected text when something is
+// selected, otherwise the statement the cursor is in.
+func selectStatement(text string, sel textRange) (string, error) {
+	from, to := byteOffset(text, sel.From), byteOffset(text, sel.To)
+	if from > to {
+		from, to = to, from
+	}
+
+	if from < to {
+		selected := text[from:to]
+		switch spans := splitStatements(selected); len(spans) {
+		case 0:
+			return "", fmt.Errorf("the selection contains no statement")
+		case 1:
+			return strings.TrimSpace(selected[spans[0][0]:spans[0][1]]), nil
+		default:
+			return "", fmt.Errorf("the selection contains %d statements, select a single one", len(spans))
+		}
+	}
+
+	// A cursor just after a semicolon still belongs to the statement it ends
+	spans := splitStatements(text)
+	for _, span := range spans {
+		if from >= span[0] && from <= span[1]+1 {
+			return strings.TrimSpace(text[span[0]:span[1]]), nil
+		}
+	}
+	if len(spans) > 0 && from > spans[len(spans)-1][1] {
+		last := spans[len(spans)-1]
+		return strings.TrimSpace(text[last[0]:last[1]]), nil
+	}
+	return "", fmt.Errorf("there is no statement at the cursor")
+}
+
+// byteOffset converts an offset in UTF-16 code units into a byte offset in s,
+// clamped to the length of s.
+func byteOffset(s string, units int) int {
+	for i, r := range s {
+		if units <= 0 {
+			return i
+		}
+		if r >= 0x10000 {
+			units -= 2
+		} else {
+			units--
+		}
+	}
+	return len(s)
+}
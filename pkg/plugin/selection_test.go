@@ -0,0 +1,45 @@
+package plugin
+
+import "testing"
+
+func TestSelectStatement(t *testing.T) {
+	text := "SELECT 1;\n-- counts; per host\nSELECT host, COUNT(*) FROM t WHERE name = 'a;b' GROUP BY host;\n\nSELECT 3"
+
+	tests := []struct {
+		sel  textRange
+		want string
+	}{
+		{textRange{From: 0, To: 0}, "SELECT 1"},
+		{textRange{From: 9, To: 9}, "SELECT 1"},
+		{textRange{From: 40, To: 40}, "-- counts; per host\nSELECT host, COUNT(*) FROM t WHERE name = 'a;b' GROUP BY host"},
+		{textRange{From: len(text), To: len(text)}, "SELECT 3"},
+		{textRange{From: 30, To: 42}, "SELECT host,"},
+		{textRange{From: 6, To: 0}, "SELECT"},
+	}
+	for _, tt := range tests {
+		got, err := selectStatement(text, tt.sel)
+		if err != nil {
+			t.Fatalf("selectStatement(%v): %v", tt.sel, err)
+		}
+		if got != tt.want {
+			t.Errorf("selectStatement(%v) = %q, want %q", tt.sel, got, tt.want)
+		}
+	}
+
+	if _, err := selectStatement(text, textRange{From: 0, To: len(text)}); err == nil {
+		t.Error("expected an error for a selection spanning statements")
+	}
+	if _, err := selectStatement(";  ;", textRange{}); err == nil {
+		t.Error("expected an error without any statement")
+	}
+}
+
+func TestByteOffset(t *testing.T) {
+	// é is one UTF-16 unit and two bytes, 😀 two units and four bytes
+	s := "é😀x"
+	for units, want := range []int{0, 2, 6, 6, 7} {
+		if got := byteOffset(s, units); got != want {
+			t.Errorf("byteOffset(%d) = %d, want %d", units, got, want)
+		}
+	}
+}
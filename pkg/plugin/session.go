@@ -0,0 +1,122 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+const (
+	// sessionPath is the endpoint of the Ocient API that opens sessions.
+	sessionPath = "/v1/session"
+	// sessionRefreshMargin is how long before it expires a session token is
+	// replaced, so that statements don't race its expiry.
+	sessionRefreshMargin = 30 * time.Second
+	// sessionDefaultTTL is the lifetime assumed for sessions opened without
+	// an expiry.
+	sessionDefaultTTL = 10 * time.Minute
+)
+
+// sessionResponse is the answer of the session endpoint.
+type sessionResponse struct {
+	Token     string `json:"token"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// sessionCache holds the session tokens of a datasource instance, by SQL node
+// and user, so that the transports of its environments and public dashboards
+// each log in once rather than with every statement.
+type sessionCache struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// session is the token of a user on a SQL node. Its lock is held while
+// logging in, so that concurrent statements wait for a single login.
+type session struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newSessionCache() *sessionCache {
+	return &sessionCache{sessions: make(map[string]*session)}
+}
+
+func (c *sessionCache) session(endpoint, username string) *session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := endpoint + "\x00" + username
+	s, ok := c.sessions[key]
+	if !ok {
+		s = &session{}
+		c.sessions[key] = s
+	}
+	return s
+}
+
+// sessionToken returns a session token of the user statements of ctx are
+// sent as on endpoint, logging in when there is none or it is about to
+// expire. A stale token, one Ocient rejected, is replaced unless another
+// statement already did.
+func (t *restTransport) sessionToken(ctx context.Context, endpoint, stale string) (string, error) {
+	username, password, err := t.credentials(ctx)
+	if err != nil {
+		return "", err
+	}
+	s := t.sessions.session(endpoint, username)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && s.token != stale && time.Until(s.expires) > sessionRefreshMargin {
+		return s.token, nil
+	}
+	token, ttl, err := openSession(ctx, t.client, t.settings, endpoint, username, password)
+	if err != nil {
+		s.token = ""
+		return "", err
+	}
+	s.token, s.expires = token, time.Now().Add(ttl)
+	return token, nil
+}
+
+// openSession logs in to the session endpoint of a SQL node with the
+// credentials, returning the session token and its lifetime.
+func openSession(ctx context.Context, client *http.Client, settings models.PluginSettings, endpoint, username, password string) (string, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiScheme(settings)+"://"+endpoint+sessionPath, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent(ctx))
+	req.SetBasicAuth(username, password)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("error opening session: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", 0, fmt.Errorf("credentials rejected by the session endpoint: %s", resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return "", 0, fmt.Errorf("error opening session: %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("error opening session: %w", err)
+	}
+	var session sessionResponse
+	if err := json.Unmarshal(body, &session); err != nil || session.Token == "" {
+		return "", 0, fmt.Errorf("session endpoint answered without a token")
+	}
+	ttl := sessionDefaultTTL
+	if session.ExpiresIn > 0 {
+		ttl = time.Duration(session.ExpiresIn) * time.Second
+	}
+	return session.Token, ttl, nil
+}
@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ocient/ocient-datasource/pkg/internal/fakeocient"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+func TestRESTTransportSessionAuth(t *testing.T) {
+	transport, server := newFakeRESTTransport(t, fakeocient.WithSessions(time.Hour), fakeocient.WithDatasets(fakeocient.Dataset{
+		Match: "from t",
+		Rows:  []map[string]interface{}{{"a": float64(1)}},
+	}))
+	transport.settings.AuthMethod = models.AuthSession
+
+	for range 3 {
+		if _, err := transport.Execute(context.Background(), "SELECT a FROM t"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if logins := server.Logins(); logins != 1 {
+		t.Errorf("got %d logins for three statements, want 1", logins)
+	}
+	if user := server.Requests()[0].Username; user != "user" {
+		t.Errorf("got statements run as %q, want user", user)
+	}
+
+	// A session ended by Ocient is replaced and the statement sent again
+	server.EndSessions()
+	if _, err := transport.Execute(context.Background(), "SELECT a FROM t"); err != nil {
+		t.Fatal(err)
+	}
+	if logins := server.Logins(); logins != 2 {
+		t.Errorf("got %d logins after the session ended, want 2", logins)
+	}
+	if n := len(server.Requests()); n != 4 {
+		t.Errorf("got %d statements run, want 4", n)
+	}
+}
+
+func TestRESTTransportSessionRefresh(t *testing.T) {
+	// Sessions shorter than the refresh margin are replaced for every statement
+	transport, server := newFakeRESTTransport(t, fakeocient.WithSessions(10*time.Second), fakeocient.WithDatasets(fakeocient.Dataset{Match: "from t"}))
+	transport.settings.AuthMethod = models.AuthSession
+
+	for range 2 {
+		if _, err := transport.Execute(context.Background(), "SELECT a FROM t"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if logins := server.Logins(); logins != 2 {
+		t.Errorf("got %d logins, want a session refreshed before it expires", logins)
+	}
+}
+
+func TestRESTTransportSessionRejected(t *testing.T) {
+	transport, server := newFakeRESTTransport(t, fakeocient.WithSessions(time.Hour))
+	transport.settings.AuthMethod = models.AuthSession
+	transport.settings.Secrets = &models.SecretPluginSettings{Username: "user", Password: "wrong"}
+
+	_, err := transport.Execute(context.Background(), "SELECT a FROM t")
+	if err == nil || !strings.Contains(err.Error(), "credentials rejected") {
+		t.Errorf("got %v, want the credentials rejected", err)
+	}
+	if n := len(server.Requests()); n != 0 {
+		t.Errorf("got %d statements run without a session", n)
+	}
+}
@@ -0,0 +1,64 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// splitFrame returns one frame per distinct value of the column, in order of
+// first appearance. The column is removed and its value becomes the frame name
+// and a label on every remaining non-time field, so panels can repeat by series and
+// legends show the value.
+func splitFrame(frame *data.Frame, column string) (data.Frames, error) {
+	if frame.Rows() == 0 {
+		return data.Frames{frame}, nil
+	}
+	field, index := frame.FieldByName(column)
+	if index < 0 {
+		return nil, fmt.Errorf("split column %q is not in the result", column)
+	}
+
+	var values []string
+	groups := make(map[string][]int)
+	for row := 0; row < field.Len(); row++ {
+		var value string
+		if v, ok := field.ConcreteAt(row); ok {
+			value = stringify(v)
+		}
+		if _, seen := groups[value]; !seen {
+			values = append(values, value)
+		}
+		groups[value] = append(groups[value], row)
+	}
+
+	rest := data.NewFrame(frame.Name)
+	rest.Meta = frame.Meta
+	for i, f := range frame.Fields {
+		if i != index {
+			rest.Fields = append(rest.Fields, f)
+		}
+	}
+
+	frames := make(data.Frames, 0, len(values))
+	for _, value := range values {
+		split := selectRows(rest, groups[value])
+		split.Name = value
+		if frame.Meta != nil {
+			meta := *frame.Meta
+			split.Meta = &meta
+		}
+		for _, f := range split.Fields {
+			if f.Type().Time() {
+				continue
+			}
+			labels := data.Labels{column: value}
+			for k, v := range f.Labels {
+				labels[k] = v
+			}
+			f.Labels = labels
+		}
+		frames = append(frames, split)
+	}
+	return frames, nil
+}
@@ -0,0 +1,35 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestSplitFrame(t *testing.T) {
+	frame := data.NewFrame("response",
+		data.NewField("time", nil, []time.Time{time.Unix(0, 0), time.Unix(0, 0), time.Unix(60, 0)}),
+		data.NewField("host", nil, []string{"b", "a", "b"}),
+		data.NewField("value", nil, []float64{1, 2, 3}),
+	)
+
+	frames, err := splitFrame(frame, "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 || frames[0].Name != "b" || frames[1].Name != "a" {
+		t.Fatalf("expected frames b and a in order of appearance, got %v", frames)
+	}
+	b := frames[0]
+	if b.Rows() != 2 || len(b.Fields) != 2 {
+		t.Errorf("expected 2 rows without the split column, got %d rows and %d fields", b.Rows(), len(b.Fields))
+	}
+	if b.Fields[1].Labels["host"] != "b" || b.Fields[0].Labels != nil {
+		t.Errorf("expected only the value field to be labeled, got %v and %v", b.Fields[0].Labels, b.Fields[1].Labels)
+	}
+
+	if _, err := splitFrame(frame, "missing"); err == nil {
+		t.Error("expected an error for an unknown split column")
+	}
+}
@@ -0,0 +1,286 @@
+package plugin
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// simpleIdentifier matches identifiers that never need quoting.
+var simpleIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedWords are keywords that can't be used as bare identifiers.
+var reservedWords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`
+		ALL ALTER AND ANY AS ASC BETWEEN BY CASE CAST CHECK COLUMN CONSTRAINT CREATE CROSS
+		CURRENT_DATE CURRENT_TIME CURRENT_TIMESTAMP DATE DEFAULT DELETE DESC DISTINCT DROP
+		ELSE END EXCEPT EXISTS FALSE FETCH FOR FOREIGN FROM FULL GRANT GROUP HAVING IN INNER
+		INSERT INTERSECT INTERVAL INTO IS JOIN KEY LEFT LIKE LIMIT NATURAL NOT NULL OFFSET ON
+		OR ORDER OUTER OVER PARTITION PRIMARY REFERENCES REVOKE RIGHT ROWS SELECT SET SOME
+		TABLE THEN TIME TIMESTAMP TO TRUE UNION UNIQUE UPDATE USER USING VALUES VIEW WHEN
+		WHERE WINDOW WITH`) {
+		reservedWords[word] = true
+	}
+}
+
+// quoteIdentifier returns name as a SQL identifier, quoting it with double quotes
+// only when it is a reserved word or contains characters that aren't valid in a
+// bare identifier.
+func quoteIdentifier(name string) string {
+	if simpleIdentifier.MatchString(name) && !reservedWords[strings.ToUpper(name)] {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteQualifiedIdentifier quotes each dot separated part of a possibly
+// qualified name such as schema.table. Parts that are already quoted are kept.
+func quoteQualifiedIdentifier(name string) string {
+	var parts []string
+	for i := 0; i < len(name); {
+		if name[i] == '"' {
+			end := skipQuoted(name, i)
+			if end < 0 {
+				end = len(name)
+			}
+			parts = append(parts, name[i:end])
+			i = end + 1
+			continue
+		}
+		end := strings.IndexByte(name[i:], '.')
+		if end < 0 {
+			end = len(name) - i
+		}
+		parts = append(parts, quoteIdentifier(strings.TrimSpace(name[i:i+end])))
+		i += end + 1
+	}
+	return strings.Join(parts, ".")
+}
+
+// selectStar matches a statement that selects every column, capturing the star.
+var selectStar = regexp.MustCompile(`(?is)^(\s*SELECT\s+)\*(\s+FROM\b)`)
+
+// projectColumns rewrites a "SELECT * FROM ..." statement to select only the
+// given columns. Any other statement is returned unchanged, so more complex
+// queries are never altered.
+func projectColumns(statement string, columns []string) string {
+	if len(columns) == 0 || !selectStar.MatchString(statement) {
+		return statement
+	}
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = quoteIdentifier(col)
+	}
+	projection := strings.ReplaceAll(strings.Join(quoted, ", "), "$", "$$")
+	return selectStar.ReplaceAllString(statement, "${1}"+projection+"${2}")
+}
+
+// readOnlyKeywords are the statements allowed to start a read-only statement.
+var readOnlyKeywords = map[string]bool{"SELECT": true, "WITH": true, "EXPLAIN": true}
+
+// writeKeywords may not appear anywhere in a read-only statement, which also
+// rejects data modifying common table expressions.
+var writeKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "DROP": true, "CREATE": true,
+	"ALTER": true, "TRUNCATE": true, "GRANT": true, "REVOKE": true, "EXPORT": true, "SET": true,
+}
+
+// isReadOnlyStatement reports whether statement is a single query that cannot
+// modify data. String literals, quoted identifiers and comments are skipped, so
+// only bare keywords are considered. The check is deliberately conservative: a
+// bare identifier that happens to be a write keyword must be quoted.
+func isReadOnlyStatement(statement string) bool {
+	var words []string
+	ended := false
+	for i := 0; i < len(statement); {
+		c := statement[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := skipQuoted(statement, i)
+			if end < 0 || ended {
+				return false
+			}
+			i = end
+		case strings.HasPrefix(statement[i:], "--"):
+			end := strings.IndexByte(statement[i:], '\n')
+			if end < 0 {
+				end = len(statement) - i
+			}
+			i += end
+		case strings.HasPrefix(statement[i:], "/*"):
+			end := strings.Index(statement[i+2:], "*/")
+			if end < 0 {
+				return false
+			}
+			i += end + 4
+		case c == ';':
+			ended = true
+			i++
+		case isWordByte(c):
+			start := i
+			for i < len(statement) && isWordByte(statement[i]) {
+				i++
+			}
+			if ended {
+				return false
+			}
+			words = append(words, strings.ToUpper(statement[start:i]))
+		default:
+			if ended && !unicode.IsSpace(rune(c)) {
+				return false
+			}
+			i++
+		}
+	}
+
+	if len(words) == 0 || !readOnlyKeywords[words[0]] {
+		return false
+	}
+	for _, word := range words {
+		if writeKeywords[word] {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// skipQuoted returns the index just past the quoted text starting at start, where
+// a doubled quote character escapes the quote, or -1 if it is unterminated.
+func skipQuoted(s string, start int) int {
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		if s[i] != quote {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return -1
+}
+
+// redactSQL replaces the contents of string literals with "***" so statements
+// can be shared without the values they filter on.
+func redactSQL(statement string) string {
+	var b strings.Builder
+	for i := 0; i < len(statement); {
+		switch statement[i] {
+		case '\'', '"':
+			end := skipQuoted(statement, i)
+			if end < 0 {
+				end = len(statement)
+			}
+			if statement[i] == '"' {
+				// Quoted identifiers are kept
+				b.WriteString(statement[i:end])
+			} else {
+				b.WriteString("'***'")
+			}
+			i = end
+		default:
+			b.WriteByte(statement[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// redactValues is redactSQL that also replaces numeric literals with ***, for
+// statements and error messages leaving the plugin, where ids and amounts are
+// as sensitive as strings.
+func redactValues(text string) string {
+	text = redactSQL(text)
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		if c == '\'' || c == '"' {
+			end := skipQuoted(text, i)
+			if end < 0 {
+				end = len(text)
+			}
+			b.WriteString(text[i:end])
+			i = end
+			continue
+		}
+		startsNumber := isDigit(c) || (c == '.' && i+1 < len(text) && isDigit(text[i+1]))
+		if !startsNumber || (i > 0 && isWordByte(text[i-1])) {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		// Exponents, fractions and hex digits belong to the literal
+		for i < len(text) && (isWordByte(text[i]) || text[i] == '.') {
+			i++
+		}
+		b.WriteString("***")
+	}
+	return b.String()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// trailingLimit matches a LIMIT clause at the end of a statement.
+var trailingLimit = regexp.MustCompile(`(?is)\bLIMIT\s+(\d+)(?:\s+OFFSET\s+\d+)?\s*;?\s*$`)
+
+// statementLimit returns the row count of the LIMIT clause ending statement, if
+// any. Literals are ignored, so a LIMIT inside a string doesn't count.
+func statementLimit(statement string) (int, bool) {
+	match := trailingLimit.FindStringSubmatch(redactSQL(statement))
+	if match == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(match[1])
+	return n, err == nil
+}
+
+// splitStatements returns the byte ranges of the statements in text, split on
+// semicolons outside literals, quoted identifiers and comments. The ranges
+// leave out the semicolons; statements that are only whitespace are dropped.
+func splitStatements(text string) [][2]int {
+	var spans [][2]int
+	add := func(start, end int) {
+		if strings.TrimSpace(text[start:end]) != "" {
+			spans = append(spans, [2]int{start, end})
+		}
+	}
+	start := 0
+	for i := 0; i < len(text); {
+		switch {
+		case text[i] == '\'' || text[i] == '"':
+			if i = skipQuoted(text, i); i < 0 {
+				i = len(text)
+			}
+		case strings.HasPrefix(text[i:], "--"):
+			if end := strings.IndexByte(text[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(text)
+			}
+		case strings.HasPrefix(text[i:], "/*"):
+			if end := strings.Index(text[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(text)
+			}
+		case text[i] == ';':
+			add(start, i)
+			i++
+			start = i
+		default:
+			i++
+		}
+	}
+	add(start, len(text))
+	return spans
+}
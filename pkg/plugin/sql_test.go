@@ -0,0 +1,102 @@
+package plugin
+
+import "testing"
+
+func TestProjectColumns(t *testing.T) {
+	tests := []struct {
+		statement string
+		columns   []string
+		want      string
+	}{
+		{"SELECT * FROM t", []string{"a", "b"}, "SELECT a, b FROM t"},
+		{"select *\n  from s.t where x = 1", []string{"my col", `q"t`}, "select \"my col\", \"q\"\"t\"\n  from s.t where x = 1"},
+		{"SELECT * FROM t", nil, "SELECT * FROM t"},
+		{"SELECT a, * FROM t", []string{"a"}, "SELECT a, * FROM t"},
+		{"SELECT COUNT(*) FROM t", []string{"a"}, "SELECT COUNT(*) FROM t"},
+		{"SELECT * FROM t", []string{"$1"}, `SELECT "$1" FROM t`},
+	}
+	for _, tt := range tests {
+		if got := projectColumns(tt.statement, tt.columns); got != tt.want {
+			t.Errorf("projectColumns(%q, %v) = %q, want %q", tt.statement, tt.columns, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteQualifiedIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"sensor_data":           "sensor_data",
+		"order":                 `"order"`,
+		"demo.user":             `demo."user"`,
+		"my schema.my-table":    `"my schema"."my-table"`,
+		`"Already.Quoted".ts`:   `"Already.Quoted".ts`,
+		`demo."Mixed ""Case"""`: `demo."Mixed ""Case"""`,
+	}
+	for name, want := range tests {
+		if got := quoteQualifiedIdentifier(name); got != want {
+			t.Errorf("quoteQualifiedIdentifier(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestIsReadOnlyStatement(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      bool
+	}{
+		{"SELECT * FROM t", true},
+		{"  -- comment\n/* block */ select a from t;", true},
+		{"WITH x AS (SELECT 1) SELECT * FROM x", true},
+		{"SELECT 'DROP TABLE t; --' AS s, \"delete\" FROM t", true},
+		{"SELECT 'it''s' FROM t", true},
+		{"DELETE FROM t", false},
+		{"SELECT 1; DROP TABLE t", false},
+		{"WITH x AS (DELETE FROM t) SELECT 1", false},
+		{"SELECT 'unterminated FROM t", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isReadOnlyStatement(tt.statement); got != tt.want {
+			t.Errorf("isReadOnlyStatement(%q) = %v, want %v", tt.statement, got, tt.want)
+		}
+	}
+}
+
+func TestRedactSQL(t *testing.T) {
+	got := redactSQL(`SELECT "it's" FROM t WHERE name = 'O''Brien' AND id = 7`)
+	want := `SELECT "it's" FROM t WHERE name = '***' AND id = 7`
+	if got != want {
+		t.Errorf("redactSQL() = %q, want %q", got, want)
+	}
+}
+
+func TestStatementLimit(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      int
+		ok        bool
+	}{
+		{"SELECT * FROM t LIMIT 100", 100, true},
+		{"select * from t limit 10 offset 20;", 10, true},
+		{"SELECT * FROM t WHERE name = 'LIMIT 5'", 0, false},
+		{"SELECT * FROM (SELECT * FROM t LIMIT 5) s", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := statementLimit(tt.statement); got != tt.want || ok != tt.ok {
+			t.Errorf("statementLimit(%q) = %d, %v, want %d, %v", tt.statement, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	text := "SELECT ';' ; /* ; */ SELECT \"a;b\" -- ;\n;  ;"
+	spans := splitStatements(text)
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 statements, got %v", spans)
+	}
+	if got := text[spans[0][0]:spans[0][1]]; got != "SELECT ';' " {
+		t.Errorf("unexpected first statement %q", got)
+	}
+	if got := text[spans[1][0]:spans[1][1]]; got != " /* ; */ SELECT \"a;b\" -- ;\n" {
+		t.Errorf("unexpected second statement %q", got)
+	}
+}
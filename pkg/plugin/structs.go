@@ -0,0 +1,117 @@
+package plugin
+
+import (
+	"sort"
+	"strings"
+)
+
+// isStructColumn reports whether a result column holds tuples or structs, either
+// by its declared type or, for untyped columns, by its first non-null value.
+func isStructColumn(result *QueryResult, index int) bool {
+	t := strings.ToUpper(strings.TrimSpace(result.Columns[index].Type))
+	if strings.HasPrefix(t, "TUPLE") || strings.HasPrefix(t, "STRUCT") {
+		return true
+	}
+	if t != "" {
+		return false
+	}
+	for _, row := range result.Rows {
+		if index < len(row) && row[index] != nil {
+			_, ok := row[index].(map[string]interface{})
+			return ok
+		}
+	}
+	return false
+}
+
+// structPaths collects the sorted dotted paths of every leaf value found in the
+// objects of a column, e.g. "x" and "y" for {"x": 1, "y": 2}.
+func structPaths(result *QueryResult, index int) []string {
+	seen := make(map[string]bool)
+	var walk func(prefix string, obj map[string]interface{})
+	walk = func(prefix string, obj map[string]interface{}) {
+		for k, v := range obj {
+			path := prefix + k
+			if nested, ok := v.(map[string]interface{}); ok && len(nested) > 0 {
+				walk(path+".", nested)
+				continue
+			}
+			seen[path] = true
+		}
+	}
+	for _, row := range result.Rows {
+		if index < len(row) {
+			if obj, ok := row[index].(map[string]interface{}); ok {
+				walk("", obj)
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// lookupPath returns the value at a dotted path inside nested objects.
+func lookupPath(v interface{}, path []string) interface{} {
+	for _, key := range path {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = obj[key]
+	}
+	return v
+}
+
+// flattenStructs replaces every tuple/struct column with one column per leaf,
+// named with dotted paths such as point.x and point.y.
+func flattenStructs(result *QueryResult) *QueryResult {
+	type source struct {
+		index int
+		path  []string
+	}
+	var columns []Column
+	var sources []source
+	flattened := false
+
+	for i, col := range result.Columns {
+		var paths []string
+		if isStructColumn(result, i) {
+			paths = structPaths(result, i)
+		}
+		if len(paths) == 0 {
+			columns = append(columns, col)
+			sources = append(sources, source{index: i})
+			continue
+		}
+		flattened = true
+		for _, path := range paths {
+			columns = append(columns, Column{Name: col.Name + "." + path})
+			sources = append(sources, source{index: i, path: strings.Split(path, ".")})
+		}
+	}
+	if !flattened {
+		return result
+	}
+
+	out := &QueryResult{QueryID: result.QueryID, Columns: columns, Rows: make([][]interface{}, len(result.Rows))}
+	for r, row := range result.Rows {
+		values := make([]interface{}, len(sources))
+		for i, src := range sources {
+			if src.index >= len(row) {
+				continue
+			}
+			if src.path == nil {
+				values[i] = row[src.index]
+			} else {
+				values[i] = lookupPath(row[src.index], src.path)
+			}
+		}
+		out.Rows[r] = values
+	}
+	return out
+}
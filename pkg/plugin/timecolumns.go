@@ -0,0 +1,19 @@
+package plugin
+
+import "strings"
+
+// defaultTimeColumn returns the time column configured in the timeColumns
+// setting for the table a query reads, matched like minInterval. Queries
+// then need not name it for the time axis or in $__timeFilter() and
+// $__timeGroup().
+func (d *Datasource) defaultTimeColumn(queryText string) string {
+	if len(d.settings.TimeColumns) == 0 {
+		return ""
+	}
+	columns := make(map[string]string, len(d.settings.TimeColumns))
+	for table, column := range d.settings.TimeColumns {
+		columns[strings.ToLower(strings.Join(splitQualifiedName(table), "."))] = column
+	}
+	column, _ := tableSetting(columns, queryText, d.settings.DefaultSchema)
+	return column
+}
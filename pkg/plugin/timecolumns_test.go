@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+func TestDefaultTimeColumn(t *testing.T) {
+	transport := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "ts", Type: "BIGINT"}, {Name: "value", Type: "DOUBLE"}},
+		Rows:    [][]interface{}{{float64(1704207600000), float64(1)}},
+	}}
+	ds := Datasource{transport: transport, settings: models.PluginSettings{
+		DefaultSchema: "app",
+		TimeColumns:   map[string]string{"App.Events": "TS"},
+	}}
+	timeRange := backend.TimeRange{
+		From: time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 1, 2, 16, 0, 0, 0, time.UTC),
+	}
+	query := func(text string) backend.DataResponse {
+		t.Helper()
+		body, _ := json.Marshal(map[string]interface{}{"queryText": text, "intervalMs": 60000})
+		resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: body, TimeRange: timeRange}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Responses["A"]
+	}
+
+	res := query("SELECT $__timeGroup() AS ts, value FROM events WHERE $__timeFilter()")
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	statement := transport.statements[0]
+	if !strings.Contains(statement, "UNIX_TIMESTAMP(TS) / 60") || !strings.Contains(statement, "TS >= '2024-01-02 15:00:00'") {
+		t.Errorf("macros did not use the configured time column: %s", statement)
+	}
+	if typ := res.Frames[0].Fields[0].Type(); typ != data.FieldTypeNullableTime && typ != data.FieldTypeTime {
+		t.Errorf("expected the configured column to be the time axis, got %s", typ)
+	}
+
+	// Other tables have no default, and the column need not be selected
+	if res = query("SELECT value FROM other WHERE $__timeFilter()"); res.Error == nil {
+		t.Error("expected $__timeFilter() without a column to fail for a table without a time column")
+	}
+	transport.result = &QueryResult{Columns: []Column{{Name: "count", Type: "BIGINT"}}, Rows: [][]interface{}{{float64(3)}}}
+	if res = query("SELECT COUNT(*) AS count FROM app.events"); res.Error != nil {
+		t.Errorf("unexpected error for a result without the time column: %v", res.Error)
+	}
+}
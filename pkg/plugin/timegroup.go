@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeGroupExpression returns the expression flooring column to buckets of
+// interval, which is rounded to whole seconds.
+func timeGroupExpression(column string, interval time.Duration) string {
+	seconds := max(int64(interval.Round(time.Second)/time.Second), 1)
+	return fmt.Sprintf("TO_TIMESTAMP(FLOOR(UNIX_TIMESTAMP(%s) / %d) * %d)", column, seconds, seconds)
+}
+
+// parseMinIntervals parses the minIntervals setting, the smallest interval
+// $__timeGroup groups each table by, into durations by lower case table name.
+func parseMinIntervals(setting map[string]string) (map[string]time.Duration, error) {
+	floors := make(map[string]time.Duration, len(setting))
+	for table, interval := range setting {
+		d, ok := parseInterval(interval)
+		if !ok || d <= 0 {
+			return nil, fmt.Errorf("invalid minimum interval %q of table %s", interval, table)
+		}
+		floors[strings.ToLower(strings.Join(splitQualifiedName(table), "."))] = d
+	}
+	return floors, nil
+}
+
+// minInterval returns the minimum interval configured for the table a query
+// reads, by its qualified name or else its name alone. Tables named without a
+// schema are qualified by the default schema first. It is zero for queries of
+// other tables or of more than one.
+func (d *Datasource) minInterval(queryText string) time.Duration {
+	if len(d.settings.MinIntervals) == 0 {
+		return 0
+	}
+	floors, err := parseMinIntervals(d.settings.MinIntervals)
+	if err != nil {
+		return 0
+	}
+	floor, _ := tableSetting(floors, queryText, d.settings.DefaultSchema)
+	return floor
+}
+
+// tableSetting returns the value of a per-table setting, keyed by lowercase
+// table names, for the table a query reads: by its qualified name or else its
+// name alone. Tables named without a schema are qualified by defaultSchema
+// first. There is none for queries of other tables or of more than one.
+func tableSetting[V any](setting map[string]V, queryText, defaultSchema string) (V, bool) {
+	var zero V
+	table, ok := singleTable(queryText)
+	if !ok {
+		return zero, false
+	}
+	parts := splitQualifiedName(strings.ToLower(table))
+	if len(parts) == 1 && defaultSchema != "" {
+		parts = []string{strings.ToLower(defaultSchema), parts[0]}
+	}
+	if v, ok := setting[strings.Join(parts, ".")]; ok {
+		return v, true
+	}
+	v, ok := setting[parts[len(parts)-1]]
+	return v, ok
+}
@@ -0,0 +1,70 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+func TestTimeGroupMacro(t *testing.T) {
+	tests := []struct {
+		statement   string
+		interval    time.Duration
+		minInterval time.Duration
+		want        string
+	}{
+		{"SELECT $__timeGroup(ts)", 30 * time.Second, 0, "SELECT TO_TIMESTAMP(FLOOR(UNIX_TIMESTAMP(ts) / 30) * 30)"},
+		{"SELECT $__timeGroup(ts)", 30 * time.Second, 5 * time.Minute, "SELECT TO_TIMESTAMP(FLOOR(UNIX_TIMESTAMP(ts) / 300) * 300)"},
+		{"SELECT $__timeGroup(ts)", time.Hour, 5 * time.Minute, "SELECT TO_TIMESTAMP(FLOOR(UNIX_TIMESTAMP(ts) / 3600) * 3600)"},
+		{"SELECT $__timeGroup(ts, '1m')", time.Hour, 0, "SELECT TO_TIMESTAMP(FLOOR(UNIX_TIMESTAMP(ts) / 60) * 60)"},
+		{"SELECT $__timeGroup(ts, 1m)", 0, 5 * time.Minute, "SELECT TO_TIMESTAMP(FLOOR(UNIX_TIMESTAMP(ts) / 300) * 300)"},
+		{"SELECT $__timeGroup(ts)", 50 * time.Millisecond, 0, "SELECT TO_TIMESTAMP(FLOOR(UNIX_TIMESTAMP(ts) / 1) * 1)"},
+	}
+	for _, tt := range tests {
+		got, err := expandMacros(tt.statement, macroContext{interval: tt.interval, minInterval: tt.minInterval})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("expandMacros(%q) = %q, want %q", tt.statement, got, tt.want)
+		}
+	}
+
+	for _, statement := range []string{"SELECT $__timeGroup(ts)", "SELECT $__timeGroup(ts, 'soon')", "SELECT $__timeGroup()"} {
+		if _, err := expandMacros(statement, macroContext{}); err == nil {
+			t.Errorf("expandMacros(%q): expected an error", statement)
+		}
+	}
+}
+
+func TestMinIntervalFloor(t *testing.T) {
+	ds := Datasource{settings: models.PluginSettings{
+		Timezone:      "UTC",
+		DefaultSchema: "app",
+		MinIntervals:  map[string]string{"App.Metrics": "5m", "events": "1 hour"},
+	}}
+	tests := []struct {
+		queryText string
+		want      string
+	}{
+		{"SELECT $__timeGroup(ts) FROM metrics", "SELECT TO_TIMESTAMP(FLOOR(UNIX_TIMESTAMP(ts) / 300) * 300) FROM app.metrics"},
+		{"SELECT $__timeGroup(ts) FROM app.metrics", "SELECT TO_TIMESTAMP(FLOOR(UNIX_TIMESTAMP(ts) / 300) * 300) FROM app.metrics"},
+		{"SELECT $__timeGroup(ts) FROM other.metrics", "SELECT TO_TIMESTAMP(FLOOR(UNIX_TIMESTAMP(ts) / 10) * 10) FROM other.metrics"},
+		{"SELECT $__timeGroup(ts) FROM web.events", "SELECT TO_TIMESTAMP(FLOOR(UNIX_TIMESTAMP(ts) / 3600) * 3600) FROM web.events"},
+	}
+	for _, tt := range tests {
+		got, _, err := ds.prepareStatement(queryModel{QueryText: tt.queryText, IntervalMs: 10000}, backend.TimeRange{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("prepareStatement(%q) = %q, want %q", tt.queryText, got, tt.want)
+		}
+	}
+
+	if _, err := parseMinIntervals(map[string]string{"metrics": "often"}); err == nil {
+		t.Error("expected an error for an invalid minimum interval")
+	}
+}
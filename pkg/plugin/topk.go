@@ -0,0 +1,46 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultTopK = 10
+	maxTopK     = 1000
+)
+
+// topKOptions asks for the most frequent values of a column, the
+// categories of a bar chart, instead of running the query text. The query
+// is generated with the ad-hoc filters of the dashboard, and limited to its
+// time range when TimeColumn is set.
+type topKOptions struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	// K is the number of categories, defaults to 10
+	K          int    `json:"k"`
+	TimeColumn string `json:"timeColumn"`
+}
+
+// topKQueryText returns the query text counting the rows of every value of
+// the column, the most frequent first. Values counted as often are ordered
+// by value, so that the categories of a panel don't shuffle on refresh.
+func topKQueryText(opts topKOptions) (string, error) {
+	if strings.TrimSpace(opts.Table) == "" || strings.TrimSpace(opts.Column) == "" {
+		return "", fmt.Errorf("top categories need a table and a column")
+	}
+	k := opts.K
+	if k == 0 {
+		k = defaultTopK
+	}
+	if k < 0 || k > maxTopK {
+		return "", fmt.Errorf("top categories can count 1 to %d values, got %d", maxTopK, k)
+	}
+	column := quoteIdentifier(opts.Column)
+	conditions := "$__adhocFilters()"
+	if opts.TimeColumn != "" {
+		conditions += " AND $__timeFilter(" + quoteIdentifier(opts.TimeColumn) + ")"
+	}
+	return fmt.Sprintf("SELECT %s AS category, COUNT(*) AS count FROM %s WHERE %s GROUP BY %s ORDER BY COUNT(*) DESC, %s LIMIT %d",
+		column, quoteQualifiedIdentifier(opts.Table), conditions, column, column, k), nil
+}
@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestTopKQueryText(t *testing.T) {
+	text, err := topKQueryText(topKOptions{Table: "web.requests", Column: "user agent", K: 5, TimeColumn: "ts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `SELECT "user agent" AS category, COUNT(*) AS count FROM web.requests WHERE $__adhocFilters() AND $__timeFilter(ts) ` +
+		`GROUP BY "user agent" ORDER BY COUNT(*) DESC, "user agent" LIMIT 5`
+	if text != want {
+		t.Errorf("got %s\nwant %s", text, want)
+	}
+
+	if text, _ := topKQueryText(topKOptions{Table: "t", Column: "c"}); !strings.HasSuffix(text, "LIMIT 10") {
+		t.Errorf("got %s, want 10 categories by default", text)
+	}
+	for _, opts := range []topKOptions{{Column: "c"}, {Table: "t"}, {Table: "t", Column: "c", K: -1}, {Table: "t", Column: "c", K: 5000}} {
+		if _, err := topKQueryText(opts); err == nil {
+			t.Errorf("%+v: expected an error", opts)
+		}
+	}
+}
+
+func TestQueryDataTopK(t *testing.T) {
+	transport := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "category", Type: "VARCHAR"}, {Name: "count", Type: "BIGINT"}},
+		Rows:    [][]interface{}{{"chrome", float64(120)}, {"firefox", float64(40)}},
+	}}
+	ds := Datasource{transport: transport}
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	body, _ := json.Marshal(map[string]interface{}{"topK": map[string]interface{}{"table": "requests", "column": "browser", "timeColumn": "ts"}})
+
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: body, TimeRange: backend.TimeRange{From: from, To: from.Add(time.Hour)}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := resp.Responses["A"]
+	if r.Error != nil {
+		t.Fatal(r.Error)
+	}
+	statement := transport.statements[0]
+	if !strings.Contains(statement, "WHERE 1=1 AND ts >= ") || !strings.Contains(statement, "GROUP BY browser") {
+		t.Errorf("unexpected statement %s", statement)
+	}
+	frame := r.Frames[0]
+	if len(frame.Fields) != 2 || frame.Fields[0].Type() != data.FieldTypeString || frame.Rows() != 2 {
+		t.Errorf("unexpected frame %v", frame)
+	}
+}
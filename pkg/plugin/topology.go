@@ -0,0 +1,188 @@
+package plugin
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// topologyTimeout bounds the system table query of the /cluster/topology
+// resource, which the configuration page waits for.
+const topologyTimeout = 15 * time.Second
+
+// topologyStatement lists the nodes of the cluster.
+const topologyStatement = "SELECT * FROM sys.nodes"
+
+// minOcientVersion is the oldest Ocient version the features of the plugin
+// are tested against.
+const minOcientVersion = "22.0"
+
+// Columns of sys.nodes read for the topology, by the names releases have
+// used for them.
+var (
+	nodeNameColumns    = []string{"name", "node_name", "hostname"}
+	nodeAddressColumns = []string{"address", "ip_address", "host"}
+	nodeRolesColumns   = []string{"roles", "role", "node_roles", "service_roles"}
+	nodeVersionColumns = []string{"version", "software_version", "ocient_version"}
+	nodeStatusColumns  = []string{"status", "operational_status", "state"}
+)
+
+// clusterNode is a node of the Ocient cluster.
+type clusterNode struct {
+	Name    string   `json:"name"`
+	Address string   `json:"address,omitempty"`
+	Roles   []string `json:"roles"`
+	Version string   `json:"version,omitempty"`
+	Status  string   `json:"status,omitempty"`
+}
+
+// clusterTopology is the response of the /cluster/topology resource.
+// Warnings flag version skew between the nodes and with the plugin.
+type clusterTopology struct {
+	Nodes    []clusterNode `json:"nodes"`
+	Versions []string      `json:"versions"`
+	Warnings []string      `json:"warnings,omitempty"`
+}
+
+// handleTopology lists the nodes of the cluster the datasource is connected
+// to, with their roles and versions, for the configuration page.
+func (d *Datasource) handleTopology(w http.ResponseWriter, r *http.Request) {
+	if d.blockInsecureTLS() {
+		writeError(w, http.StatusForbidden, insecureTLSMessage+"; enable verification to run queries")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), topologyTimeout)
+	defer cancel()
+	result, err := d.transport.Execute(ctx, topologyStatement)
+	if err == nil {
+		err = result.decodeRows()
+	}
+	if err != nil {
+		backend.Logger.Error("Cluster topology query failed", "error", err.Error())
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("listing the nodes failed: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, newClusterTopology(result))
+}
+
+// newClusterTopology reads the nodes from a sys.nodes result.
+func newClusterTopology(result *QueryResult) clusterTopology {
+	column := func(names []string) int {
+		return findColumn(result.Columns, names)
+	}
+	name, address, roles := column(nodeNameColumns), column(nodeAddressColumns), column(nodeRolesColumns)
+	version, status := column(nodeVersionColumns), column(nodeStatusColumns)
+
+	topology := clusterTopology{Nodes: make([]clusterNode, 0, len(result.Rows)), Versions: []string{}}
+	versions := make(map[string]bool)
+	for _, row := range result.Rows {
+		node := clusterNode{
+			Name:    rowString(row, name),
+			Address: rowString(row, address),
+			Roles:   nodeRoles(row, roles),
+			Version: rowString(row, version),
+			Status:  rowString(row, status),
+		}
+		if node.Version != "" && !versions[node.Version] {
+			versions[node.Version] = true
+			topology.Versions = append(topology.Versions, node.Version)
+		}
+		topology.Nodes = append(topology.Nodes, node)
+	}
+	sort.Slice(topology.Versions, func(i, j int) bool {
+		return compareVersions(topology.Versions[i], topology.Versions[j]) < 0
+	})
+
+	if len(topology.Versions) > 1 {
+		topology.Warnings = append(topology.Warnings, fmt.Sprintf(
+			"The nodes run different Ocient versions (%s), results may differ between SQL nodes", strings.Join(topology.Versions, ", ")))
+	}
+	if len(topology.Versions) > 0 && compareVersions(topology.Versions[0], minOcientVersion) < 0 {
+		topology.Warnings = append(topology.Warnings, fmt.Sprintf(
+			"Ocient %s is older than %s, some features of the plugin may not work", topology.Versions[0], minOcientVersion))
+	}
+	return topology
+}
+
+// findColumn returns the index of the first of names among the columns,
+// compared case-insensitively, or -1 when none is there. System tables have
+// renamed columns between Ocient releases.
+func findColumn(columns []Column, names []string) int {
+	for _, name := range names {
+		for i, c := range columns {
+			if strings.EqualFold(c.Name, name) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// rowString returns the value of column i of a row as a string, empty when
+// the column is missing or null.
+func rowString(row []interface{}, i int) string {
+	if i < 0 || i >= len(row) || row[i] == nil {
+		return ""
+	}
+	return stringify(row[i])
+}
+
+// nodeRoles returns the roles of a node, given as an array or a comma
+// separated list.
+func nodeRoles(row []interface{}, i int) []string {
+	roles := []string{}
+	if i < 0 || i >= len(row) {
+		return roles
+	}
+	var values []interface{}
+	switch v := row[i].(type) {
+	case []interface{}:
+		values = v
+	case string:
+		for _, role := range strings.Split(strings.Trim(v, "{}[]"), ",") {
+			values = append(values, role)
+		}
+	}
+	for _, v := range values {
+		if role := strings.Trim(strings.TrimSpace(stringify(v)), `"`); role != "" {
+			roles = append(roles, strings.ToLower(role))
+		}
+	}
+	return roles
+}
+
+// compareVersions compares dotted versions such as 23.1.4 by the numbers
+// their parts start with; missing parts count as zero.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x = leadingNumber(as[i])
+		}
+		if i < len(bs) {
+			y = leadingNumber(bs[i])
+		}
+		if x != y {
+			return cmp.Compare(x, y)
+		}
+	}
+	return 0
+}
+
+// leadingNumber returns the number s starts with, 0 when there is none.
+func leadingNumber(s string) int {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTopologyResource(t *testing.T) {
+	transport := &fakeTransport{result: &QueryResult{
+		Columns: []Column{{Name: "NAME"}, {Name: "address"}, {Name: "roles"}, {Name: "software_version"}, {Name: "status"}},
+		Rows: [][]interface{}{
+			{"sql-1", "10.0.0.1", "SQL, Admin", "23.1.4", "ACTIVE"},
+			{"sql-2", "10.0.0.2", []interface{}{"sql"}, "23.1.10", "ACTIVE"},
+			{"lts-1", "10.0.0.3", "{foundation}", "23.1.4", nil},
+		},
+	}}
+	ds := &Datasource{transport: transport}
+
+	resp := callResource(t, ds, "GET", "cluster/topology")
+	if resp.Status != 200 {
+		t.Fatalf("status %d: %s", resp.Status, resp.Body)
+	}
+	var topology clusterTopology
+	if err := json.Unmarshal(resp.Body, &topology); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.statements) != 1 || transport.statements[0] != topologyStatement {
+		t.Errorf("executed %q", transport.statements)
+	}
+	if len(topology.Nodes) != 3 {
+		t.Fatalf("got %d nodes, want 3", len(topology.Nodes))
+	}
+	if node := topology.Nodes[0]; node.Name != "sql-1" || node.Address != "10.0.0.1" || len(node.Roles) != 2 ||
+		node.Roles[0] != "sql" || node.Roles[1] != "admin" || node.Status != "ACTIVE" {
+		t.Errorf("unexpected node %+v", node)
+	}
+	if roles := topology.Nodes[2].Roles; len(roles) != 1 || roles[0] != "foundation" {
+		t.Errorf("unexpected roles %q", roles)
+	}
+	if len(topology.Versions) != 2 || topology.Versions[0] != "23.1.4" || topology.Versions[1] != "23.1.10" {
+		t.Errorf("unexpected versions %q", topology.Versions)
+	}
+	if len(topology.Warnings) != 1 {
+		t.Errorf("expected a version skew warning, got %q", topology.Warnings)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"23.1.4", "23.1.10", -1},
+		{"23.1", "23.1.0", 0},
+		{"24.0-rc1", "23.9", 1},
+		{"21.3", minOcientVersion, -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
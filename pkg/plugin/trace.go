@@ -0,0 +1,182 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// traceOptions names the span columns of a trace result. Unset names default
+// to trace_id, span_id, parent_span_id, operation, service, start_time,
+// duration and tags; the parent, service and tags columns are optional.
+type traceOptions struct {
+	TraceIDColumn   string `json:"traceIdColumn"`
+	SpanIDColumn    string `json:"spanIdColumn"`
+	ParentColumn    string `json:"parentColumn"`
+	OperationColumn string `json:"operationColumn"`
+	ServiceColumn   string `json:"serviceColumn"`
+	StartColumn     string `json:"startColumn"`
+	DurationColumn  string `json:"durationColumn"`
+	TagsColumn      string `json:"tagsColumn"`
+}
+
+// traceTag is a key value pair as the trace viewer expects tags.
+type traceTag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// toTraceFrame converts a result with one row per span into the frame the
+// trace viewer expects. The start column is a timestamp, or milliseconds since
+// the epoch, and the duration is in milliseconds. The tags column holds a JSON
+// object; without one, every column that isn't mapped becomes a tag.
+func toTraceFrame(frame *data.Frame, opts *traceOptions) (*data.Frame, error) {
+	if frame.Rows() == 0 {
+		return frame, nil
+	}
+	if opts == nil {
+		opts = &traceOptions{}
+	}
+
+	mapped := make(map[*data.Field]bool)
+	column := func(name, def string, required bool) (*data.Field, error) {
+		if name == "" {
+			name = def
+		}
+		field, _ := frame.FieldByName(name)
+		if field == nil {
+			if required || name != def {
+				return nil, fmt.Errorf("trace column %q is not in the result", name)
+			}
+			return nil, nil
+		}
+		mapped[field] = true
+		return field, nil
+	}
+	traceID, err := column(opts.TraceIDColumn, "trace_id", true)
+	if err != nil {
+		return nil, err
+	}
+	spanID, err := column(opts.SpanIDColumn, "span_id", true)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := column(opts.ParentColumn, "parent_span_id", false)
+	if err != nil {
+		return nil, err
+	}
+	operation, err := column(opts.OperationColumn, "operation", true)
+	if err != nil {
+		return nil, err
+	}
+	service, err := column(opts.ServiceColumn, "service", false)
+	if err != nil {
+		return nil, err
+	}
+	start, err := column(opts.StartColumn, "start_time", true)
+	if err != nil {
+		return nil, err
+	}
+	duration, err := column(opts.DurationColumn, "duration", true)
+	if err != nil {
+		return nil, err
+	}
+	tagsColumn, err := column(opts.TagsColumn, "tags", false)
+	if err != nil {
+		return nil, err
+	}
+	if !start.Type().Time() && !start.Type().Numeric() {
+		return nil, fmt.Errorf("trace start column %q must be a timestamp or epoch milliseconds", start.Name)
+	}
+	if !duration.Type().Numeric() {
+		return nil, fmt.Errorf("trace duration column %q must be numeric", duration.Name)
+	}
+
+	rows := frame.Rows()
+	stringAt := func(field *data.Field, row int) string {
+		if field == nil {
+			return ""
+		}
+		v, _ := field.ConcreteAt(row)
+		return stringify(v)
+	}
+	traceIDs, spanIDs, parents := make([]string, rows), make([]string, rows), make([]string, rows)
+	operations, services := make([]string, rows), make([]string, rows)
+	starts, durations := make([]float64, rows), make([]float64, rows)
+	tags := make([]json.RawMessage, rows)
+	for row := 0; row < rows; row++ {
+		traceIDs[row] = stringAt(traceID, row)
+		spanIDs[row] = stringAt(spanID, row)
+		parents[row] = stringAt(parent, row)
+		operations[row] = stringAt(operation, row)
+		services[row] = stringAt(service, row)
+		if start.Type().Time() {
+			if v, ok := start.ConcreteAt(row); ok {
+				starts[row] = float64(v.(time.Time).UnixMicro()) / 1000
+			}
+		} else {
+			starts[row], _ = start.FloatAt(row)
+		}
+		durations[row], _ = duration.FloatAt(row)
+
+		spanTags, err := traceTags(frame, row, tagsColumn, mapped)
+		if err != nil {
+			return nil, err
+		}
+		if tags[row], err = json.Marshal(spanTags); err != nil {
+			return nil, fmt.Errorf("error encoding span tags: %w", err)
+		}
+	}
+
+	out := data.NewFrame(frame.Name,
+		data.NewField("traceID", nil, traceIDs),
+		data.NewField("spanID", nil, spanIDs),
+		data.NewField("parentSpanID", nil, parents),
+		data.NewField("operationName", nil, operations),
+		data.NewField("serviceName", nil, services),
+		data.NewField("startTime", nil, starts),
+		data.NewField("duration", nil, durations),
+		data.NewField("tags", nil, tags),
+	)
+	meta := data.FrameMeta{}
+	if frame.Meta != nil {
+		meta = *frame.Meta
+	}
+	out.Meta = &meta
+	setFrameType(out, data.FrameTypeUnknown, data.VisTypeTrace)
+	return out, nil
+}
+
+// traceTags returns the tags of the span in row: the keys of the JSON object in
+// the tags column when there is one, otherwise the unmapped columns.
+func traceTags(frame *data.Frame, row int, tagsColumn *data.Field, mapped map[*data.Field]bool) ([]traceTag, error) {
+	tags := []traceTag{}
+	if tagsColumn != nil {
+		v, ok := tagsColumn.ConcreteAt(row)
+		if !ok || stringify(v) == "" {
+			return tags, nil
+		}
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(stringify(v)), &object); err != nil {
+			return nil, fmt.Errorf("trace tags column %q must hold JSON objects: %w", tagsColumn.Name, err)
+		}
+		for key, value := range object {
+			tags = append(tags, traceTag{Key: key, Value: stringify(value)})
+		}
+		// Map order is random; keep spans rendering the same every time
+		sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+		return tags, nil
+	}
+	for _, field := range frame.Fields {
+		if mapped[field] {
+			continue
+		}
+		if v, ok := field.ConcreteAt(row); ok {
+			tags = append(tags, traceTag{Key: field.Name, Value: stringify(v)})
+		}
+	}
+	return tags, nil
+}
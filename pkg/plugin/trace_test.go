@@ -0,0 +1,64 @@
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestToTraceFrame(t *testing.T) {
+	t0 := time.UnixMilli(1700000000000).UTC()
+	frame := data.NewFrame("response",
+		data.NewField("trace_id", nil, []string{"t1", "t1"}),
+		data.NewField("span_id", nil, []string{"a", "b"}),
+		data.NewField("parent_span_id", nil, []string{"", "a"}),
+		data.NewField("operation", nil, []string{"GET /", "SELECT"}),
+		data.NewField("start_time", nil, []time.Time{t0, t0.Add(5 * time.Millisecond)}),
+		data.NewField("duration", nil, []float64{20, 10}),
+		data.NewField("host", nil, []string{"web-1", "db-1"}),
+	)
+
+	trace, err := toTraceFrame(frame, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trace.Meta.PreferredVisualization != data.VisTypeTrace {
+		t.Errorf("expected the trace visualization, got %s", trace.Meta.PreferredVisualization)
+	}
+	for i, name := range []string{"traceID", "spanID", "parentSpanID", "operationName", "serviceName", "startTime", "duration", "tags"} {
+		if trace.Fields[i].Name != name {
+			t.Errorf("field %d: got %s, want %s", i, trace.Fields[i].Name, name)
+		}
+	}
+	if start := trace.Fields[5].At(1); start != 1700000000005.0 {
+		t.Errorf("expected the start in epoch milliseconds, got %v", start)
+	}
+	if tags := string(trace.Fields[7].At(0).(json.RawMessage)); tags != `[{"key":"host","value":"web-1"}]` {
+		t.Errorf("expected unmapped columns as tags, got %s", tags)
+	}
+
+	withTags := data.NewFrame("response",
+		data.NewField("tid", nil, []string{"t1"}),
+		data.NewField("span_id", nil, []string{"a"}),
+		data.NewField("operation", nil, []string{"GET /"}),
+		data.NewField("start_time", nil, []int64{1700000000000}),
+		data.NewField("duration", nil, []int64{20}),
+		data.NewField("attrs", nil, []string{`{"status":200,"method":"GET"}`}),
+	)
+	trace, err = toTraceFrame(withTags, &traceOptions{TraceIDColumn: "tid", TagsColumn: "attrs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags := string(trace.Fields[7].At(0).(json.RawMessage)); tags != `[{"key":"method","value":"GET"},{"key":"status","value":"200"}]` {
+		t.Errorf("unexpected tags %s", tags)
+	}
+
+	if _, err := toTraceFrame(withTags, nil); err == nil {
+		t.Error("expected an error for a missing trace id column")
+	}
+	if _, err := toTraceFrame(frame, &traceOptions{ServiceColumn: "svc"}); err == nil {
+		t.Error("expected an error for a missing configured service column")
+	}
+}
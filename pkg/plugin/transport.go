@@ -0,0 +1,226 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+// QueryTransport executes SQL statements against an Ocient cluster. Both the REST
+// client and the native driver implement it, so macro expansion and frame conversion
+// are shared regardless of how a statement actually reaches Ocient. Tests can supply
+// their own implementation instead of talking to a server.
+type QueryTransport interface {
+	// Execute runs a single SQL statement and returns its decoded result.
+	Execute(ctx context.Context, statement string) (*QueryResult, error)
+	// Close releases any connections held by the transport.
+	Close() error
+}
+
+// Column describes a result column as declared by Ocient.
+type Column struct {
+	Name string `json:"name"`
+	// Type is the SQL type name, e.g. BIGINT, TIMESTAMP or VARCHAR(255). It is
+	// empty when the server did not report column metadata.
+	Type string `json:"type"`
+}
+
+// QueryResult is the transport independent result of a single statement. Rows
+// hold values in the order of Columns. A transport may leave the rows of a
+// response encoded so they can be converted in chunks; call decodeRows before
+// reading Rows directly.
+type QueryResult struct {
+	QueryID string
+	Columns []Column
+	Rows    [][]interface{}
+
+	// encodedRows is the undecoded JSON array of rows, if any
+	encodedRows json.RawMessage
+}
+
+// decodeRows decodes rows that were left encoded by the transport.
+func (r *QueryResult) decodeRows() error {
+	if r.encodedRows == nil {
+		return nil
+	}
+	var rows [][]interface{}
+	if err := decodeJSON(r.encodedRows, &rows); err != nil {
+		return err
+	}
+	r.Rows, r.encodedRows = rows, nil
+	return nil
+}
+
+// streamRows calls fn with consecutive chunks of at most size rows. The chunk
+// slice is reused between calls, so fn must not retain it. Encoded rows are
+// released once streamed.
+func (r *QueryResult) streamRows(size int, fn func(rows [][]interface{})) error {
+	if r.encodedRows == nil {
+		for start := 0; start < len(r.Rows); start += size {
+			end := start + size
+			if end > len(r.Rows) {
+				end = len(r.Rows)
+			}
+			fn(r.Rows[start:end])
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(r.encodedRows))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('[') {
+		return fmt.Errorf("expected an array of rows, got %v", tok)
+	}
+
+	chunk := make([][]interface{}, 0, size)
+	for dec.More() {
+		var row []interface{}
+		if err := dec.Decode(&row); err != nil {
+			return err
+		}
+		chunk = append(chunk, row)
+		if len(chunk) == size {
+			fn(chunk)
+			// Drop the references so decoded rows can be collected right away
+			for i := range chunk {
+				chunk[i] = nil
+			}
+			chunk = chunk[:0]
+		}
+	}
+	if len(chunk) > 0 {
+		fn(chunk)
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	r.encodedRows = nil
+	return nil
+}
+
+// resultFromCollection builds a result from collection formatted rows, where each
+// row is an object keyed by column name and no type information is available.
+// Columns are ordered by name since objects carry no column order.
+func resultFromCollection(queryID string, collection []map[string]interface{}) *QueryResult {
+	result := &QueryResult{QueryID: queryID}
+	seen := make(map[string]bool)
+	for _, row := range collection {
+		for name := range row {
+			if !seen[name] {
+				seen[name] = true
+				result.Columns = append(result.Columns, Column{Name: name})
+			}
+		}
+	}
+	sort.Slice(result.Columns, func(i, j int) bool { return result.Columns[i].Name < result.Columns[j].Name })
+
+	result.Rows = make([][]interface{}, len(collection))
+	for i, row := range collection {
+		values := make([]interface{}, len(result.Columns))
+		for j, col := range result.Columns {
+			values[j] = row[col.Name]
+		}
+		result.Rows[i] = values
+	}
+	return result
+}
+
+// OcientStatus represents the status of an Ocient API response as defined in the OpenAPI spec
+type OcientStatus struct {
+	Reason     string `json:"reason"`
+	SQLState   string `json:"sql_state"`
+	VendorCode int    `json:"vendor_code"`
+}
+
+// StatusError is returned by a transport when Ocient rejects a statement with a
+// non-successful SQL state.
+type StatusError struct {
+	Status OcientStatus
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("query error: %s (SQL state: %s, vendor code: %d)",
+		e.Status.Reason, e.Status.SQLState, e.Status.VendorCode)
+}
+
+// GatewayError is returned by the REST transport when a gateway in front of
+// Ocient answers 502, 503 or 504 instead of Ocient itself.
+type GatewayError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *GatewayError) Error() string {
+	return "gateway error: " + e.Status
+}
+
+// newTransport creates the transport selected by the datasource settings,
+// retrying transient errors, pausing while Ocient is unreachable and wrapped
+// in fault injection when chaos settings are present. REST transports send
+// their requests with client, to the SQL nodes of discovery when not nil,
+// sharing the session tokens of sessions when not nil.
+func newTransport(settings models.PluginSettings, client *http.Client, discovery *endpointDiscovery, sessions *sessionCache) (QueryTransport, error) {
+	var transport QueryTransport
+	switch settings.Transport {
+	case "", models.TransportREST:
+		rest := newRESTTransport(settings, client)
+		rest.discovery = discovery
+		if sessions != nil {
+			rest.sessions = sessions
+		}
+		transport = rest
+	case models.TransportNative:
+		if settings.AuthMethod == models.AuthSession {
+			return nil, fmt.Errorf("session authentication needs the REST transport")
+		}
+		if settings.OAuthPassThru {
+			return nil, fmt.Errorf("forwarding OAuth identity needs the REST transport")
+		}
+		if len(settings.CredentialMappings) > 0 {
+			return nil, fmt.Errorf("credential mappings need the REST transport")
+		}
+		native, err := newNativeTransport(settings)
+		if err != nil {
+			return nil, err
+		}
+		transport = native
+	default:
+		return nil, fmt.Errorf("unknown transport %q", settings.Transport)
+	}
+
+	// Faults are injected where Ocient answers, so that retries and the
+	// circuit breaker deal with them as with real ones
+	if settings.Chaos != nil {
+		backend.Logger.Warn("Chaos mode enabled, injecting faults into queries",
+			"failureRate", settings.Chaos.FailureRate,
+			"malformedRate", settings.Chaos.MalformedRate,
+			"latencyMs", settings.Chaos.LatencyMs)
+		transport = newChaosTransport(transport, *settings.Chaos)
+	}
+
+	if settings.AuditComments {
+		transport = newAuditTransport(transport)
+	}
+
+	if settings.Retry != nil && settings.Retry.MaxAttempts > 1 {
+		transport = newRetryTransport(transport, *settings.Retry)
+	}
+
+	if settings.CircuitBreaker != nil && settings.CircuitBreaker.FailureThreshold > 0 {
+		var vendorCodes []int
+		if settings.Retry != nil {
+			vendorCodes = settings.Retry.VendorCodes
+		}
+		transport = newCircuitTransport(transport, *settings.CircuitBreaker, vendorCodes)
+	}
+
+	return transport, nil
+}
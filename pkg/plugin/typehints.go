@@ -0,0 +1,70 @@
+package plugin
+
+import (
+	"regexp"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// typeHintKey is the custom field config key carrying the Ocient type of fields
+// that Grafana only sees as plain strings, so panels and data links can treat
+// them specially.
+const typeHintKey = "typeHint"
+
+// Type hints set on converted fields.
+const (
+	typeHintUUID = "uuid"
+	typeHintIPv4 = "ipv4"
+	typeHintIPv6 = "ipv6"
+	// typeHintPoint and typeHintGeometry mark geospatial columns, which also
+	// get latitude and longitude fields
+	typeHintPoint    = "point"
+	typeHintGeometry = "geometry"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// columnTypeHint returns the type hint for a result column, taken from its
+// declared type or, for untyped columns, from its first non-null value.
+func columnTypeHint(result *QueryResult, index int) string {
+	if result.Columns[index].Type != "" {
+		switch baseSQLType(result.Columns[index].Type) {
+		case "UUID":
+			return typeHintUUID
+		case "IPV4", "IP":
+			return typeHintIPv4
+		case "IPV6":
+			return typeHintIPv6
+		case "ST_POINT", "POINT":
+			return typeHintPoint
+		}
+		if isGeoSQLType(baseSQLType(result.Columns[index].Type)) {
+			return typeHintGeometry
+		}
+		return ""
+	}
+
+	for _, row := range result.Rows {
+		if index < len(row) && row[index] != nil {
+			if s, ok := row[index].(string); ok && uuidPattern.MatchString(s) {
+				return typeHintUUID
+			}
+			if s, ok := row[index].(string); ok && wktPoint.MatchString(s) {
+				return typeHintPoint
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// setTypeHint records the type hint in the custom config of the field.
+func setTypeHint(field *data.Field, hint string) {
+	if field.Config == nil {
+		field.Config = &data.FieldConfig{}
+	}
+	if field.Config.Custom == nil {
+		field.Config.Custom = make(map[string]interface{})
+	}
+	field.Config.Custom[typeHintKey] = hint
+}
@@ -0,0 +1,134 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// utilityKeywords start statements that describe the database rather than
+// query it. Their results are often text meant for a terminal.
+var utilityKeywords = map[string]bool{"SHOW": true, "DESCRIBE": true, "DESC": true, "EXPLAIN": true}
+
+// keyValueLine matches the "key: value" and "key = value" lines of utility output.
+var keyValueLine = regexp.MustCompile(`^\s*([^:=]*[^:=\s])\s*[:=]\s*(.*?)\s*$`)
+
+// isUtilityStatement reports whether statement is a SHOW, DESCRIBE or EXPLAIN
+// statement. Leading comments are skipped.
+func isUtilityStatement(statement string) bool {
+	for {
+		statement = strings.TrimSpace(statement)
+		switch {
+		case strings.HasPrefix(statement, "--"):
+			end := strings.IndexByte(statement, '\n')
+			if end < 0 {
+				return false
+			}
+			statement = statement[end:]
+		case strings.HasPrefix(statement, "/*"):
+			end := strings.Index(statement, "*/")
+			if end < 0 {
+				return false
+			}
+			statement = statement[end+2:]
+		default:
+			word := statement
+			if end := strings.IndexFunc(statement, func(r rune) bool { return r > 0x7f || !isWordByte(byte(r)) }); end >= 0 {
+				word = statement[:end]
+			}
+			return utilityKeywords[strings.ToUpper(word)]
+		}
+	}
+}
+
+// prettyUtilityResult splits a utility result made of a single multi-line
+// text value, such as the output of SHOW CREATE TABLE, into a row per line.
+// Any other result is returned unchanged.
+func prettyUtilityResult(result *QueryResult) (*QueryResult, error) {
+	if err := result.decodeRows(); err != nil {
+		return nil, err
+	}
+	if len(result.Columns) != 1 || len(result.Rows) != 1 || len(result.Rows[0]) != 1 {
+		return result, nil
+	}
+	text, ok := result.Rows[0][0].(string)
+	if !ok || !strings.Contains(text, "\n") {
+		return result, nil
+	}
+	return linesResult(result.QueryID, result.Columns[0].Name, text), nil
+}
+
+// linesResult builds a result from the lines of text. When every line is a
+// key and value pair they become key and value columns, otherwise there is a
+// column of the given name with one row per line. Blank lines are dropped.
+func linesResult(queryID, name, text string) *QueryResult {
+	var lines []string
+	keyValues := true
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t"))
+		keyValues = keyValues && keyValueLine.MatchString(line)
+	}
+
+	result := &QueryResult{QueryID: queryID}
+	if keyValues && len(lines) > 0 {
+		result.Columns = []Column{{Name: "key", Type: "VARCHAR"}, {Name: "value"}}
+		for _, line := range lines {
+			match := keyValueLine.FindStringSubmatch(line)
+			result.Rows = append(result.Rows, []interface{}{match[1], match[2]})
+		}
+		return result
+	}
+	result.Columns = []Column{{Name: name, Type: "VARCHAR"}}
+	for _, line := range lines {
+		result.Rows = append(result.Rows, []interface{}{line})
+	}
+	return result
+}
+
+// keyValueResult builds a key and value result from a single object, ordered
+// by key.
+func keyValueResult(queryID string, object map[string]interface{}) *QueryResult {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := &QueryResult{QueryID: queryID, Columns: []Column{{Name: "key", Type: "VARCHAR"}, {Name: "value"}}}
+	for _, key := range keys {
+		result.Rows = append(result.Rows, []interface{}{key, object[key]})
+	}
+	return result
+}
+
+// decodeUntabulated decodes response data that is neither in the table nor
+// the collection format, as the API returns for some utility statements: a
+// text blob, an array of lines or a single object.
+func decodeUntabulated(queryID string, data []byte) (*QueryResult, error) {
+	var value interface{}
+	if err := decodeJSON(data, &value); err != nil {
+		return nil, err
+	}
+	switch v := value.(type) {
+	case string:
+		return linesResult(queryID, "line", v), nil
+	case map[string]interface{}:
+		return keyValueResult(queryID, v), nil
+	case []interface{}:
+		lines := make([]string, len(v))
+		for i, item := range v {
+			line, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected %T in an array of lines", item)
+			}
+			lines[i] = line
+		}
+		return linesResult(queryID, "line", strings.Join(lines, "\n")), nil
+	default:
+		return nil, fmt.Errorf("unexpected result data of type %T", value)
+	}
+}
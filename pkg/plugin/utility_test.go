@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestIsUtilityStatement(t *testing.T) {
+	for statement, want := range map[string]bool{
+		"SHOW TABLES":                 true,
+		"  describe sys.tables":       true,
+		"-- schema\nEXPLAIN SELECT 1": true,
+		"/* x */ SHOW CREATE TABLE t": true,
+		"SELECT * FROM show_tables":   false,
+		"SHOWCASE":                    false,
+		"-- SHOW TABLES":              false,
+	} {
+		if got := isUtilityStatement(statement); got != want {
+			t.Errorf("isUtilityStatement(%q) = %v, want %v", statement, got, want)
+		}
+	}
+}
+
+func TestPrettyUtilityResult(t *testing.T) {
+	result, err := prettyUtilityResult(&QueryResult{
+		Columns: []Column{{Name: "ddl"}},
+		Rows:    [][]interface{}{{"CREATE TABLE t (\n  id INT,\n\n  name VARCHAR\n)"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Columns) != 1 || result.Columns[0].Name != "ddl" || len(result.Rows) != 4 || result.Rows[1][0] != "  id INT," {
+		t.Errorf("expected a row per line, got %+v %+v", result.Columns, result.Rows)
+	}
+
+	single := &QueryResult{Columns: []Column{{Name: "count"}}, Rows: [][]interface{}{{json.Number("3")}}}
+	if result, _ := prettyUtilityResult(single); result != single {
+		t.Error("expected other results unchanged")
+	}
+}
+
+func TestDecodeTableDataUtilityShapes(t *testing.T) {
+	tests := []struct {
+		data    string
+		columns []string
+		rows    int
+	}{
+		{`"Version: 24.0\nNodes = 3"`, []string{"key", "value"}, 2},
+		{`["sys", "public"]`, []string{"line"}, 2},
+		{`{"version": "24.0", "nodes": 3}`, []string{"key", "value"}, 2},
+	}
+	for _, tt := range tests {
+		result, err := decodeTableData(TableResponse{QueryID: "q", Data: []byte(tt.data)})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.data, err)
+		}
+		if len(result.Columns) != len(tt.columns) || len(result.Rows) != tt.rows {
+			t.Errorf("%s: unexpected result %+v %+v", tt.data, result.Columns, result.Rows)
+			continue
+		}
+		for i, name := range tt.columns {
+			if result.Columns[i].Name != name {
+				t.Errorf("%s: column %d is %s, want %s", tt.data, i, result.Columns[i].Name, name)
+			}
+		}
+	}
+
+	if _, err := decodeTableData(TableResponse{Data: []byte(`[1, 2]`)}); err == nil {
+		t.Error("expected an error for an array of numbers")
+	}
+}
@@ -0,0 +1,141 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	// currentUserStatement names the Ocient user statements are sent as.
+	currentUserStatement = "SELECT CURRENT_USER"
+	// roleGrantsStatement lists which users and roles were granted which
+	// roles.
+	roleGrantsStatement = "SELECT grantee, role_name FROM information_schema.applicable_roles"
+)
+
+// tablePrivilegesStatement lists the tables the grantees, or everyone, were
+// granted SELECT on.
+func tablePrivilegesStatement(grantees []string) string {
+	literals := make([]string, len(grantees), len(grantees)+1)
+	for i, grantee := range grantees {
+		literals[i] = stringLiteral(grantee)
+	}
+	literals = append(literals, "'PUBLIC'")
+	return "SELECT table_schema, table_name FROM information_schema.table_privileges WHERE privilege_type = 'SELECT' AND grantee IN (" +
+		strings.Join(literals, ", ") + ")"
+}
+
+// userGrantees returns user and the roles granted to it, directly or through
+// other roles, as the catalog lists privileges granted to roles with the role
+// as the grantee.
+func userGrantees(user string, grants [][]interface{}) []string {
+	roles := make(map[string][]string)
+	for _, row := range grants {
+		grantee := strings.ToLower(rowString(row, 0))
+		roles[grantee] = append(roles[grantee], rowString(row, 1))
+	}
+	grantees := []string{user}
+	seen := map[string]bool{strings.ToLower(user): true}
+	for i := 0; i < len(grantees); i++ {
+		for _, role := range roles[strings.ToLower(grantees[i])] {
+			if !seen[strings.ToLower(role)] {
+				seen[strings.ToLower(role)] = true
+				grantees = append(grantees, role)
+			}
+		}
+	}
+	return grantees
+}
+
+// tableVisibility tells whether a table, named schema.table, is visible to
+// the user the metadata is listed for. A nil visibility shows every table.
+type tableVisibility map[string]bool
+
+func (v tableVisibility) visible(qualified string) bool {
+	return v == nil || v[strings.ToLower(qualified)]
+}
+
+// metadataUser tells whether the queries of a resource call run as another
+// Ocient user than the datasource's: the user of the credential mapping of
+// the signed-in Grafana user, or the user whose OAuth identity is forwarded.
+// Metadata is then filtered to what that user can read, so that the editor
+// doesn't suggest tables its queries fail on. The user is empty when only
+// Ocient knows it, as for forwarded identities.
+func (d *Datasource) metadataUser(r *http.Request) (string, bool) {
+	if forwardedToken(r.Context()) != "" {
+		return "", true
+	}
+	mapping, ok := mappedCredentials(r.Context())
+	return mapping.Username, ok
+}
+
+// userTableVisibility returns the tables the user the statements of ctx are
+// sent as can read, from the privilege catalog and cached like other catalog
+// listings. An empty user is asked of Ocient. It is nil unless filter is set.
+func (d *Datasource) userTableVisibility(ctx context.Context, transport QueryTransport, environment, user string, filter bool) (tableVisibility, error) {
+	if !filter {
+		return nil, nil
+	}
+	key := environment + "\x00privileges\x00" + cacheScope(ctx) + user
+	var tables []string
+	var ok bool
+	if d.catalogCache != nil {
+		tables, _, ok = d.catalogCache.get(key)
+	}
+	if !ok {
+		var err error
+		if tables, err = readableTables(ctx, transport, user); err != nil {
+			return nil, err
+		}
+		if d.catalogCache != nil {
+			d.catalogCache.set(key, tables)
+		}
+	}
+	visibility := make(tableVisibility, len(tables))
+	for _, table := range tables {
+		visibility[table] = true
+	}
+	return visibility, nil
+}
+
+// readableTables lists the tables, as lower case schema.table, that user or
+// its roles were granted SELECT on.
+func readableTables(ctx context.Context, transport QueryTransport, user string) ([]string, error) {
+	rows := func(statement string) ([][]interface{}, error) {
+		result, err := transport.Execute(ctx, statement)
+		if err == nil {
+			err = result.decodeRows()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return result.Rows, nil
+	}
+	if user == "" {
+		current, err := rows(currentUserStatement)
+		if err != nil {
+			return nil, err
+		}
+		if len(current) > 0 {
+			user = rowString(current[0], 0)
+		}
+		if user == "" {
+			return nil, errors.New("the current user is unknown")
+		}
+	}
+	grants, err := rows(roleGrantsStatement)
+	if err != nil {
+		return nil, err
+	}
+	privileges, err := rows(tablePrivilegesStatement(userGrantees(user, grants)))
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]string, 0, len(privileges))
+	for _, row := range privileges {
+		tables = append(tables, strings.ToLower(rowString(row, 0)+"."+rowString(row, 1)))
+	}
+	return tables, nil
+}
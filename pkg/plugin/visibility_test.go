@@ -0,0 +1,85 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUserTableVisibility(t *testing.T) {
+	transport := &statementTransport{results: map[string]*QueryResult{
+		roleGrantsStatement: {
+			Columns: []Column{{Name: "grantee"}, {Name: "role_name"}},
+			Rows:    [][]interface{}{{"O'Brien", "analyst"}, {"analyst", "reader"}, {"reader", "analyst"}, {"someone", "admin"}},
+		},
+		"SELECT table_schema, table_name FROM information_schema.table_privileges": {
+			Columns: []Column{{Name: "table_schema"}, {Name: "table_name"}},
+			Rows:    [][]interface{}{{"web", "Events"}, {"sales", "orders"}},
+		},
+	}}
+	ds := &Datasource{catalogCache: newTTLCache[[]string](catalogCacheTTL, catalogCacheEntries)}
+
+	if visibility, err := ds.userTableVisibility(context.Background(), transport, "", "", false); err != nil || visibility != nil {
+		t.Fatalf("expected no filtering for the datasource user, got %v, %v", visibility, err)
+	}
+
+	visibility, err := ds.userTableVisibility(context.Background(), transport, "", "o'brien", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Roles granted through other roles count too, however they cycle
+	if len(transport.statements) != 2 || !strings.Contains(transport.statements[1], "grantee IN ('o''brien', 'analyst', 'reader', 'PUBLIC')") {
+		t.Errorf("unexpected statements %q", transport.statements)
+	}
+	if !visibility.visible("web.events") || !visibility.visible("SALES.orders") || visibility.visible("web.hosts") {
+		t.Errorf("unexpected visibility %v", visibility)
+	}
+
+	// The privileges are cached like other catalog listings
+	if _, err := ds.userTableVisibility(context.Background(), transport, "", "o'brien", true); err != nil || len(transport.statements) != 2 {
+		t.Errorf("expected the privileges from the cache, ran %d statements, %v", len(transport.statements), err)
+	}
+}
+
+func TestUserTableVisibilityThroughRole(t *testing.T) {
+	transport := &statementTransport{results: map[string]*QueryResult{
+		currentUserStatement: {Columns: []Column{{Name: "current_user"}}, Rows: [][]interface{}{{"alice"}}},
+		roleGrantsStatement: {
+			Columns: []Column{{Name: "grantee"}, {Name: "role_name"}},
+			Rows:    [][]interface{}{{"alice", "finance"}},
+		},
+	}}
+	// The table is granted to the role only, never to the user
+	grants := &QueryResult{Columns: []Column{{Name: "table_schema"}, {Name: "table_name"}}, Rows: [][]interface{}{{"sales", "orders"}}}
+	transport.results[tablePrivilegesStatement([]string{"alice", "finance"})] = grants
+	ds := &Datasource{}
+
+	// Forwarded identities are filtered too, as the user Ocient names
+	visibility, err := ds.userTableVisibility(context.Background(), transport, "", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !visibility.visible("sales.orders") {
+		t.Errorf("expected the table granted through a role to be visible, got %v from %q", visibility, transport.statements)
+	}
+}
+
+func TestWriteMetadataPageFiltered(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeMetadataPage(rec, []string{"events", "hosts", "metrics"}, 2, func(name string) string { return name },
+		func(name string) bool { return name != "events" })
+
+	var page struct {
+		Items      []string `json:"items"`
+		NextCursor string   `json:"nextCursor"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	// The next page still starts after the last table listed, visible or not
+	if strings.Join(page.Items, ",") != "hosts" || page.NextCursor == "" {
+		t.Errorf("unexpected page %+v", page)
+	}
+}
@@ -0,0 +1,45 @@
+package plugin
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// warmUpTimeout bounds the warm-up of a datasource instance.
+const warmUpTimeout = 30 * time.Second
+
+// startWarmUp opens a connection to Ocient, authenticates and lists the
+// schemas and the tables of the default schema into the catalog cache in the
+// background, so that the first dashboard after a settings change doesn't pay
+// for all of it. Dispose cancels the warm-up and waits for it.
+func (d *Datasource) startWarmUp() {
+	ctx, cancel := context.WithTimeout(context.Background(), warmUpTimeout)
+	done := make(chan struct{})
+	d.stopWarmUp = func() {
+		cancel()
+		<-done
+	}
+	go func() {
+		defer close(done)
+		defer cancel()
+		d.warmUp(ctx)
+	}()
+}
+
+// warmUp runs the statements of the warm-up, stopping at the first failure.
+func (d *Datasource) warmUp(ctx context.Context) {
+	start := time.Now()
+	if _, err := d.transport.Execute(ctx, "SELECT 1"); err != nil {
+		backend.Logger.Warn("Warm-up failed to reach Ocient", "error", err.Error())
+		return
+	}
+	for _, q := range []catalogQuery{{Function: catalogSchemas}, {Function: catalogTables}} {
+		if res := d.catalogResponse(ctx, queryModel{}, q); res.Error != nil {
+			backend.Logger.Warn("Warm-up failed to list the catalog", "function", q.Function, "error", res.Error.Error())
+			return
+		}
+	}
+	backend.Logger.Info("Warm-up finished", "duration", time.Since(start))
+}
@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ocient/ocient-datasource/pkg/models"
+)
+
+func TestWarmUp(t *testing.T) {
+	transport := &statementTransport{results: map[string]*QueryResult{
+		"SELECT 1": {Columns: []Column{{Name: "1", Type: "INT"}}, Rows: [][]interface{}{{float64(1)}}},
+		"SELECT DISTINCT table_schema": {
+			Columns: []Column{{Name: "table_schema", Type: "VARCHAR"}},
+			Rows:    [][]interface{}{{"sales"}},
+		},
+		"SELECT table_name": {
+			Columns: []Column{{Name: "table_name", Type: "VARCHAR"}},
+			Rows:    [][]interface{}{{"orders"}},
+		},
+	}}
+	ds := &Datasource{
+		settings:     models.PluginSettings{DefaultSchema: "sales"},
+		transport:    transport,
+		catalogCache: newTTLCache[[]string](catalogCacheTTL, catalogCacheEntries),
+	}
+	ds.startWarmUp()
+	ds.stopWarmUp()
+
+	if len(transport.statements) != 3 || transport.statements[0] != "SELECT 1" {
+		t.Fatalf("unexpected statements %q", transport.statements)
+	}
+	statement, _ := catalogQuery{Function: catalogTables}.catalogStatement("sales")
+	if names, _, ok := ds.catalogCache.get("\x00\x00" + statement); !ok || len(names) != 1 || names[0] != "orders" {
+		t.Errorf("expected the tables to be cached, got %q", names)
+	}
+}
+
+func TestWarmUpDispose(t *testing.T) {
+	ds := &Datasource{transport: hangingTransport{}}
+	ds.startWarmUp()
+
+	done := make(chan struct{})
+	go func() {
+		ds.Dispose()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Dispose did not cancel the warm-up")
+	}
+}
@@ -0,0 +1,136 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// toWideFrame converts a long time series frame, with one row per time and
+// label combination, into a wide frame with one field per value column and
+// label combination. Label columns are converted to strings; when none are
+// given every string and boolean column is a label. Gaps are filled with nulls.
+func toWideFrame(frame *data.Frame, labelColumns []string) (*data.Frame, error) {
+	long, err := toLongFrame(frame, labelColumns)
+	if err != nil || long.Rows() == 0 {
+		return long, err
+	}
+
+	if long.TimeSeriesSchema().Type != data.TimeSeriesTypeLong {
+		// Without labels the frame already holds one series per value column
+		return long, nil
+	}
+	wide, err := data.LongToWide(long, &data.FillMissing{Mode: data.FillModeNull})
+	if err != nil {
+		return nil, fmt.Errorf("error converting results to time series: %w", err)
+	}
+	return wide, nil
+}
+
+// toLongFrame returns a time series frame in the long shape, ordered by time
+// with the label columns as strings.
+func toLongFrame(frame *data.Frame, labelColumns []string) (*data.Frame, error) {
+	if frame.Rows() == 0 {
+		return frame, nil
+	}
+
+	timeIndex := -1
+	for i, field := range frame.Fields {
+		if field.Type().Time() {
+			timeIndex = i
+			break
+		}
+	}
+	if timeIndex < 0 {
+		return nil, fmt.Errorf("a time column is required to convert results to time series")
+	}
+
+	long := frame
+	if len(labelColumns) > 0 {
+		var err error
+		if long, err = withLabelColumns(frame, labelColumns, timeIndex); err != nil {
+			return nil, err
+		}
+	}
+	return sortByTime(long, timeIndex), nil
+}
+
+// withLabelColumns returns a copy of frame in which exactly the label columns
+// are string fields, so that they alone become labels.
+func withLabelColumns(frame *data.Frame, labelColumns []string, timeIndex int) (*data.Frame, error) {
+	labels := make(map[string]bool, len(labelColumns))
+	for _, name := range labelColumns {
+		labels[name] = true
+	}
+
+	out := data.NewFrame(frame.Name)
+	out.Meta = frame.Meta
+	found := 0
+	for i, field := range frame.Fields {
+		switch {
+		case labels[field.Name]:
+			found++
+			values := make([]string, field.Len())
+			for row := range values {
+				if v, ok := field.ConcreteAt(row); ok {
+					values[row] = stringify(v)
+				}
+			}
+			out.Fields = append(out.Fields, data.NewField(field.Name, field.Labels, values))
+		case i != timeIndex && (field.Type() == data.FieldTypeString || field.Type() == data.FieldTypeNullableString ||
+			field.Type() == data.FieldTypeBool || field.Type() == data.FieldTypeNullableBool):
+			return nil, fmt.Errorf("column %q is not numeric; add it to the label columns or leave it out of the query", field.Name)
+		default:
+			out.Fields = append(out.Fields, field)
+		}
+	}
+	if found != len(labels) {
+		return nil, fmt.Errorf("label columns %v are not all in the result", labelColumns)
+	}
+	return out, nil
+}
+
+// sortByTime returns frame ordered by ascending time, as LongToWide requires.
+// Rows with equal times keep their order.
+func sortByTime(frame *data.Frame, timeIndex int) *data.Frame {
+	rows := frame.Rows()
+	timeAt := func(row int) int64 {
+		if v, ok := frame.Fields[timeIndex].ConcreteAt(row); ok {
+			return v.(time.Time).UnixNano()
+		}
+		return 0
+	}
+
+	order := make([]int, rows)
+	sorted := true
+	for i := range order {
+		order[i] = i
+		if i > 0 && timeAt(i) < timeAt(i-1) {
+			sorted = false
+		}
+	}
+	if sorted {
+		return frame
+	}
+	sort.SliceStable(order, func(a, b int) bool { return timeAt(order[a]) < timeAt(order[b]) })
+
+	return selectRows(frame, order)
+}
+
+// selectRows returns a frame with the given rows of frame, in that order. The
+// metadata is shared with frame.
+func selectRows(frame *data.Frame, rows []int) *data.Frame {
+	out := data.NewFrame(frame.Name)
+	out.Meta = frame.Meta
+	for _, field := range frame.Fields {
+		copied := data.NewFieldFromFieldType(field.Type(), len(rows))
+		copied.Name, copied.Labels, copied.Config = field.Name, field.Labels, field.Config
+		for row, from := range rows {
+			copied.Set(row, field.CopyAt(from))
+		}
+		out.Fields = append(out.Fields, copied)
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestToWideFrame(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Minute)
+	long := data.NewFrame("response",
+		data.NewField("time", nil, []time.Time{t1, t0, t0}),
+		data.NewField("host", nil, []string{"a", "a", "b"}),
+		data.NewField("node_id", nil, []int64{1, 1, 2}),
+		data.NewField("value", nil, []float64{2, 1, 3}),
+	)
+
+	wide, err := toWideFrame(long, []string{"host", "node_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wide.Rows() != 2 || len(wide.Fields) != 3 {
+		t.Fatalf("expected 2 times and 2 series, got %d rows and %d fields", wide.Rows(), len(wide.Fields))
+	}
+	a, b := wide.Fields[1], wide.Fields[2]
+	if a.Labels["host"] != "a" || a.Labels["node_id"] != "1" || b.Labels["host"] != "b" {
+		t.Errorf("unexpected labels %v and %v", a.Labels, b.Labels)
+	}
+	if v, _ := a.ConcreteAt(1); v != float64(2) {
+		t.Errorf("expected the rows sorted by time, got %v", v)
+	}
+	if _, ok := b.ConcreteAt(1); ok {
+		t.Error("expected a null where a series has no value")
+	}
+
+	if _, err := toWideFrame(long, []string{"node_id"}); err == nil {
+		t.Error("expected an error for a string column that is not a label")
+	}
+}
@@ -0,0 +1,43 @@
+import React, { useState } from 'react';
+import { Alert, Button } from '@grafana/ui';
+import { getBackendSrv } from '@grafana/runtime';
+import { ClusterTopology } from '../types';
+
+interface Props {
+  datasourceUid: string;
+}
+
+// Lists the nodes of the connected cluster with their roles and versions
+export function ClusterTopologyView({ datasourceUid }: Props) {
+  const [topology, setTopology] = useState<ClusterTopology>();
+  const [loading, setLoading] = useState(false);
+
+  const onShow = async () => {
+    setLoading(true);
+    try {
+      setTopology(await getBackendSrv().get<ClusterTopology>(`/api/datasources/uid/${datasourceUid}/resources/cluster/topology`));
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <>
+      <Button variant="secondary" onClick={onShow} disabled={loading}>
+        {loading ? 'Loading...' : 'Show cluster'}
+      </Button>
+      {topology?.warnings?.map((warning) => (
+        <Alert key={warning} title={warning} severity="warning" />
+      ))}
+      {topology && (
+        <ul>
+          {topology.nodes.map((node) => (
+            <li key={node.name + node.address}>
+              <strong>{node.name}</strong> {node.address} {node.roles.join(', ')} {node.version} {node.status}
+            </li>
+          ))}
+        </ul>
+      )}
+    </>
+  );
+}
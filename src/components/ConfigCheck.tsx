@@ -0,0 +1,37 @@
+import React, { useEffect, useState } from 'react';
+import { Alert } from '@grafana/ui';
+import { getBackendSrv } from '@grafana/runtime';
+import { ConfigReport } from '../types';
+
+interface Props {
+  datasourceUid: string;
+}
+
+// Shows how well the saved settings follow best practices, with hints for what to improve
+export function ConfigCheck({ datasourceUid }: Props) {
+  const [report, setReport] = useState<ConfigReport>();
+
+  useEffect(() => {
+    getBackendSrv()
+      .get<ConfigReport>(`/api/datasources/uid/${datasourceUid}/resources/config-check`)
+      .then(setReport)
+      .catch((error) => console.error('Failed to load the configuration check', error));
+  }, [datasourceUid]);
+
+  const issues = report?.checks.filter((check) => check.status !== 'pass') ?? [];
+  if (!report || issues.length === 0) {
+    return null;
+  }
+
+  return (
+    <Alert title={`Configuration score: ${report.score}/100`} severity={report.score < 50 ? 'warning' : 'info'}>
+      <ul>
+        {issues.map((check) => (
+          <li key={check.id}>
+            <strong>{check.title}:</strong> {check.hint}
+          </li>
+        ))}
+      </ul>
+    </Alert>
+  );
+}
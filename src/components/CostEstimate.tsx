@@ -0,0 +1,65 @@
+import React, { useState } from 'react';
+import { Alert, Button } from '@grafana/ui';
+import { TimeRange } from '@grafana/data';
+import { getBackendSrv } from '@grafana/runtime';
+import { DataSource } from '../datasource';
+import { CostEstimate as Estimate, MyQuery } from '../types';
+
+interface Props {
+  datasource: DataSource;
+  query: MyQuery;
+  range?: TimeRange;
+}
+
+const severities: Record<Estimate['costClass'], 'info' | 'success' | 'warning' | 'error'> = {
+  unknown: 'info',
+  low: 'success',
+  medium: 'info',
+  high: 'warning',
+  'very high': 'error',
+};
+
+// Estimates what the query in the editor will scan, from its EXPLAIN plan, without running it
+export function CostEstimate({ datasource, query, range }: Props) {
+  const [estimate, setEstimate] = useState<Estimate | null>(null);
+  const [error, setError] = useState<string | null>(null);
+  const [running, setRunning] = useState(false);
+
+  const onEstimate = async () => {
+    setRunning(true);
+    setError(null);
+    try {
+      const interpolated = datasource.applyTemplateVariables(query, {});
+      setEstimate(
+        await getBackendSrv().post<Estimate>(`/api/datasources/uid/${datasource.uid}/resources/estimate`, {
+          ...interpolated,
+          from: range?.from.toISOString(),
+          to: range?.to.toISOString(),
+        })
+      );
+    } catch (err: any) {
+      setEstimate(null);
+      setError(err?.data?.message || String(err));
+    } finally {
+      setRunning(false);
+    }
+  };
+
+  return (
+    <>
+      <Button variant="secondary" size="sm" onClick={onEstimate} disabled={running || !query.queryText}>
+        {running ? 'Estimating...' : 'Estimate cost'}
+      </Button>
+      {estimate && (
+        <Alert title={`Estimated cost: ${estimate.costClass}`} severity={severities[estimate.costClass]} onRemove={() => setEstimate(null)}>
+          {estimate.summary}
+        </Alert>
+      )}
+      {error && (
+        <Alert title="Estimate failed" severity="error" onRemove={() => setError(null)}>
+          {error}
+        </Alert>
+      )}
+    </>
+  );
+}
@@ -0,0 +1,47 @@
+import React, { useEffect, useState } from 'react';
+import { Button, InlineField, InlineFieldRow } from '@grafana/ui';
+import { getBackendSrv } from '@grafana/runtime';
+import { BundledDashboard } from '../types';
+
+interface Props {
+  datasourceUid: string;
+}
+
+// Lists the dashboards bundled with the backend and imports them, bound to this datasource
+export function DashboardImport({ datasourceUid }: Props) {
+  const [dashboards, setDashboards] = useState<BundledDashboard[]>([]);
+  const [imported, setImported] = useState<Record<string, boolean>>({});
+  const resources = `/api/datasources/uid/${datasourceUid}/resources`;
+
+  useEffect(() => {
+    getBackendSrv()
+      .get<BundledDashboard[]>(`${resources}/dashboards`)
+      .then(setDashboards)
+      .catch((error) => console.error('Failed to load bundled dashboards', error));
+  }, [resources]);
+
+  const onImport = async (dashboard: BundledDashboard) => {
+    const payload = await getBackendSrv().get(`${resources}/dashboards/${dashboard.id}`);
+    await getBackendSrv().post('/api/dashboards/db', payload);
+    setImported({ ...imported, [dashboard.id]: true });
+  };
+
+  if (dashboards.length === 0) {
+    return null;
+  }
+
+  return (
+    <>
+      <h3 className="page-heading">Dashboards</h3>
+      {dashboards.map((dashboard) => (
+        <InlineFieldRow key={dashboard.id}>
+          <InlineField label={dashboard.title} labelWidth={30} tooltip={dashboard.description}>
+            <Button variant="secondary" size="sm" onClick={() => onImport(dashboard)}>
+              {imported[dashboard.id] ? 'Re-import' : 'Import'}
+            </Button>
+          </InlineField>
+        </InlineFieldRow>
+      ))}
+    </>
+  );
+}
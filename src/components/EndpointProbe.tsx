@@ -0,0 +1,46 @@
+import React, { useState } from 'react';
+import { Button } from '@grafana/ui';
+import { getBackendSrv } from '@grafana/runtime';
+import { ProbeResult } from '../types';
+
+interface Props {
+  datasourceUid: string;
+}
+
+// Runs the DNS, TCP, TLS and auth probe of every endpoint and shows the stage timings
+export function EndpointProbe({ datasourceUid }: Props) {
+  const [results, setResults] = useState<ProbeResult[]>([]);
+  const [running, setRunning] = useState(false);
+
+  const onProbe = async () => {
+    setRunning(true);
+    try {
+      setResults(await getBackendSrv().get<ProbeResult[]>(`/api/datasources/uid/${datasourceUid}/resources/probe`));
+    } finally {
+      setRunning(false);
+    }
+  };
+
+  return (
+    <>
+      <Button variant="secondary" onClick={onProbe} disabled={running}>
+        {running ? 'Probing...' : 'Probe endpoints'}
+      </Button>
+      {results.map((result) => (
+        <div key={result.endpoint}>
+          <strong>{result.endpoint}</strong>
+          <ul>
+            {result.stages.map((stage) => (
+              <li key={stage.name}>
+                {stage.name}:{' '}
+                {stage.skipped
+                  ? 'skipped'
+                  : `${stage.ok ? 'ok' : 'failed'} in ${stage.durationMs.toFixed(1)} ms ${stage.error || stage.detail || ''}`}
+              </li>
+            ))}
+          </ul>
+        </div>
+      ))}
+    </>
+  );
+}
@@ -1,4 +1,4 @@
-import React, { ChangeEvent, useEffect, useState, useCallback } from 'react';
+import React, { ChangeEvent, KeyboardEvent, useEffect, useState, useCallback } from 'react';
 import { 
   InlineField, 
   TextArea, 
@@ -18,10 +18,12 @@ import {
 import { QueryEditorProps, SelectableValue } from '@grafana/data';
 import { DataSource } from '../datasource';
 import { MyDataSourceOptions, MyQuery, ColumnInfo, SelectedColumn, WhereClause } from '../types';
+import { quoteIdentifier, quoteTable } from '../sql';
+import { CostEstimate } from './CostEstimate';
 
 type Props = QueryEditorProps<DataSource, MyQuery, MyDataSourceOptions>;
 
-export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props) {
+export function QueryEditor({ query, onChange, onRunQuery, datasource, range }: Props) {
   // Log when the component renders
   console.log('=== QueryEditor RENDERING ===');
   console.log('Props:', { query, onChange: !!onChange, onRunQuery: !!onRunQuery, datasource: !!datasource });
@@ -72,12 +74,12 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
     }
   }, [datasource, setIsLoading, setError, setSchemas]);
 
-  // Load tables for a selected schema
-  const loadTables = useCallback(async (schema: string) => {
+  // Load tables for a selected schema, the first page of those matching search
+  const loadTables = useCallback(async (schema: string, search = '') => {
     setIsLoading(true);
     setError(null);
     try {
-      const tableList = await datasource.getTables(schema);
+      const tableList = await datasource.getTables(schema, search);
       setTables(tableList.map(t => ({ label: t, value: t })));
     } catch (err) {
       console.error('Error loading tables:', err);
@@ -130,7 +132,17 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
 
   // Handle changes to the query text
   const onQueryTextChange = (event: ChangeEvent<HTMLTextAreaElement>) => {
-    onChange({ ...query, queryText: event.target.value });
+    onChange({ ...query, queryText: event.target.value, selection: undefined });
+  };
+
+  // Ctrl/Cmd+Enter runs only the selected statement, or the one at the cursor
+  const onQueryTextKeyDown = (event: KeyboardEvent<HTMLTextAreaElement>) => {
+    if (event.key === 'Enter' && (event.ctrlKey || event.metaKey)) {
+      event.preventDefault();
+      const { selectionStart, selectionEnd } = event.currentTarget;
+      onChange({ ...query, selection: { from: selectionStart, to: selectionEnd } });
+      onRunQuery();
+    }
   };
 
   // Handle changes to the schema selection
@@ -256,7 +268,7 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
     });
   };
   
-  const onUpdateWhereClause = (index: number, field: keyof WhereClause, value: string) => {
+  const onUpdateWhereClause = (index: number, field: keyof WhereClause, value: string | boolean) => {
     const updatedClauses = whereClauses.map((clause, i) => {
       if (i === index) {
         return { ...clause, [field]: value };
@@ -407,27 +419,38 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
   // Build SQL query from schema, table, columns, and where clauses
   const buildQuery = () => {
     if (query.schema && query.table) {
+      // Tables with a configured time column are filtered by it unless another one is picked
+      const configuredTimeColumn = datasource.defaultTimeColumn(query.schema, query.table);
+      const timeColumn =
+        timeseriesColumn ||
+        (configuredTimeColumn && columns.some((c) => c.column_name.toLowerCase() === configuredTimeColumn.toLowerCase())
+          ? configuredTimeColumn
+          : undefined);
+
       // Get columns string
       let columnsStr = '*';
       if (selectedColumns.length > 0) {
-        columnsStr = selectedColumns.map(c => c.name).join(', ');
+        columnsStr = selectedColumns.map(c => quoteIdentifier(c.name)).join(', ');
       }
       
       // Start building the SQL
-      let sql = `SELECT ${columnsStr} FROM ${query.schema}.${query.table}`;
+      let sql = `SELECT ${columnsStr} FROM ${quoteTable(query.schema, query.table)}`;
       
       // Add WHERE clauses
-      if (whereClauses.length > 0 || timeseriesColumn) {
+      if (whereClauses.length > 0 || timeColumn) {
         sql += ' WHERE ';
         
         // Add regular WHERE clauses
-        const whereClauseStrings = whereClauses.map(clause => 
-          `${clause.column} ${clause.operator} '${clause.value}'`
+        const whereClauseStrings = whereClauses.map(clause =>
+          clause.ignoreCase
+            ? `LOWER(${quoteIdentifier(clause.column)}) ${clause.operator} LOWER('${clause.value}')`
+            : `${quoteIdentifier(clause.column)} ${clause.operator} '${clause.value}'`
         );
         
         // Add time range filter for timeseries column if specified
-        if (timeseriesColumn) {
-          const timeFilter = `${timeseriesColumn} >= $__timeFrom() AND ${timeseriesColumn} <= $__timeTo() ORDER BY ${timeseriesColumn} ASC`;
+        if (timeColumn) {
+          const timeCol = quoteIdentifier(timeColumn);
+          const timeFilter = `${timeCol} >= $__timeFrom() AND ${timeCol} <= $__timeTo() ORDER BY ${timeCol} ASC`;
           whereClauseStrings.push(timeFilter);
         }
         
@@ -469,18 +492,22 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
       )}
 
       {rawQuery ? (
-        <InlineField label="SQL Query" grow tooltip="Enter SQL query to execute against Ocient">
-          <TextArea
-            id="query-editor-query-text"
-            rows={5}
-            className="gf-form-input"
-            onChange={onQueryTextChange}
-            value={query.queryText || ''}
-            required
-            placeholder="SELECT * FROM my_table LIMIT 100"
-            onBlur={onRunQueryClick}
-          />
-        </InlineField>
+        <>
+          <InlineField label="SQL Query" grow tooltip="Enter SQL query to execute against Ocient">
+            <TextArea
+              id="query-editor-query-text"
+              rows={5}
+              className="gf-form-input"
+              onChange={onQueryTextChange}
+              onKeyDown={onQueryTextKeyDown}
+              value={query.queryText || ''}
+              required
+              placeholder="SELECT * FROM my_table LIMIT 100"
+              onBlur={onRunQueryClick}
+            />
+          </InlineField>
+          <CostEstimate datasource={datasource} query={query} range={range} />
+        </>
       ) : (
         <>
           <InlineFieldRow>
@@ -501,8 +528,14 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
             <InlineField label="Table" tooltip="Select a table from the schema">
               <Select
                 options={tables}
-                value={tables.find(t => t.value === query.table)}
+                value={query.table ? { label: query.table, value: query.table } : null}
                 onChange={onTableChange}
+                onInputChange={(search, { action }) => {
+                  // Large schemas are searched by the backend, a page at a time
+                  if (query.schema && action === 'input-change') {
+                    loadTables(query.schema, search);
+                  }
+                }}
                 isLoading={isLoading}
                 placeholder={isLoading ? "Loading tables..." : "Type space to see options"}
                 width={30}
@@ -618,6 +651,14 @@ export function QueryEditor({ query, onChange, onRunQuery, datasource }: Props)
                   placeholder="Value"
                 />
                 
+                {/* Case-insensitive comparison */}
+                <InlineField label="Ignore case" tooltip="Compare the lowercased column and value" style={{ marginLeft: '4px' }}>
+                  <Checkbox
+                    value={clause.ignoreCase || false}
+                    onChange={e => onUpdateWhereClause(index, 'ignoreCase', e.currentTarget.checked)}
+                  />
+                </InlineField>
+                
                 {/* Values button - only show if column is selected */}
                 {clause.column && (
                   <Button
@@ -1,62 +1,154 @@
-import { DataSourceInstanceSettings, CoreApp, ScopedVars } from '@grafana/data';
+import {
+  AdHocVariableFilter,
+  DataQueryRequest,
+  DataSourceInstanceSettings,
+  CoreApp,
+  ScopedVars,
+  DataQueryResponse,
+  DataSourceWithLogsContextSupport,
+  LogRowContextOptions,
+  LogRowContextQueryDirection,
+  LogRowModel,
+  MetricFindValue,
+} from '@grafana/data';
 import { DataSourceWithBackend, getTemplateSrv } from '@grafana/runtime';
-import { firstValueFrom } from 'rxjs';
+import { Observable, firstValueFrom } from 'rxjs';
 
-import { MyQuery, MyDataSourceOptions, DEFAULT_QUERY, ColumnInfo } from './types';
+import { MyQuery, MyDataSourceOptions, DEFAULT_QUERY, ColumnInfo, MetadataPage, GeometryRequest, GeometryCollection } from './types';
+import { quoteIdentifier, quoteTable } from './sql';
+
+export class DataSource
+  extends DataSourceWithBackend<MyQuery, MyDataSourceOptions>
+  implements DataSourceWithLogsContextSupport<MyQuery>
+{
+  private defaultSchema?: string;
+  private timeColumns: Record<string, string>;
+  private cacheTtlSeconds?: number;
 
-export class DataSource extends DataSourceWithBackend<MyQuery, MyDataSourceOptions> {
   constructor(instanceSettings: DataSourceInstanceSettings<MyDataSourceOptions>) {
     super(instanceSettings);
+    this.defaultSchema = instanceSettings.jsonData.defaultSchema;
+    this.cacheTtlSeconds = instanceSettings.jsonData.cacheTtlSeconds;
+    this.timeColumns = {};
+    for (const [table, column] of Object.entries(instanceSettings.jsonData.timeColumns || {})) {
+      this.timeColumns[table.toLowerCase()] = column;
+    }
+  }
+
+  /**
+   * Returns the time column configured for a table in the timeColumns setting,
+   * by its qualified name or else its name alone, as the backend matches it.
+   */
+  defaultTimeColumn(schema: string, table: string): string | undefined {
+    return this.timeColumns[`${schema}.${table}`.toLowerCase()] ?? this.timeColumns[table.toLowerCase()];
+  }
+
+  /**
+   * Asks Grafana's query caching to keep responses for the cacheTtlSeconds
+   * setting, unless the panel sets a cache TTL of its own.
+   */
+  query(request: DataQueryRequest<MyQuery>): Observable<DataQueryResponse> {
+    if (this.cacheTtlSeconds && !request.queryCachingTTL) {
+      request = { ...request, queryCachingTTL: this.cacheTtlSeconds * 1000 };
+    }
+    return super.query(request);
   }
 
   getDefaultQuery(_: CoreApp): Partial<MyQuery> {
     return DEFAULT_QUERY;
   }
 
-  applyTemplateVariables(query: MyQuery, scopedVars: ScopedVars) {
+  applyTemplateVariables(query: MyQuery, scopedVars: ScopedVars, filters?: AdHocVariableFilter[]) {
     let queryText = query.queryText || '';
     
     // Replace template variables
     queryText = getTemplateSrv().replace(queryText, scopedVars);
     
-    // Replace Grafana time macros with actual timestamps
-    queryText = queryText.replace(/\$__timeFrom\(\)/g, `'${this.getTimeFromValue(scopedVars)}'`);
-    queryText = queryText.replace(/\$__timeTo\(\)/g, `'${this.getTimeToValue(scopedVars)}'`);
+    // Time macros are expanded by the backend in the session timezone
     
     return {
       ...query,
       queryText,
+      environment: query.environment ? getTemplateSrv().replace(query.environment, scopedVars) : undefined,
+      topK: query.topK
+        ? {
+            ...query.topK,
+            table: getTemplateSrv().replace(query.topK.table, scopedVars),
+            column: getTemplateSrv().replace(query.topK.column, scopedVars),
+          }
+        : undefined,
+      // Applied by the backend where the query uses $__adhocFilters()
+      adhocFilters: filters?.length
+        ? filters.map((f) => ({ key: f.key, operator: f.operator, value: f.value, values: f.values }))
+        : undefined,
     };
   }
-  
-  private getTimeFromValue(scopedVars: ScopedVars): string {
-    // Get the timeFrom value in the format Ocient expects (YYYY-MM-DD HH:MM:SS)
-    return this.formatOcientTimestamp(getTemplateSrv().replace('${__from:date:iso}', scopedVars));
+
+  /**
+   * Resolves template variable queries to the values of their first column.
+   * Besides SQL, the backend answers databases(), schemas(database) and
+   * tables(schema) from its metadata cache.
+   */
+  async metricFindQuery(query: string, options?: any): Promise<MetricFindValue[]> {
+    const response = await firstValueFrom(this.query({
+      targets: [{ refId: 'variable', queryText: query } as MyQuery],
+      range: options?.range,
+      scopedVars: options?.scopedVars || {},
+    } as any));
+
+    if (response?.data && response.data.length > 0 && response.data[0].fields.length > 0) {
+      return response.data[0].fields[0].values.toArray().map((value: any) => ({ text: String(value) }));
+    }
+    return [];
   }
-  
-  private getTimeToValue(scopedVars: ScopedVars): string {
-    // Get the timeTo value in the format Ocient expects (YYYY-MM-DD HH:MM:SS)
-    return this.formatOcientTimestamp(getTemplateSrv().replace('${__to:date:iso}', scopedVars));
+
+  /**
+   * Offers the columns of the default schema, or of every schema, as ad-hoc
+   * filter keys
+   */
+  async getTagKeys(): Promise<MetricFindValue[]> {
+    const schemaFilter = this.defaultSchema ? ` WHERE table_schema = '${this.defaultSchema.replace(/'/g, "''")}'` : '';
+    const response = await firstValueFrom(this.query({
+      targets: [{
+        refId: 'tag_keys',
+        queryText: `SELECT DISTINCT column_name FROM information_schema.columns${schemaFilter} ORDER BY column_name`,
+      } as MyQuery],
+    } as any));
+
+    if (response?.data && response.data.length > 0 && response.data[0].fields.length > 0) {
+      return response.data[0].fields[0].values.toArray().map((name: string) => ({ text: name }));
+    }
+    return [];
   }
   
-  private formatOcientTimestamp(isoTimestamp: string): string {
-    // Convert from ISO format to Ocient's preferred format
-    const date = new Date(isoTimestamp);
-    
-    const year = date.getUTCFullYear();
-    const month = String(date.getUTCMonth() + 1).padStart(2, '0');
-    const day = String(date.getUTCDate()).padStart(2, '0');
-    const hours = String(date.getUTCHours()).padStart(2, '0');
-    const minutes = String(date.getUTCMinutes()).padStart(2, '0');
-    const seconds = String(date.getUTCSeconds()).padStart(2, '0');
-    
-    // Format: YYYY-MM-DD HH:MM:SS
-    return `${year}-${month}-${day} ${hours}:${minutes}:${seconds}`;
-  }
-
   filterQuery(query: MyQuery): boolean {
     // if no query has been provided, prevent the query from being executed
-    return !!query.queryText;
+    return !!query.queryText || !!(query.topK?.table && query.topK?.column);
+  }
+
+  /**
+   * Fetches the log lines before or after a line for "show context". The
+   * backend wraps the query into a bounded one around the line's timestamp.
+   */
+  async getLogRowContext(row: LogRowModel, options?: LogRowContextOptions, query?: MyQuery): Promise<DataQueryResponse> {
+    if (!query) {
+      return { data: [] };
+    }
+    // Keep the nanoseconds of the line, which Date drops
+    const iso = new Date(row.timeEpochMs).toISOString();
+    const time = row.timeEpochNs ? iso.replace('Z', row.timeEpochNs.slice(-6).padStart(6, '0') + 'Z') : iso;
+    const target: MyQuery = {
+      ...query,
+      refId: `log-context-${query.refId}`,
+      logContext: {
+        time,
+        direction: options?.direction === LogRowContextQueryDirection.Forward ? 'forward' : 'backward',
+        limit: options?.limit,
+        timeColumn: row.dataFrame.meta?.custom?.timeColumn,
+        labels: row.labels,
+      },
+    };
+    return firstValueFrom(this.query({ targets: [target] } as any));
   }
 
   /**
@@ -125,73 +217,53 @@ export class DataSource extends DataSourceWithBackend<MyQuery, MyDataSourceOptio
   }
 
   /**
-   * Fetches the list of tables for a given schema
+   * Fetches the first page of the tables of a schema whose names contain
+   * search. Large catalogs are searched by the backend rather than listed.
    */
-  async getTables(schema: string): Promise<string[]> {
+  async getTables(schema: string, search = ''): Promise<string[]> {
     if (!schema) {
       return [];
     }
-
-    const query = {
-      refId: 'tables',
-      queryText: `SELECT DISTINCT(table_name) FROM information_schema.tables WHERE table_schema = '${schema}' ORDER BY table_name`,
-    };
-
-    const response = await firstValueFrom(this.query({
-      targets: [query as MyQuery],
-    } as any));
-
-    if (response?.data && response.data.length > 0) {
-      // Extract the table names from the first column of each row
-      return response.data[0].fields[0].values.toArray();
-    }
-
-    return [];
+    // Pages filtered to the tables the user can read may come back empty
+    let page: MetadataPage<string> = { items: [], nextCursor: '' };
+    do {
+      page = await this.getResource('tables', { schema, search, cursor: page.nextCursor });
+    } while (page.items.length === 0 && page.nextCursor);
+    return page.items;
   }
 
   /**
-   * Fetches the column information for a given schema and table
+   * Fetches the column information for a given schema and table, a page at
+   * a time
    */
   async getColumns(schema: string, table: string): Promise<ColumnInfo[]> {
     if (!schema || !table) {
       return [];
     }
 
-    const query = {
-      refId: 'columns',
-      queryText: `SELECT column_name, data_type, is_nullable, column_default FROM information_schema.columns WHERE table_schema = '${schema}' AND table_name = '${table}' ORDER BY column_name`,
-    };
-
-    const response = await firstValueFrom(this.query({
-      targets: [query as MyQuery],
-    } as any));
-
-    if (response?.data && response.data.length > 0) {
-      // Transform the data frame into ColumnInfo objects
-      const frame = response.data[0];
-      const result: ColumnInfo[] = [];
-
-      const nameField = frame.fields.find((f: any) => f.name === 'column_name');
-      const typeField = frame.fields.find((f: any) => f.name === 'data_type');
-      const nullableField = frame.fields.find((f: any) => f.name === 'is_nullable');
-      const defaultField = frame.fields.find((f: any) => f.name === 'column_default');
-
-      if (nameField && typeField && nullableField && defaultField) {
-        const length = nameField.values.length;
-        for (let i = 0; i < length; i++) {
-          result.push({
-            column_name: nameField.values.get(i),
-            data_type: typeField.values.get(i),
-            is_nullable: nullableField.values.get(i),
-            column_default: defaultField.values.get(i),
-          });
-        }
-      }
-
-      return result;
-    }
+    const result: ColumnInfo[] = [];
+    let cursor = '';
+    do {
+      const page: MetadataPage<ColumnInfo> = await this.getResource('columns', { schema, table, cursor });
+      result.push(...page.items);
+      cursor = page.nextCursor;
+    } while (cursor);
+    return result;
+  }
 
-    return [];
+  /**
+   * Fetches the geometries of a table within a bounding box as GeoJSON,
+   * counted in grid cells or simplified for the zoom they are shown at
+   */
+  async getGeometries(req: GeometryRequest): Promise<GeometryCollection> {
+    return this.getResource('geometries', {
+      table: req.table,
+      column: req.column,
+      bbox: req.bbox.join(','),
+      zoom: req.zoom,
+      properties: req.properties?.join(',') ?? '',
+      environment: req.environment ?? '',
+    });
   }
 
   /**
@@ -203,9 +275,10 @@ export class DataSource extends DataSourceWithBackend<MyQuery, MyDataSourceOptio
       return 0;
     }
 
+    const col = quoteIdentifier(column);
     const query = {
       refId: 'distinct_count',
-      queryText: `SELECT COUNT(DISTINCT ${column}) AS value_count FROM ${schema}.${table} WHERE ${column} IS NOT NULL`,
+      queryText: `SELECT COUNT(DISTINCT ${col}) AS value_count FROM ${quoteTable(schema, table)} WHERE ${col} IS NOT NULL`,
     };
 
     try {
@@ -253,16 +326,17 @@ export class DataSource extends DataSourceWithBackend<MyQuery, MyDataSourceOptio
     const totalCount = await this.getDistinctValueCount(schema, table, column);
     
     // Build the query with optional filtering
-    let queryText = `SELECT DISTINCT ${column} 
-                     FROM ${schema}.${table} 
-                     WHERE ${column} IS NOT NULL`;
+    const col = quoteIdentifier(column);
+    let queryText = `SELECT DISTINCT ${col} 
+                     FROM ${quoteTable(schema, table)} 
+                     WHERE ${col} IS NOT NULL`;
       
     if (searchPattern) {
       // Add case-insensitive search filter if provided
-      queryText += ` AND LOWER(CAST(${column} AS VARCHAR)) LIKE LOWER('%${searchPattern}%')`;
+      queryText += ` AND LOWER(CAST(${col} AS VARCHAR)) LIKE LOWER('%${searchPattern}%')`;
     }
       
-    queryText += ` ORDER BY ${column} ASC 
+    queryText += ` ORDER BY ${col} ASC 
                   LIMIT ${limit} OFFSET ${offset}`;
 
     const query = {
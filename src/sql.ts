@@ -0,0 +1,30 @@
+// Keywords that can't be used as bare identifiers, kept in sync with pkg/plugin/sql.go
+const RESERVED_WORDS = new Set(
+  `ALL ALTER AND ANY AS ASC BETWEEN BY CASE CAST CHECK COLUMN CONSTRAINT CREATE CROSS
+  CURRENT_DATE CURRENT_TIME CURRENT_TIMESTAMP DATE DEFAULT DELETE DESC DISTINCT DROP
+  ELSE END EXCEPT EXISTS FALSE FETCH FOR FOREIGN FROM FULL GRANT GROUP HAVING IN INNER
+  INSERT INTERSECT INTERVAL INTO IS JOIN KEY LEFT LIKE LIMIT NATURAL NOT NULL OFFSET ON
+  OR ORDER OUTER OVER PARTITION PRIMARY REFERENCES REVOKE RIGHT ROWS SELECT SET SOME
+  TABLE THEN TIME TIMESTAMP TO TRUE UNION UNIQUE UPDATE USER USING VALUES VIEW WHEN
+  WHERE WINDOW WITH`.split(/\s+/)
+);
+
+const SIMPLE_IDENTIFIER = /^[A-Za-z_][A-Za-z0-9_]*$/;
+
+/**
+ * Returns name as a SQL identifier, double quoted only when it is a reserved word
+ * or contains characters that aren't valid in a bare identifier.
+ */
+export function quoteIdentifier(name: string): string {
+  if (SIMPLE_IDENTIFIER.test(name) && !RESERVED_WORDS.has(name.toUpperCase())) {
+    return name;
+  }
+  return `"${name.replace(/"/g, '""')}"`;
+}
+
+/**
+ * Quotes a table reference made of a schema and a table name.
+ */
+export function quoteTable(schema: string, table: string): string {
+  return `${quoteIdentifier(schema)}.${quoteIdentifier(table)}`;
+}
@@ -9,6 +9,118 @@ export interface MyQuery extends DataQuery {
   selectedColumns?: SelectedColumn[];
   whereClauses?: WhereClause[];
   timeseriesColumn?: string; // Name of the column to use for time series data
+  arrayMode?: 'json' | 'explode'; // How array columns are returned, defaults to JSON values
+  keepStructsAsJson?: boolean; // Return tuple columns as JSON strings instead of dotted fields
+  distinct?: boolean; // Remove exact duplicate rows from the result
+  numericIps?: boolean; // Add numeric companion fields for IPV4/IPV6 columns
+  binaryFormat?: 'hex' | 'base64' | 'length'; // How binary columns are rendered, defaults to hex
+  extract?: ExtractionRule[]; // Add fields for the named groups of patterns matched against string columns
+  jsonColumn?: JsonColumnOptions; // Split a column of JSON strings into a field per key
+  strict?: boolean; // Fail the query on values that can't be converted to their column type
+  nullPolicy?: NullPolicy; // Overrides the datasource null policy for this query
+  probeEmptyRange?: boolean; // On an empty result, tell when the table only has data outside the time range
+  retryEmpty?: boolean; // Run read-only statements again, briefly, while they return no rows
+  errorFrame?: boolean; // Answer errors with a one-row frame (time, message, sqlstate) instead of a panel error
+  noData?: 'empty' | 'zero'; // Answer no rows with an empty frame (default) or a zero point at the end of the range
+  options?: Record<string, boolean | number | string>; // Backend feature flags of the query, such as profiling
+  fieldOptions?: Record<string, FieldOptions>; // Unit, display name and decimals of columns by name
+  fields?: string[]; // Columns displayed by the panel, used to narrow SELECT * queries
+  timezone?: string; // Overrides the datasource session timezone for this query
+  environment?: string; // Named environment to query, may be a dashboard variable; empty or 'default' is the default cluster
+  selection?: TextRange; // Run only the selected statement, or the one at the cursor
+  format?: 'table' | 'time_series' | 'time_series_long' | 'logs' | 'heatmap' | 'trace'; // Shape of the returned frames, defaults to table
+  heatmap?: HeatmapOptions; // Bucket columns for the heatmap format
+  topK?: TopKOptions; // Count the most frequent values of a column instead of running the query text
+  logs?: LogsOptions; // Body and level columns for the logs format
+  trace?: TraceOptions; // Span columns for the trace format
+  longToWide?: boolean; // Convert long results into one time series per label combination
+  splitBy?: string; // Return one frame per distinct value of this column, labeled with the value
+  alias?: string; // Name of the frames of the query, defaults to its RefID
+  queryTimeoutSeconds?: number; // Cancel the query after this long instead of the datasource timeout
+  labelColumns?: string[]; // Label columns for longToWide, defaults to every string column
+  timeColumn?: string; // Column used as the time axis; other untyped columns are never guessed to be times
+  timeColumnUnit?: 's' | 'ms' | 'us' | 'ns'; // Unit of numeric time values since the epoch, defaults to ms
+  logContext?: LogContextOptions; // Set by "show context" to fetch the lines around a log line
+  adhocFilters?: AdHocFilter[]; // Filters of the dashboard's ad-hoc variables, applied by $__adhocFilters()
+}
+
+// zero returns 0, '' or false, null returns nulls and skip leaves the row out
+export type NullPolicy = 'zero' | 'null' | 'skip';
+
+export interface FieldOptions {
+  unit?: string; // A Grafana unit id such as ms, bytes or percent
+  displayName?: string;
+  decimals?: number;
+  lookup?: string; // SELECT returning values of the column and their text, applied as value mappings
+  links?: FieldLink[]; // Data links of the field
+}
+
+export interface FieldLink {
+  title?: string;
+  url: string; // A Grafana link template, such as https://admin.example.com/queries/${__value.raw}
+  targetBlank?: boolean;
+}
+
+export interface ExtractionRule {
+  column: string;
+  pattern: string; // Go regular expression with named groups, such as status=(?P<status>\d+)
+}
+
+export interface JsonColumnOptions {
+  column: string;
+  keys?: string[]; // Dotted key paths to extract, defaults to every leaf
+}
+
+export interface TextRange {
+  from: number; // Offsets into queryText, equal for a bare cursor
+  to: number;
+}
+
+export interface TopKOptions {
+  table: string;
+  column: string;
+  k?: number; // Number of categories, defaults to 10
+  timeColumn?: string; // Limits the rows counted to the dashboard time range
+}
+
+export interface HeatmapOptions {
+  lowColumn?: string; // Defaults to bucket_low
+  highColumn?: string; // Defaults to bucket_high
+  countColumn?: string; // Defaults to count
+}
+
+export interface LogsOptions {
+  bodyColumn?: string; // Defaults to a column named body, or the first string column
+  levelColumn?: string; // Defaults to a column named level, if any
+  levels?: Record<string, string>; // Grafana log levels of level values, merged over the logLevels setting
+  contextLabels?: string[]; // Label columns the context lines of a line share with it, such as host
+}
+
+// Operators are =, !=, <, <=, >, >=, =~ and !~ (LIKE patterns), =| and !=| (one of values)
+export interface AdHocFilter {
+  key: string;
+  operator: string;
+  value: string;
+  values?: string[];
+}
+
+export interface LogContextOptions {
+  time: string; // RFC 3339 timestamp of the line, with nanoseconds
+  direction?: 'backward' | 'forward';
+  limit?: number; // Defaults to 50, at most 1000
+  timeColumn?: string; // Column of the line's timestamp, defaults to timeColumn
+  labels?: Record<string, string>; // Labels of the line
+}
+
+export interface TraceOptions {
+  traceIdColumn?: string; // Defaults to trace_id
+  spanIdColumn?: string; // Defaults to span_id
+  parentColumn?: string; // Defaults to parent_span_id, if any
+  operationColumn?: string; // Defaults to operation
+  serviceColumn?: string; // Defaults to service, if any
+  startColumn?: string; // Timestamp or epoch milliseconds, defaults to start_time
+  durationColumn?: string; // Milliseconds, defaults to duration
+  tagsColumn?: string; // JSON object of tags, defaults to tags; otherwise unmapped columns become tags
 }
 
 export interface SelectedColumn {
@@ -20,6 +132,7 @@ export interface WhereClause {
   column: string;
   operator: string;
   value: string;
+  ignoreCase?: boolean; // Compare lowercased column and value
 }
 
 export const DEFAULT_QUERY: Partial<MyQuery> = {
@@ -35,6 +148,34 @@ export interface ColumnInfo {
   column_default: string | null;
 }
 
+// Discovery of the SQL nodes, from either srv or url. refreshSeconds defaults to 300; below 0 resolves once
+export interface DiscoveryOptions {
+  srv?: string; // SRV record name, e.g. _ocient._tcp.example.com
+  url?: string; // URL answering a JSON array of host or host:port strings
+  refreshSeconds?: number;
+}
+
+// A page of the /tables or /columns resource; nextCursor is empty on the last page
+export interface MetadataPage<T> {
+  items: T[];
+  nextCursor: string;
+}
+
+export interface GeometryRequest {
+  table: string;
+  column: string;
+  bbox: [number, number, number, number]; // minLon, minLat, maxLon, maxLat
+  zoom: number;
+  properties?: string[]; // Columns carried by simplified features
+  environment?: string;
+}
+
+export interface GeometryCollection {
+  type: 'FeatureCollection';
+  features: Array<{ type: 'Feature'; geometry: { type: string; coordinates: unknown } | null; properties: Record<string, unknown> }>;
+  truncated: boolean; // More features intersect the box than were returned
+}
+
 export interface DataPoint {
   Time: number;
   Value: number;
@@ -49,9 +190,168 @@ export interface DataSourceResponse {
  */
 export interface MyDataSourceOptions extends DataSourceJsonData {
   host?: string;
+  hosts?: string[]; // SQL nodes tried in turn when the host can't be reached, as host or host:port
+  loadBalancing?: 'failover' | 'round_robin' | 'least_outstanding'; // How statements are spread over the host and hosts, defaults to failover
+  discovery?: DiscoveryOptions; // Resolve the SQL nodes from DNS SRV records or a discovery URL instead of host and hosts
+  configuredSecrets?: string[]; // Secure fields saved by the config editor, to report secrets Grafana can't decrypt
   port?: number;
   database?: string;
+  defaultSchema?: string; // Qualify tables named without a schema with this one
   insecureSkipVerify?: boolean;
+  scheme?: 'https' | 'http'; // Plain HTTP is for development clusters without TLS, defaults to https
+  ignoreProxyEnvironment?: boolean; // Connect directly even when HTTP_PROXY/HTTPS_PROXY name a proxy
+  enableSecureSocksProxy?: boolean; // Reach the cluster through Grafana's secure socks proxy (Private Data source Connect)
+  insecureSkipVerifyPolicy?: 'warn' | 'block'; // Warn on every query (default) or refuse queries while TLS verification is skipped
+  tlsServerName?: string; // Name the server certificate is verified against, instead of the host
+  transport?: 'rest'; // How the backend talks to Ocient; builds linking the Ocient database/sql driver also accept 'native'
+  authMethod?: 'basic' | 'session'; // Send credentials with every statement (default) or a token of a session opened with them
+  maxColumns?: number; // Reject results wider than this, defaults to 1000
+  maxRows?: number; // Truncate interactive query results to this many rows, 0 means no limit
+  queryTimeoutSeconds?: number; // Cancel interactive queries after this long, defaults to 30
+  cacheTtlSeconds?: number; // Default TTL of Grafana's query caching for panels without one, unset keeps Grafana's
+  maxIdleConns?: number; // Idle connections kept open across all hosts, defaults to 100
+  maxIdleConnsPerHost?: number; // Idle connections kept open per host, defaults to 16
+  maxConnsPerHost?: number; // Limit on connections per host, 0 means no limit
+  idleConnTimeoutSeconds?: number; // Close idle connections after this long, defaults to 90
+  compressRequestsOverBytes?: number; // Gzip request payloads larger than this, off by default
+  timezone?: string; // IANA name of the session timezone, defaults to UTC
+  timestampFormats?: string[]; // Extra Go time layouts tried when parsing timestamps
+  nullPolicy?: NullPolicy; // What null and invalid values become, defaults to zero
+  nonFiniteNumbers?: 'keep' | 'null'; // Return NaN and Infinity as they are (default) or as nulls
+  logLevels?: Record<string, string>; // Grafana log levels of the level values of logs queries, such as {"3": "error"}
+  timeColumns?: Record<string, string>; // Time column of tables, such as {"app.events": "ts"}, for the time axis, time macros and the builder
+  minIntervals?: Record<string, string>; // Smallest $__timeGroup interval of tables, such as {"app.metrics": "5m"}
+  healthCheckObjects?: string[]; // Tables (schema.table) and schemas that Save & test checks the user can read
+  warmUp?: boolean; // Connect, authenticate and list the catalog in the background when the settings are loaded
+  devFakeServer?: boolean; // Serve queries from the built-in fake Ocient API (development only)
+  retry?: RetrySettings; // Retries of read-only statements failing with a transient error
+  circuitBreaker?: CircuitBreakerSettings; // Pause queries while Ocient is unreachable
+  chaos?: ChaosSettings; // Fault injection for resilience testing, not shown in the config editor
+  publicDashboards?: PublicDashboardSettings; // Allow restricted, read-only queries from public dashboards
+  reporting?: ReportingSettings; // Higher limits for PDF reports and CSV exports
+  environments?: EnvironmentSettings[]; // Other clusters, such as dev or stage, that queries can select
+  credentialMappings?: CredentialMapping[]; // Ocient credentials of Grafana users, first match wins
+  auditComments?: boolean; // Tag statements with the Grafana user, org and dashboard they run for, off by default
+}
+
+// Credentials are the credential.<name>.username and credential.<name>.password
+// secure fields
+export interface CredentialMapping {
+  name: string;
+  users?: string[]; // Logins or emails, matched case-insensitively
+  roles?: string[]; // Organization roles, such as Viewer or Editor
+}
+
+// Credentials are the environment.<name>.username and environment.<name>.password
+// secure fields; anything left unset is taken from the default cluster
+export interface EnvironmentSettings {
+  name: string;
+  host?: string;
+  port?: number;
+  database?: string;
+}
+
+export interface ReportingSettings {
+  header?: string; // Request header marking report requests, defaults to X-Grafana-Reporting
+  maxRows?: number; // Defaults to 1000000
+  queryTimeoutSeconds?: number; // Defaults to 300
+}
+
+export interface PublicDashboardSettings {
+  maxRows?: number; // Defaults to 10000
+}
+
+export interface RetrySettings {
+  maxAttempts?: number; // Defaults to 3, 1 disables retries
+  initialBackoffMs?: number; // Defaults to 200, doubled after every attempt
+  maxBackoffMs?: number; // Defaults to 2000
+  vendorCodes?: number[]; // Ocient vendor codes retried besides connection errors
+}
+
+export interface CircuitBreakerSettings {
+  failureThreshold?: number; // Transient failures in a row that pause queries, defaults to 5, below 0 disables
+  cooldownSeconds?: number; // Defaults to 30
+}
+
+export interface ChaosSettings {
+  failureRate?: number;
+  malformedRate?: number;
+  latencyMs?: number;
+  latencyJitterMs?: number;
+  seed?: number;
+}
+
+// A dashboard bundled with the backend, listed by the /dashboards resource
+export interface BundledDashboard {
+  id: string;
+  uid: string;
+  title: string;
+  description: string;
+  tags: string[];
+}
+
+// The /config-check report of how the settings follow best practices
+export interface ConfigReport {
+  score: number; // 0 to 100
+  checks: ConfigCheckResult[];
+}
+
+export interface ConfigCheckResult {
+  id: string;
+  title: string;
+  status: 'pass' | 'warn' | 'fail';
+  weight: number;
+  hint?: string;
+}
+
+// The /estimate result, read from the EXPLAIN plan of the query
+export interface CostEstimate {
+  statement: string;
+  rows?: number;
+  bytes?: number;
+  costClass: 'unknown' | 'low' | 'medium' | 'high' | 'very high';
+  summary: string; // Such as "This will scan ~4.2 TB"
+}
+
+// A query in flight, as listed by the /activity resource
+export interface ActivityEntry {
+  refId: string;
+  mode: 'interactive' | 'reporting' | 'public';
+  state: 'queued' | 'running' | 'streaming';
+  sqlHash?: string; // Set once the statement is sent to Ocient
+  startedAt: string;
+  elapsedMs: number;
+}
+
+// The /probe result for one endpoint; stages after a failed one are skipped
+export interface ProbeResult {
+  endpoint: string;
+  ok: boolean;
+  stages: ProbeStage[];
+}
+
+export interface ProbeStage {
+  name: 'dns' | 'tcp' | 'tls' | 'auth';
+  ok: boolean;
+  skipped?: boolean;
+  durationMs: number;
+  detail?: string;
+  error?: string;
+}
+
+// The /cluster/topology result; warnings flag version skew between nodes and with the plugin
+export interface ClusterTopology {
+  nodes: ClusterNode[];
+  versions: string[];
+  warnings?: string[];
+}
+
+export interface ClusterNode {
+  name: string;
+  address?: string;
+  roles: string[];
+  version?: string;
+  status?: string;
 }
 
 // Default values for datasource configuration
@@ -66,4 +366,9 @@ export const DEFAULT_CONFIG: Partial<MyDataSourceOptions> = {
 export interface MySecureJsonData {
   username?: string;
   password?: string;
+  publicUsername?: string; // Restricted credentials for public dashboard queries
+  publicPassword?: string;
+  tlsCACert?: string; // PEM bundle of the CA certificates trusted to sign the Ocient certificate
+  tlsClientCert?: string; // PEM client certificate presented for mutual TLS
+  tlsClientKey?: string; // PEM private key of the client certificate
 }